@@ -58,6 +58,8 @@ func (src *AzureMachine) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.AdditionalCapabilities = restored.Spec.AdditionalCapabilities
 	}
 
+	restoreDataDisksPerformance(dst.Spec.DataDisks, restored.Spec.DataDisks)
+
 	dst.Spec.SubnetName = restored.Spec.SubnetName
 
 	dst.Status.LongRunningOperationStates = restored.Status.LongRunningOperationStates
@@ -172,3 +174,19 @@ func Convert_v1alpha3_AzureMarketplaceImage_To_v1beta1_AzureMarketplaceImage(in
 func Convert_v1beta1_Image_To_v1alpha3_Image(in *v1beta1.Image, out *Image, s apiconversion.Scope) error {
 	return autoConvert_v1beta1_Image_To_v1alpha3_Image(in, out, s)
 }
+
+// restoreDataDisksPerformance restores the performance settings of the managed data disks, which don't exist in this
+// version, from the matching data disks of the restored Hub version.
+func restoreDataDisksPerformance(dst, restored []v1beta1.DataDisk) {
+	for i := range dst {
+		if dst[i].ManagedDisk == nil {
+			continue
+		}
+		for _, disk := range restored {
+			if disk.NameSuffix == dst[i].NameSuffix && disk.ManagedDisk != nil {
+				dst[i].ManagedDisk.DiskIOPSReadWrite = disk.ManagedDisk.DiskIOPSReadWrite
+				dst[i].ManagedDisk.DiskMBpsReadWrite = disk.ManagedDisk.DiskMBpsReadWrite
+			}
+		}
+	}
+}
@@ -44,6 +44,8 @@ func (src *AzureMachine) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.AdditionalCapabilities = restored.Spec.AdditionalCapabilities
 	}
 
+	restoreDataDisksPerformance(dst.Spec.DataDisks, restored.Spec.DataDisks)
+
 	return nil
 }
 
@@ -94,3 +96,24 @@ func Convert_v1beta1_Image_To_v1alpha4_Image(in *v1beta1.Image, out *Image, s ap
 func Convert_v1beta1_AzureMachineSpec_To_v1alpha4_AzureMachineSpec(in *v1beta1.AzureMachineSpec, out *AzureMachineSpec, s apiconversion.Scope) error {
 	return autoConvert_v1beta1_AzureMachineSpec_To_v1alpha4_AzureMachineSpec(in, out, s)
 }
+
+// Convert_v1beta1_ManagedDiskParameters_To_v1alpha4_ManagedDiskParameters converts from the Hub version (v1beta1) of the ManagedDiskParameters to this version.
+func Convert_v1beta1_ManagedDiskParameters_To_v1alpha4_ManagedDiskParameters(in *v1beta1.ManagedDiskParameters, out *ManagedDiskParameters, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_ManagedDiskParameters_To_v1alpha4_ManagedDiskParameters(in, out, s)
+}
+
+// restoreDataDisksPerformance restores the performance settings of the managed data disks, which don't exist in this
+// version, from the matching data disks of the restored Hub version.
+func restoreDataDisksPerformance(dst, restored []v1beta1.DataDisk) {
+	for i := range dst {
+		if dst[i].ManagedDisk == nil {
+			continue
+		}
+		for _, disk := range restored {
+			if disk.NameSuffix == dst[i].NameSuffix && disk.ManagedDisk != nil {
+				dst[i].ManagedDisk.DiskIOPSReadWrite = disk.ManagedDisk.DiskIOPSReadWrite
+				dst[i].ManagedDisk.DiskMBpsReadWrite = disk.ManagedDisk.DiskMBpsReadWrite
+			}
+		}
+	}
+}
@@ -44,6 +44,8 @@ func (src *AzureMachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.Template.Spec.AdditionalCapabilities = restored.Spec.Template.Spec.AdditionalCapabilities
 	}
 
+	restoreDataDisksPerformance(dst.Spec.Template.Spec.DataDisks, restored.Spec.Template.Spec.DataDisks)
+
 	dst.Spec.Template.ObjectMeta = restored.Spec.Template.ObjectMeta
 
 	return nil
@@ -111,7 +111,7 @@ func validateNetworkSpec(networkSpec NetworkSpec, old NetworkSpec, fldPath *fiel
 	// that she intends to use a pre-existing vnet. In this case,
 	// we need to verify the information she provides
 	if networkSpec.Vnet.ResourceGroup != "" {
-		if err := validateResourceGroup(networkSpec.Vnet.ResourceGroup,
+		if err := ValidateResourceGroup(networkSpec.Vnet.ResourceGroup,
 			fldPath.Child("vnet").Child("resourceGroup")); err != nil {
 			allErrs = append(allErrs, err)
 		}
@@ -154,8 +154,8 @@ func validateNetworkSpec(networkSpec NetworkSpec, old NetworkSpec, fldPath *fiel
 	return allErrs
 }
 
-// validateResourceGroup validates a ResourceGroup.
-func validateResourceGroup(resourceGroup string, fldPath *field.Path) *field.Error {
+// ValidateResourceGroup validates a ResourceGroup.
+func ValidateResourceGroup(resourceGroup string, fldPath *field.Path) *field.Error {
 	if success, _ := regexp.MatchString(resourceGroupRegex, resourceGroup); !success {
 		return field.Invalid(fldPath, resourceGroup,
 			fmt.Sprintf("resourceGroup doesn't match regex %s", resourceGroupRegex))
@@ -342,7 +342,7 @@ func TestResourceGroupValid(t *testing.T) {
 	}
 
 	t.Run(testCase.name, func(t *testing.T) {
-		err := validateResourceGroup(testCase.resourceGroup,
+		err := ValidateResourceGroup(testCase.resourceGroup,
 			field.NewPath("spec").Child("networkSpec").Child("vnet").Child("resourceGroup"))
 		g.Expect(err).To(BeNil())
 	})
@@ -362,7 +362,7 @@ func TestResourceGroupInvalid(t *testing.T) {
 	}
 
 	t.Run(testCase.name, func(t *testing.T) {
-		err := validateResourceGroup(testCase.resourceGroup,
+		err := ValidateResourceGroup(testCase.resourceGroup,
 			field.NewPath("spec").Child("networkSpec").Child("vnet").Child("resourceGroup"))
 		g.Expect(err).NotTo(BeNil())
 		g.Expect(err.Type).To(Equal(field.ErrorTypeInvalid))
@@ -17,9 +17,19 @@ limitations under the License.
 package v1beta1
 
 import (
+	"regexp"
+	"strings"
+
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
+var (
+	// communityGalleryImageIDPattern matches the IDs of image versions of community galleries.
+	communityGalleryImageIDPattern = regexp.MustCompile(`(?i)^/CommunityGalleries/[^/]+/Images/[^/]+/Versions/[^/]+$`)
+	// sharedGalleryImageIDPattern matches the IDs of image versions of galleries shared directly with the subscription or tenant.
+	sharedGalleryImageIDPattern = regexp.MustCompile(`(?i)^/SharedGalleries/[^/]+/Images/[^/]+/Versions/[^/]+$`)
+)
+
 // ValidateImage validates an image.
 func ValidateImage(image *Image, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
@@ -95,6 +105,9 @@ func validateComputeGalleryImage(image *Image, fldPath *field.Path) field.ErrorL
 	if image.ComputeGallery.ResourceGroup != nil && image.ComputeGallery.SubscriptionID == nil {
 		allErrs = append(allErrs, field.Invalid(fldPath.Child("SubscriptionID"), "", "SubscriptionID cannot be empty when ResourceGroup is specified"))
 	}
+	if image.ComputeGallery.DirectShared && (image.ComputeGallery.SubscriptionID != nil || image.ComputeGallery.ResourceGroup != nil) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("DirectShared"), "DirectShared cannot be used as SubscriptionID and ResourceGroup of a private image have been specified"))
+	}
 
 	return allErrs
 }
@@ -146,5 +159,16 @@ func validateSpecificImage(image *Image, fldPath *field.Path) field.ErrorList {
 		allErrs = append(allErrs, field.Invalid(fldPath.Child("ID"), "", "ID cannot be empty when specifying an AzureImageByID"))
 	}
 
+	// Image versions of community and direct shared galleries are not ARM resources and have their own ID formats.
+	id := strings.ToLower(*image.ID)
+	if strings.HasPrefix(id, "/communitygalleries/") && !communityGalleryImageIDPattern.MatchString(*image.ID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("ID"), *image.ID,
+			"ID of a community gallery image must have the format /CommunityGalleries/<gallery>/Images/<image>/Versions/<version>"))
+	}
+	if strings.HasPrefix(id, "/sharedgalleries/") && !sharedGalleryImageIDPattern.MatchString(*image.ID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("ID"), *image.ID,
+			"ID of a shared gallery image must have the format /SharedGalleries/<gallery>/Images/<image>/Versions/<version>"))
+	}
+
 	return allErrs
 }
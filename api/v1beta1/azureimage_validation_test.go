@@ -84,6 +84,22 @@ func TestComputeImageGalleryValid(t *testing.T) {
 			expectedErrors: 1,
 			image:          createTestComputeImage(to.StringPtr("SUB1234"), nil),
 		},
+		"AzureComputeGalleryImage - fully specified direct shared image": {
+			expectedErrors: 0,
+			image: func() *Image {
+				image := createTestComputeImage(nil, nil)
+				image.ComputeGallery.DirectShared = true
+				return image
+			}(),
+		},
+		"AzureComputeGalleryImage - direct shared image with subscription and resource group": {
+			expectedErrors: 1,
+			image: func() *Image {
+				image := createTestComputeImage(to.StringPtr("SUB1234"), to.StringPtr("RG1234"))
+				image.ComputeGallery.DirectShared = true
+				return image
+			}(),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -178,6 +194,22 @@ func TestImageByIDValid(t *testing.T) {
 			expectedErrors: 1,
 			image:          createTestImageByID(""),
 		},
+		"AzureImageByID - community gallery image": {
+			expectedErrors: 0,
+			image:          createTestImageByID("/CommunityGalleries/my-gallery-1234/Images/my-image/Versions/1.0.0"),
+		},
+		"AzureImageByID - community gallery image without version": {
+			expectedErrors: 1,
+			image:          createTestImageByID("/CommunityGalleries/my-gallery-1234/Images/my-image"),
+		},
+		"AzureImageByID - shared gallery image": {
+			expectedErrors: 0,
+			image:          createTestImageByID("/SharedGalleries/my-gallery-1234/Images/my-image/Versions/1.0.0"),
+		},
+		"AzureImageByID - shared gallery image with an invalid format": {
+			expectedErrors: 1,
+			image:          createTestImageByID("/SharedGalleries/my-gallery-1234/Versions/1.0.0"),
+		},
 	}
 
 	for _, tc := range testCases {
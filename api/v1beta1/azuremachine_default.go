@@ -69,7 +69,8 @@ func (s *AzureMachineSpec) SetDataDisksDefaults() {
 		}
 		if disk.CachingType == "" {
 			if s.DataDisks[i].ManagedDisk != nil &&
-				s.DataDisks[i].ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) {
+				(s.DataDisks[i].ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) ||
+					s.DataDisks[i].ManagedDisk.StorageAccountType == StorageAccountTypePremiumV2LRS) {
 				s.DataDisks[i].CachingType = string(compute.CachingTypesNone)
 			} else {
 				s.DataDisks[i].CachingType = string(compute.CachingTypesReadWrite)
@@ -80,7 +81,7 @@ func (s *AzureMachineSpec) SetDataDisksDefaults() {
 
 // SetIdentityDefaults sets the defaults for VM Identity.
 func (s *AzureMachineSpec) SetIdentityDefaults() {
-	if s.Identity == VMIdentitySystemAssigned {
+	if s.Identity == VMIdentitySystemAssigned || s.Identity == VMIdentitySystemAndUserAssigned {
 		if s.RoleAssignmentName == "" {
 			s.RoleAssignmentName = string(uuid.NewUUID())
 		}
@@ -62,6 +62,10 @@ func TestAzureMachineSpec_SetIdentityDefaults(t *testing.T) {
 		Identity:           VMIdentitySystemAssigned,
 		RoleAssignmentName: "",
 	}}}
+	systemAndUserAssignedTest := test{machine: &AzureMachine{Spec: AzureMachineSpec{
+		Identity:           VMIdentitySystemAndUserAssigned,
+		RoleAssignmentName: "",
+	}}}
 	notSystemAssignedTest := test{machine: &AzureMachine{Spec: AzureMachineSpec{
 		Identity: VMIdentityUserAssigned,
 	}}}
@@ -74,6 +78,9 @@ func TestAzureMachineSpec_SetIdentityDefaults(t *testing.T) {
 	_, err := uuid.Parse(roleAssignmentEmptyTest.machine.Spec.RoleAssignmentName)
 	g.Expect(err).To(Not(HaveOccurred()))
 
+	systemAndUserAssignedTest.machine.Spec.SetIdentityDefaults()
+	g.Expect(systemAndUserAssignedTest.machine.Spec.RoleAssignmentName).To(Not(BeEmpty()))
+
 	notSystemAssignedTest.machine.Spec.SetIdentityDefaults()
 	g.Expect(notSystemAssignedTest.machine.Spec.RoleAssignmentName).To(BeEmpty())
 }
@@ -223,6 +230,14 @@ func TestAzureMachineSpec_SetDataDisksDefaults(t *testing.T) {
 					},
 					Lun: to.Int32Ptr(3),
 				},
+				{
+					NameSuffix: "testdisk4",
+					DiskSizeGB: 30,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "PremiumV2_LRS",
+					},
+					Lun: to.Int32Ptr(4),
+				},
 			},
 			output: []DataDisk{
 				{
@@ -246,6 +261,15 @@ func TestAzureMachineSpec_SetDataDisksDefaults(t *testing.T) {
 					},
 					CachingType: "None",
 				},
+				{
+					NameSuffix: "testdisk4",
+					DiskSizeGB: 30,
+					Lun:        to.Int32Ptr(4),
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "PremiumV2_LRS",
+					},
+					CachingType: "None",
+				},
 			},
 		},
 	}
@@ -54,7 +54,8 @@ type AzureMachineSpec struct {
 	// The type 'SystemAssigned' is an implicitly created identity.
 	// The generated identity will be assigned a Subscription contributor role.
 	// The type 'UserAssigned' is a standalone Azure resource provided by the user
-	// and assigned to the VM
+	// and assigned to the VM.
+	// The type 'SystemAssigned, UserAssigned' assigns both a system-assigned identity and the user-assigned identities.
 	// +kubebuilder:default=None
 	// +optional
 	Identity VMIdentity `json:"identity,omitempty"`
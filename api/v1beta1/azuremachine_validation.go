@@ -79,7 +79,7 @@ func ValidateSSHKey(sshKey string, fldPath *field.Path) field.ErrorList {
 func ValidateSystemAssignedIdentity(identityType VMIdentity, oldIdentity, newIdentity string, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	if identityType == VMIdentitySystemAssigned {
+	if identityType == VMIdentitySystemAssigned || identityType == VMIdentitySystemAndUserAssigned {
 		if _, err := uuid.Parse(newIdentity); err != nil {
 			allErrs = append(allErrs, field.Invalid(fldPath, newIdentity, "Role assignment name must be a valid GUID. It is optional and will be auto-generated when not specified."))
 		}
@@ -97,8 +97,8 @@ func ValidateSystemAssignedIdentity(identityType VMIdentity, oldIdentity, newIde
 func ValidateUserAssignedIdentity(identityType VMIdentity, userAssignedIdenteties []UserAssignedIdentity, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	if identityType == VMIdentityUserAssigned && len(userAssignedIdenteties) == 0 {
-		allErrs = append(allErrs, field.Required(fldPath, "must be specified for the 'UserAssigned' identity type"))
+	if (identityType == VMIdentityUserAssigned || identityType == VMIdentitySystemAndUserAssigned) && len(userAssignedIdenteties) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath, fmt.Sprintf("must be specified for the '%s' identity type", identityType)))
 	}
 	return allErrs
 }
@@ -187,6 +187,53 @@ func validateManagedDisk(m *ManagedDiskParameters, fieldPath *field.Path, isOSDi
 
 	if m != nil {
 		allErrs = append(allErrs, validateStorageAccountType(m.StorageAccountType, fieldPath.Child("StorageAccountType"), isOSDisk)...)
+		allErrs = append(allErrs, ValidateManagedDiskPerformance(m, fieldPath)...)
+	}
+
+	return allErrs
+}
+
+// diskPerformanceLimits are the limits of the read-write IOPS and throughput of a storage account type.
+type diskPerformanceLimits struct {
+	minIOPS, maxIOPS int64
+	minMBps, maxMBps int64
+}
+
+// tunableDiskPerformanceLimits are the performance limits of the storage account types whose read-write IOPS and
+// throughput can be set independently of the disk size.
+var tunableDiskPerformanceLimits = map[string]diskPerformanceLimits{
+	string(compute.StorageAccountTypesUltraSSDLRS): {minIOPS: 100, maxIOPS: 160000, minMBps: 1, maxMBps: 4000},
+	StorageAccountTypePremiumV2LRS:                 {minIOPS: 3000, maxIOPS: 80000, minMBps: 125, maxMBps: 1200},
+}
+
+// ValidateManagedDiskPerformance validates that the read-write IOPS and throughput of a managed disk are only set on
+// UltraSSD_LRS and PremiumV2_LRS disks and are within the limits of their storage account type.
+func ValidateManagedDiskPerformance(m *ManagedDiskParameters, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if m == nil || (m.DiskIOPSReadWrite == nil && m.DiskMBpsReadWrite == nil) {
+		return allErrs
+	}
+
+	limits, ok := tunableDiskPerformanceLimits[m.StorageAccountType]
+	if !ok {
+		msg := "can only be set when storageAccountType is '%s' or '%s'"
+		if m.DiskIOPSReadWrite != nil {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("diskIOPSReadWrite"), fmt.Sprintf("diskIOPSReadWrite "+msg, compute.StorageAccountTypesUltraSSDLRS, StorageAccountTypePremiumV2LRS)))
+		}
+		if m.DiskMBpsReadWrite != nil {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("diskMBpsReadWrite"), fmt.Sprintf("diskMBpsReadWrite "+msg, compute.StorageAccountTypesUltraSSDLRS, StorageAccountTypePremiumV2LRS)))
+		}
+		return allErrs
+	}
+
+	if m.DiskIOPSReadWrite != nil && (*m.DiskIOPSReadWrite < limits.minIOPS || *m.DiskIOPSReadWrite > limits.maxIOPS) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("diskIOPSReadWrite"), *m.DiskIOPSReadWrite,
+			fmt.Sprintf("diskIOPSReadWrite of a %s disk must be between %d and %d", m.StorageAccountType, limits.minIOPS, limits.maxIOPS)))
+	}
+	if m.DiskMBpsReadWrite != nil && (*m.DiskMBpsReadWrite < limits.minMBps || *m.DiskMBpsReadWrite > limits.maxMBps) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("diskMBpsReadWrite"), *m.DiskMBpsReadWrite,
+			fmt.Sprintf("diskMBpsReadWrite of a %s disk must be between %d and %d", m.StorageAccountType, limits.minMBps, limits.maxMBps)))
 	}
 
 	return allErrs
@@ -264,6 +311,13 @@ func validateStorageAccountType(storageAccountType string, fieldPath *field.Path
 		allErrs = append(allErrs, field.Invalid(fieldPath.Child("managedDisks").Child("storageAccountType"), storageAccountType, "UltraSSD_LRS can only be used with data disks, it cannot be used with OS Disks"))
 	}
 
+	if storageAccountType == StorageAccountTypePremiumV2LRS {
+		if isOSDisk {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("managedDisks").Child("storageAccountType"), storageAccountType, "PremiumV2_LRS can only be used with data disks, it cannot be used with OS Disks"))
+		}
+		return allErrs
+	}
+
 	if storageAccountType == "" {
 		allErrs = append(allErrs, field.Required(fieldPath, "the Storage Account Type for Managed Disk cannot be empty"))
 		return allErrs
@@ -282,9 +336,9 @@ func validateCachingType(cachingType string, fieldPath *field.Path, managedDisk
 	allErrs := field.ErrorList{}
 	cachingTypeChildPath := fieldPath.Child("CachingType")
 
-	if managedDisk != nil && managedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) {
+	if managedDisk != nil && (managedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) || managedDisk.StorageAccountType == StorageAccountTypePremiumV2LRS) {
 		if cachingType != string(compute.CachingTypesNone) {
-			allErrs = append(allErrs, field.Invalid(cachingTypeChildPath, cachingType, fmt.Sprintf("cachingType '%s' is not supported when storageAccountType is '%s'. Allowed values are: '%s'", cachingType, compute.StorageAccountTypesUltraSSDLRS, compute.CachingTypesNone)))
+			allErrs = append(allErrs, field.Invalid(cachingTypeChildPath, cachingType, fmt.Sprintf("cachingType '%s' is not supported when storageAccountType is '%s'. Allowed values are: '%s'", cachingType, managedDisk.StorageAccountType, compute.CachingTypesNone)))
 		}
 	}
 
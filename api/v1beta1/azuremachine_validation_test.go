@@ -186,6 +186,14 @@ func generateNegativeTestCases() []osDiskTestInput {
 				StorageAccountType: "invalid_type",
 			},
 		},
+		{
+			DiskSizeGB: to.Int32Ptr(30),
+			OSType:     "blah",
+			ManagedDisk: &ManagedDiskParameters{
+				StorageAccountType: "PremiumV2_LRS",
+			},
+			CachingType: string(compute.CachingTypesNone),
+		},
 		{
 			DiskSizeGB: to.Int32Ptr(30),
 			OSType:     "blah",
@@ -430,6 +438,183 @@ func TestAzureMachine_ValidateDataDisks(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid UltraSSD_LRS disk IOPS and throughput",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesUltraSSDLRS),
+						DiskIOPSReadWrite:  to.Int64Ptr(20000),
+						DiskMBpsReadWrite:  to.Int64Ptr(500),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid disk IOPS on a Premium_LRS disk",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesPremiumLRS),
+						DiskIOPSReadWrite:  to.Int64Ptr(20000),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid disk throughput on a Premium_LRS disk",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesPremiumLRS),
+						DiskMBpsReadWrite:  to.Int64Ptr(500),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid UltraSSD_LRS disk IOPS below the minimum",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesUltraSSDLRS),
+						DiskIOPSReadWrite:  to.Int64Ptr(99),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid UltraSSD_LRS disk IOPS above the maximum",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesUltraSSDLRS),
+						DiskIOPSReadWrite:  to.Int64Ptr(160001),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid UltraSSD_LRS disk throughput below the minimum",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesUltraSSDLRS),
+						DiskMBpsReadWrite:  to.Int64Ptr(0),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid UltraSSD_LRS disk throughput above the maximum",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: string(compute.StorageAccountTypesUltraSSDLRS),
+						DiskMBpsReadWrite:  to.Int64Ptr(4001),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid PremiumV2_LRS disk IOPS and throughput",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: StorageAccountTypePremiumV2LRS,
+						DiskIOPSReadWrite:  to.Int64Ptr(5000),
+						DiskMBpsReadWrite:  to.Int64Ptr(200),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid combination of managed disk storage account type PremiumV2_LRS and cachingType ReadOnly",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: StorageAccountTypePremiumV2LRS,
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesReadOnly),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid PremiumV2_LRS disk IOPS below the minimum",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: StorageAccountTypePremiumV2LRS,
+						DiskIOPSReadWrite:  to.Int64Ptr(2999),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid PremiumV2_LRS disk throughput above the maximum",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: StorageAccountTypePremiumV2LRS,
+						DiskMBpsReadWrite:  to.Int64Ptr(1201),
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.CachingTypesNone),
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, test := range testcases {
@@ -460,6 +645,18 @@ func TestAzureMachine_ValidateSystemAssignedIdentity(t *testing.T) {
 			Identity:           VMIdentitySystemAssigned,
 			wantErr:            false,
 		},
+		{
+			name:               "valid UUID with system and user assigned identity",
+			roleAssignmentName: uuid.New().String(),
+			Identity:           VMIdentitySystemAndUserAssigned,
+			wantErr:            false,
+		},
+		{
+			name:               "not a valid UUID with system and user assigned identity",
+			roleAssignmentName: "notaguid",
+			Identity:           VMIdentitySystemAndUserAssigned,
+			wantErr:            true,
+		},
 		{
 			name:               "wrong Identity type",
 			roleAssignmentName: uuid.New().String(),
@@ -499,6 +696,56 @@ func TestAzureMachine_ValidateSystemAssignedIdentity(t *testing.T) {
 	}
 }
 
+func TestAzureMachine_ValidateUserAssignedIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name       string
+		Identity   VMIdentity
+		identities []UserAssignedIdentity
+		wantErr    bool
+	}{
+		{
+			name:       "user assigned identity with identities",
+			Identity:   VMIdentityUserAssigned,
+			identities: []UserAssignedIdentity{{ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"}},
+			wantErr:    false,
+		},
+		{
+			name:     "user assigned identity without identities",
+			Identity: VMIdentityUserAssigned,
+			wantErr:  true,
+		},
+		{
+			name:       "system and user assigned identity with identities",
+			Identity:   VMIdentitySystemAndUserAssigned,
+			identities: []UserAssignedIdentity{{ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"}},
+			wantErr:    false,
+		},
+		{
+			name:     "system and user assigned identity without identities",
+			Identity: VMIdentitySystemAndUserAssigned,
+			wantErr:  true,
+		},
+		{
+			name:     "system assigned identity without identities",
+			Identity: VMIdentitySystemAssigned,
+			wantErr:  false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateUserAssignedIdentity(tc.Identity, tc.identities, field.NewPath("userAssignedIdentities"))
+			if tc.wantErr {
+				g.Expect(err).NotTo(BeEmpty())
+			} else {
+				g.Expect(err).To(BeEmpty())
+			}
+		})
+	}
+}
+
 func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 	g := NewWithT(t)
 
@@ -74,6 +74,11 @@ const (
 	ScaleSetModelUpdatedCondition clusterv1.ConditionType = "ScaleSetModelUpdated"
 	// ScaleSetModelOutOfDateReason describes the machine pool model being out of date.
 	ScaleSetModelOutOfDateReason = "ScaleSetModelOutOfDate"
+
+	// ScaleSetSpotCapacityCondition reports whether the spot instances of the machine pool have been evicted by Azure.
+	ScaleSetSpotCapacityCondition clusterv1.ConditionType = "ScaleSetSpotCapacity"
+	// ScaleSetSpotInstancesEvictedReason describes spot instances of the machine pool having been evicted by Azure.
+	ScaleSetSpotInstancesEvictedReason = "ScaleSetSpotInstancesEvicted"
 )
 
 // AzureManagedCluster Conditions and Reasons.
@@ -84,6 +89,26 @@ const (
 	AgentPoolsReadyCondition clusterv1.ConditionType = "AgentPoolsReady"
 )
 
+// AzureManagedMachinePool Conditions and Reasons.
+const (
+	// AgentPoolRunningCondition reports on the current provisioning state of the AKS agent pool.
+	AgentPoolRunningCondition clusterv1.ConditionType = "AgentPoolRunning"
+	// AgentPoolCreatingReason used when the agent pool creation is in progress.
+	AgentPoolCreatingReason = "AgentPoolCreating"
+	// AgentPoolUpdatingReason used when the agent pool update is in progress.
+	AgentPoolUpdatingReason = "AgentPoolUpdating"
+	// AgentPoolUpgradingReason used when the agent pool upgrade is in progress.
+	AgentPoolUpgradingReason = "AgentPoolUpgrading"
+	// AgentPoolScalingReason used when the agent pool is scaling up or down.
+	AgentPoolScalingReason = "AgentPoolScaling"
+	// AgentPoolDeletingReason used when the agent pool deletion is in progress.
+	AgentPoolDeletingReason = "AgentPoolDeleting"
+	// AgentPoolProvisionFailedReason used for failures during agent pool provisioning.
+	AgentPoolProvisionFailedReason = "AgentPoolProvisionFailed"
+	// AgentPoolStoppedReason used when the agent pool is stopped.
+	AgentPoolStoppedReason = "AgentPoolStopped"
+)
+
 // Azure Services Conditions and Reasons.
 const (
 	// ResourceGroupReadyCondition means the resource group exists and is ready to be used.
@@ -362,6 +362,10 @@ type AzureComputeGalleryImage struct {
 	// ResourceGroup specifies the resource group containing the private compute gallery.
 	// +optional
 	ResourceGroup *string `json:"resourceGroup,omitempty"`
+	// DirectShared specifies that Gallery is the unique name of a compute gallery shared directly with the
+	// subscription or tenant. SubscriptionID and ResourceGroup must not be set for directly shared galleries.
+	// +optional
+	DirectShared bool `json:"directShared,omitempty"`
 	// Plan contains plan information.
 	// +optional
 	Plan *ImagePlan `json:"plan,omitempty"`
@@ -440,7 +444,7 @@ type AzureSharedGalleryImage struct {
 }
 
 // VMIdentity defines the identity of the virtual machine, if configured.
-// +kubebuilder:validation:Enum=None;SystemAssigned;UserAssigned
+// +kubebuilder:validation:Enum=None;SystemAssigned;UserAssigned;"SystemAssigned, UserAssigned"
 type VMIdentity string
 
 const (
@@ -450,6 +454,8 @@ const (
 	VMIdentitySystemAssigned VMIdentity = "SystemAssigned"
 	// VMIdentityUserAssigned ...
 	VMIdentityUserAssigned VMIdentity = "UserAssigned"
+	// VMIdentitySystemAndUserAssigned assigns both a system-assigned identity and the user-assigned identities.
+	VMIdentitySystemAndUserAssigned VMIdentity = "SystemAssigned, UserAssigned"
 )
 
 // UserAssignedIdentity defines the user-assigned identities provided
@@ -533,8 +539,22 @@ type ManagedDiskParameters struct {
 	StorageAccountType string `json:"storageAccountType,omitempty"`
 	// +optional
 	DiskEncryptionSet *DiskEncryptionSetParameters `json:"diskEncryptionSet,omitempty"`
+	// DiskIOPSReadWrite specifies the read-write IOPS of the disk. It can only be set on UltraSSD_LRS data disks,
+	// where it must be between 100 and 160000, and on PremiumV2_LRS data disks, where it must be between 3000 and 80000.
+	// If not set, Azure derives it from the disk size.
+	// +optional
+	DiskIOPSReadWrite *int64 `json:"diskIOPSReadWrite,omitempty"`
+	// DiskMBpsReadWrite specifies the read-write throughput of the disk in MBps. It can only be set on UltraSSD_LRS
+	// data disks, where it must be between 1 and 4000, and on PremiumV2_LRS data disks, where it must be between 125
+	// and 1200. If not set, Azure derives it from the disk size.
+	// +optional
+	DiskMBpsReadWrite *int64 `json:"diskMBpsReadWrite,omitempty"`
 }
 
+// StorageAccountTypePremiumV2LRS is the storage account type of Premium SSD v2 disks. It is not known to the compute
+// API version used by CAPZ, which passes it to Azure as is.
+const StorageAccountTypePremiumV2LRS = "PremiumV2_LRS"
+
 // DiskEncryptionSetParameters defines disk encryption options.
 type DiskEncryptionSetParameters struct {
 	// ID defines resourceID for diskEncryptionSet resource. It must be in the same subscription
@@ -28,4 +28,95 @@ const (
 	// See https://kubernetes.io/docs/concepts/overview/working-with-objects/annotations/
 	// for annotation formatting rules.
 	RGTagsLastAppliedAnnotation = "sigs.k8s.io/cluster-api-provider-azure-last-applied-tags-rg"
+
+	// DisableBootstrapExtensionAnnotation is the key for the AzureMachinePool object annotation
+	// which, when set to "true", disables the automatically injected bootstrapping VM extension,
+	// e.g. for images which bootstrap via cloud-init custom data only.
+	DisableBootstrapExtensionAnnotation = "sigs.k8s.io/cluster-api-provider-azure-disable-bootstrap-extension"
+
+	// VerifyImagePlanTermsAnnotation is the key for the AzureMachinePool object annotation which, when set to "true",
+	// verifies that the terms of the marketplace image plan have been accepted in the subscription before the Virtual
+	// Machine Scale Set is created.
+	VerifyImagePlanTermsAnnotation = "sigs.k8s.io/cluster-api-provider-azure-verify-image-plan-terms"
+
+	// FollowLatestImageVersionAnnotation is the key for the AzureMachinePool object annotation which, when set to
+	// "true", keeps the version "latest" of a marketplace image instead of pinning it to the version it resolves to.
+	FollowLatestImageVersionAnnotation = "sigs.k8s.io/cluster-api-provider-azure-follow-latest-image-version"
+
+	// RollToNewerDefaultImageAnnotation is the key for the AzureMachinePool object annotation which, when set to "true",
+	// updates the Virtual Machine Scale Set to a newer version of the default image once it's published, which rolls
+	// the instances of the machine pool.
+	RollToNewerDefaultImageAnnotation = "sigs.k8s.io/cluster-api-provider-azure-roll-to-newer-default-image"
+
+	// VMSSOperationTimeoutAnnotation is the key for the AzureMachinePool object annotation which overrides the maximum
+	// age of a long running operation on the Virtual Machine Scale Set, e.g. "3h", before the operation is treated as
+	// failed.
+	VMSSOperationTimeoutAnnotation = "sigs.k8s.io/cluster-api-provider-azure-vmss-operation-timeout"
+
+	// VMSSOperationStartedAtAnnotation is the key for the AzureMachinePool object annotation which records the time
+	// the current long running operation on the Virtual Machine Scale Set was started at, in RFC3339 format.
+	VMSSOperationStartedAtAnnotation = "sigs.k8s.io/cluster-api-provider-azure-vmss-operation-started-at"
+
+	// CheckQuotaBeforeSurgeAnnotation is the key for the AzureMachinePool object annotation which, when set to "true",
+	// caps the surge of the Virtual Machine Scale Set to the number of instances the regional vCPU quotas of the
+	// subscription allow for, so a rollout doesn't fail with a quota exceeded error.
+	CheckQuotaBeforeSurgeAnnotation = "sigs.k8s.io/cluster-api-provider-azure-check-quota-before-surge"
+
+	// RollOnBootstrapDataChangesAnnotation is the key for the AzureMachinePool object annotation which, when set to
+	// "true", treats changes of the bootstrap data as changes of the Virtual Machine Scale Set model, which patches the
+	// scale set and rolls the instances of the machine pool. Otherwise changed bootstrap data is only applied along with
+	// other changes of the model and doesn't roll the instances on its own.
+	RollOnBootstrapDataChangesAnnotation = "sigs.k8s.io/cluster-api-provider-azure-roll-on-bootstrap-data-changes"
+
+	// BootstrapDataHashAnnotation is the key for the AzureMachinePool object annotation which records the SHA-256 hash
+	// of the bootstrap data last applied to the Virtual Machine Scale Set while RollOnBootstrapDataChangesAnnotation is
+	// set.
+	BootstrapDataHashAnnotation = "sigs.k8s.io/cluster-api-provider-azure-bootstrap-data-hash"
+
+	// AutoAssignDataDiskLunsAnnotation is the key for the AzureMachinePool object annotation which, when set to "true",
+	// assigns the lowest unused logical unit number to each data disk of the Virtual Machine Scale Set without one.
+	// Otherwise every data disk must set its logical unit number.
+	AutoAssignDataDiskLunsAnnotation = "sigs.k8s.io/cluster-api-provider-azure-auto-assign-data-disk-luns"
+
+	// UpgradeNodeImageAnnotation is the key for the AzureManagedMachinePool object annotation
+	// which, when set to "true", upgrades the nodes of the agent pool to the latest node image version.
+	// The annotation is removed once the upgrade has been issued.
+	UpgradeNodeImageAnnotation = "sigs.k8s.io/cluster-api-provider-azure-upgrade-node-image"
+
+	// ReimageAnnotation is the key for the AzureMachinePoolMachine object annotation which, when set to "true",
+	// reimages the scale set instance of the machine. The annotation is removed once the instance has been reimaged.
+	ReimageAnnotation = "sigs.k8s.io/cluster-api-provider-azure-reimage"
+
+	// DoNotDeleteAnnotation is the key for the AzureMachinePoolMachine object annotation which, when present, protects
+	// the machine from being selected for deletion by the deployment strategy of its AzureMachinePool, unless no other
+	// machine can be selected. It is the inverse of the Cluster API delete-machine annotation.
+	DoNotDeleteAnnotation = "sigs.k8s.io/cluster-api-provider-azure-do-not-delete"
+
+	// VMSSPatchConflictsAnnotation is the key for the AzureMachinePool object annotation which counts the consecutive
+	// conflicts when patching its Virtual Machine Scale Set, used to back off retries exponentially. The annotation is
+	// removed once a patch has been accepted.
+	VMSSPatchConflictsAnnotation = "sigs.k8s.io/cluster-api-provider-azure-vmss-patch-conflicts"
+
+	// ReplicasManagedByAnnotation is the key for the MachinePool object annotation which, when present, marks the
+	// replicas of the machine pool as managed by an external autoscaler, so that the capacity of the scale set is
+	// not reset to the replicas of the machine pool.
+	ReplicasManagedByAnnotation = "cluster.x-k8s.io/replicas-managed-by"
+
+	// VMSSVMIDAnnotation is the key for the AzureMachinePoolMachine object annotation which stores the unique ID of
+	// the VMSS VM the machine was created for. Azure reuses instance IDs, so the VM ID tells the instance a machine
+	// was created for apart from a newer instance with the same instance ID.
+	VMSSVMIDAnnotation = "sigs.k8s.io/cluster-api-provider-azure-vmss-vm-id"
+)
+
+const (
+	// AgentPoolPowerStateRunning is the power state of a running AKS agent pool.
+	AgentPoolPowerStateRunning = "Running"
+	// AgentPoolPowerStateStopped is the power state of an AKS agent pool which has been stopped intentionally.
+	AgentPoolPowerStateStopped = "Stopped"
+)
+
+const (
+	// MaxCustomDataLength is the maximum length of the base64 encoded custom data of a Virtual Machine, which
+	// corresponds to 65535 bytes before encoding.
+	MaxCustomDataLength = 87380
 )
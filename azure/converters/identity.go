@@ -36,14 +36,18 @@ func VMIdentityToVMSDK(identity infrav1.VMIdentity, uami []infrav1.UserAssignedI
 		}, nil
 	}
 
-	if identity == infrav1.VMIdentityUserAssigned {
+	if identity == infrav1.VMIdentityUserAssigned || identity == infrav1.VMIdentitySystemAndUserAssigned {
 		userIdentitiesMap, err := UserAssignedIdentitiesToVMSDK(uami)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to assign VM identity")
 		}
 
+		identityType := compute.ResourceIdentityTypeUserAssigned
+		if identity == infrav1.VMIdentitySystemAndUserAssigned {
+			identityType = compute.ResourceIdentityTypeSystemAssignedUserAssigned
+		}
 		return &compute.VirtualMachineIdentity{
-			Type:                   compute.ResourceIdentityTypeUserAssigned,
+			Type:                   identityType,
 			UserAssignedIdentities: userIdentitiesMap,
 		}, nil
 	}
@@ -80,6 +80,28 @@ func Test_VMIdentityToVMSDK(t *testing.T) {
 				}))
 			},
 		},
+		{
+			Name:         "Should return system and user assigned identities when identity is system and user assigned",
+			identityType: infrav1.VMIdentitySystemAndUserAssigned,
+			uami:         []infrav1.UserAssignedIdentity{{ProviderID: "my-uami-1"}},
+			Expect: func(g *GomegaWithT, m *compute.VirtualMachineIdentity, err error) {
+				g.Expect(err).Should(BeNil())
+				g.Expect(m).Should(Equal(&compute.VirtualMachineIdentity{
+					Type: compute.ResourceIdentityTypeSystemAssignedUserAssigned,
+					UserAssignedIdentities: map[string]*compute.VirtualMachineIdentityUserAssignedIdentitiesValue{
+						"my-uami-1": {},
+					},
+				}))
+			},
+		},
+		{
+			Name:         "Should fail when no user assigned identities are specified and identity is system and user assigned",
+			identityType: infrav1.VMIdentitySystemAndUserAssigned,
+			uami:         []infrav1.UserAssignedIdentity{},
+			Expect: func(g *GomegaWithT, m *compute.VirtualMachineIdentity, err error) {
+				g.Expect(err.Error()).Should(ContainSubstring(ErrUserAssignedIdentitiesNotFound.Error()))
+			},
+		},
 		{
 			Name:         "Should fail when no user assigned identities are specified and identity is user assigned",
 			identityType: infrav1.VMIdentityUserAssigned,
@@ -18,6 +18,7 @@ package converters
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
@@ -64,13 +65,23 @@ func computeImageToSDK(image *infrav1.Image) (*compute.ImageReference, error) {
 		}, nil
 	}
 
+	// Galleries shared directly with the subscription or tenant are referenced by their unique name.
+	if image.ComputeGallery.DirectShared {
+		return &compute.ImageReference{
+			SharedGalleryImageID: to.StringPtr(fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/%s",
+				image.ComputeGallery.Gallery,
+				image.ComputeGallery.Name,
+				image.ComputeGallery.Version)),
+		}, nil
+	}
+
 	// For private Azure Compute Gallery consumption both resource group and subscription ID must be provided.
 	// If they are not, we assume use of community gallery.
 	if image.ComputeGallery.ResourceGroup != nil && image.ComputeGallery.SubscriptionID != nil {
 		return &compute.ImageReference{
 			ID: to.StringPtr(fmt.Sprintf(idTemplate,
-				image.ComputeGallery.SubscriptionID,
-				image.ComputeGallery.ResourceGroup,
+				to.String(image.ComputeGallery.SubscriptionID),
+				to.String(image.ComputeGallery.ResourceGroup),
 				image.ComputeGallery.Gallery,
 				image.ComputeGallery.Name,
 				image.ComputeGallery.Version,
@@ -87,6 +98,19 @@ func computeImageToSDK(image *infrav1.Image) (*compute.ImageReference, error) {
 }
 
 func specificImageToSDK(image *infrav1.Image) (*compute.ImageReference, error) {
+	// Image versions of community and direct shared galleries are not ARM resources and have their own ID formats.
+	id := strings.ToLower(to.String(image.ID))
+	switch {
+	case strings.HasPrefix(id, "/communitygalleries/"):
+		return &compute.ImageReference{
+			CommunityGalleryImageID: image.ID,
+		}, nil
+	case strings.HasPrefix(id, "/sharedgalleries/"):
+		return &compute.ImageReference{
+			SharedGalleryImageID: image.ID,
+		}, nil
+	}
+
 	return &compute.ImageReference{
 		ID: image.ID,
 	}, nil
@@ -104,7 +128,8 @@ func ImageToPlan(image *infrav1.Image) *compute.Plan {
 	}
 
 	// Plan is needed for third party Marketplace images.
-	if image.Marketplace != nil && image.Marketplace.ThirdPartyImage {
+	if image.Marketplace != nil && image.Marketplace.ThirdPartyImage &&
+		image.Marketplace.Publisher != "" && image.Marketplace.SKU != "" && image.Marketplace.Offer != "" {
 		return &compute.Plan{
 			Publisher: to.StringPtr(image.Marketplace.Publisher),
 			Name:      to.StringPtr(image.Marketplace.SKU),
@@ -121,6 +146,6 @@ func ImageToPlan(image *infrav1.Image) *compute.Plan {
 		}
 	}
 
-	// Otherwise return nil.
+	// Otherwise return nil, which includes images referenced by ID as the ID carries no plan details.
 	return nil
 }
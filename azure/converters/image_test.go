@@ -72,6 +72,28 @@ func Test_ImageToPlan(t *testing.T) {
 				}))
 			},
 		},
+		{
+			name: "Should return a plan for a SIG image with plan details in another subscription",
+			image: &infrav1.Image{
+				SharedGallery: &infrav1.AzureSharedGalleryImage{
+					SubscriptionID: "other-sub-id",
+					ResourceGroup:  "central-rg",
+					Gallery:        "central-gallery",
+					Name:           "my-image",
+					Version:        "1.0.0",
+					Publisher:      to.StringPtr("my-publisher"),
+					Offer:          to.StringPtr("my-offer"),
+					SKU:            to.StringPtr("my-sku"),
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.Plan) {
+				g.Expect(result).To(Equal(&compute.Plan{
+					Name:      to.StringPtr("my-sku"),
+					Publisher: to.StringPtr("my-publisher"),
+					Product:   to.StringPtr("my-offer"),
+				}))
+			},
+		},
 		{
 			name: "Should return nil for a SIG image without plan info",
 			image: &infrav1.Image{
@@ -87,6 +109,24 @@ func Test_ImageToPlan(t *testing.T) {
 				g.Expect(result).To(BeNil())
 			},
 		},
+		{
+			name: "Should return nil for a Compute Gallery image version ID",
+			image: &infrav1.Image{
+				ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/galleries/my-gallery/images/my-image/versions/1.0.0"),
+			},
+			expect: func(g *GomegaWithT, result *compute.Plan) {
+				g.Expect(result).To(BeNil())
+			},
+		},
+		{
+			name: "Should return nil for a community gallery image version ID",
+			image: &infrav1.Image{
+				ID: to.StringPtr("/CommunityGalleries/my-gallery-1234/Images/my-image/Versions/1.0.0"),
+			},
+			expect: func(g *GomegaWithT, result *compute.Plan) {
+				g.Expect(result).To(BeNil())
+			},
+		},
 		{
 			name: "Should return nil for a Marketplace first party image",
 			image: &infrav1.Image{
@@ -125,6 +165,17 @@ func Test_ImageToPlan(t *testing.T) {
 				}))
 			},
 		},
+		{
+			name: "Should return nil for a Marketplace third party image without plan details",
+			image: &infrav1.Image{
+				Marketplace: &infrav1.AzureMarketplaceImage{
+					ThirdPartyImage: true,
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.Plan) {
+				g.Expect(result).To(BeNil())
+			},
+		},
 		{
 			name: "Should return nil for an image ID",
 			image: &infrav1.Image{
@@ -146,3 +197,153 @@ func Test_ImageToPlan(t *testing.T) {
 		})
 	}
 }
+
+func Test_ImageToSDK(t *testing.T) {
+	cases := []struct {
+		name   string
+		image  *infrav1.Image
+		expect func(*GomegaWithT, *compute.ImageReference, error)
+	}{
+		{
+			name: "Should set the ID of a private Compute Gallery image version",
+			image: &infrav1.Image{
+				ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/galleries/my-gallery/images/my-image/versions/1.0.0"),
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/galleries/my-gallery/images/my-image/versions/1.0.0"),
+				}))
+			},
+		},
+		{
+			name: "Should set the community gallery image ID of a community gallery image version",
+			image: &infrav1.Image{
+				ID: to.StringPtr("/CommunityGalleries/my-gallery-1234/Images/my-image/Versions/1.0.0"),
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					CommunityGalleryImageID: to.StringPtr("/CommunityGalleries/my-gallery-1234/Images/my-image/Versions/1.0.0"),
+				}))
+			},
+		},
+		{
+			name: "Should set the shared gallery image ID of a direct shared gallery image version",
+			image: &infrav1.Image{
+				ID: to.StringPtr("/SharedGalleries/1234-my-gallery/Images/my-image/Versions/latest"),
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					SharedGalleryImageID: to.StringPtr("/SharedGalleries/1234-my-gallery/Images/my-image/Versions/latest"),
+				}))
+			},
+		},
+		{
+			name: "Should set the community gallery image ID of a community Compute Gallery image",
+			image: &infrav1.Image{
+				ComputeGallery: &infrav1.AzureComputeGalleryImage{
+					Gallery: "my-gallery-1234",
+					Name:    "my-image",
+					Version: "1.0.0",
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					CommunityGalleryImageID: to.StringPtr("/CommunityGalleries/my-gallery-1234/Images/my-image/Versions/1.0.0"),
+				}))
+			},
+		},
+		{
+			name: "Should set the shared gallery image ID of a direct shared Compute Gallery image",
+			image: &infrav1.Image{
+				ComputeGallery: &infrav1.AzureComputeGalleryImage{
+					Gallery:      "1234-my-gallery",
+					Name:         "my-image",
+					Version:      "latest",
+					DirectShared: true,
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					SharedGalleryImageID: to.StringPtr("/SharedGalleries/1234-my-gallery/Images/my-image/Versions/latest"),
+				}))
+			},
+		},
+		{
+			name: "Should set the ID of a private Compute Gallery image",
+			image: &infrav1.Image{
+				ComputeGallery: &infrav1.AzureComputeGalleryImage{
+					Gallery:        "my-gallery",
+					Name:           "my-image",
+					Version:        "1.0.0",
+					SubscriptionID: to.StringPtr("123"),
+					ResourceGroup:  to.StringPtr("my-rg"),
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/galleries/my-gallery/images/my-image/versions/1.0.0"),
+				}))
+			},
+		},
+		{
+			name: "Should set the ID of a private Compute Gallery image in another subscription",
+			image: &infrav1.Image{
+				ComputeGallery: &infrav1.AzureComputeGalleryImage{
+					Gallery:        "central-gallery",
+					Name:           "my-image",
+					Version:        "1.0.0",
+					SubscriptionID: to.StringPtr("other-sub-id"),
+					ResourceGroup:  to.StringPtr("central-rg"),
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					ID: to.StringPtr("/subscriptions/other-sub-id/resourceGroups/central-rg/providers/Microsoft.Compute/galleries/central-gallery/images/my-image/versions/1.0.0"),
+				}))
+			},
+		},
+		{
+			name: "Should set the ID of a Shared Image Gallery image in another subscription",
+			image: &infrav1.Image{
+				SharedGallery: &infrav1.AzureSharedGalleryImage{
+					SubscriptionID: "other-sub-id",
+					ResourceGroup:  "central-rg",
+					Gallery:        "central-gallery",
+					Name:           "my-image",
+					Version:        "1.0.0",
+				},
+			},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(result).To(Equal(&compute.ImageReference{
+					ID: to.StringPtr("/subscriptions/other-sub-id/resourceGroups/central-rg/providers/Microsoft.Compute/galleries/central-gallery/images/my-image/versions/1.0.0"),
+				}))
+			},
+		},
+		{
+			name:  "Should fail without image details",
+			image: &infrav1.Image{},
+			expect: func(g *GomegaWithT, result *compute.ImageReference, err error) {
+				g.Expect(err).To(MatchError("unable to convert image as no options set"))
+				g.Expect(result).To(BeNil())
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			g := NewGomegaWithT(t)
+			result, err := ImageToSDK(c.image)
+			c.expect(g, result, err)
+		})
+	}
+}
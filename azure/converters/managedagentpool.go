@@ -17,32 +17,49 @@ limitations under the License.
 package converters
 
 import (
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 )
 
 // AgentPoolToManagedClusterAgentPoolProfile converts a AgentPoolSpec to an Azure SDK ManagedClusterAgentPoolProfile used in managedcluster reconcile.
 func AgentPoolToManagedClusterAgentPoolProfile(pool azure.AgentPoolSpec) containerservice.ManagedClusterAgentPoolProfile {
 	return containerservice.ManagedClusterAgentPoolProfile{
-		Name:                &pool.Name,
-		VMSize:              &pool.SKU,
-		OsType:              containerservice.OSType(to.String(pool.OSType)),
-		OsDiskSizeGB:        &pool.OSDiskSizeGB,
-		Count:               &pool.Replicas,
-		Type:                containerservice.AgentPoolTypeVirtualMachineScaleSets,
-		OrchestratorVersion: pool.Version,
-		VnetSubnetID:        &pool.VnetSubnetID,
-		Mode:                containerservice.AgentPoolMode(pool.Mode),
-		EnableAutoScaling:   pool.EnableAutoScaling,
-		MaxCount:            pool.MaxCount,
-		MinCount:            pool.MinCount,
-		NodeTaints:          &pool.NodeTaints,
-		AvailabilityZones:   &pool.AvailabilityZones,
-		MaxPods:             pool.MaxPods,
-		OsDiskType:          containerservice.OSDiskType(to.String(pool.OsDiskType)),
-		NodeLabels:          pool.NodeLabels,
-		EnableUltraSSD:      pool.EnableUltraSSD,
+		Name:                   &pool.Name,
+		VMSize:                 &pool.SKU,
+		OsType:                 containerservice.OSType(to.String(pool.OSType)),
+		OsDiskSizeGB:           &pool.OSDiskSizeGB,
+		Count:                  &pool.Replicas,
+		Type:                   containerservice.AgentPoolTypeVirtualMachineScaleSets,
+		OrchestratorVersion:    pool.Version,
+		VnetSubnetID:           &pool.VnetSubnetID,
+		Mode:                   containerservice.AgentPoolMode(pool.Mode),
+		EnableAutoScaling:      pool.EnableAutoScaling,
+		MaxCount:               pool.MaxCount,
+		MinCount:               pool.MinCount,
+		NodeTaints:             &pool.NodeTaints,
+		AvailabilityZones:      &pool.AvailabilityZones,
+		MaxPods:                pool.MaxPods,
+		OsDiskType:             containerservice.OSDiskType(to.String(pool.OsDiskType)),
+		NodeLabels:             pool.NodeLabels,
+		EnableUltraSSD:         pool.EnableUltraSSD,
+		KubeletConfig:          kubeletConfigToContainerServiceKubeletConfig(pool.KubeletConfig),
+		LinuxOSConfig:          linuxOSConfigToContainerServiceLinuxOSConfig(pool.LinuxOSConfig),
+		ScaleSetPriority:       containerservice.ScaleSetPriority(to.String(pool.ScaleSetPriority)),
+		SpotMaxPrice:           pool.SpotMaxPrice,
+		EnableNodePublicIP:     pool.EnableNodePublicIP,
+		NodePublicIPPrefixID:   pool.NodePublicIPPrefixID,
+		UpgradeSettings:        maxSurgeToContainerServiceUpgradeSettings(pool.MaxSurge),
+		EnableFIPS:             pool.EnableFIPS,
+		OsSKU:                  osSKUToContainerServiceOSSKU(pool.OSSKU),
+		Tags:                   tagsToContainerServiceTags(pool.AdditionalTags),
+		GpuInstanceProfile:     containerservice.GPUInstanceProfile(pool.GPUInstanceProfile),
+		PodSubnetID:            pool.PodSubnetID,
+		EnableEncryptionAtHost: pool.EnableEncryptionAtHost,
+		CreationData:           creationDataToContainerServiceCreationData(pool.CreationData),
+		ScaleDownMode:          containerservice.ScaleDownMode(pool.ScaleDownMode),
+		WorkloadRuntime:        containerservice.WorkloadRuntime(pool.WorkloadRuntime),
 	}
 }
 
@@ -50,23 +67,133 @@ func AgentPoolToManagedClusterAgentPoolProfile(pool azure.AgentPoolSpec) contain
 func AgentPoolToContainerServiceAgentPool(pool azure.AgentPoolSpec) containerservice.AgentPool {
 	return containerservice.AgentPool{
 		ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
-			VMSize:              &pool.SKU,
-			OsType:              containerservice.OSType(to.String(pool.OSType)),
-			OsDiskSizeGB:        &pool.OSDiskSizeGB,
-			Count:               &pool.Replicas,
-			Type:                containerservice.AgentPoolTypeVirtualMachineScaleSets,
-			OrchestratorVersion: pool.Version,
-			VnetSubnetID:        &pool.VnetSubnetID,
-			Mode:                containerservice.AgentPoolMode(pool.Mode),
-			EnableAutoScaling:   pool.EnableAutoScaling,
-			MaxCount:            pool.MaxCount,
-			MinCount:            pool.MinCount,
-			NodeTaints:          &pool.NodeTaints,
-			AvailabilityZones:   &pool.AvailabilityZones,
-			MaxPods:             pool.MaxPods,
-			OsDiskType:          containerservice.OSDiskType(to.String(pool.OsDiskType)),
-			NodeLabels:          pool.NodeLabels,
-			EnableUltraSSD:      pool.EnableUltraSSD,
+			VMSize:                 &pool.SKU,
+			OsType:                 containerservice.OSType(to.String(pool.OSType)),
+			OsDiskSizeGB:           &pool.OSDiskSizeGB,
+			Count:                  &pool.Replicas,
+			Type:                   containerservice.AgentPoolTypeVirtualMachineScaleSets,
+			OrchestratorVersion:    pool.Version,
+			VnetSubnetID:           &pool.VnetSubnetID,
+			Mode:                   containerservice.AgentPoolMode(pool.Mode),
+			EnableAutoScaling:      pool.EnableAutoScaling,
+			MaxCount:               pool.MaxCount,
+			MinCount:               pool.MinCount,
+			NodeTaints:             &pool.NodeTaints,
+			AvailabilityZones:      &pool.AvailabilityZones,
+			MaxPods:                pool.MaxPods,
+			OsDiskType:             containerservice.OSDiskType(to.String(pool.OsDiskType)),
+			NodeLabels:             pool.NodeLabels,
+			EnableUltraSSD:         pool.EnableUltraSSD,
+			KubeletConfig:          kubeletConfigToContainerServiceKubeletConfig(pool.KubeletConfig),
+			LinuxOSConfig:          linuxOSConfigToContainerServiceLinuxOSConfig(pool.LinuxOSConfig),
+			ScaleSetPriority:       containerservice.ScaleSetPriority(to.String(pool.ScaleSetPriority)),
+			SpotMaxPrice:           pool.SpotMaxPrice,
+			EnableNodePublicIP:     pool.EnableNodePublicIP,
+			NodePublicIPPrefixID:   pool.NodePublicIPPrefixID,
+			UpgradeSettings:        maxSurgeToContainerServiceUpgradeSettings(pool.MaxSurge),
+			EnableFIPS:             pool.EnableFIPS,
+			OsSKU:                  osSKUToContainerServiceOSSKU(pool.OSSKU),
+			Tags:                   tagsToContainerServiceTags(pool.AdditionalTags),
+			GpuInstanceProfile:     containerservice.GPUInstanceProfile(pool.GPUInstanceProfile),
+			PodSubnetID:            pool.PodSubnetID,
+			EnableEncryptionAtHost: pool.EnableEncryptionAtHost,
+			CreationData:           creationDataToContainerServiceCreationData(pool.CreationData),
+			ScaleDownMode:          containerservice.ScaleDownMode(pool.ScaleDownMode),
+			WorkloadRuntime:        containerservice.WorkloadRuntime(pool.WorkloadRuntime),
 		},
 	}
 }
+
+// kubeletConfigToContainerServiceKubeletConfig converts a KubeletConfig to an Azure SDK KubeletConfig.
+func kubeletConfigToContainerServiceKubeletConfig(kubeletConfig *azure.KubeletConfig) *containerservice.KubeletConfig {
+	if kubeletConfig == nil {
+		return nil
+	}
+
+	result := &containerservice.KubeletConfig{
+		CPUManagerPolicy:      kubeletConfig.CPUManagerPolicy,
+		CPUCfsQuota:           kubeletConfig.CPUCfsQuota,
+		CPUCfsQuotaPeriod:     kubeletConfig.CPUCfsQuotaPeriod,
+		ImageGcHighThreshold:  kubeletConfig.ImageGcHighThreshold,
+		ImageGcLowThreshold:   kubeletConfig.ImageGcLowThreshold,
+		TopologyManagerPolicy: kubeletConfig.TopologyManagerPolicy,
+		FailSwapOn:            kubeletConfig.FailSwapOn,
+		ContainerLogMaxSizeMB: kubeletConfig.ContainerLogMaxSizeMB,
+		ContainerLogMaxFiles:  kubeletConfig.ContainerLogMaxFiles,
+		PodMaxPids:            kubeletConfig.PodMaxPids,
+	}
+	if len(kubeletConfig.AllowedUnsafeSysctls) > 0 {
+		result.AllowedUnsafeSysctls = &kubeletConfig.AllowedUnsafeSysctls
+	}
+
+	return result
+}
+
+// linuxOSConfigToContainerServiceLinuxOSConfig converts a LinuxOSConfig to an Azure SDK LinuxOSConfig.
+func linuxOSConfigToContainerServiceLinuxOSConfig(linuxOSConfig *azure.LinuxOSConfig) *containerservice.LinuxOSConfig {
+	if linuxOSConfig == nil {
+		return nil
+	}
+
+	result := &containerservice.LinuxOSConfig{
+		SwapFileSizeMB:             linuxOSConfig.SwapFileSizeMB,
+		TransparentHugePageDefrag:  linuxOSConfig.TransparentHugePageDefrag,
+		TransparentHugePageEnabled: linuxOSConfig.TransparentHugePageEnabled,
+	}
+	if sysctls := linuxOSConfig.Sysctls; sysctls != nil {
+		result.Sysctls = &containerservice.SysctlConfig{
+			FsFileMax:                  sysctls.FsFileMax,
+			FsInotifyMaxUserWatches:    sysctls.FsInotifyMaxUserWatches,
+			KernelThreadsMax:           sysctls.KernelThreadsMax,
+			NetCoreSomaxconn:           sysctls.NetCoreSomaxconn,
+			NetIpv4IPLocalPortRange:    sysctls.NetIpv4IPLocalPortRange,
+			NetIpv4TCPTwReuse:          sysctls.NetIpv4TCPTwReuse,
+			NetNetfilterNfConntrackMax: sysctls.NetNetfilterNfConntrackMax,
+			VMMaxMapCount:              sysctls.VMMaxMapCount,
+			VMSwappiness:               sysctls.VMSwappiness,
+			VMVfsCachePressure:         sysctls.VMVfsCachePressure,
+		}
+	}
+
+	return result
+}
+
+// maxSurgeToContainerServiceUpgradeSettings converts a max surge value to Azure SDK AgentPoolUpgradeSettings.
+func maxSurgeToContainerServiceUpgradeSettings(maxSurge string) *containerservice.AgentPoolUpgradeSettings {
+	if maxSurge == "" {
+		return nil
+	}
+
+	return &containerservice.AgentPoolUpgradeSettings{
+		MaxSurge: to.StringPtr(maxSurge),
+	}
+}
+
+// osSKUToContainerServiceOSSKU converts an OS SKU to an Azure SDK OSSKU. AzureLinux is the new name of CBLMariner,
+// which is the only name known to the AKS API version in use.
+func osSKUToContainerServiceOSSKU(osSKU string) containerservice.OSSKU {
+	if osSKU == "AzureLinux" {
+		return containerservice.OSSKUCBLMariner
+	}
+	return containerservice.OSSKU(osSKU)
+}
+
+// creationDataToContainerServiceCreationData converts a CreationData to an Azure SDK CreationData.
+func creationDataToContainerServiceCreationData(creationData *azure.CreationData) *containerservice.CreationData {
+	if creationData == nil {
+		return nil
+	}
+
+	return &containerservice.CreationData{
+		SourceResourceID: to.StringPtr(creationData.SourceResourceID),
+	}
+}
+
+// tagsToContainerServiceTags converts tags to Azure SDK agent pool tags.
+func tagsToContainerServiceTags(tags infrav1.Tags) map[string]*string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return TagsToMap(tags)
+}
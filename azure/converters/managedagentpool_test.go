@@ -19,9 +19,10 @@ package converters
 import (
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
 	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 )
 
@@ -145,6 +146,182 @@ func Test_AgentPoolToAgentPoolToContainerServiceAgentPool(t *testing.T) {
 				}))
 			},
 		},
+		{
+			name: "Should set kubelet and Linux OS config",
+			pool: azure.AgentPoolSpec{
+				Name: "agentpool1",
+				KubeletConfig: &azure.KubeletConfig{
+					CPUManagerPolicy:     to.StringPtr("static"),
+					AllowedUnsafeSysctls: []string{"net.*"},
+				},
+				LinuxOSConfig: &azure.LinuxOSConfig{
+					TransparentHugePageEnabled: to.StringPtr("never"),
+					Sysctls: &azure.SysctlConfig{
+						VMMaxMapCount: to.Int32Ptr(262144),
+					},
+				},
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.KubeletConfig).To(Equal(&containerservice.KubeletConfig{
+					CPUManagerPolicy:     to.StringPtr("static"),
+					AllowedUnsafeSysctls: to.StringSlicePtr([]string{"net.*"}),
+				}))
+				g.Expect(result.LinuxOSConfig).To(Equal(&containerservice.LinuxOSConfig{
+					TransparentHugePageEnabled: to.StringPtr("never"),
+					Sysctls: &containerservice.SysctlConfig{
+						VMMaxMapCount: to.Int32Ptr(262144),
+					},
+				}))
+			},
+		},
+		{
+			name: "Should set spot priority and max price",
+			pool: azure.AgentPoolSpec{
+				Name:             "agentpool1",
+				ScaleSetPriority: to.StringPtr("Spot"),
+				SpotMaxPrice:     to.Float64Ptr(-1),
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.ScaleSetPriority).To(Equal(containerservice.ScaleSetPrioritySpot))
+				g.Expect(result.SpotMaxPrice).To(Equal(to.Float64Ptr(-1)))
+			},
+		},
+		{
+			name: "Should map AzureLinux OS SKU to CBLMariner",
+			pool: azure.AgentPoolSpec{
+				Name:   "agentpool1",
+				OSType: to.StringPtr(azure.LinuxOS),
+				OSSKU:  "AzureLinux",
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.OsSKU).To(Equal(containerservice.OSSKUCBLMariner))
+			},
+		},
+		{
+			name: "Should set Ubuntu OS SKU",
+			pool: azure.AgentPoolSpec{
+				Name:   "agentpool1",
+				OSType: to.StringPtr(azure.LinuxOS),
+				OSSKU:  "Ubuntu",
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.OsSKU).To(Equal(containerservice.OSSKUUbuntu))
+			},
+		},
+		{
+			name: "Should set GPU instance profile",
+			pool: azure.AgentPoolSpec{
+				Name:               "agentpool1",
+				SKU:                "Standard_ND96asr_v4",
+				GPUInstanceProfile: "MIG3g",
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.GpuInstanceProfile).To(Equal(containerservice.GPUInstanceProfileMIG3g))
+			},
+		},
+		{
+			name: "Should set pod subnet",
+			pool: azure.AgentPoolSpec{
+				Name:         "agentpool1",
+				VnetSubnetID: "node-subnet",
+				PodSubnetID:  to.StringPtr("pod-subnet"),
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.VnetSubnetID).To(Equal(to.StringPtr("node-subnet")))
+				g.Expect(result.PodSubnetID).To(Equal(to.StringPtr("pod-subnet")))
+			},
+		},
+		{
+			name: "Should set encryption at host",
+			pool: azure.AgentPoolSpec{
+				Name:                   "agentpool1",
+				EnableEncryptionAtHost: to.BoolPtr(true),
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.EnableEncryptionAtHost).To(Equal(to.BoolPtr(true)))
+			},
+		},
+		{
+			name: "Should set additional tags",
+			pool: azure.AgentPoolSpec{
+				Name:           "agentpool1",
+				AdditionalTags: infrav1.Tags{"env": "prod"},
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.Tags).To(Equal(map[string]*string{"env": to.StringPtr("prod")}))
+			},
+		},
+		{
+			name: "Should set max surge upgrade setting",
+			pool: azure.AgentPoolSpec{
+				Name:     "agentpool1",
+				MaxSurge: "33%",
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.UpgradeSettings).To(Equal(&containerservice.AgentPoolUpgradeSettings{
+					MaxSurge: to.StringPtr("33%"),
+				}))
+			},
+		},
+		{
+			name: "Should set node public IP and prefix",
+			pool: azure.AgentPoolSpec{
+				Name:                 "agentpool1",
+				EnableNodePublicIP:   to.BoolPtr(true),
+				NodePublicIPPrefixID: to.StringPtr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix"),
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.EnableNodePublicIP).To(Equal(to.BoolPtr(true)))
+				g.Expect(result.NodePublicIPPrefixID).To(Equal(to.StringPtr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix")))
+			},
+		},
+		{
+			name: "Should set scale-down mode",
+			pool: azure.AgentPoolSpec{
+				Name:          "agentpool1",
+				ScaleDownMode: "Deallocate",
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.ScaleDownMode).To(Equal(containerservice.ScaleDownModeDeallocate))
+			},
+		},
+		{
+			name: "Should set workload runtime",
+			pool: azure.AgentPoolSpec{
+				Name:            "agentpool1",
+				WorkloadRuntime: "WasmWasi",
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.WorkloadRuntime).To(Equal(containerservice.WorkloadRuntimeWasmWasi))
+			},
+		},
+		{
+			name: "Should set creation data of a snapshot",
+			pool: azure.AgentPoolSpec{
+				Name: "agentpool1",
+				CreationData: &azure.CreationData{
+					SourceResourceID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/my-snapshot",
+				},
+			},
+
+			expect: func(g *GomegaWithT, result containerservice.AgentPool) {
+				g.Expect(result.CreationData).To(Equal(&containerservice.CreationData{
+					SourceResourceID: to.StringPtr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/my-snapshot"),
+				}))
+			},
+		},
 	}
 
 	for _, c := range cases {
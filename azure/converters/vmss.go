@@ -17,6 +17,8 @@ limitations under the License.
 package converters
 
 import (
+	"sort"
+
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
@@ -26,13 +28,15 @@ import (
 // SDKToVMSS converts an Azure SDK VirtualMachineScaleSet to the AzureMachinePool type.
 func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.VirtualMachineScaleSetVM) *azure.VMSS {
 	vmss := &azure.VMSS{
-		ID:    to.String(sdkvmss.ID),
-		Name:  to.String(sdkvmss.Name),
-		State: infrav1.ProvisioningState(to.String(sdkvmss.ProvisioningState)),
+		ID:       to.String(sdkvmss.ID),
+		UniqueID: to.String(sdkvmss.UniqueID),
+		Name:     to.String(sdkvmss.Name),
+		State:    infrav1.ProvisioningState(to.String(sdkvmss.ProvisioningState)),
 	}
 
 	if sdkvmss.Sku != nil {
 		vmss.Sku = to.String(sdkvmss.Sku.Name)
+		vmss.Tier = to.String(sdkvmss.Sku.Tier)
 		vmss.Capacity = to.Int64(sdkvmss.Sku.Capacity)
 	}
 
@@ -40,6 +44,16 @@ func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.Vi
 		vmss.Zones = to.StringSlice(sdkvmss.Zones)
 	}
 
+	if sdkvmss.Identity != nil {
+		// the identity types of the SDK and the API share their values
+		vmss.Identity = infrav1.VMIdentity(sdkvmss.Identity.Type)
+		for id := range sdkvmss.Identity.UserAssignedIdentities {
+			vmss.UserAssignedIdentities = append(vmss.UserAssignedIdentities, id)
+		}
+		// the identities are sorted, since the order of a map is random
+		sort.Strings(vmss.UserAssignedIdentities)
+	}
+
 	if len(sdkvmss.Tags) > 0 {
 		vmss.Tags = MapToTags(sdkvmss.Tags)
 	}
@@ -58,6 +72,32 @@ func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.Vi
 		vmss.Image = SDKImageToImage(imageRef, sdkvmss.Plan != nil)
 	}
 
+	if sdkvmss.VirtualMachineProfile != nil &&
+		sdkvmss.VirtualMachineProfile.NetworkProfile != nil &&
+		sdkvmss.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations != nil {
+		for _, config := range *sdkvmss.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations {
+			if config.VirtualMachineScaleSetNetworkConfigurationProperties != nil && config.DNSSettings != nil &&
+				config.DNSSettings.DNSServers != nil && len(*config.DNSSettings.DNSServers) > 0 {
+				vmss.DNSServers = to.StringSlice(config.DNSSettings.DNSServers)
+				break
+			}
+		}
+	}
+
+	if sdkvmss.VirtualMachineProfile != nil &&
+		sdkvmss.VirtualMachineProfile.ExtensionProfile != nil &&
+		sdkvmss.VirtualMachineProfile.ExtensionProfile.Extensions != nil {
+		for _, extension := range *sdkvmss.VirtualMachineProfile.ExtensionProfile.Extensions {
+			if extension.Name == nil || extension.VirtualMachineScaleSetExtensionProperties == nil || extension.ForceUpdateTag == nil {
+				continue
+			}
+			if vmss.ExtensionForceUpdateTags == nil {
+				vmss.ExtensionForceUpdateTags = make(map[string]string, len(*sdkvmss.VirtualMachineProfile.ExtensionProfile.Extensions))
+			}
+			vmss.ExtensionForceUpdateTags[*extension.Name] = *extension.ForceUpdateTag
+		}
+	}
+
 	return vmss
 }
 
@@ -72,6 +112,8 @@ func SDKToVMSSVM(sdkInstance compute.VirtualMachineScaleSetVM) *azure.VMSSVM {
 		return &instance
 	}
 
+	instance.VMID = to.String(sdkInstance.VMID)
+
 	instance.State = infrav1.Creating
 	if sdkInstance.ProvisioningState != nil {
 		instance.State = infrav1.ProvisioningState(to.String(sdkInstance.ProvisioningState))
@@ -91,6 +133,18 @@ func SDKToVMSSVM(sdkInstance compute.VirtualMachineScaleSetVM) *azure.VMSSVM {
 		instance.AvailabilityZone = to.StringSlice(sdkInstance.Zones)[0]
 	}
 
+	if sdkInstance.Resources != nil {
+		for _, extension := range *sdkInstance.Resources {
+			if extension.Name == nil || extension.VirtualMachineExtensionProperties == nil || extension.ProvisioningState == nil {
+				continue
+			}
+			if instance.ExtensionStates == nil {
+				instance.ExtensionStates = make(map[string]infrav1.ProvisioningState, len(*sdkInstance.Resources))
+			}
+			instance.ExtensionStates[*extension.Name] = infrav1.ProvisioningState(*extension.ProvisioningState)
+		}
+	}
+
 	return &instance
 }
 
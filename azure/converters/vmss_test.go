@@ -23,6 +23,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/converters"
 )
@@ -51,9 +52,13 @@ func Test_SDKToVMSS(t *testing.T) {
 						Name:     to.StringPtr("vmssName"),
 						Location: to.StringPtr("westus2"),
 						Tags:     tags,
+						Identity: &compute.VirtualMachineScaleSetIdentity{
+							Type: compute.ResourceIdentityTypeSystemAssigned,
+						},
 						VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
 							SinglePlacementGroup: to.BoolPtr(false),
 							ProvisioningState:    to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+							UniqueID:             to.StringPtr("vmssUniqueID"),
 						},
 					},
 					[]compute.VirtualMachineScaleSetVM{
@@ -63,6 +68,7 @@ func Test_SDKToVMSS(t *testing.T) {
 							Name:       to.StringPtr("vm0"),
 							Zones:      to.StringSlicePtr([]string{"zone0"}),
 							VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
+								VMID:              to.StringPtr("vm-id-0"),
 								ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
 								OsProfile: &compute.OSProfile{
 									ComputerName: to.StringPtr("instance-000000"),
@@ -75,6 +81,7 @@ func Test_SDKToVMSS(t *testing.T) {
 							Name:       to.StringPtr("vm1"),
 							Zones:      to.StringSlicePtr([]string{"zone1"}),
 							VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
+								VMID:              to.StringPtr("vm-id-1"),
 								ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
 								OsProfile: &compute.OSProfile{
 									ComputerName: to.StringPtr("instance-000001"),
@@ -86,11 +93,14 @@ func Test_SDKToVMSS(t *testing.T) {
 			Expect: func(g *gomega.GomegaWithT, actual *azure.VMSS) {
 				expected := azure.VMSS{
 					ID:       "vmssID",
+					UniqueID: "vmssUniqueID",
 					Name:     "vmssName",
 					Sku:      "skuName",
+					Tier:     "skuTier",
 					Capacity: 2,
 					Zones:    []string{"zone0", "zone1"},
 					State:    "Succeeded",
+					Identity: infrav1.VMIdentitySystemAssigned,
 					Tags: map[string]string{
 						"foo": "bazz",
 					},
@@ -101,6 +111,7 @@ func Test_SDKToVMSS(t *testing.T) {
 					expected.Instances[i] = azure.VMSSVM{
 						ID:               fmt.Sprintf("vm/%d", i),
 						InstanceID:       fmt.Sprintf("%d", i),
+						VMID:             fmt.Sprintf("vm-id-%d", i),
 						Name:             fmt.Sprintf("instance-00000%d", i),
 						AvailabilityZone: fmt.Sprintf("zone%d", i),
 						State:            "Succeeded",
@@ -122,3 +133,72 @@ func Test_SDKToVMSS(t *testing.T) {
 		})
 	}
 }
+
+func Test_SDKToVMSSVMExtensionStates(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := compute.VirtualMachineScaleSetVM{
+		InstanceID: to.StringPtr("0"),
+		ID:         to.StringPtr("vm/0"),
+		VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
+			VMID:              to.StringPtr("vm-id-0"),
+			ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+		},
+		Resources: &[]compute.VirtualMachineExtension{
+			{
+				Name: to.StringPtr("CAPZ.Linux.Bootstrapping"),
+				VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
+					ProvisioningState: to.StringPtr("Failed"),
+				},
+			},
+			{
+				Name: to.StringPtr("other"),
+				VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+				},
+			},
+			{
+				Name: to.StringPtr("without-properties"),
+			},
+		},
+	}
+
+	actual := converters.SDKToVMSSVM(instance)
+	g.Expect(actual.ExtensionStates).To(gomega.Equal(map[string]infrav1.ProvisioningState{
+		"CAPZ.Linux.Bootstrapping": infrav1.Failed,
+		"other":                    infrav1.Succeeded,
+	}))
+}
+
+func Test_SDKToVMSSExtensionForceUpdateTags(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	vmss := compute.VirtualMachineScaleSet{
+		ID:   to.StringPtr("vmssID"),
+		Name: to.StringPtr("vmssName"),
+		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				ExtensionProfile: &compute.VirtualMachineScaleSetExtensionProfile{
+					Extensions: &[]compute.VirtualMachineScaleSetExtension{
+						{
+							Name: to.StringPtr("CAPZ.Linux.Bootstrapping"),
+							VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{
+								ForceUpdateTag: to.StringPtr("e66050ff"),
+							},
+						},
+						{
+							Name: to.StringPtr("without-force-update-tag"),
+							VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{},
+						},
+						{
+							Name: to.StringPtr("without-properties"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	actual := converters.SDKToVMSS(vmss, nil)
+	g.Expect(actual.ExtensionForceUpdateTags).To(gomega.Equal(map[string]string{
+		"CAPZ.Linux.Bootstrapping": "e66050ff",
+	}))
+}
@@ -33,6 +33,11 @@ const (
 	DefaultAKSUserName = "azureuser"
 )
 
+const (
+	// DefaultVMSSTier is the default tier of a Virtual Machine Scale Set SKU.
+	DefaultVMSSTier = "Standard"
+)
+
 const (
 	// DefaultImageOfferID is the default Azure Marketplace offer ID.
 	DefaultImageOfferID = "capi"
@@ -276,6 +281,11 @@ func NATRuleID(subscriptionID, resourceGroup, loadBalancerName, natRuleName stri
 	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/loadBalancers/%s/inboundNatRules/%s", subscriptionID, resourceGroup, loadBalancerName, natRuleName)
 }
 
+// InboundNATPoolID returns the azure resource ID for a inbound NAT pool.
+func InboundNATPoolID(subscriptionID, resourceGroup, loadBalancerName, natPoolName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/loadBalancers/%s/inboundNatPools/%s", subscriptionID, resourceGroup, loadBalancerName, natPoolName)
+}
+
 // AvailabilitySetID returns the azure resource ID for a given availability set.
 func AvailabilitySetID(subscriptionID, resourceGroup, availabilitySetName string) string {
 	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/availabilitySets/%s", subscriptionID, resourceGroup, availabilitySetName)
@@ -316,6 +326,27 @@ func GetBootstrappingVMExtension(osType string, cloud string, vmName string) *Ex
 	return nil
 }
 
+// GetCustomBootstrappingVMExtension returns the CAPZ Bootstrapping VM extension of the given OS with its type, publisher
+// and version overridden where they are set, e.g. for environments which don't provide the built-in extension. The type
+// is used as the name of the extension as well.
+func GetCustomBootstrappingVMExtension(osType string, vmName string, extensionType string, publisher string, version string) *ExtensionSpec {
+	extension := GetBootstrappingVMExtension(osType, azure.PublicCloud.Name, vmName)
+	if extension == nil {
+		return nil
+	}
+
+	if extensionType != "" {
+		extension.Name = extensionType
+	}
+	if publisher != "" {
+		extension.Publisher = publisher
+	}
+	if version != "" {
+		extension.Version = version
+	}
+	return extension
+}
+
 // UserAgent specifies a string to append to the agent identifier.
 func UserAgent() string {
 	return fmt.Sprintf("cluster-api-provider-azure/%s", version.Get().String())
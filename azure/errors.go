@@ -47,6 +47,20 @@ func ResourceConflict(err error) bool {
 	return errors.As(err, &derr) && derr.StatusCode == 409
 }
 
+// CorrelationID returns the ID Azure correlates the requests of an operation with from the response of a failed request,
+// or the ID of the request itself if the correlation ID is missing. An empty string is returned if the error doesn't
+// carry a response.
+func CorrelationID(err error) string {
+	derr := autorest.DetailedError{}
+	if !errors.As(err, &derr) || derr.Response == nil {
+		return ""
+	}
+	if id := derr.Response.Header.Get("x-ms-correlation-request-id"); id != "" {
+		return id
+	}
+	return derr.Response.Header.Get("x-ms-request-id")
+}
+
 // VMDeletedError is returned when a virtual machine is deleted outside of capz.
 type VMDeletedError struct {
 	ProviderID string
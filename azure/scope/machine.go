@@ -324,7 +324,8 @@ func (m *MachineScope) RoleAssignmentResourceType() string {
 // HasSystemAssignedIdentity returns true if the azure machine has
 // system assigned identity.
 func (m *MachineScope) HasSystemAssignedIdentity() bool {
-	return m.AzureMachine.Spec.Identity == infrav1.VMIdentitySystemAssigned
+	return m.AzureMachine.Spec.Identity == infrav1.VMIdentitySystemAssigned ||
+		m.AzureMachine.Spec.Identity == infrav1.VMIdentitySystemAndUserAssigned
 }
 
 // VMExtensionSpecs returns the VM extension specs.
@@ -17,16 +17,26 @@ limitations under the License.
 package scope
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/pointer"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
@@ -52,6 +62,22 @@ import (
 // added here to avoid a circular dependency.
 const ScalesetsServiceName = "scalesets"
 
+const (
+	// maxCustomDataLength is the maximum length in bytes of the custom data of a Virtual Machine before base64 encoding.
+	maxCustomDataLength = 65535
+
+	// cloudInitBoundary is the boundary of the MIME multipart archive of merged cloud-init.
+	cloudInitBoundary = "==CAPZ-CLOUD-INIT-BOUNDARY=="
+
+	// defaultCloudInitMergeType appends lists and merges dictionaries of additional cloud-config fragments instead of
+	// replacing them.
+	defaultCloudInitMergeType = "list(append)+dict(no_replace,recurse_list)+str()"
+
+	// defaultMachineCreationConcurrency is the number of AzureMachinePoolMachines created concurrently if no
+	// concurrency is configured.
+	defaultMachineCreationConcurrency = 10
+)
+
 type (
 	// MachinePoolScopeParams defines the input parameters used to create a new MachinePoolScope.
 	MachinePoolScopeParams struct {
@@ -59,16 +85,29 @@ type (
 		MachinePool      *expv1.MachinePool
 		AzureMachinePool *infrav1exp.AzureMachinePool
 		ClusterScope     azure.ClusterScoper
+
+		// MachineCreationConcurrency is the maximum number of AzureMachinePoolMachines created concurrently. Defaults
+		// to defaultMachineCreationConcurrency if not positive.
+		MachineCreationConcurrency int
 	}
 
 	// MachinePoolScope defines a scope defined around a machine pool and its cluster.
 	MachinePoolScope struct {
 		azure.ClusterScoper
-		AzureMachinePool *infrav1exp.AzureMachinePool
-		MachinePool      *expv1.MachinePool
-		client           client.Client
-		patchHelper      *patch.Helper
-		vmssState        *azure.VMSS
+		AzureMachinePool           *infrav1exp.AzureMachinePool
+		MachinePool                *expv1.MachinePool
+		client                     client.Client
+		patchHelper                *patch.Helper
+		vmssState                  *azure.VMSS
+		machineCreationConcurrency int
+		// imageVersionResolver resolves the version "latest" of marketplace images. The VM images service is used if
+		// nil.
+		imageVersionResolver imageVersionResolver
+	}
+
+	// imageVersionResolver resolves the concrete version of a marketplace image with the version "latest".
+	imageVersionResolver interface {
+		GetLatestMarketplaceImageVersion(ctx context.Context, location, publisher, offer, sku string) (string, error)
 	}
 
 	// NodeStatus represents the status of a Kubernetes node.
@@ -98,37 +137,145 @@ func NewMachinePoolScope(params MachinePoolScopeParams) (*MachinePoolScope, erro
 		return nil, errors.Wrap(err, "failed to init patch helper")
 	}
 
+	machineCreationConcurrency := params.MachineCreationConcurrency
+	if machineCreationConcurrency <= 0 {
+		machineCreationConcurrency = defaultMachineCreationConcurrency
+	}
+
 	return &MachinePoolScope{
-		client:           params.Client,
-		MachinePool:      params.MachinePool,
-		AzureMachinePool: params.AzureMachinePool,
-		patchHelper:      helper,
-		ClusterScoper:    params.ClusterScope,
+		client:                     params.Client,
+		MachinePool:                params.MachinePool,
+		AzureMachinePool:           params.AzureMachinePool,
+		patchHelper:                helper,
+		ClusterScoper:              params.ClusterScope,
+		machineCreationConcurrency: machineCreationConcurrency,
 	}, nil
 }
 
 // ScaleSetSpec returns the scale set spec.
 func (m *MachinePoolScope) ScaleSetSpec() azure.ScaleSetSpec {
-	return azure.ScaleSetSpec{
+	spec := azure.ScaleSetSpec{
 		Name:                         m.Name(),
+		OrchestrationMode:            m.OrchestrationMode(),
 		Size:                         m.AzureMachinePool.Spec.Template.VMSize,
-		Capacity:                     int64(to.Int32(m.MachinePool.Spec.Replicas)),
+		Capacity:                     int64(m.DesiredReplicas()),
 		SSHKeyData:                   m.AzureMachinePool.Spec.Template.SSHPublicKey,
+		SSHAuthorizedKeysPath:        m.AzureMachinePool.Spec.Template.SSHAuthorizedKeysPath,
 		OSDisk:                       m.AzureMachinePool.Spec.Template.OSDisk,
 		DataDisks:                    m.AzureMachinePool.Spec.Template.DataDisks,
 		SubnetName:                   m.AzureMachinePool.Spec.Template.SubnetName,
 		VNetName:                     m.Vnet().Name,
 		VNetResourceGroup:            m.Vnet().ResourceGroup,
-		PublicLBName:                 m.OutboundLBName(infrav1.Node),
-		PublicLBAddressPoolName:      azure.GenerateOutboundBackendAddressPoolName(m.OutboundLBName(infrav1.Node)),
 		AcceleratedNetworking:        m.AzureMachinePool.Spec.Template.AcceleratedNetworking,
 		Identity:                     m.AzureMachinePool.Spec.Identity,
 		UserAssignedIdentities:       m.AzureMachinePool.Spec.UserAssignedIdentities,
 		SecurityProfile:              m.AzureMachinePool.Spec.Template.SecurityProfile,
 		SpotVMOptions:                m.AzureMachinePool.Spec.Template.SpotVMOptions,
 		FailureDomains:               m.MachinePool.Spec.FailureDomains,
+		PlatformFaultDomainCount:     m.AzureMachinePool.Spec.PlatformFaultDomainCount,
 		TerminateNotificationTimeout: m.AzureMachinePool.Spec.Template.TerminateNotificationTimeout,
+		WindowsConfiguration:         m.WindowsConfiguration(),
+		Secrets:                      m.Secrets(),
+		ReplicasManagedExternally:    m.ReplicasManagedExternally(),
+		VerifyImagePlanTerms:         m.AzureMachinePool.GetAnnotations()[azure.VerifyImagePlanTermsAnnotation] == "true",
+		OperationTimeout:             m.OperationTimeout(),
+		CheckQuotaBeforeSurge:        m.AzureMachinePool.GetAnnotations()[azure.CheckQuotaBeforeSurgeAnnotation] == "true",
+		RollOnBootstrapDataChanges:   m.AzureMachinePool.GetAnnotations()[azure.RollOnBootstrapDataChangesAnnotation] == "true",
+		BootstrapDataHash:            m.AzureMachinePool.GetAnnotations()[azure.BootstrapDataHashAnnotation],
+		AutoAssignDataDiskLuns:       m.AzureMachinePool.GetAnnotations()[azure.AutoAssignDataDiskLunsAnnotation] == "true",
+		ResourceGroup:                m.AzureMachinePool.Spec.ResourceGroup,
+	}
+
+	if spec.OrchestrationMode == azure.AvailabilitySetOrchestrationMode {
+		spec.AvailabilitySetName = azure.GenerateAvailabilitySetName(m.ClusterName(), m.Name())
+	}
+
+	if linuxConfig := m.AzureMachinePool.Spec.Template.LinuxConfiguration; linuxConfig != nil {
+		spec.DisablePasswordAuthentication = linuxConfig.DisablePasswordAuthentication
+	}
+
+	if !m.AzureMachinePool.Spec.OutboundLBDisabled {
+		spec.PublicLBName = m.OutboundLBName(infrav1.Node)
+		spec.PublicLBAddressPoolName = azure.GenerateOutboundBackendAddressPoolName(m.OutboundLBName(infrav1.Node))
+	}
+
+	return spec
+}
+
+// ScaleSetResourceGroup returns the resource group of the Virtual Machine Scale Set, which defaults to the resource
+// group of the cluster.
+func (m *MachinePoolScope) ScaleSetResourceGroup() string {
+	if m.AzureMachinePool.Spec.ResourceGroup != "" {
+		return m.AzureMachinePool.Spec.ResourceGroup
+	}
+	return m.ResourceGroup()
+}
+
+// OperationTimeout returns the maximum age of a long running operation on the scale set as set by the AzureMachinePool
+// annotation, or 0 to use the default timeout if the annotation is absent or invalid.
+func (m *MachinePoolScope) OperationTimeout() time.Duration {
+	timeout, err := time.ParseDuration(m.AzureMachinePool.GetAnnotations()[azure.VMSSOperationTimeoutAnnotation])
+	if err != nil || timeout <= 0 {
+		return 0
+	}
+	return timeout
+}
+
+// WindowsConfiguration returns the operating system settings of Windows Virtual Machines, or nil if none are set.
+func (m *MachinePoolScope) WindowsConfiguration() *azure.WindowsConfiguration {
+	windowsConfig := m.AzureMachinePool.Spec.Template.WindowsConfiguration
+	if windowsConfig == nil {
+		return nil
 	}
+
+	config := &azure.WindowsConfiguration{
+		TimeZone: windowsConfig.TimeZone,
+	}
+	for _, content := range windowsConfig.AdditionalUnattendContent {
+		config.AdditionalUnattendContent = append(config.AdditionalUnattendContent, azure.AdditionalUnattendContent{
+			SettingName: content.SettingName,
+			Content:     content.Content,
+		})
+	}
+	if windowsConfig.WinRM != nil {
+		for _, listener := range windowsConfig.WinRM.Listeners {
+			config.WinRMListeners = append(config.WinRMListeners, azure.WinRMListener{
+				Protocol:       string(listener.Protocol),
+				CertificateURL: listener.CertificateURL,
+				KeyVaultID:     listener.KeyVaultID,
+			})
+		}
+	}
+
+	return config
+}
+
+// Secrets returns the certificates from Azure Key Vault which are installed on the Virtual Machines.
+func (m *MachinePoolScope) Secrets() []azure.VaultSecretGroup {
+	var secrets []azure.VaultSecretGroup
+	for _, group := range m.AzureMachinePool.Spec.Template.Secrets {
+		secret := azure.VaultSecretGroup{
+			SourceVaultID: group.SourceVaultID,
+		}
+		for _, certificate := range group.VaultCertificates {
+			secret.VaultCertificates = append(secret.VaultCertificates, azure.VaultCertificate{
+				CertificateURL:   certificate.CertificateURL,
+				CertificateStore: certificate.CertificateStore,
+			})
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets
+}
+
+// OrchestrationMode returns how the machines of the machine pool are orchestrated. Defaults to a Virtual Machine
+// Scale Set.
+func (m *MachinePoolScope) OrchestrationMode() azure.MachinePoolOrchestrationMode {
+	if m.AzureMachinePool.Spec.OrchestrationMode == infrav1exp.AvailabilitySetOrchestrationMode {
+		return azure.AvailabilitySetOrchestrationMode
+	}
+	return azure.ScaleSetOrchestrationMode
 }
 
 // Name returns the Azure Machine Pool Name.
@@ -183,11 +330,21 @@ func (m *MachinePoolScope) NeedsRequeue() bool {
 	return !(state != nil && infrav1.IsTerminalProvisioningState(*state) && desiredMatchesActual)
 }
 
-// DesiredReplicas returns the replica count on machine pool or 0 if machine pool replicas is nil.
+// DesiredReplicas returns the replica count on machine pool or 0 if machine pool replicas is nil. If the replicas are
+// managed by an external autoscaler, the current capacity of the VMSS is returned instead, once it is known.
 func (m MachinePoolScope) DesiredReplicas() int32 {
+	if m.ReplicasManagedExternally() && m.vmssState != nil {
+		return int32(m.vmssState.Capacity)
+	}
 	return to.Int32(m.MachinePool.Spec.Replicas)
 }
 
+// ReplicasManagedExternally returns true if the replicas of the machine pool are managed by an external autoscaler.
+func (m MachinePoolScope) ReplicasManagedExternally() bool {
+	_, ok := m.MachinePool.GetAnnotations()[azure.ReplicasManagedByAnnotation]
+	return ok
+}
+
 // MaxSurge returns the number of machines to surge, or 0 if the deployment strategy does not support surge.
 func (m MachinePoolScope) MaxSurge() (int, error) {
 	if surger, ok := m.getDeploymentStrategy().(machinepool.Surger); ok {
@@ -227,6 +384,74 @@ func (m *MachinePoolScope) updateReplicasAndProviderIDs(ctx context.Context) err
 	return nil
 }
 
+// updateModelReplicas updates the AzureMachinePool counts of the VMSS instances with and without the latest model
+// applied, to show the progress of rolling out a new model.
+func (m *MachinePoolScope) updateModelReplicas() {
+	var updatedReplicas, outdatedReplicas int32
+	for _, instance := range m.vmssState.Instances {
+		if m.vmssState.HasLatestModelApplied(instance) {
+			updatedReplicas++
+		} else {
+			outdatedReplicas++
+		}
+	}
+
+	m.AzureMachinePool.Status.UpdatedReplicas = updatedReplicas
+	m.AzureMachinePool.Status.OutdatedReplicas = outdatedReplicas
+}
+
+// updateVMSSIdentifiers records the unique ID and the latest model ID of the VMSS on the AzureMachinePool status, to
+// correlate the AzureMachinePool with the VMSS and to detect model changes.
+func (m *MachinePoolScope) updateVMSSIdentifiers() error {
+	modelID, err := m.vmssState.ModelID()
+	if err != nil {
+		return err
+	}
+
+	m.AzureMachinePool.Status.UniqueID = m.vmssState.UniqueID
+	m.AzureMachinePool.Status.LatestModelID = modelID
+	return nil
+}
+
+// InstancesToReimage returns the instance IDs of the AzureMachinePoolMachines which are annotated to be reimaged.
+func (m *MachinePoolScope) InstancesToReimage(ctx context.Context) ([]string, error) {
+	ampms, err := m.getMachinePoolMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var instanceIDs []string
+	for _, ampm := range ampms {
+		if ampm.GetAnnotations()[azure.ReimageAnnotation] == "true" {
+			instanceIDs = append(instanceIDs, ampm.Spec.InstanceID)
+		}
+	}
+
+	return instanceIDs, nil
+}
+
+// RemoveReimageAnnotation removes the reimage annotation from the AzureMachinePoolMachine of the given instance.
+func (m *MachinePoolScope) RemoveReimageAnnotation(ctx context.Context, instanceID string) error {
+	ampms, err := m.getMachinePoolMachines(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range ampms {
+		ampm := ampms[i]
+		if ampm.Spec.InstanceID != instanceID {
+			continue
+		}
+		patch := client.MergeFrom(ampm.DeepCopy())
+		delete(ampm.Annotations, azure.ReimageAnnotation)
+		if err := m.client.Patch(ctx, &ampm, patch); err != nil {
+			return errors.Wrapf(err, "failed removing reimage annotation of AzureMachinePoolMachine %s", ampm.Name)
+		}
+	}
+
+	return nil
+}
+
 func (m *MachinePoolScope) getMachinePoolMachines(ctx context.Context) ([]infrav1exp.AzureMachinePoolMachine, error) {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.getMachinePoolMachines")
 	defer done()
@@ -268,30 +493,63 @@ func (m *MachinePoolScope) applyAzureMachinePoolMachines(ctx context.Context) er
 
 	// determine which machines need to be created to reflect the current state in Azure
 	azureMachinesByProviderID := m.vmssState.InstancesByProviderID()
+
+	// record the state the decisions are based on to be able to diagnose unexpected churn from traces
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.Int("desiredReplicas", int(m.DesiredReplicas())),
+		attribute.Int("existingMachines", len(existingMachinesByProviderID)),
+		attribute.Int("azureInstances", len(azureMachinesByProviderID)),
+	)
+	var deletedCount int
+	defer func() {
+		span.SetAttributes(attribute.Int("deletedMachines", deletedCount))
+	}()
+
+	var machinesToCreate []azure.VMSSVM
 	for key, val := range azureMachinesByProviderID {
+		if existing, ok := existingMachinesByProviderID[key]; ok && isStaleMachine(existing, val) {
+			// Azure reused the instance ID of a deleted instance, so the machine belongs to the deleted instance and
+			// must not be adopted by the new one.
+			log.V(4).Info("replacing stale AzureMachinePoolMachine of a deleted instance with a reused instance ID", "providerID", key, "name", existing.Name)
+			if err := m.deleteStaleMachine(ctx, existing); err != nil {
+				return errors.Wrap(err, "failed deleting stale AzureMachinePoolMachine")
+			}
+			delete(existingMachinesByProviderID, key)
+		}
 		if _, ok := existingMachinesByProviderID[key]; !ok {
 			log.V(4).Info("creating AzureMachinePoolMachine", "providerID", key)
-			if err := m.createMachine(ctx, val); err != nil {
-				return errors.Wrap(err, "failed creating AzureMachinePoolMachine")
-			}
-			continue
+			machinesToCreate = append(machinesToCreate, val)
 		}
 	}
 
+	span.SetAttributes(attribute.Int("createdMachines", len(machinesToCreate)))
+	if err := m.createMachines(ctx, machinesToCreate); err != nil {
+		return errors.Wrap(err, "failed creating AzureMachinePoolMachine(s)")
+	}
+
 	deleted := false
+	var evicted []string
 	// delete machines that no longer exist in Azure
 	for key, machine := range existingMachinesByProviderID {
 		machine := machine
 		if _, ok := azureMachinesByProviderID[key]; !ok {
 			deleted = true
+			if m.isEvictedMachine(machine) {
+				log.Info("spot instance of AzureMachinePoolMachine was evicted by Azure", "providerID", key, "name", machine.Name)
+				evicted = append(evicted, machine.Name)
+			}
 			log.V(4).Info("deleting AzureMachinePoolMachine because it no longer exists in the VMSS", "providerID", key)
 			delete(existingMachinesByProviderID, key)
 			if err := m.client.Delete(ctx, &machine); err != nil {
 				return errors.Wrap(err, "failed deleting AzureMachinePoolMachine to reduce replica count")
 			}
+			deletedCount++
 		}
 	}
 
+	m.setSpotCapacityCondition(evicted, len(azureMachinesByProviderID))
+
 	if deleted {
 		log.V(4).Info("exiting early due to finding AzureMachinePoolMachine(s) that were deleted because they no longer exist in the VMSS")
 		// exit early to be less greedy about delete
@@ -311,23 +569,88 @@ func (m *MachinePoolScope) applyAzureMachinePoolMachines(ctx context.Context) er
 	}
 
 	// select machines to delete to lower the replica count
-	toDelete, err := deleteSelector.SelectMachinesToDelete(ctx, m.DesiredReplicas(), existingMachinesByProviderID)
+	toDelete, err := m.selectMachinesToDelete(ctx, deleteSelector, existingMachinesByProviderID)
 	if err != nil {
 		return errors.Wrap(err, "failed selecting AzureMachinePoolMachine(s) to delete")
 	}
 
 	for _, machine := range toDelete {
 		machine := machine
-		log.Info("deleting selected AzureMachinePoolMachine", "providerID", machine.Spec.ProviderID)
+		if machinepool.IsProtectedFromDeletion(machine) {
+			log.Info("deleting selected AzureMachinePoolMachine although it is protected from deletion, since no other machine can be deleted", "providerID", machine.Spec.ProviderID)
+		} else {
+			log.Info("deleting selected AzureMachinePoolMachine", "providerID", machine.Spec.ProviderID)
+		}
 		if err := m.client.Delete(ctx, &machine); err != nil {
 			return errors.Wrap(err, "failed deleting AzureMachinePoolMachine to reduce replica count")
 		}
+		deletedCount++
 	}
 
 	log.V(4).Info("done reconciling AzureMachinePoolMachine(s)")
 	return nil
 }
 
+// selectMachinesToDelete selects the AzureMachinePoolMachines to delete to lower the replica count in a span of its own.
+func (m *MachinePoolScope) selectMachinesToDelete(ctx context.Context, deleteSelector machinepool.TypedDeleteSelector, machinesByProviderID map[string]infrav1exp.AzureMachinePoolMachine) ([]infrav1exp.AzureMachinePoolMachine, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.selectMachinesToDelete",
+		tele.KVP("strategy", string(deleteSelector.Type())),
+	)
+	defer done()
+
+	toDelete, err := deleteSelector.SelectMachinesToDelete(ctx, m.DesiredReplicas(), machinesByProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var protected int
+	for _, machine := range toDelete {
+		if machinepool.IsProtectedFromDeletion(machine) {
+			protected++
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("candidateMachines", len(machinesByProviderID)),
+		attribute.Int("selectedMachines", len(toDelete)),
+		attribute.Int("selectedProtectedMachines", protected),
+	)
+
+	return toDelete, nil
+}
+
+// createMachines creates an AzureMachinePoolMachine for each of the given VMSS instances using up to the configured
+// number of concurrent workers. The errors of all failed creations are aggregated.
+func (m *MachinePoolScope) createMachines(ctx context.Context, machines []azure.VMSSVM) error {
+	workers := m.machineCreationConcurrency
+	if workers <= 0 {
+		workers = defaultMachineCreationConcurrency
+	}
+	if workers > len(machines) {
+		workers = len(machines)
+	}
+
+	errs := make([]error, len(machines))
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = m.createMachine(ctx, machines[i])
+			}
+		}()
+	}
+	for i := range machines {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return kerrors.NewAggregate(errs)
+}
+
 func (m *MachinePoolScope) createMachine(ctx context.Context, machine azure.VMSSVM) error {
 	if machine.InstanceID == "" {
 		return errors.New("machine.InstanceID must not be empty")
@@ -339,7 +662,7 @@ func (m *MachinePoolScope) createMachine(ctx context.Context, machine azure.VMSS
 
 	ampm := infrav1exp.AzureMachinePoolMachine{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      strings.Join([]string{m.AzureMachinePool.Name, machine.InstanceID}, "-"),
+			Name:      machinePoolMachineName(m.AzureMachinePool.Name, machine),
 			Namespace: m.AzureMachinePool.Namespace,
 			OwnerReferences: []metav1.OwnerReference{
 				{
@@ -357,11 +680,18 @@ func (m *MachinePoolScope) createMachine(ctx context.Context, machine azure.VMSS
 			},
 		},
 		Spec: infrav1exp.AzureMachinePoolMachineSpec{
-			ProviderID: machine.ProviderID(),
-			InstanceID: machine.InstanceID,
+			ProviderID:       machine.ProviderID(),
+			InstanceID:       machine.InstanceID,
+			NodeDrainTimeout: m.AzureMachinePool.Spec.NodeDrainTimeout,
 		},
 	}
 
+	if machine.VMID != "" {
+		ampm.Annotations = map[string]string{
+			azure.VMSSVMIDAnnotation: machine.VMID,
+		}
+	}
+
 	controllerutil.AddFinalizer(&ampm, infrav1exp.AzureMachinePoolMachineFinalizer)
 	conditions.MarkFalse(&ampm, infrav1.VMRunningCondition, string(infrav1.Creating), clusterv1.ConditionSeverityInfo, "")
 	if err := m.client.Create(ctx, &ampm); err != nil {
@@ -371,6 +701,39 @@ func (m *MachinePoolScope) createMachine(ctx context.Context, machine azure.VMSS
 	return nil
 }
 
+// deleteStaleMachine deletes an AzureMachinePoolMachine of an instance which no longer exists. The finalizer is
+// removed first, as the instance ID of the machine now belongs to another instance which must not be deleted.
+func (m *MachinePoolScope) deleteStaleMachine(ctx context.Context, ampm infrav1exp.AzureMachinePoolMachine) error {
+	patch := client.MergeFrom(ampm.DeepCopy())
+	controllerutil.RemoveFinalizer(&ampm, infrav1exp.AzureMachinePoolMachineFinalizer)
+	if err := m.client.Patch(ctx, &ampm, patch); err != nil {
+		return errors.Wrapf(err, "failed removing finalizer of AzureMachinePoolMachine %s", ampm.Name)
+	}
+
+	if err := m.client.Delete(ctx, &ampm); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed deleting AzureMachinePoolMachine %s", ampm.Name)
+	}
+
+	return nil
+}
+
+// machinePoolMachineName returns the name of the AzureMachinePoolMachine of a VMSS VM. The unique VM ID is part of the
+// name, so that the machine of an instance doesn't collide with a machine of a deleted instance with the same
+// instance ID.
+func machinePoolMachineName(poolName string, machine azure.VMSSVM) string {
+	if machine.VMID == "" {
+		return strings.Join([]string{poolName, machine.InstanceID}, "-")
+	}
+	return strings.ToLower(strings.Join([]string{poolName, machine.InstanceID, machine.VMID}, "-"))
+}
+
+// isStaleMachine returns true if the AzureMachinePoolMachine was created for another instance than the given VMSS VM
+// with the same instance ID. Machines created before the VM ID was recorded are never considered stale.
+func isStaleMachine(ampm infrav1exp.AzureMachinePoolMachine, machine azure.VMSSVM) bool {
+	vmID := ampm.GetAnnotations()[azure.VMSSVMIDAnnotation]
+	return vmID != "" && machine.VMID != "" && !strings.EqualFold(vmID, machine.VMID)
+}
+
 // SetLongRunningOperationState will set the future on the AzureMachinePool status to allow the resource to continue
 // in the next reconciliation.
 func (m *MachinePoolScope) SetLongRunningOperationState(future *infrav1.Future) {
@@ -387,21 +750,47 @@ func (m *MachinePoolScope) DeleteLongRunningOperationState(name, service string)
 	futures.Delete(m.AzureMachinePool, name, service)
 }
 
+// isEvictedMachine returns true if the instance of the machine is presumed to be evicted by Azure, i.e. it disappeared
+// from a spot machine pool without the machine being deleted, leaving the scale set with fewer instances than its
+// capacity. Intentionally deleted instances disappear only after the deletion of their machine started, while instances
+// removed by decreasing the capacity, e.g. when scaling to zero or deleting instances from the portal, lower the capacity
+// as well. Instances of externally scaled pools may be deleted by the autoscaler.
+func (m *MachinePoolScope) isEvictedMachine(machine infrav1exp.AzureMachinePoolMachine) bool {
+	return m.AzureMachinePool.Spec.Template.SpotVMOptions != nil &&
+		!m.ReplicasManagedExternally() &&
+		m.DesiredReplicas() > 0 &&
+		int64(len(m.vmssState.Instances)) < m.vmssState.Capacity &&
+		machine.DeletionTimestamp.IsZero()
+}
+
+// setSpotCapacityCondition marks the spot capacity condition false if spot instances of the machine pool were
+// evicted, and true again once the scale set is back at the desired replicas.
+func (m *MachinePoolScope) setSpotCapacityCondition(evicted []string, instances int) {
+	switch {
+	case len(evicted) > 0:
+		sort.Strings(evicted)
+		conditions.MarkFalse(m.AzureMachinePool, infrav1.ScaleSetSpotCapacityCondition, infrav1.ScaleSetSpotInstancesEvictedReason, clusterv1.ConditionSeverityWarning,
+			"%d spot instance(s) evicted by Azure: %s", len(evicted), strings.Join(evicted, ", "))
+	case conditions.IsFalse(m.AzureMachinePool, infrav1.ScaleSetSpotCapacityCondition) && instances >= int(m.DesiredReplicas()):
+		conditions.MarkTrue(m.AzureMachinePool, infrav1.ScaleSetSpotCapacityCondition)
+	}
+}
+
 // setProvisioningStateAndConditions sets the AzureMachinePool provisioning state and conditions.
 func (m *MachinePoolScope) setProvisioningStateAndConditions(v infrav1.ProvisioningState) {
 	m.AzureMachinePool.Status.ProvisioningState = &v
 	switch {
-	case v == infrav1.Succeeded && *m.MachinePool.Spec.Replicas == m.AzureMachinePool.Status.Replicas:
+	case v == infrav1.Succeeded && m.DesiredReplicas() == m.AzureMachinePool.Status.Replicas:
 		// vmss is provisioned with enough ready replicas
 		conditions.MarkTrue(m.AzureMachinePool, infrav1.ScaleSetRunningCondition)
 		conditions.MarkTrue(m.AzureMachinePool, infrav1.ScaleSetModelUpdatedCondition)
 		conditions.MarkTrue(m.AzureMachinePool, infrav1.ScaleSetDesiredReplicasCondition)
 		m.SetReady()
-	case v == infrav1.Succeeded && *m.MachinePool.Spec.Replicas != m.AzureMachinePool.Status.Replicas:
+	case v == infrav1.Succeeded && m.DesiredReplicas() != m.AzureMachinePool.Status.Replicas:
 		// not enough ready or too many ready replicas we must still be scaling up or down
 		updatingState := infrav1.Updating
 		m.AzureMachinePool.Status.ProvisioningState = &updatingState
-		if *m.MachinePool.Spec.Replicas > m.AzureMachinePool.Status.Replicas {
+		if m.DesiredReplicas() > m.AzureMachinePool.Status.Replicas {
 			conditions.MarkFalse(m.AzureMachinePool, infrav1.ScaleSetDesiredReplicasCondition, infrav1.ScaleSetScaleUpReason, clusterv1.ConditionSeverityInfo, "")
 		} else {
 			conditions.MarkFalse(m.AzureMachinePool, infrav1.ScaleSetDesiredReplicasCondition, infrav1.ScaleSetScaleDownReason, clusterv1.ConditionSeverityInfo, "")
@@ -442,22 +831,56 @@ func (m *MachinePoolScope) SetFailureReason(v capierrors.MachineStatusError) {
 	m.AzureMachinePool.Status.FailureReason = &v
 }
 
-// SetBootstrapConditions sets the AzureMachinePool BootstrapSucceeded condition based on the extension provisioning states.
-func (m *MachinePoolScope) SetBootstrapConditions(ctx context.Context, provisioningState string, extensionName string) error {
+// SetBootstrapConditions sets the AzureMachinePool BootstrapSucceeded condition based on the provisioning states of the
+// extension with the given name across all instances of the scale set.
+func (m *MachinePoolScope) SetBootstrapConditions(ctx context.Context, extensionName string) error {
 	_, log, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.SetBootstrapConditions")
 	defer done()
 
-	switch infrav1.ProvisioningState(provisioningState) {
+	if m.bootstrapExtensionDisabled() {
+		// without a bootstrap extension there is nothing to wait for
+		log.V(4).Info("bootstrap extension is skipped", "scale set", m.Name())
+		m.AzureMachinePool.Status.BootstrapFailedSince = nil
+		conditions.MarkTrue(m.AzureMachinePool, infrav1.BootstrapSucceededCondition)
+		return nil
+	}
+
+	if extensionName == "" || m.vmssState == nil || len(m.vmssState.Instances) == 0 {
+		return nil
+	}
+
+	provisioningState, failedInstances := aggregateExtensionProvisioningState(m.vmssState.Instances, extensionName)
+	switch provisioningState {
 	case infrav1.Succeeded:
 		log.V(4).Info("extension provisioning state is succeeded", "vm extension", extensionName, "scale set", m.Name())
+		m.AzureMachinePool.Status.BootstrapFailedSince = nil
 		conditions.MarkTrue(m.AzureMachinePool, infrav1.BootstrapSucceededCondition)
 		return nil
 	case infrav1.Creating:
 		log.V(4).Info("extension provisioning state is creating", "vm extension", extensionName, "scale set", m.Name())
+		m.AzureMachinePool.Status.BootstrapFailedSince = nil
 		conditions.MarkFalse(m.AzureMachinePool, infrav1.BootstrapSucceededCondition, infrav1.BootstrapInProgressReason, clusterv1.ConditionSeverityInfo, "")
 		return azure.WithTransientError(errors.New("extension is still in provisioning state. This likely means that bootstrapping has not yet completed on the VM"), 30*time.Second)
 	case infrav1.Failed:
-		log.V(4).Info("extension provisioning state is failed", "vm extension", extensionName, "scale set", m.Name())
+		log.V(4).Info("extension provisioning state is failed", "vm extension", extensionName, "scale set", m.Name(), "instances", failedInstances)
+		now := time.Now()
+		if m.AzureMachinePool.Status.BootstrapFailedSince == nil {
+			failedSince := metav1.NewTime(now)
+			m.AzureMachinePool.Status.BootstrapFailedSince = &failedSince
+		}
+		// a failed extension may still recover from transient boot failures within the grace period
+		if gracePeriod := m.AzureMachinePool.Spec.BootstrapFailureGracePeriod; gracePeriod != nil {
+			if remaining := gracePeriod.Duration - now.Sub(m.AzureMachinePool.Status.BootstrapFailedSince.Time); remaining > 0 {
+				log.V(4).Info("waiting for the failed extension to recover", "vm extension", extensionName, "scale set", m.Name(), "remaining", remaining)
+				conditions.MarkFalse(m.AzureMachinePool, infrav1.BootstrapSucceededCondition, infrav1.BootstrapFailedReason, clusterv1.ConditionSeverityWarning,
+					"extension state failed, waiting up to %s for it to recover", remaining.Round(time.Second))
+				requeueAfter := 30 * time.Second
+				if remaining < requeueAfter {
+					requeueAfter = remaining
+				}
+				return azure.WithTransientError(errors.New("extension state failed. Waiting for the bootstrap to recover within the bootstrap failure grace period"), requeueAfter)
+			}
+		}
 		conditions.MarkFalse(m.AzureMachinePool, infrav1.BootstrapSucceededCondition, infrav1.BootstrapFailedReason, clusterv1.ConditionSeverityError, "")
 		return azure.WithTerminalError(errors.New("extension state failed. This likely means the Kubernetes node bootstrapping process failed or timed out. Check VM boot diagnostics logs to learn more"))
 	default:
@@ -465,6 +888,35 @@ func (m *MachinePoolScope) SetBootstrapConditions(ctx context.Context, provision
 	}
 }
 
+// aggregateExtensionProvisioningState aggregates the provisioning states of the extension with the given name across the
+// instances. The extension is failed if it failed on any instance, succeeded only if it succeeded on all instances and
+// creating otherwise. The IDs of the instances on which the extension failed are returned as well.
+func aggregateExtensionProvisioningState(instances []azure.VMSSVM, extensionName string) (infrav1.ProvisioningState, []string) {
+	var (
+		failedInstances []string
+		allSucceeded    = true
+	)
+	for _, instance := range instances {
+		switch instance.ExtensionStates[extensionName] {
+		case infrav1.Failed:
+			failedInstances = append(failedInstances, instance.InstanceID)
+		case infrav1.Succeeded:
+		default:
+			// instances which did not report the extension yet are still bootstrapping
+			allSucceeded = false
+		}
+	}
+
+	switch {
+	case len(failedInstances) > 0:
+		return infrav1.Failed, failedInstances
+	case allSucceeded:
+		return infrav1.Succeeded, nil
+	default:
+		return infrav1.Creating, nil
+	}
+}
+
 // AdditionalTags merges AdditionalTags from the scope's AzureCluster and AzureMachinePool. If the same key is present in both,
 // the value from AzureMachinePool takes precedence.
 func (m *MachinePoolScope) AdditionalTags() infrav1.Tags {
@@ -479,6 +931,16 @@ func (m *MachinePoolScope) AdditionalTags() infrav1.Tags {
 	return tags
 }
 
+// AzureMachinePoolAnnotations returns the annotations of the AzureMachinePool.
+func (m *MachinePoolScope) AzureMachinePoolAnnotations() map[string]string {
+	return m.AzureMachinePool.Annotations
+}
+
+// RemoveAzureMachinePoolAnnotation removes the annotation with the given key from the AzureMachinePool.
+func (m *MachinePoolScope) RemoveAzureMachinePoolAnnotation(key string) {
+	delete(m.AzureMachinePool.Annotations, key)
+}
+
 // SetAnnotation sets a key value annotation on the AzureMachinePool.
 func (m *MachinePoolScope) SetAnnotation(key, value string) {
 	if m.AzureMachinePool.Annotations == nil {
@@ -502,6 +964,7 @@ func (m *MachinePoolScope) PatchObject(ctx context.Context) error {
 			infrav1.ScaleSetDesiredReplicasCondition,
 			infrav1.ScaleSetModelUpdatedCondition,
 			infrav1.ScaleSetRunningCondition,
+			infrav1.ScaleSetSpotCapacityCondition,
 		}})
 }
 
@@ -517,6 +980,10 @@ func (m *MachinePoolScope) Close(ctx context.Context) error {
 		}
 
 		m.setProvisioningStateAndConditions(m.vmssState.State)
+		m.updateModelReplicas()
+		if err := m.updateVMSSIdentifiers(); err != nil {
+			return errors.Wrap(err, "failed to update VMSS identifiers")
+		}
 		if err := m.updateReplicasAndProviderIDs(ctx); err != nil {
 			return errors.Wrap(err, "failed to update replicas and providerIDs")
 		}
@@ -544,17 +1011,115 @@ func (m *MachinePoolScope) GetBootstrapData(ctx context.Context) (string, error)
 	if !ok {
 		return "", errors.New("error retrieving bootstrap data: secret value key is missing")
 	}
+
+	if fragments := m.AzureMachinePool.Spec.Template.AdditionalCloudInit; len(fragments) > 0 {
+		merged, err := mergeCloudInit(value, fragments)
+		if err != nil {
+			return "", azure.WithTerminalError(errors.Wrapf(err, "failed to merge bootstrap data with additional cloud-init of AzureMachinePool %s/%s", m.AzureMachinePool.Namespace, m.Name()))
+		}
+		value = merged
+	}
 	return base64.StdEncoding.EncodeToString(value), nil
 }
 
+// GetLinuxAdminPassword returns the password of the admin user of Linux Virtual Machines from the secret referenced by
+// the Linux configuration of the AzureMachinePool.
+func (m *MachinePoolScope) GetLinuxAdminPassword(ctx context.Context) (string, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.GetLinuxAdminPassword")
+	defer done()
+
+	linuxConfig := m.AzureMachinePool.Spec.Template.LinuxConfiguration
+	if linuxConfig == nil || linuxConfig.AdminPasswordSecretName == "" {
+		return "", azure.WithTerminalError(errors.Errorf("AzureMachinePool %s/%s enables password authentication without an admin password secret", m.AzureMachinePool.Namespace, m.Name()))
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: m.AzureMachinePool.Namespace, Name: linuxConfig.AdminPasswordSecretName}
+	if err := m.client.Get(ctx, key, secret); err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve admin password secret for AzureMachinePool %s/%s", m.AzureMachinePool.Namespace, m.Name())
+	}
+
+	password, ok := secret.Data["password"]
+	if !ok || len(password) == 0 {
+		return "", errors.Errorf("admin password secret %s/%s has no password key", key.Namespace, key.Name)
+	}
+	return string(password), nil
+}
+
+// mergeCloudInit merges the bootstrap data with additional cloud-init fragments into a MIME multipart archive.
+// The archive uses a fixed boundary so the custom data of the scale set does not change between reconciles.
+func mergeCloudInit(bootstrapData []byte, fragments []infrav1exp.CloudInitFragment) ([]byte, error) {
+	contentType, err := cloudInitContentType(bootstrapData)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary(cloudInitBoundary); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\nMIME-Version: 1.0\r\n\r\n", cloudInitBoundary)
+
+	if err := writeCloudInitPart(mw, contentType, "", bootstrapData); err != nil {
+		return nil, err
+	}
+	for _, fragment := range fragments {
+		mergeType := fragment.MergeType
+		if fragment.ContentType == infrav1exp.CloudInitContentTypeCloudConfig && mergeType == "" {
+			mergeType = defaultCloudInitMergeType
+		}
+		if err := writeCloudInitPart(mw, string(fragment.ContentType), mergeType, []byte(fragment.Content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	if buf.Len() > maxCustomDataLength {
+		return nil, errors.Errorf("merged bootstrap data is %d bytes long, which exceeds the maximum custom data length of %d bytes", buf.Len(), maxCustomDataLength)
+	}
+	return buf.Bytes(), nil
+}
+
+// cloudInitContentType returns the MIME content type of the bootstrap data.
+func cloudInitContentType(bootstrapData []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(bootstrapData, []byte("## template: jinja")):
+		return "text/jinja2", nil
+	case bytes.HasPrefix(bootstrapData, []byte("#cloud-config")):
+		return string(infrav1exp.CloudInitContentTypeCloudConfig), nil
+	case bytes.HasPrefix(bootstrapData, []byte("#!")):
+		return string(infrav1exp.CloudInitContentTypeShellScript), nil
+	default:
+		return "", errors.New("bootstrap data must be a cloud-config or a shell script to be merged with additional cloud-init")
+	}
+}
+
+// writeCloudInitPart writes a part with the given content type and optional cloud-init merge type.
+func writeCloudInitPart(mw *multipart.Writer, contentType, mergeType string, content []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", fmt.Sprintf("%s; charset=\"us-ascii\"", contentType))
+	header.Set("MIME-Version", "1.0")
+	if mergeType != "" {
+		header.Set("Merge-Type", mergeType)
+	}
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(content)
+	return err
+}
+
 // GetVMImage picks an image from the AzureMachinePool configuration, or uses a default one.
 func (m *MachinePoolScope) GetVMImage(ctx context.Context) (*infrav1.Image, error) {
-	_, log, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.GetVMImage")
+	ctx, log, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.GetVMImage")
 	defer done()
 
 	// Use custom Marketplace image, Image ID or a Shared Image Gallery image if provided
 	if m.AzureMachinePool.Spec.Template.Image != nil {
-		return m.AzureMachinePool.Spec.Template.Image, nil
+		return m.pinVMImageVersion(ctx, m.AzureMachinePool.Spec.Template.Image, false)
 	}
 
 	svc := virtualmachineimages.New(m)
@@ -576,7 +1141,64 @@ func (m *MachinePoolScope) GetVMImage(ctx context.Context) (*infrav1.Image, erro
 		return defaultImage, errors.Wrap(err, "failed to get default OS image")
 	}
 
-	return defaultImage, nil
+	return m.pinVMImageVersion(ctx, defaultImage, true)
+}
+
+// pinVMImageVersion returns the image with the version "latest" of a marketplace image replaced by a concrete version,
+// so that instances don't silently run different versions once Azure publishes a new one. The version saved to the
+// status by an earlier reconciliation is kept as long as the image plan doesn't change, unless the AzureMachinePool is
+// annotated to follow the latest version. The version of default images is pinned as well, unless the AzureMachinePool
+// is annotated to roll to newer default images. A pool whose status already has the version "latest" for the same plan
+// keeps it, since pinning the model of its existing scale set would roll all of its instances.
+func (m *MachinePoolScope) pinVMImageVersion(ctx context.Context, image *infrav1.Image, isDefault bool) (*infrav1.Image, error) {
+	ctx, log, done := tele.StartSpanWithLogger(ctx, "scope.MachinePoolScope.pinVMImageVersion")
+	defer done()
+
+	if image.Marketplace == nil || (!isDefault && image.Marketplace.Version != azure.LatestVersion) {
+		return image, nil
+	}
+	if image.Marketplace.Version == azure.LatestVersion && m.AzureMachinePool.GetAnnotations()[azure.FollowLatestImageVersionAnnotation] == "true" {
+		return image, nil
+	}
+
+	var pinnedVersion string
+	if status := m.AzureMachinePool.Status.Image; status != nil && status.Marketplace != nil &&
+		status.Marketplace.ImagePlan == image.Marketplace.ImagePlan {
+		if status.Marketplace.Version == azure.LatestVersion {
+			// the scale set was created with the version "latest" and isn't changed to a pinned version
+			return image, nil
+		}
+		pinnedVersion = status.Marketplace.Version
+	}
+
+	pinned := image.DeepCopy()
+	rollToNewer := isDefault && m.AzureMachinePool.GetAnnotations()[azure.RollToNewerDefaultImageAnnotation] == "true"
+	if pinnedVersion != "" && !rollToNewer {
+		pinned.Marketplace.Version = pinnedVersion
+		return pinned, nil
+	}
+
+	if pinned.Marketplace.Version == azure.LatestVersion {
+		resolver := m.imageVersionResolver
+		if resolver == nil {
+			resolver = virtualmachineimages.New(m)
+		}
+		plan := image.Marketplace.ImagePlan
+		version, err := resolver.GetLatestMarketplaceImageVersion(ctx, m.Location(), plan.Publisher, plan.Offer, plan.SKU)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve the latest image version")
+		}
+		pinned.Marketplace.Version = version
+	}
+
+	if pinnedVersion != "" {
+		if virtualmachineimages.CompareImageVersions(pinned.Marketplace.Version, pinnedVersion) <= 0 {
+			pinned.Marketplace.Version = pinnedVersion
+			return pinned, nil
+		}
+		log.Info("rolling to a newer default image version", "from", pinnedVersion, "to", pinned.Marketplace.Version)
+	}
+	return pinned, nil
 }
 
 // SaveVMImageToStatus persists the AzureMachinePool image to the status.
@@ -588,12 +1210,22 @@ func (m *MachinePoolScope) SaveVMImageToStatus(image *infrav1.Image) {
 func (m *MachinePoolScope) RoleAssignmentSpecs(principalID *string) []azure.ResourceSpecGetter {
 	roles := make([]azure.ResourceSpecGetter, 1)
 	if m.HasSystemAssignedIdentity() {
+		scope := m.AzureMachinePool.Spec.RoleAssignmentScope
+		if scope == "" {
+			scope = azure.GenerateSubscriptionScope(m.SubscriptionID())
+		}
+		roleDefinitionID := m.AzureMachinePool.Spec.RoleDefinitionID
+		if roleDefinitionID == "" {
+			roleDefinitionID = azure.GenerateContributorRoleDefinitionID(m.SubscriptionID())
+		}
 		roles[0] = &roleassignments.RoleAssignmentSpec{
-			Name:          m.AzureMachinePool.Spec.RoleAssignmentName,
-			MachineName:   m.Name(),
-			ResourceGroup: m.ResourceGroup(),
-			ResourceType:  azure.VirtualMachineScaleSet,
-			PrincipalID:   principalID,
+			Name:             m.AzureMachinePool.Spec.RoleAssignmentName,
+			MachineName:      m.Name(),
+			ResourceGroup:    m.ResourceGroup(),
+			ResourceType:     azure.VirtualMachineScaleSet,
+			Scope:            scope,
+			RoleDefinitionID: roleDefinitionID,
+			PrincipalID:      principalID,
 		}
 		return roles
 	}
@@ -608,24 +1240,51 @@ func (m *MachinePoolScope) RoleAssignmentResourceType() string {
 // HasSystemAssignedIdentity returns true if the azure machine pool has system
 // assigned identity.
 func (m *MachinePoolScope) HasSystemAssignedIdentity() bool {
-	return m.AzureMachinePool.Spec.Identity == infrav1.VMIdentitySystemAssigned
+	return m.AzureMachinePool.Spec.Identity == infrav1.VMIdentitySystemAssigned ||
+		m.AzureMachinePool.Spec.Identity == infrav1.VMIdentitySystemAndUserAssigned
 }
 
 // VMSSExtensionSpecs returns the VMSS extension specs.
 func (m *MachinePoolScope) VMSSExtensionSpecs() []azure.ResourceSpecGetter {
 	var extensionSpecs = []azure.ResourceSpecGetter{}
-	bootstrapExtensionSpec := azure.GetBootstrappingVMExtension(m.AzureMachinePool.Spec.Template.OSDisk.OSType, m.CloudEnvironment(), m.Name())
+	if m.bootstrapExtensionDisabled() {
+		return extensionSpecs
+	}
 
-	if bootstrapExtensionSpec != nil {
+	if bootstrapExtensionSpec := m.bootstrapExtensionSpec(); bootstrapExtensionSpec != nil {
 		extensionSpecs = append(extensionSpecs, &scalesets.VMSSExtensionSpec{
 			ExtensionSpec: *bootstrapExtensionSpec,
-			ResourceGroup: m.ResourceGroup(),
+			ResourceGroup: m.ScaleSetResourceGroup(),
 		})
 	}
 
 	return extensionSpecs
 }
 
+// BootstrapExtensionName returns the name of the VMSS extension which reports the bootstrap status of the instances, or an
+// empty string if there is no bootstrap extension for the OS type.
+func (m *MachinePoolScope) BootstrapExtensionName() string {
+	if bootstrapExtensionSpec := m.bootstrapExtensionSpec(); bootstrapExtensionSpec != nil {
+		return bootstrapExtensionSpec.Name
+	}
+	return ""
+}
+
+// bootstrapExtensionDisabled returns true if the bootstrap extension is skipped, either with the skipBootstrapExtension
+// field or the disable bootstrap extension annotation.
+func (m *MachinePoolScope) bootstrapExtensionDisabled() bool {
+	return m.AzureMachinePool.Spec.SkipBootstrapExtension || m.AzureMachinePool.GetAnnotations()[azure.DisableBootstrapExtensionAnnotation] == "true"
+}
+
+// bootstrapExtensionSpec returns the spec of the bootstrap extension, taking a custom bootstrap extension into account.
+func (m *MachinePoolScope) bootstrapExtensionSpec() *azure.ExtensionSpec {
+	osType := m.AzureMachinePool.Spec.Template.OSDisk.OSType
+	if custom := m.AzureMachinePool.Spec.BootstrapExtension; custom != nil {
+		return azure.GetCustomBootstrappingVMExtension(osType, m.Name(), custom.Type, custom.Publisher, custom.Version)
+	}
+	return azure.GetBootstrappingVMExtension(osType, m.CloudEnvironment(), m.Name())
+}
+
 func (m *MachinePoolScope) getDeploymentStrategy() machinepool.TypedDeleteSelector {
 	if m.AzureMachinePool == nil {
 		return nil
@@ -18,15 +18,25 @@ package scope
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	autorestazure "github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -34,11 +44,14 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/mock_azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/roleassignments"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/scalesets"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-azure/util/futures"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -101,13 +114,13 @@ func TestMachinePoolScope_Name(t *testing.T) {
 func TestMachinePoolScope_SetBootstrapConditions(t *testing.T) {
 	cases := []struct {
 		Name   string
-		Setup  func() (provisioningState string, extensionName string)
+		Setup  func() (instances []azure.VMSSVM, extensionName string)
 		Verify func(g *WithT, amp *infrav1exp.AzureMachinePool, err error)
 	}{
 		{
 			Name: "should set bootstrap succeeded condition if provisioning state succeeded",
-			Setup: func() (provisioningState string, extensionName string) {
-				return string(infrav1.Succeeded), "foo"
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return instancesWithExtensionStates("foo", infrav1.Succeeded), "foo"
 			},
 			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
 				g.Expect(err).NotTo(HaveOccurred())
@@ -116,8 +129,8 @@ func TestMachinePoolScope_SetBootstrapConditions(t *testing.T) {
 		},
 		{
 			Name: "should set bootstrap succeeded false condition with reason if provisioning state creating",
-			Setup: func() (provisioningState string, extensionName string) {
-				return string(infrav1.Creating), "bazz"
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return instancesWithExtensionStates("bazz", infrav1.Creating), "bazz"
 			},
 			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
 				g.Expect(err).To(MatchError("extension is still in provisioning state. This likely means that bootstrapping has not yet completed on the VM. Object will be requeued after 30s"))
@@ -130,8 +143,8 @@ func TestMachinePoolScope_SetBootstrapConditions(t *testing.T) {
 		},
 		{
 			Name: "should set bootstrap succeeded false condition with reason if provisioning state failed",
-			Setup: func() (provisioningState string, extensionName string) {
-				return string(infrav1.Failed), "buzz"
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return instancesWithExtensionStates("buzz", infrav1.Failed), "buzz"
 			},
 			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
 				g.Expect(err).To(MatchError("reconcile error that cannot be recovered occurred: extension state failed. This likely means the Kubernetes node bootstrapping process failed or timed out. Check VM boot diagnostics logs to learn more. Object will not be requeued"))
@@ -142,6 +155,63 @@ func TestMachinePoolScope_SetBootstrapConditions(t *testing.T) {
 				g.Expect(*severity).To(Equal(clusterv1.ConditionSeverityError))
 			},
 		},
+		{
+			Name: "should set bootstrap succeeded condition if provisioning state succeeded on all instances",
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return instancesWithExtensionStates("foo", infrav1.Succeeded, infrav1.Succeeded, infrav1.Succeeded), "foo"
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(conditions.IsTrue(amp, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+			},
+		},
+		{
+			Name: "should set bootstrap failed condition if provisioning state failed on any instance",
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return instancesWithExtensionStates("foo", infrav1.Succeeded, infrav1.Creating, infrav1.Failed), "foo"
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+				g.Expect(conditions.IsFalse(amp, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(amp, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapFailedReason))
+			},
+		},
+		{
+			Name: "should set bootstrap in progress condition if provisioning state is creating on any instance",
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return instancesWithExtensionStates("foo", infrav1.Succeeded, infrav1.Creating), "foo"
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTransient()).To(BeTrue())
+				g.Expect(conditions.IsFalse(amp, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(amp, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapInProgressReason))
+			},
+		},
+		{
+			Name: "should set bootstrap in progress condition if the extension is not yet reported by an instance",
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				instances = instancesWithExtensionStates("foo", infrav1.Succeeded)
+				return append(instances, azure.VMSSVM{InstanceID: "new"}), "foo"
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(conditions.GetReason(amp, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapInProgressReason))
+			},
+		},
+		{
+			Name: "should not set the bootstrap condition without instances",
+			Setup: func() (instances []azure.VMSSVM, extensionName string) {
+				return nil, "foo"
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(conditions.Has(amp, infrav1.BootstrapSucceededCondition)).To(BeFalse())
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -152,16 +222,109 @@ func TestMachinePoolScope_SetBootstrapConditions(t *testing.T) {
 			)
 			defer mockCtrl.Finish()
 
-			state, name := c.Setup()
+			instances, name := c.Setup()
 			s := &MachinePoolScope{
 				AzureMachinePool: &infrav1exp.AzureMachinePool{},
+				vmssState: &azure.VMSS{
+					Instances: instances,
+				},
 			}
-			err := s.SetBootstrapConditions(context.TODO(), state, name)
+			err := s.SetBootstrapConditions(context.TODO(), name)
 			c.Verify(g, s.AzureMachinePool, err)
 		})
 	}
 }
 
+// instancesWithExtensionStates returns one instance per given provisioning state of the extension with the given name.
+func instancesWithExtensionStates(extensionName string, states ...infrav1.ProvisioningState) []azure.VMSSVM {
+	instances := make([]azure.VMSSVM, len(states))
+	for i, state := range states {
+		instances[i] = azure.VMSSVM{
+			InstanceID: strconv.Itoa(i),
+			ExtensionStates: map[string]infrav1.ProvisioningState{
+				extensionName: state,
+			},
+		}
+	}
+	return instances
+}
+
+func TestMachinePoolScope_SetBootstrapConditionsSkipBootstrapExtension(t *testing.T) {
+	g := NewWithT(t)
+	s := &MachinePoolScope{
+		AzureMachinePool: &infrav1exp.AzureMachinePool{
+			Spec: infrav1exp.AzureMachinePoolSpec{
+				SkipBootstrapExtension: true,
+			},
+		},
+	}
+
+	g.Expect(s.SetBootstrapConditions(context.TODO(), "foo")).To(Succeed())
+	g.Expect(conditions.IsTrue(s.AzureMachinePool, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+}
+
+func TestMachinePoolScope_SetBootstrapConditionsDisableBootstrapExtensionAnnotation(t *testing.T) {
+	g := NewWithT(t)
+	s := &MachinePoolScope{
+		AzureMachinePool: &infrav1exp.AzureMachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					azure.DisableBootstrapExtensionAnnotation: "true",
+				},
+			},
+		},
+	}
+	s.SetVMSSState(&azure.VMSS{
+		Instances: []azure.VMSSVM{
+			{InstanceID: "0"},
+		},
+	})
+
+	g.Expect(s.SetBootstrapConditions(context.TODO(), "foo")).To(Succeed())
+	g.Expect(conditions.IsTrue(s.AzureMachinePool, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+}
+
+func TestMachinePoolScope_SetBootstrapConditionsGracePeriod(t *testing.T) {
+	g := NewWithT(t)
+	s := &MachinePoolScope{
+		AzureMachinePool: &infrav1exp.AzureMachinePool{
+			Spec: infrav1exp.AzureMachinePoolSpec{
+				BootstrapFailureGracePeriod: &metav1.Duration{Duration: 10 * time.Minute},
+			},
+		},
+		vmssState: &azure.VMSS{
+			Instances: instancesWithExtensionStates("foo", infrav1.Failed),
+		},
+	}
+
+	// within the grace period a failed extension is transient
+	err := s.SetBootstrapConditions(context.TODO(), "foo")
+	var reconcileErr azure.ReconcileError
+	g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+	g.Expect(reconcileErr.IsTransient()).To(BeTrue())
+	g.Expect(reconcileErr.RequeueAfter()).To(Equal(30 * time.Second))
+	g.Expect(s.AzureMachinePool.Status.BootstrapFailedSince).NotTo(BeNil())
+	g.Expect(conditions.GetReason(s.AzureMachinePool, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapFailedReason))
+	g.Expect(*conditions.GetSeverity(s.AzureMachinePool, infrav1.BootstrapSucceededCondition)).To(Equal(clusterv1.ConditionSeverityWarning))
+
+	// the first failure time is kept while the extension keeps failing
+	failedSince := *s.AzureMachinePool.Status.BootstrapFailedSince
+	g.Expect(s.SetBootstrapConditions(context.TODO(), "foo")).To(HaveOccurred())
+	g.Expect(*s.AzureMachinePool.Status.BootstrapFailedSince).To(Equal(failedSince))
+
+	// after the grace period a failed extension is terminal
+	s.AzureMachinePool.Status.BootstrapFailedSince = &metav1.Time{Time: time.Now().Add(-11 * time.Minute)}
+	err = s.SetBootstrapConditions(context.TODO(), "foo")
+	g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+	g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+	g.Expect(*conditions.GetSeverity(s.AzureMachinePool, infrav1.BootstrapSucceededCondition)).To(Equal(clusterv1.ConditionSeverityError))
+
+	// a recovered extension clears the first failure time
+	s.vmssState.Instances = instancesWithExtensionStates("foo", infrav1.Succeeded)
+	g.Expect(s.SetBootstrapConditions(context.TODO(), "foo")).To(Succeed())
+	g.Expect(s.AzureMachinePool.Status.BootstrapFailedSince).To(BeNil())
+}
+
 func TestMachinePoolScope_MaxSurge(t *testing.T) {
 	cases := []struct {
 		Name   string
@@ -329,7 +492,7 @@ func TestMachinePoolScope_GetVMImage(t *testing.T) {
 							Offer:     "capi",
 							SKU:       "k8s-1dot19dot11-ubuntu-1804",
 						},
-						Version:         "latest",
+						Version:         "2022.01.01",
 						ThirdPartyImage: false,
 					},
 				}
@@ -337,6 +500,27 @@ func TestMachinePoolScope_GetVMImage(t *testing.T) {
 				g.Expect(amp.Spec.Template.Image).To(BeNil())
 			},
 		},
+		{
+			Name: "should keep the version latest of the default image of an existing scale set",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				mp.Spec.Template.Spec.Version = to.StringPtr("v1.19.11")
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{
+							Publisher: "cncf-upstream",
+							Offer:     "capi",
+							SKU:       "k8s-1dot19dot11-ubuntu-1804",
+						},
+						Version: "latest",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.SKU).To(Equal("k8s-1dot19dot11-ubuntu-1804"))
+				g.Expect(vmImage.Marketplace.Version).To(Equal("latest"))
+			},
+		},
 		{
 			Name: "should not default or set the image on the AzureMachinePool if it already exists",
 			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
@@ -366,10 +550,142 @@ func TestMachinePoolScope_GetVMImage(t *testing.T) {
 						ThirdPartyImage: false,
 					},
 				}
-				g.Expect(vmImage).To(Equal(image))
+				pinned := image.DeepCopy()
+				pinned.Marketplace.Version = "2022.01.01"
+				g.Expect(vmImage).To(Equal(pinned))
 				g.Expect(amp.Spec.Template.Image).To(Equal(image))
 			},
 		},
+		{
+			Name: "should keep the image version pinned in the status",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				amp.Spec.Template.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "baz"},
+						Version:   "latest",
+					},
+				}
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "baz"},
+						Version:   "2021.12.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.Version).To(Equal("2021.12.01"))
+			},
+		},
+		{
+			Name: "should resolve the image version again if the image plan changed",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				amp.Spec.Template.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "new-sku"},
+						Version:   "latest",
+					},
+				}
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "baz"},
+						Version:   "2021.12.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.SKU).To(Equal("new-sku"))
+				g.Expect(vmImage.Marketplace.Version).To(Equal("2022.01.01"))
+			},
+		},
+		{
+			Name: "should keep following the latest image version if annotated",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				amp.Annotations = map[string]string{azure.FollowLatestImageVersionAnnotation: "true"}
+				amp.Spec.Template.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "baz"},
+						Version:   "latest",
+					},
+				}
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "baz"},
+						Version:   "2021.12.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.Version).To(Equal("latest"))
+			},
+		},
+		{
+			Name: "should keep the default image version pinned in the status although a newer version exists",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				mp.Spec.Template.Spec.Version = to.StringPtr("v1.19.11")
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "cncf-upstream", Offer: "capi", SKU: "k8s-1dot19dot11-ubuntu-1804"},
+						Version:   "2021.12.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.Version).To(Equal("2021.12.01"))
+			},
+		},
+		{
+			Name: "should roll to a newer default image version if annotated",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				mp.Spec.Template.Spec.Version = to.StringPtr("v1.19.11")
+				amp.Annotations = map[string]string{azure.RollToNewerDefaultImageAnnotation: "true"}
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "cncf-upstream", Offer: "capi", SKU: "k8s-1dot19dot11-ubuntu-1804"},
+						Version:   "2021.12.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.Version).To(Equal("2022.01.01"))
+			},
+		},
+		{
+			Name: "should not roll to an older default image version",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				mp.Spec.Template.Spec.Version = to.StringPtr("v1.19.11")
+				amp.Annotations = map[string]string{azure.RollToNewerDefaultImageAnnotation: "true"}
+				amp.Status.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "cncf-upstream", Offer: "capi", SKU: "k8s-1dot19dot11-ubuntu-1804"},
+						Version:   "2023.01.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.Version).To(Equal("2023.01.01"))
+			},
+		},
+		{
+			Name: "should not change a concrete image version",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool) {
+				amp.Spec.Template.Image = &infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{Publisher: "foo", Offer: "bar", SKU: "baz"},
+						Version:   "2021.11.01",
+					},
+				}
+			},
+			Verify: func(g *WithT, amp *infrav1exp.AzureMachinePool, vmImage *infrav1.Image, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(vmImage.Marketplace.Version).To(Equal("2021.11.01"))
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -404,9 +720,10 @@ func TestMachinePoolScope_GetVMImage(t *testing.T) {
 			}
 
 			s := &MachinePoolScope{
-				MachinePool:      mp,
-				AzureMachinePool: amp,
-				ClusterScoper:    clusterMock,
+				MachinePool:          mp,
+				AzureMachinePool:     amp,
+				ClusterScoper:        clusterMock,
+				imageVersionResolver: fakeImageVersionResolver{version: "2022.01.01"},
 			}
 			image, err := s.GetVMImage(context.TODO())
 			c.Verify(g, amp, image, err)
@@ -414,6 +731,15 @@ func TestMachinePoolScope_GetVMImage(t *testing.T) {
 	}
 }
 
+// fakeImageVersionResolver resolves the version "latest" of every marketplace image to the same version.
+type fakeImageVersionResolver struct {
+	version string
+}
+
+func (f fakeImageVersionResolver) GetLatestMarketplaceImageVersion(_ context.Context, _, _, _, _ string) (string, error) {
+	return f.version, nil
+}
+
 func TestMachinePoolScope_NeedsRequeue(t *testing.T) {
 	cases := []struct {
 		Name   string
@@ -469,6 +795,27 @@ func TestMachinePoolScope_NeedsRequeue(t *testing.T) {
 				g.Expect(requeue).To(BeFalse())
 			},
 		},
+		{
+			Name: "should not requeue if the machine is in succeeded state and the externally managed replica count matches the vmss capacity",
+			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool, vmss *azure.VMSS) {
+				succeeded := infrav1.Succeeded
+				mp.Annotations = map[string]string{azure.ReplicasManagedByAnnotation: "cluster-autoscaler"}
+				mp.Spec.Replicas = to.Int32Ptr(1)
+				amp.Status.ProvisioningState = &succeeded
+				vmss.Capacity = 2
+				vmss.Instances = []azure.VMSSVM{
+					{
+						Name: "instance1",
+					},
+					{
+						Name: "instance2",
+					},
+				}
+			},
+			Verify: func(g *WithT, requeue bool) {
+				g.Expect(requeue).To(BeFalse())
+			},
+		},
 		{
 			Name: "should requeue if an instance VM image does not match the VM image of the VMSS",
 			Setup: func(mp *expv1.MachinePool, amp *infrav1exp.AzureMachinePool, vmss *azure.VMSS) {
@@ -640,70 +987,1007 @@ func TestMachinePoolScope_updateReplicasAndProviderIDs(t *testing.T) {
 	}
 }
 
-func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
-	tests := []struct {
-		name             string
-		machinePoolScope MachinePoolScope
-		want             []azure.ResourceSpecGetter
+func TestMachinePoolScope_updateModelReplicas(t *testing.T) {
+	latestImage := infrav1.Image{
+		Marketplace: &infrav1.AzureMarketplaceImage{
+			Version: "2.0",
+		},
+	}
+	outdatedImage := infrav1.Image{
+		Marketplace: &infrav1.AzureMarketplaceImage{
+			Version: "1.0",
+		},
+	}
+
+	cases := []struct {
+		Name         string
+		Instances    []azure.VMSSVM
+		WantUpdated  int32
+		WantOutdated int32
 	}{
 		{
-			name: "If OS type is Linux and cloud is AzurePublicCloud, it returns ExtensionSpec",
-			machinePoolScope: MachinePoolScope{
-				MachinePool: &expv1.MachinePool{},
-				AzureMachinePool: &infrav1exp.AzureMachinePool{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "machinepool-name",
-					},
-					Spec: infrav1exp.AzureMachinePoolSpec{
-						Template: infrav1exp.AzureMachinePoolMachineTemplate{
-							OSDisk: infrav1.OSDisk{
-								OSType: "Linux",
-							},
-						},
-					},
-				},
-				ClusterScoper: &ClusterScope{
-					AzureClients: AzureClients{
-						EnvironmentSettings: auth.EnvironmentSettings{
-							Environment: autorestazure.Environment{
-								Name: autorestazure.PublicCloud.Name,
-							},
-						},
-					},
-					AzureCluster: &infrav1.AzureCluster{
-						Spec: infrav1.AzureClusterSpec{
-							ResourceGroup: "my-rg",
-						},
-					},
-				},
-			},
-			want: []azure.ResourceSpecGetter{
-				&scalesets.VMSSExtensionSpec{
-					ExtensionSpec: azure.ExtensionSpec{
-						Name:      "CAPZ.Linux.Bootstrapping",
-						VMName:    "machinepool-name",
-						Publisher: "Microsoft.Azure.ContainerUpstream",
-						Version:   "1.0",
-						ProtectedSettings: map[string]string{
-							"commandToExecute": azure.LinuxBootstrapExtensionCommand,
-						},
-					},
-					ResourceGroup: "my-rg",
-				},
+			Name: "no instances",
+		},
+		{
+			Name: "all instances have the latest model",
+			Instances: []azure.VMSSVM{
+				{InstanceID: "0", Image: latestImage},
+				{InstanceID: "1", Image: latestImage},
 			},
+			WantUpdated: 2,
 		},
 		{
-			name: "If OS type is Linux and cloud is not AzurePublicCloud, it returns empty",
-			machinePoolScope: MachinePoolScope{
-				MachinePool: &expv1.MachinePool{},
+			Name: "some instances have an outdated model",
+			Instances: []azure.VMSSVM{
+				{InstanceID: "0", Image: latestImage},
+				{InstanceID: "1", Image: outdatedImage},
+				{InstanceID: "2", Image: outdatedImage},
+			},
+			WantUpdated:  1,
+			WantOutdated: 2,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			s := &MachinePoolScope{
 				AzureMachinePool: &infrav1exp.AzureMachinePool{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "machinepool-name",
+					Status: infrav1exp.AzureMachinePoolStatus{
+						UpdatedReplicas:  5,
+						OutdatedReplicas: 5,
+					},
+				},
+				vmssState: &azure.VMSS{
+					Image:     latestImage,
+					Instances: c.Instances,
+				},
+			}
+
+			s.updateModelReplicas()
+			g.Expect(s.AzureMachinePool.Status.UpdatedReplicas).To(Equal(c.WantUpdated))
+			g.Expect(s.AzureMachinePool.Status.OutdatedReplicas).To(Equal(c.WantOutdated))
+		})
+	}
+}
+
+func TestMachinePoolScope_CloseUpdatesVMSSIdentifiers(t *testing.T) {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	amp := &infrav1exp.AzureMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "amp1",
+			Namespace: "default",
+		},
+	}
+	// A long running operation on the scale set skips selecting machines to delete.
+	futures.Set(amp, &infrav1.Future{
+		Type:        infrav1.PatchFuture,
+		ServiceName: ScalesetsServiceName,
+		Name:        "amp1",
+	})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(amp).Build()
+
+	s, err := NewMachinePoolScope(MachinePoolScopeParams{
+		Client: c,
+		ClusterScope: &ClusterScope{
+			Cluster: &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster1",
+					Namespace: "default",
+				},
+			},
+		},
+		MachinePool:      &expv1.MachinePool{},
+		AzureMachinePool: amp,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	vmss := azure.VMSS{
+		ID:       "subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/amp1",
+		UniqueID: "11111111-2222-3333-4444-555555555555",
+		Name:     "amp1",
+		Sku:      "Standard_D2s_v3",
+		State:    infrav1.Succeeded,
+		Image: infrav1.Image{
+			Marketplace: &infrav1.AzureMarketplaceImage{
+				Version: "1.0",
+			},
+		},
+	}
+	wantModelID, err := vmss.ModelID()
+	g.Expect(err).NotTo(HaveOccurred())
+	s.SetVMSSState(&vmss)
+
+	g.Expect(s.Close(context.TODO())).To(Succeed())
+
+	patched := &infrav1exp.AzureMachinePool{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(amp), patched)).To(Succeed())
+	g.Expect(patched.Status.UniqueID).To(Equal("11111111-2222-3333-4444-555555555555"))
+	g.Expect(patched.Status.LatestModelID).To(Equal(wantModelID))
+}
+
+func TestMachinePoolScope_applyAzureMachinePoolMachines(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	instance := azure.VMSSVM{
+		ID:         "subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/amp1/virtualMachines/1",
+		InstanceID: "1",
+		VMID:       "New-VM-ID",
+		Name:       "amp1000001",
+	}
+	existingMachine := func(name, vmID string) *infrav1exp.AzureMachinePoolMachine {
+		ampm := &infrav1exp.AzureMachinePoolMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "default",
+				Finalizers: []string{infrav1exp.AzureMachinePoolMachineFinalizer},
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName:      "cluster1",
+					infrav1exp.MachinePoolNameLabel: "amp1",
+				},
+			},
+			Spec: infrav1exp.AzureMachinePoolMachineSpec{
+				ProviderID: instance.ProviderID(),
+				InstanceID: instance.InstanceID,
+			},
+		}
+		if vmID != "" {
+			ampm.Annotations = map[string]string{azure.VMSSVMIDAnnotation: vmID}
+		}
+		return ampm
+	}
+
+	cases := []struct {
+		Name      string
+		Existing  []*infrav1exp.AzureMachinePoolMachine
+		WantNames []string
+	}{
+		{
+			Name:      "creates a machine named after the instance and VM ID",
+			WantNames: []string{"amp1-1-new-vm-id"},
+		},
+		{
+			Name:      "keeps the machine of the instance",
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{existingMachine("amp1-1-new-vm-id", "New-VM-ID")},
+			WantNames: []string{"amp1-1-new-vm-id"},
+		},
+		{
+			Name:      "keeps a machine created before the VM ID was recorded",
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{existingMachine("amp1-1", "")},
+			WantNames: []string{"amp1-1"},
+		},
+		{
+			Name:      "replaces the stale machine of a deleted instance with a reused instance ID",
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{existingMachine("amp1-1-old-vm-id", "old-vm-id")},
+			WantNames: []string{"amp1-1-new-vm-id"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			cb := fake.NewClientBuilder().WithScheme(scheme)
+			for _, ampm := range c.Existing {
+				cb.WithObjects(ampm)
+			}
+			amp := &infrav1exp.AzureMachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "amp1",
+					Namespace: "default",
+				},
+			}
+			// A long running operation on the scale set skips selecting machines to delete.
+			futures.Set(amp, &infrav1.Future{
+				Type:        infrav1.PatchFuture,
+				ServiceName: ScalesetsServiceName,
+				Name:        "amp1",
+			})
+			s := &MachinePoolScope{
+				client: cb.Build(),
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "cluster1",
+							Namespace: "default",
+						},
+					},
+				},
+				MachinePool:      &expv1.MachinePool{},
+				AzureMachinePool: amp,
+				vmssState: &azure.VMSS{
+					Instances: []azure.VMSSVM{instance},
+				},
+			}
+
+			g.Expect(s.applyAzureMachinePoolMachines(context.TODO())).To(Succeed())
+
+			ampml := &infrav1exp.AzureMachinePoolMachineList{}
+			g.Expect(s.client.List(context.TODO(), ampml)).To(Succeed())
+			names := make([]string, len(ampml.Items))
+			for i, ampm := range ampml.Items {
+				names[i] = ampm.Name
+				g.Expect(ampm.Spec.ProviderID).To(Equal(instance.ProviderID()))
+			}
+			g.Expect(names).To(ConsistOf(c.WantNames))
+		})
+	}
+}
+
+func TestMachinePoolScope_applyAzureMachinePoolMachinesSpanAttributes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	instance := func(id string) azure.VMSSVM {
+		return azure.VMSSVM{
+			ID:         "subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/amp1/virtualMachines/" + id,
+			InstanceID: id,
+			Name:       "amp100000" + id,
+		}
+	}
+	goneMachine := &infrav1exp.AzureMachinePoolMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "amp1-9",
+			Namespace: "default",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName:      "cluster1",
+				infrav1exp.MachinePoolNameLabel: "amp1",
+			},
+		},
+		Spec: infrav1exp.AzureMachinePoolMachineSpec{
+			ProviderID: instance("9").ProviderID(),
+			InstanceID: "9",
+		},
+	}
+
+	cases := []struct {
+		Name              string
+		Existing          []*infrav1exp.AzureMachinePoolMachine
+		WantAttributes    map[string]int64
+		WantSelectionSpan bool
+	}{
+		{
+			Name: "records the created machines and the delete selection",
+			WantAttributes: map[string]int64{
+				"desiredReplicas":  2,
+				"existingMachines": 0,
+				"azureInstances":   2,
+				"createdMachines":  2,
+				"deletedMachines":  0,
+			},
+			WantSelectionSpan: true,
+		},
+		{
+			Name:     "records the deleted machines which no longer exist in Azure",
+			Existing: []*infrav1exp.AzureMachinePoolMachine{goneMachine},
+			WantAttributes: map[string]int64{
+				"desiredReplicas":  2,
+				"existingMachines": 1,
+				"azureInstances":   2,
+				"createdMachines":  2,
+				"deletedMachines":  1,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+			cb := fake.NewClientBuilder().WithScheme(scheme)
+			for _, ampm := range c.Existing {
+				cb.WithObjects(ampm)
+			}
+			s := &MachinePoolScope{
+				client: cb.Build(),
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "cluster1",
+							Namespace: "default",
+						},
+					},
+				},
+				MachinePool: &expv1.MachinePool{
+					Spec: expv1.MachinePoolSpec{
+						Replicas: to.Int32Ptr(2),
+					},
+				},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "amp1",
+						Namespace: "default",
+					},
+				},
+				vmssState: &azure.VMSS{
+					Instances: []azure.VMSSVM{instance("0"), instance("1")},
+				},
+			}
+
+			g.Expect(s.applyAzureMachinePoolMachines(context.TODO())).To(Succeed())
+
+			spans := map[string]sdktrace.ReadOnlySpan{}
+			for _, span := range recorder.Ended() {
+				spans[span.Name()] = span
+			}
+			g.Expect(spans).To(HaveKey("scope.MachinePoolScope.applyAzureMachinePoolMachines"))
+			attributes := map[string]int64{}
+			for _, kv := range spans["scope.MachinePoolScope.applyAzureMachinePoolMachines"].Attributes() {
+				if kv.Value.Type() == attribute.INT64 {
+					attributes[string(kv.Key)] = kv.Value.AsInt64()
+				}
+			}
+			g.Expect(attributes).To(Equal(c.WantAttributes))
+
+			if c.WantSelectionSpan {
+				g.Expect(spans).To(HaveKey("scope.MachinePoolScope.selectMachinesToDelete"))
+				g.Expect(spans["scope.MachinePoolScope.selectMachinesToDelete"].Parent().SpanID()).
+					To(Equal(spans["scope.MachinePoolScope.applyAzureMachinePoolMachines"].SpanContext().SpanID()))
+			} else {
+				g.Expect(spans).NotTo(HaveKey("scope.MachinePoolScope.selectMachinesToDelete"))
+			}
+		})
+	}
+}
+
+func TestMachinePoolScope_applyAzureMachinePoolMachinesSpotEviction(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	instance := func(id string) azure.VMSSVM {
+		return azure.VMSSVM{
+			ID:         "subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/amp1/virtualMachines/" + id,
+			InstanceID: id,
+			Name:       "amp100000" + id,
+		}
+	}
+	machine := func(id string) *infrav1exp.AzureMachinePoolMachine {
+		return &infrav1exp.AzureMachinePoolMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "amp1-" + id,
+				Namespace:  "default",
+				Finalizers: []string{infrav1exp.AzureMachinePoolMachineFinalizer},
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName:      "cluster1",
+					infrav1exp.MachinePoolNameLabel: "amp1",
+				},
+			},
+			Spec: infrav1exp.AzureMachinePoolMachineSpec{
+				ProviderID: instance(id).ProviderID(),
+				InstanceID: id,
+			},
+		}
+	}
+	deletingMachine := func(id string) *infrav1exp.AzureMachinePoolMachine {
+		ampm := machine(id)
+		ampm.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		return ampm
+	}
+
+	cases := []struct {
+		Name          string
+		Spot          bool
+		Replicas      *int32
+		Capacity      *int64
+		Existing      []*infrav1exp.AzureMachinePoolMachine
+		Instances     []azure.VMSSVM
+		WasEvicted    bool
+		WantCondition *clusterv1.Condition
+	}{
+		{
+			Name:      "marks the eviction of an instance which disappeared from a spot pool",
+			Spot:      true,
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{machine("0"), machine("1")},
+			Instances: []azure.VMSSVM{instance("0")},
+			WantCondition: &clusterv1.Condition{
+				Type:     infrav1.ScaleSetSpotCapacityCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityWarning,
+				Reason:   infrav1.ScaleSetSpotInstancesEvictedReason,
+				Message:  "1 spot instance(s) evicted by Azure: amp1-1",
+			},
+		},
+		{
+			Name:      "does not mark the intentional deletion of an instance of a spot pool",
+			Spot:      true,
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{machine("0"), deletingMachine("1")},
+			Instances: []azure.VMSSVM{instance("0")},
+		},
+		{
+			Name:      "does not mark the instances removed by scaling a spot pool to zero",
+			Spot:      true,
+			Replicas:  to.Int32Ptr(0),
+			Capacity:  to.Int64Ptr(0),
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{machine("0"), machine("1")},
+			Instances: []azure.VMSSVM{},
+		},
+		{
+			Name:      "does not mark an instance removed by decreasing the capacity of a spot pool",
+			Spot:      true,
+			Replicas:  to.Int32Ptr(1),
+			Capacity:  to.Int64Ptr(1),
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{machine("0"), machine("1")},
+			Instances: []azure.VMSSVM{instance("0")},
+		},
+		{
+			Name:      "does not mark an instance which disappeared from a regular pool",
+			Existing:  []*infrav1exp.AzureMachinePoolMachine{machine("0"), machine("1")},
+			Instances: []azure.VMSSVM{instance("0")},
+		},
+		{
+			Name:       "keeps the eviction marked while the spot pool is below the desired replicas",
+			Spot:       true,
+			Existing:   []*infrav1exp.AzureMachinePoolMachine{machine("0")},
+			Instances:  []azure.VMSSVM{instance("0")},
+			WasEvicted: true,
+			WantCondition: &clusterv1.Condition{
+				Type:     infrav1.ScaleSetSpotCapacityCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityWarning,
+				Reason:   infrav1.ScaleSetSpotInstancesEvictedReason,
+				Message:  "1 spot instance(s) evicted by Azure: amp1-1",
+			},
+		},
+		{
+			Name:       "marks the spot pool recovered once it is back at the desired replicas",
+			Spot:       true,
+			Existing:   []*infrav1exp.AzureMachinePoolMachine{machine("0")},
+			Instances:  []azure.VMSSVM{instance("0"), instance("2")},
+			WasEvicted: true,
+			WantCondition: &clusterv1.Condition{
+				Type:   infrav1.ScaleSetSpotCapacityCondition,
+				Status: corev1.ConditionTrue,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			cb := fake.NewClientBuilder().WithScheme(scheme)
+			for _, ampm := range c.Existing {
+				cb.WithObjects(ampm)
+			}
+			amp := &infrav1exp.AzureMachinePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "amp1",
+					Namespace: "default",
+				},
+			}
+			if c.Spot {
+				amp.Spec.Template.SpotVMOptions = &infrav1.SpotVMOptions{}
+			}
+			if c.WasEvicted {
+				conditions.MarkFalse(amp, infrav1.ScaleSetSpotCapacityCondition, infrav1.ScaleSetSpotInstancesEvictedReason, clusterv1.ConditionSeverityWarning,
+					"1 spot instance(s) evicted by Azure: amp1-1")
+			}
+			// A long running operation on the scale set skips selecting machines to delete.
+			futures.Set(amp, &infrav1.Future{
+				Type:        infrav1.PatchFuture,
+				ServiceName: ScalesetsServiceName,
+				Name:        "amp1",
+			})
+			replicas, capacity := to.Int32Ptr(2), to.Int64Ptr(2)
+			if c.Replicas != nil {
+				replicas = c.Replicas
+			}
+			if c.Capacity != nil {
+				capacity = c.Capacity
+			}
+			s := &MachinePoolScope{
+				client: cb.Build(),
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "cluster1",
+							Namespace: "default",
+						},
+					},
+				},
+				MachinePool: &expv1.MachinePool{
+					Spec: expv1.MachinePoolSpec{
+						Replicas: replicas,
+					},
+				},
+				AzureMachinePool: amp,
+				vmssState: &azure.VMSS{
+					Capacity:  *capacity,
+					Instances: c.Instances,
+				},
+			}
+
+			g.Expect(s.applyAzureMachinePoolMachines(context.TODO())).To(Succeed())
+
+			condition := conditions.Get(amp, infrav1.ScaleSetSpotCapacityCondition)
+			if c.WantCondition == nil {
+				g.Expect(condition).To(BeNil())
+				return
+			}
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(c.WantCondition.Status))
+			g.Expect(condition.Severity).To(Equal(c.WantCondition.Severity))
+			g.Expect(condition.Reason).To(Equal(c.WantCondition.Reason))
+			g.Expect(condition.Message).To(Equal(c.WantCondition.Message))
+		})
+	}
+}
+
+func TestMachinePoolScope_createMachines(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	instances := func(count int) []azure.VMSSVM {
+		vms := make([]azure.VMSSVM, count)
+		for i := range vms {
+			vms[i] = azure.VMSSVM{
+				ID:         fmt.Sprintf("subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/amp1/virtualMachines/%d", i),
+				InstanceID: fmt.Sprintf("%d", i),
+				Name:       fmt.Sprintf("amp1%06d", i),
+			}
+		}
+		return vms
+	}
+
+	cases := []struct {
+		Name        string
+		Instances   []azure.VMSSVM
+		Concurrency int
+		WantCreated int
+		WantErrs    []string
+	}{
+		{
+			Name:        "creates nothing without instances",
+			Concurrency: 2,
+		},
+		{
+			Name:        "creates a machine for every instance",
+			Instances:   instances(25),
+			Concurrency: 3,
+			WantCreated: 25,
+		},
+		{
+			Name:        "creates a machine for every instance with more workers than instances",
+			Instances:   instances(2),
+			Concurrency: 10,
+			WantCreated: 2,
+		},
+		{
+			Name: "surfaces the errors of all workers",
+			Instances: func() []azure.VMSSVM {
+				vms := instances(10)
+				vms[3].Name = ""
+				vms[7].InstanceID = ""
+				return vms
+			}(),
+			Concurrency: 4,
+			WantCreated: 8,
+			WantErrs: []string{
+				"machine.Name must not be empty",
+				"machine.InstanceID must not be empty",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			s := &MachinePoolScope{
+				client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "cluster1",
+							Namespace: "default",
+						},
+					},
+				},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "amp1",
+						Namespace: "default",
+					},
+				},
+				machineCreationConcurrency: c.Concurrency,
+			}
+
+			err := s.createMachines(context.TODO(), c.Instances)
+			if len(c.WantErrs) == 0 {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(HaveOccurred())
+				for _, want := range c.WantErrs {
+					g.Expect(err.Error()).To(ContainSubstring(want))
+				}
+			}
+
+			ampml := &infrav1exp.AzureMachinePoolMachineList{}
+			g.Expect(s.client.List(context.TODO(), ampml)).To(Succeed())
+			g.Expect(ampml.Items).To(HaveLen(c.WantCreated))
+		})
+	}
+}
+
+func TestMachinePoolScope_createMachineNodeDrainTimeout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clusterv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name             string
+		NodeDrainTimeout *metav1.Duration
+	}{
+		{
+			Name: "creates a machine without a node drain timeout",
+		},
+		{
+			Name:             "creates a machine waiting indefinitely for the node to drain",
+			NodeDrainTimeout: &metav1.Duration{Duration: 0},
+		},
+		{
+			Name:             "creates a machine with the node drain timeout of the pool",
+			NodeDrainTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			s := &MachinePoolScope{
+				client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "cluster1",
+							Namespace: "default",
+						},
+					},
+				},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "amp1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						NodeDrainTimeout: c.NodeDrainTimeout,
+					},
+				},
+			}
+
+			g.Expect(s.createMachine(context.TODO(), azure.VMSSVM{
+				ID:         "subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/amp1/virtualMachines/0",
+				InstanceID: "0",
+				Name:       "amp1000000",
+			})).To(Succeed())
+
+			ampm := &infrav1exp.AzureMachinePoolMachine{}
+			g.Expect(s.client.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "amp1-0"}, ampm)).To(Succeed())
+			g.Expect(ampm.Spec.NodeDrainTimeout).To(Equal(c.NodeDrainTimeout))
+		})
+	}
+}
+
+func TestMachinePoolScope_ReimageInstances(t *testing.T) {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	_ = infrav1exp.AddToScheme(scheme)
+
+	machine := func(name, instanceID string, annotations map[string]string) *infrav1exp.AzureMachinePoolMachine {
+		return &infrav1exp.AzureMachinePoolMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: annotations,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName:      "cluster1",
+					infrav1exp.MachinePoolNameLabel: "amp1",
+				},
+			},
+			Spec: infrav1exp.AzureMachinePoolMachineSpec{
+				InstanceID: instanceID,
+			},
+		}
+	}
+
+	s := &MachinePoolScope{
+		client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			machine("amp1-0", "0", map[string]string{azure.ReimageAnnotation: "true"}),
+			machine("amp1-1", "1", nil),
+			machine("amp1-2", "2", map[string]string{azure.ReimageAnnotation: "false"}),
+		).Build(),
+		ClusterScoper: &ClusterScope{
+			Cluster: &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster1",
+					Namespace: "default",
+				},
+			},
+		},
+		AzureMachinePool: &infrav1exp.AzureMachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "amp1",
+				Namespace: "default",
+			},
+		},
+	}
+
+	instanceIDs, err := s.InstancesToReimage(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instanceIDs).To(Equal([]string{"0"}))
+
+	g.Expect(s.RemoveReimageAnnotation(context.TODO(), "0")).To(Succeed())
+	ampm := &infrav1exp.AzureMachinePoolMachine{}
+	g.Expect(s.client.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "amp1-0"}, ampm)).To(Succeed())
+	g.Expect(ampm.Annotations).NotTo(HaveKey(azure.ReimageAnnotation))
+
+	instanceIDs, err = s.InstancesToReimage(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instanceIDs).To(BeEmpty())
+}
+
+func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
+	tests := []struct {
+		name             string
+		machinePoolScope MachinePoolScope
+		want             []azure.ResourceSpecGetter
+	}{
+		{
+			name: "If OS type is Linux and cloud is AzurePublicCloud, it returns ExtensionSpec",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							OSDisk: infrav1.OSDisk{
+								OSType: "Linux",
+							},
+						},
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.PublicCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+			},
+			want: []azure.ResourceSpecGetter{
+				&scalesets.VMSSExtensionSpec{
+					ExtensionSpec: azure.ExtensionSpec{
+						Name:      "CAPZ.Linux.Bootstrapping",
+						VMName:    "machinepool-name",
+						Publisher: "Microsoft.Azure.ContainerUpstream",
+						Version:   "1.0",
+						ProtectedSettings: map[string]string{
+							"commandToExecute": azure.LinuxBootstrapExtensionCommand,
+						},
+					},
+					ResourceGroup: "my-rg",
+				},
+			},
+		},
+		{
+			name: "If OS type is Linux and cloud is not AzurePublicCloud, it returns empty",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							OSDisk: infrav1.OSDisk{
+								OSType: "Linux",
+							},
+						},
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.USGovernmentCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+			},
+			want: []azure.ResourceSpecGetter{},
+		},
+		{
+			name: "If OS type is Windows and cloud is AzurePublicCloud, it returns ExtensionSpec",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						// Note: machine pool names longer than 9 characters get truncated. See MachinePoolScope::Name() for more details.
+						Name: "winpool",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							OSDisk: infrav1.OSDisk{
+								OSType: "Windows",
+							},
+						},
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.PublicCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+			},
+			want: []azure.ResourceSpecGetter{
+				&scalesets.VMSSExtensionSpec{
+					ExtensionSpec: azure.ExtensionSpec{
+						Name: "CAPZ.Windows.Bootstrapping",
+						// Note: machine pool names longer than 9 characters get truncated. See MachinePoolScope::Name() for more details.
+						VMName:    "winpool",
+						Publisher: "Microsoft.Azure.ContainerUpstream",
+						Version:   "1.0",
+						ProtectedSettings: map[string]string{
+							"commandToExecute": azure.WindowsBootstrapExtensionCommand,
+						},
+					},
+					ResourceGroup: "my-rg",
+				},
+			},
+		},
+		{
+			name: "If OS type is Windows and cloud is not AzurePublicCloud, it returns empty",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							OSDisk: infrav1.OSDisk{
+								OSType: "Windows",
+							},
+						},
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.USGovernmentCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+			},
+			want: []azure.ResourceSpecGetter{},
+		},
+		{
+			name: "If the bootstrap extension is disabled, it returns empty",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+						Annotations: map[string]string{
+							azure.DisableBootstrapExtensionAnnotation: "true",
+						},
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							OSDisk: infrav1.OSDisk{
+								OSType: "Linux",
+							},
+						},
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.PublicCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+			},
+			want: []azure.ResourceSpecGetter{},
+		},
+		{
+			name: "If OS type is not Linux or Windows and cloud is AzurePublicCloud, it returns empty",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							OSDisk: infrav1.OSDisk{
+								OSType: "Other",
+							},
+						},
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.PublicCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+			},
+			want: []azure.ResourceSpecGetter{},
+		},
+		{
+			name: "If OS type is not Windows or Linux and cloud is not AzurePublicCloud, it returns empty",
+			machinePoolScope: MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
 					},
 					Spec: infrav1exp.AzureMachinePoolSpec{
 						Template: infrav1exp.AzureMachinePoolMachineTemplate{
 							OSDisk: infrav1.OSDisk{
-								OSType: "Linux",
+								OSType: "Other",
 							},
 						},
 					},
@@ -726,20 +2010,20 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 			want: []azure.ResourceSpecGetter{},
 		},
 		{
-			name: "If OS type is Windows and cloud is AzurePublicCloud, it returns ExtensionSpec",
+			name: "If the bootstrap extension is skipped, it returns empty",
 			machinePoolScope: MachinePoolScope{
 				MachinePool: &expv1.MachinePool{},
 				AzureMachinePool: &infrav1exp.AzureMachinePool{
 					ObjectMeta: metav1.ObjectMeta{
-						// Note: machine pool names longer than 9 characters get truncated. See MachinePoolScope::Name() for more details.
-						Name: "winpool",
+						Name: "machinepool-name",
 					},
 					Spec: infrav1exp.AzureMachinePoolSpec{
 						Template: infrav1exp.AzureMachinePoolMachineTemplate{
 							OSDisk: infrav1.OSDisk{
-								OSType: "Windows",
+								OSType: "Linux",
 							},
 						},
+						SkipBootstrapExtension: true,
 					},
 				},
 				ClusterScoper: &ClusterScope{
@@ -757,24 +2041,10 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 					},
 				},
 			},
-			want: []azure.ResourceSpecGetter{
-				&scalesets.VMSSExtensionSpec{
-					ExtensionSpec: azure.ExtensionSpec{
-						Name: "CAPZ.Windows.Bootstrapping",
-						// Note: machine pool names longer than 9 characters get truncated. See MachinePoolScope::Name() for more details.
-						VMName:    "winpool",
-						Publisher: "Microsoft.Azure.ContainerUpstream",
-						Version:   "1.0",
-						ProtectedSettings: map[string]string{
-							"commandToExecute": azure.WindowsBootstrapExtensionCommand,
-						},
-					},
-					ResourceGroup: "my-rg",
-				},
-			},
+			want: []azure.ResourceSpecGetter{},
 		},
 		{
-			name: "If OS type is Windows and cloud is not AzurePublicCloud, it returns empty",
+			name: "If a custom bootstrap extension is set and cloud is not AzurePublicCloud, it returns the custom ExtensionSpec",
 			machinePoolScope: MachinePoolScope{
 				MachinePool: &expv1.MachinePool{},
 				AzureMachinePool: &infrav1exp.AzureMachinePool{
@@ -784,9 +2054,14 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 					Spec: infrav1exp.AzureMachinePoolSpec{
 						Template: infrav1exp.AzureMachinePoolMachineTemplate{
 							OSDisk: infrav1.OSDisk{
-								OSType: "Windows",
+								OSType: "Linux",
 							},
 						},
+						BootstrapExtension: &infrav1exp.BootstrapExtension{
+							Publisher: "Custom.Publisher",
+							Type:      "Custom.Bootstrapping",
+							Version:   "2.0",
+						},
 					},
 				},
 				ClusterScoper: &ClusterScope{
@@ -804,10 +2079,23 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 					},
 				},
 			},
-			want: []azure.ResourceSpecGetter{},
+			want: []azure.ResourceSpecGetter{
+				&scalesets.VMSSExtensionSpec{
+					ExtensionSpec: azure.ExtensionSpec{
+						Name:      "Custom.Bootstrapping",
+						VMName:    "machinepool-name",
+						Publisher: "Custom.Publisher",
+						Version:   "2.0",
+						ProtectedSettings: map[string]string{
+							"commandToExecute": azure.LinuxBootstrapExtensionCommand,
+						},
+					},
+					ResourceGroup: "my-rg",
+				},
+			},
 		},
 		{
-			name: "If OS type is not Linux or Windows and cloud is AzurePublicCloud, it returns empty",
+			name: "If a custom bootstrap extension only sets the version, it returns the built-in ExtensionSpec with that version",
 			machinePoolScope: MachinePoolScope{
 				MachinePool: &expv1.MachinePool{},
 				AzureMachinePool: &infrav1exp.AzureMachinePool{
@@ -817,9 +2105,12 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 					Spec: infrav1exp.AzureMachinePoolSpec{
 						Template: infrav1exp.AzureMachinePoolMachineTemplate{
 							OSDisk: infrav1.OSDisk{
-								OSType: "Other",
+								OSType: "Linux",
 							},
 						},
+						BootstrapExtension: &infrav1exp.BootstrapExtension{
+							Version: "2.0",
+						},
 					},
 				},
 				ClusterScoper: &ClusterScope{
@@ -837,29 +2128,150 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 					},
 				},
 			},
-			want: []azure.ResourceSpecGetter{},
+			want: []azure.ResourceSpecGetter{
+				&scalesets.VMSSExtensionSpec{
+					ExtensionSpec: azure.ExtensionSpec{
+						Name:      "CAPZ.Linux.Bootstrapping",
+						VMName:    "machinepool-name",
+						Publisher: "Microsoft.Azure.ContainerUpstream",
+						Version:   "2.0",
+						ProtectedSettings: map[string]string{
+							"commandToExecute": azure.LinuxBootstrapExtensionCommand,
+						},
+					},
+					ResourceGroup: "my-rg",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.machinePoolScope.VMSSExtensionSpecs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VMSSExtensionSpecs() = \n%s, want \n%s", specArrayToString(got), specArrayToString(tt.want))
+			}
+		})
+	}
+}
+
+func TestMachinePoolScope_ScaleSetSpec(t *testing.T) {
+	tests := []struct {
+		name                  string
+		outboundLBDisabled    bool
+		wantLBName            string
+		wantLBAddressPoolName string
+	}{
+		{
+			name:                  "adds the node outbound load balancer backend pool by default",
+			wantLBName:            "my-cluster",
+			wantLBAddressPoolName: "my-cluster-outboundBackendPool",
 		},
 		{
-			name: "If OS type is not Windows or Linux and cloud is not AzurePublicCloud, it returns empty",
-			machinePoolScope: MachinePoolScope{
+			name:               "leaves the node outbound load balancer backend pool empty when disabled",
+			outboundLBDisabled: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machinePoolScope := MachinePoolScope{
 				MachinePool: &expv1.MachinePool{},
 				AzureMachinePool: &infrav1exp.AzureMachinePool{
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "machinepool-name",
 					},
 					Spec: infrav1exp.AzureMachinePoolSpec{
-						Template: infrav1exp.AzureMachinePoolMachineTemplate{
-							OSDisk: infrav1.OSDisk{
-								OSType: "Other",
+						OutboundLBDisabled: tt.outboundLBDisabled,
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							NetworkSpec: infrav1.NetworkSpec{
+								NodeOutboundLB: &infrav1.LoadBalancerSpec{
+									Name: "my-cluster",
+								},
 							},
 						},
 					},
 				},
+			}
+
+			spec := machinePoolScope.ScaleSetSpec()
+			g.Expect(spec.PublicLBName).To(Equal(tt.wantLBName))
+			g.Expect(spec.PublicLBAddressPoolName).To(Equal(tt.wantLBAddressPoolName))
+		})
+	}
+}
+
+func TestMachinePoolScope_RoleAssignmentSpecs(t *testing.T) {
+	tests := []struct {
+		name                string
+		identity            infrav1.VMIdentity
+		roleAssignmentScope string
+		roleDefinitionID    string
+		want                []azure.ResourceSpecGetter
+	}{
+		{
+			name:     "returns empty if VMSS identity is not system assigned",
+			identity: infrav1.VMIdentityUserAssigned,
+			want:     []azure.ResourceSpecGetter{},
+		},
+		{
+			name:     "returns a contributor role assignment at the subscription scope by default",
+			identity: infrav1.VMIdentitySystemAssigned,
+			want: []azure.ResourceSpecGetter{
+				&roleassignments.RoleAssignmentSpec{
+					Name:             "azure-role-assignment-name",
+					MachineName:      "machinepool-name",
+					ResourceGroup:    "my-rg",
+					ResourceType:     azure.VirtualMachineScaleSet,
+					Scope:            azure.GenerateSubscriptionScope("123"),
+					RoleDefinitionID: azure.GenerateContributorRoleDefinitionID("123"),
+					PrincipalID:      to.StringPtr("fakePrincipalID"),
+				},
+			},
+		},
+		{
+			name:                "returns a custom role assignment at a custom scope",
+			identity:            infrav1.VMIdentitySystemAndUserAssigned,
+			roleAssignmentScope: "/subscriptions/456",
+			roleDefinitionID:    "/subscriptions/456/providers/Microsoft.Authorization/roleDefinitions/my-role",
+			want: []azure.ResourceSpecGetter{
+				&roleassignments.RoleAssignmentSpec{
+					Name:             "azure-role-assignment-name",
+					MachineName:      "machinepool-name",
+					ResourceGroup:    "my-rg",
+					ResourceType:     azure.VirtualMachineScaleSet,
+					Scope:            "/subscriptions/456",
+					RoleDefinitionID: "/subscriptions/456/providers/Microsoft.Authorization/roleDefinitions/my-role",
+					PrincipalID:      to.StringPtr("fakePrincipalID"),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machinePoolScope := MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Identity:            tt.identity,
+						RoleAssignmentName:  "azure-role-assignment-name",
+						RoleAssignmentScope: tt.roleAssignmentScope,
+						RoleDefinitionID:    tt.roleDefinitionID,
+					},
+				},
 				ClusterScoper: &ClusterScope{
 					AzureClients: AzureClients{
 						EnvironmentSettings: auth.EnvironmentSettings{
-							Environment: autorestazure.Environment{
-								Name: autorestazure.USGovernmentCloud.Name,
+							Values: map[string]string{
+								auth.SubscriptionID: "123",
 							},
 						},
 					},
@@ -869,15 +2281,126 @@ func TestMachinePoolScope_VMSSExtensionSpecs(t *testing.T) {
 						},
 					},
 				},
-			},
-			want: []azure.ResourceSpecGetter{},
+			}
+
+			g.Expect(machinePoolScope.RoleAssignmentSpecs(to.StringPtr("fakePrincipalID"))).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestMachinePoolScope_DesiredReplicas(t *testing.T) {
+	tests := []struct {
+		name                  string
+		annotations           map[string]string
+		vmssState             *azure.VMSS
+		wantReplicas          int32
+		wantManagedExternally bool
+	}{
+		{
+			name:         "uses the machine pool replicas",
+			vmssState:    &azure.VMSS{Capacity: 5},
+			wantReplicas: 3,
+		},
+		{
+			name:                  "uses the vmss capacity when the replicas are managed externally",
+			annotations:           map[string]string{azure.ReplicasManagedByAnnotation: "cluster-autoscaler"},
+			vmssState:             &azure.VMSS{Capacity: 5},
+			wantReplicas:          5,
+			wantManagedExternally: true,
+		},
+		{
+			name:                  "uses the machine pool replicas when the replicas are managed externally but the vmss is unknown",
+			annotations:           map[string]string{azure.ReplicasManagedByAnnotation: ""},
+			wantReplicas:          3,
+			wantManagedExternally: true,
 		},
 	}
 	for _, tt := range tests {
+		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.machinePoolScope.VMSSExtensionSpecs(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("VMSSExtensionSpecs() = \n%s, want \n%s", specArrayToString(got), specArrayToString(tt.want))
+			g := NewWithT(t)
+			machinePoolScope := MachinePoolScope{
+				MachinePool: &expv1.MachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: tt.annotations,
+					},
+					Spec: expv1.MachinePoolSpec{
+						Replicas: to.Int32Ptr(3),
+					},
+				},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					AzureCluster: &infrav1.AzureCluster{},
+				},
+				vmssState: tt.vmssState,
+			}
+
+			g.Expect(machinePoolScope.DesiredReplicas()).To(Equal(tt.wantReplicas))
+			g.Expect(machinePoolScope.ReplicasManagedExternally()).To(Equal(tt.wantManagedExternally))
+
+			spec := machinePoolScope.ScaleSetSpec()
+			g.Expect(spec.Capacity).To(Equal(int64(tt.wantReplicas)))
+			g.Expect(spec.ReplicasManagedExternally).To(Equal(tt.wantManagedExternally))
+		})
+	}
+}
+
+func TestMachinePoolScope_ScaleSetSpecOrchestrationMode(t *testing.T) {
+	tests := []struct {
+		name                    string
+		orchestrationMode       infrav1exp.AzureMachinePoolOrchestrationMode
+		wantOrchestrationMode   azure.MachinePoolOrchestrationMode
+		wantAvailabilitySetName string
+	}{
+		{
+			name:                  "defaults to a Virtual Machine Scale Set",
+			wantOrchestrationMode: azure.ScaleSetOrchestrationMode,
+		},
+		{
+			name:                  "with Virtual Machine Scale Set orchestration",
+			orchestrationMode:     infrav1exp.VirtualMachineScaleSetOrchestrationMode,
+			wantOrchestrationMode: azure.ScaleSetOrchestrationMode,
+		},
+		{
+			name:                    "with availability set orchestration",
+			orchestrationMode:       infrav1exp.AvailabilitySetOrchestrationMode,
+			wantOrchestrationMode:   azure.AvailabilitySetOrchestrationMode,
+			wantAvailabilitySetName: "my-cluster_machinepool-name-as",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			machinePoolScope := MachinePoolScope{
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machinepool-name",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						OrchestrationMode:  tt.orchestrationMode,
+						OutboundLBDisabled: true,
+					},
+				},
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "my-cluster",
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{},
+				},
 			}
+
+			spec := machinePoolScope.ScaleSetSpec()
+			g.Expect(spec.Name).To(Equal("machinepool-name"))
+			g.Expect(spec.OrchestrationMode).To(Equal(tt.wantOrchestrationMode))
+			g.Expect(spec.AvailabilitySetName).To(Equal(tt.wantAvailabilitySetName))
 		})
 	}
 }
@@ -912,3 +2435,186 @@ func getReadyAzureMachinePoolMachines(count int32) []infrav1exp.AzureMachinePool
 
 	return machines
 }
+
+func TestMachinePoolScope_GetBootstrapData(t *testing.T) {
+	tests := []struct {
+		name          string
+		bootstrapData string
+		fragments     []infrav1exp.CloudInitFragment
+		wantErr       string
+		verify        func(g *WithT, data string)
+	}{
+		{
+			name:          "returns the bootstrap data without additional cloud-init",
+			bootstrapData: "#cloud-config\nruncmd:\n- kubeadm join\n",
+			verify: func(g *WithT, data string) {
+				g.Expect(data).To(Equal("#cloud-config\nruncmd:\n- kubeadm join\n"))
+			},
+		},
+		{
+			name:          "merges additional cloud-init into a MIME multipart archive",
+			bootstrapData: "## template: jinja\n#cloud-config\nruncmd:\n- kubeadm join\n",
+			fragments: []infrav1exp.CloudInitFragment{
+				{ContentType: infrav1exp.CloudInitContentTypeCloudConfig, Content: "#cloud-config\nruncmd:\n- echo hello\n"},
+				{ContentType: infrav1exp.CloudInitContentTypeShellScript, Content: "#!/bin/bash\necho world\n"},
+			},
+			verify: func(g *WithT, data string) {
+				g.Expect(data).To(HavePrefix("Content-Type: multipart/mixed; boundary=\"==CAPZ-CLOUD-INIT-BOUNDARY==\"\r\nMIME-Version: 1.0\r\n"))
+				g.Expect(data).To(ContainSubstring("Content-Type: text/jinja2; charset=\"us-ascii\"\r\nMime-Version: 1.0\r\n\r\n## template: jinja\n#cloud-config\nruncmd:\n- kubeadm join\n"))
+				g.Expect(data).To(ContainSubstring("Content-Type: text/cloud-config; charset=\"us-ascii\"\r\nMerge-Type: list(append)+dict(no_replace,recurse_list)+str()\r\nMime-Version: 1.0\r\n\r\n#cloud-config\nruncmd:\n- echo hello\n"))
+				g.Expect(data).To(ContainSubstring("Content-Type: text/x-shellscript; charset=\"us-ascii\"\r\nMime-Version: 1.0\r\n\r\n#!/bin/bash\necho world\n"))
+				g.Expect(data).To(HaveSuffix("--==CAPZ-CLOUD-INIT-BOUNDARY==--\r\n"))
+			},
+		},
+		{
+			name:          "uses the merge type of a cloud-config fragment",
+			bootstrapData: "#!/bin/bash\nkubeadm join\n",
+			fragments: []infrav1exp.CloudInitFragment{
+				{ContentType: infrav1exp.CloudInitContentTypeCloudConfig, MergeType: "list(prepend)", Content: "#cloud-config\nruncmd:\n- echo hello\n"},
+			},
+			verify: func(g *WithT, data string) {
+				g.Expect(data).To(ContainSubstring("Content-Type: text/x-shellscript; charset=\"us-ascii\"\r\nMime-Version: 1.0\r\n\r\n#!/bin/bash\nkubeadm join\n"))
+				g.Expect(data).To(ContainSubstring("Merge-Type: list(prepend)\r\n"))
+			},
+		},
+		{
+			name:          "fails to merge additional cloud-init with ignition bootstrap data",
+			bootstrapData: `{"ignition":{"version":"3.1.0"}}`,
+			fragments: []infrav1exp.CloudInitFragment{
+				{ContentType: infrav1exp.CloudInitContentTypeShellScript, Content: "#!/bin/bash\necho hello\n"},
+			},
+			wantErr: "bootstrap data must be a cloud-config or a shell script to be merged with additional cloud-init",
+		},
+		{
+			name:          "fails when the merged bootstrap data exceeds the custom data limit",
+			bootstrapData: "#cloud-config\n",
+			fragments: []infrav1exp.CloudInitFragment{
+				{ContentType: infrav1exp.CloudInitContentTypeShellScript, Content: "#!/bin/bash\n" + strings.Repeat("a", maxCustomDataLength)},
+			},
+			wantErr: "exceeds the maximum custom data length of 65535 bytes",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bootstrap-data",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					"value": []byte(tt.bootstrapData),
+				},
+			}
+			machinePoolScope := MachinePoolScope{
+				client: fake.NewClientBuilder().WithObjects(secret).Build(),
+				MachinePool: &expv1.MachinePool{
+					Spec: expv1.MachinePoolSpec{
+						Template: clusterv1.MachineTemplateSpec{
+							Spec: clusterv1.MachineSpec{
+								Bootstrap: clusterv1.Bootstrap{
+									DataSecretName: to.StringPtr("bootstrap-data"),
+								},
+							},
+						},
+					},
+				},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "machinepool-name",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							AdditionalCloudInit: tt.fragments,
+						},
+					},
+				},
+			}
+
+			data, err := machinePoolScope.GetBootstrapData(context.TODO())
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			decoded, err := base64.StdEncoding.DecodeString(data)
+			g.Expect(err).NotTo(HaveOccurred())
+			tt.verify(g, string(decoded))
+		})
+	}
+}
+
+func TestMachinePoolScope_GetLinuxAdminPassword(t *testing.T) {
+	tests := []struct {
+		name         string
+		linuxConfig  *infrav1exp.LinuxConfiguration
+		secretData   map[string][]byte
+		wantPassword string
+		wantErr      string
+	}{
+		{
+			name: "returns the password of the referenced secret",
+			linuxConfig: &infrav1exp.LinuxConfiguration{
+				DisablePasswordAuthentication: to.BoolPtr(false),
+				AdminPasswordSecretName:       "admin-password",
+			},
+			secretData:   map[string][]byte{"password": []byte("s3cr3t-Passw0rd")},
+			wantPassword: "s3cr3t-Passw0rd",
+		},
+		{
+			name: "fails without a password key",
+			linuxConfig: &infrav1exp.LinuxConfiguration{
+				DisablePasswordAuthentication: to.BoolPtr(false),
+				AdminPasswordSecretName:       "admin-password",
+			},
+			secretData: map[string][]byte{"value": []byte("s3cr3t-Passw0rd")},
+			wantErr:    "admin password secret default/admin-password has no password key",
+		},
+		{
+			name: "fails without a referenced secret",
+			linuxConfig: &infrav1exp.LinuxConfiguration{
+				DisablePasswordAuthentication: to.BoolPtr(false),
+			},
+			wantErr: "AzureMachinePool default/machinepool-name enables password authentication without an admin password secret",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "admin-password",
+					Namespace: "default",
+				},
+				Data: tt.secretData,
+			}
+			machinePoolScope := MachinePoolScope{
+				client: fake.NewClientBuilder().WithObjects(secret).Build(),
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "machinepool-name",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							LinuxConfiguration: tt.linuxConfig,
+						},
+					},
+				},
+			}
+
+			password, err := machinePoolScope.GetLinuxAdminPassword(context.TODO())
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(password).To(Equal(tt.wantPassword))
+		})
+	}
+}
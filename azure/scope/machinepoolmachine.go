@@ -25,6 +25,7 @@ import (
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 	kubedrain "k8s.io/kubectl/pkg/drain"
@@ -163,6 +164,16 @@ func (s *MachinePoolMachineScope) ScaleSetName() string {
 	return s.MachinePoolScope.Name()
 }
 
+// ScaleSetResourceGroup is the resource group of the VMSS.
+func (s *MachinePoolMachineScope) ScaleSetResourceGroup() string {
+	return s.MachinePoolScope.ScaleSetResourceGroup()
+}
+
+// ForceDeletion returns true if the VMSS instance should be force deleted.
+func (s *MachinePoolMachineScope) ForceDeletion() bool {
+	return s.AzureMachinePool.Spec.ForceDeleteInstances
+}
+
 // SetLongRunningOperationState will set the future on the AzureMachinePoolMachine status to allow the resource to continue
 // in the next reconciliation.
 func (s *MachinePoolMachineScope) SetLongRunningOperationState(future *infrav1.Future) {
@@ -482,12 +493,11 @@ func (s *MachinePoolMachineScope) isNodeDrainAllowed() bool {
 	return true
 }
 
-// nodeDrainTimeoutExceeded will check to see if the AzureMachinePool's NodeDrainTimeout is exceeded for the
-// AzureMachinePoolMachine.
+// nodeDrainTimeoutExceeded will check to see if the NodeDrainTimeout is exceeded for the AzureMachinePoolMachine.
 func (s *MachinePoolMachineScope) nodeDrainTimeoutExceeded() bool {
 	// if the NodeDrainTineout type is not set by user
-	pool := s.AzureMachinePool
-	if pool == nil || pool.Spec.NodeDrainTimeout == nil || pool.Spec.NodeDrainTimeout.Seconds() <= 0 {
+	timeout := s.nodeDrainTimeout()
+	if timeout == nil || timeout.Seconds() <= 0 {
 		return false
 	}
 
@@ -499,7 +509,19 @@ func (s *MachinePoolMachineScope) nodeDrainTimeoutExceeded() bool {
 	now := time.Now()
 	firstTimeDrain := conditions.GetLastTransitionTime(s.AzureMachinePoolMachine, clusterv1.DrainingSucceededCondition)
 	diff := now.Sub(firstTimeDrain.Time)
-	return diff.Seconds() >= s.AzureMachinePool.Spec.NodeDrainTimeout.Seconds()
+	return diff.Seconds() >= timeout.Seconds()
+}
+
+// nodeDrainTimeout returns the NodeDrainTimeout the AzureMachinePoolMachine was created with, or the NodeDrainTimeout of
+// the AzureMachinePool for machines created before the timeout was propagated to them.
+func (s *MachinePoolMachineScope) nodeDrainTimeout() *metav1.Duration {
+	if s.AzureMachinePoolMachine.Spec.NodeDrainTimeout != nil {
+		return s.AzureMachinePoolMachine.Spec.NodeDrainTimeout
+	}
+	if s.AzureMachinePool != nil {
+		return s.AzureMachinePool.Spec.NodeDrainTimeout
+	}
+	return nil
 }
 
 func (s *MachinePoolMachineScope) hasLatestModelApplied(ctx context.Context) (bool, error) {
@@ -19,6 +19,7 @@ package scope
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/Azure/go-autorest/autorest/to"
@@ -35,6 +36,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// maxLinuxAgentPoolNameLength is the maximum length of the name of a Linux agent pool.
+	maxLinuxAgentPoolNameLength = 12
+	// maxWindowsAgentPoolNameLength is the maximum length of the name of a Windows agent pool.
+	maxWindowsAgentPoolNameLength = 6
+)
+
+// agentPoolNameRegex matches names which start with a lowercase letter and contain only lowercase letters and numbers.
+var agentPoolNameRegex = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
 // ManagedMachinePoolScopeParams defines the input parameters used to create a new managed
 // control plane.
 type ManagedMachinePoolScopeParams struct {
@@ -120,11 +131,55 @@ func (s *ManagedMachinePoolScope) AgentPoolAnnotations() map[string]string {
 	return s.InfraMachinePool.Annotations
 }
 
+// RemoveAgentPoolAnnotation removes the annotation with the given key from the agent pool.
+func (s *ManagedMachinePoolScope) RemoveAgentPoolAnnotation(key string) {
+	delete(s.InfraMachinePool.Annotations, key)
+}
+
 // AgentPoolSpec returns an azure.AgentPoolSpec for currently reconciled AzureManagedMachinePool.
 func (s *ManagedMachinePoolScope) AgentPoolSpec() azure.AgentPoolSpec {
 	return buildAgentPoolSpec(s.ControlPlane, s.MachinePool, s.InfraMachinePool)
 }
 
+// ValidateAgentPoolName checks that the name of the agent pool satisfies the naming rules of AKS, so that an invalid
+// name which was not caught by the webhook fails before calling Azure. The returned error is terminal.
+func (s *ManagedMachinePoolScope) ValidateAgentPoolName() error {
+	return validateAgentPoolName(to.String(s.InfraMachinePool.Spec.Name), s.InfraMachinePool.Spec.OSType)
+}
+
+func validateAgentPoolName(name string, osType *string) error {
+	maxLength := maxLinuxAgentPoolNameLength
+	if to.String(osType) == azure.WindowsOS {
+		maxLength = maxWindowsAgentPoolNameLength
+	}
+
+	if len(name) == 0 || len(name) > maxLength {
+		return azure.WithTerminalError(errors.Errorf("name %q of agent pool must be between 1 and %d characters long", name, maxLength))
+	}
+
+	if !agentPoolNameRegex.MatchString(name) {
+		return azure.WithTerminalError(errors.Errorf("name %q of agent pool must start with a lowercase letter and contain only lowercase letters and numbers", name))
+	}
+
+	return nil
+}
+
+// agentPoolTags merges the AdditionalTags of the AzureManagedControlPlane and the AzureManagedMachinePool. If the same
+// key is present in both, the value from the AzureManagedMachinePool takes precedence.
+func agentPoolTags(controlPlaneTags, machinePoolTags infrav1.Tags) infrav1.Tags {
+	if len(controlPlaneTags) == 0 && len(machinePoolTags) == 0 {
+		return nil
+	}
+
+	tags := make(infrav1.Tags)
+	// Start with the cluster-wide tags...
+	tags.Merge(controlPlaneTags)
+	// ... and merge in the agent pool's
+	tags.Merge(machinePoolTags)
+
+	return tags
+}
+
 func buildAgentPoolSpec(managedControlPlane *infrav1exp.AzureManagedControlPlane,
 	machinePool *expv1.MachinePool,
 	managedMachinePool *infrav1exp.AzureManagedMachinePool) azure.AgentPoolSpec {
@@ -153,11 +208,46 @@ func buildAgentPoolSpec(managedControlPlane *infrav1exp.AzureManagedControlPlane
 			managedControlPlane.Spec.VirtualNetwork.Name,
 			managedControlPlane.Spec.VirtualNetwork.Subnet.Name,
 		),
-		Mode:              managedMachinePool.Spec.Mode,
-		MaxPods:           managedMachinePool.Spec.MaxPods,
-		AvailabilityZones: managedMachinePool.Spec.AvailabilityZones,
-		OsDiskType:        managedMachinePool.Spec.OsDiskType,
-		EnableUltraSSD:    managedMachinePool.Spec.EnableUltraSSD,
+		Mode:                   managedMachinePool.Spec.Mode,
+		MaxPods:                managedMachinePool.Spec.MaxPods,
+		AvailabilityZones:      managedMachinePool.Spec.AvailabilityZones,
+		OsDiskType:             managedMachinePool.Spec.OsDiskType,
+		EnableUltraSSD:         managedMachinePool.Spec.EnableUltraSSD,
+		ScaleSetPriority:       managedMachinePool.Spec.ScaleSetPriority,
+		EnableNodePublicIP:     managedMachinePool.Spec.EnableNodePublicIP,
+		NodePublicIPPrefixID:   managedMachinePool.Spec.NodePublicIPPrefixID,
+		MaxSurge:               managedMachinePool.Spec.MaxSurge,
+		EnableFIPS:             managedMachinePool.Spec.EnableFIPS,
+		OSSKU:                  managedMachinePool.Spec.OSSKU,
+		AdditionalTags:         agentPoolTags(managedControlPlane.Spec.AdditionalTags, managedMachinePool.Spec.AdditionalTags),
+		GPUInstanceProfile:     managedMachinePool.Spec.GPUInstanceProfile,
+		ScaleDownMode:          managedMachinePool.Spec.ScaleDownMode,
+		PowerState:             managedMachinePool.Spec.PowerState,
+		EnableEncryptionAtHost: managedMachinePool.Spec.EnableEncryptionAtHost,
+		WorkloadRuntime:        managedMachinePool.Spec.WorkloadRuntime,
+	}
+
+	if managedMachinePool.Spec.PodSubnetName != nil {
+		agentPoolSpec.PodSubnetID = to.StringPtr(azure.SubnetID(
+			managedControlPlane.Spec.SubscriptionID,
+			managedControlPlane.Spec.ResourceGroupName,
+			managedControlPlane.Spec.VirtualNetwork.Name,
+			*managedMachinePool.Spec.PodSubnetName,
+		))
+	}
+
+	if managedControlPlane.Spec.NetworkPlugin != nil {
+		agentPoolSpec.NetworkPlugin = *managedControlPlane.Spec.NetworkPlugin
+	}
+
+	if managedMachinePool.Spec.SnapshotID != "" {
+		agentPoolSpec.CreationData = &azure.CreationData{
+			SourceResourceID: managedMachinePool.Spec.SnapshotID,
+		}
+	}
+
+	if managedMachinePool.Spec.SpotMaxPrice != nil {
+		agentPoolSpec.SpotMaxPrice = to.Float64Ptr(managedMachinePool.Spec.SpotMaxPrice.AsApproximateFloat64())
 	}
 
 	if managedMachinePool.Spec.OSDiskSizeGB != nil {
@@ -185,6 +275,44 @@ func buildAgentPoolSpec(managedControlPlane *infrav1exp.AzureManagedControlPlane
 		}
 	}
 
+	if kubeletConfig := managedMachinePool.Spec.KubeletConfig; kubeletConfig != nil {
+		agentPoolSpec.KubeletConfig = &azure.KubeletConfig{
+			CPUManagerPolicy:      kubeletConfig.CPUManagerPolicy,
+			CPUCfsQuota:           kubeletConfig.CPUCfsQuota,
+			CPUCfsQuotaPeriod:     kubeletConfig.CPUCfsQuotaPeriod,
+			ImageGcHighThreshold:  kubeletConfig.ImageGcHighThreshold,
+			ImageGcLowThreshold:   kubeletConfig.ImageGcLowThreshold,
+			TopologyManagerPolicy: kubeletConfig.TopologyManagerPolicy,
+			AllowedUnsafeSysctls:  kubeletConfig.AllowedUnsafeSysctls,
+			FailSwapOn:            kubeletConfig.FailSwapOn,
+			ContainerLogMaxSizeMB: kubeletConfig.ContainerLogMaxSizeMB,
+			ContainerLogMaxFiles:  kubeletConfig.ContainerLogMaxFiles,
+			PodMaxPids:            kubeletConfig.PodMaxPids,
+		}
+	}
+
+	if linuxOSConfig := managedMachinePool.Spec.LinuxOSConfig; linuxOSConfig != nil {
+		agentPoolSpec.LinuxOSConfig = &azure.LinuxOSConfig{
+			SwapFileSizeMB:             linuxOSConfig.SwapFileSizeMB,
+			TransparentHugePageDefrag:  linuxOSConfig.TransparentHugePageDefrag,
+			TransparentHugePageEnabled: linuxOSConfig.TransparentHugePageEnabled,
+		}
+		if sysctls := linuxOSConfig.Sysctls; sysctls != nil {
+			agentPoolSpec.LinuxOSConfig.Sysctls = &azure.SysctlConfig{
+				FsFileMax:                  sysctls.FsFileMax,
+				FsInotifyMaxUserWatches:    sysctls.FsInotifyMaxUserWatches,
+				KernelThreadsMax:           sysctls.KernelThreadsMax,
+				NetCoreSomaxconn:           sysctls.NetCoreSomaxconn,
+				NetIpv4IPLocalPortRange:    sysctls.NetIpv4IPLocalPortRange,
+				NetIpv4TCPTwReuse:          sysctls.NetIpv4TCPTwReuse,
+				NetNetfilterNfConntrackMax: sysctls.NetNetfilterNfConntrackMax,
+				VMMaxMapCount:              sysctls.VMMaxMapCount,
+				VMSwappiness:               sysctls.VMSwappiness,
+				VMVfsCachePressure:         sysctls.VMVfsCachePressure,
+			}
+		}
+	}
+
 	return agentPoolSpec
 }
 
@@ -203,6 +331,49 @@ func (s *ManagedMachinePoolScope) SetAgentPoolReady(ready bool) {
 	s.InfraMachinePool.Status.Ready = ready
 }
 
+// SetAgentPoolNodeImageVersion sets the node image version of the agent pool.
+func (s *ManagedMachinePoolScope) SetAgentPoolNodeImageVersion(nodeImageVersion string) {
+	s.InfraMachinePool.Status.NodeImageVersion = nodeImageVersion
+}
+
+// SetAgentPoolProvisioningState sets the provisioning state of the agent pool and the AgentPoolRunning condition
+// derived from it.
+func (s *ManagedMachinePoolScope) SetAgentPoolProvisioningState(state string) {
+	s.InfraMachinePool.Status.ProvisioningState = state
+	switch state {
+	case string(infrav1.Succeeded):
+		conditions.MarkTrue(s.InfraMachinePool, infrav1.AgentPoolRunningCondition)
+	case string(infrav1.Creating):
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolCreatingReason, clusterv1.ConditionSeverityInfo, "")
+	case string(infrav1.Updating):
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolUpdatingReason, clusterv1.ConditionSeverityInfo, "")
+	case "Upgrading":
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolUpgradingReason, clusterv1.ConditionSeverityInfo, "")
+	case "Scaling":
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolScalingReason, clusterv1.ConditionSeverityInfo, "")
+	case string(infrav1.Deleting):
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolDeletingReason, clusterv1.ConditionSeverityInfo, "")
+	case string(infrav1.Failed):
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolProvisionFailedReason, clusterv1.ConditionSeverityError, "agent pool is in provisioning state %s", state)
+	default:
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, state, clusterv1.ConditionSeverityInfo, "")
+	}
+}
+
+// SetAgentPoolPowerState sets the power state of the agent pool. A stopped agent pool is not running, regardless of
+// its provisioning state, so the power state must be set after the provisioning state.
+func (s *ManagedMachinePoolScope) SetAgentPoolPowerState(state string) {
+	s.InfraMachinePool.Status.PowerState = state
+	if state == azure.AgentPoolPowerStateStopped {
+		conditions.MarkFalse(s.InfraMachinePool, infrav1.AgentPoolRunningCondition, infrav1.AgentPoolStoppedReason, clusterv1.ConditionSeverityInfo, "")
+	}
+}
+
+// AgentPoolPowerState returns the most recently observed power state of the agent pool.
+func (s *ManagedMachinePoolScope) AgentPoolPowerState() string {
+	return s.InfraMachinePool.Status.PowerState
+}
+
 // SetLongRunningOperationState will set the future on the AzureManagedControlPlane status to allow the resource to continue
 // in the next reconciliation.
 func (s *ManagedMachinePoolScope) SetLongRunningOperationState(future *infrav1.Future) {
@@ -20,15 +20,20 @@ import (
 	"context"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -502,6 +507,962 @@ func TestManagedMachinePoolScope_OSDiskType(t *testing.T) {
 	}
 }
 
+func TestManagedMachinePoolScope_KubeletAndLinuxOSConfig(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "Without KubeletConfig and LinuxOSConfig",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePool("pool0", infrav1exp.NodePoolModeSystem),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:         "pool0",
+				SKU:          "Standard_D2s_v3",
+				Replicas:     1,
+				Mode:         "System",
+				Cluster:      "cluster1",
+				VnetSubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+			},
+		},
+		{
+			Name: "With KubeletConfig and LinuxOSConfig",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool: getMachinePool("pool1"),
+					InfraMachinePool: getAzureMachinePoolWithKubeletAndLinuxOSConfig("pool1",
+						&infrav1exp.KubeletConfig{
+							CPUManagerPolicy:     to.StringPtr("static"),
+							ImageGcHighThreshold: to.Int32Ptr(85),
+							ImageGcLowThreshold:  to.Int32Ptr(80),
+							AllowedUnsafeSysctls: []string{"net.*"},
+						},
+						&infrav1exp.LinuxOSConfig{
+							TransparentHugePageEnabled: to.StringPtr("never"),
+							Sysctls: &infrav1exp.SysctlConfig{
+								VMMaxMapCount: to.Int32Ptr(262144),
+							},
+						}),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:         "pool1",
+				SKU:          "Standard_D2s_v3",
+				Mode:         "User",
+				Cluster:      "cluster1",
+				Replicas:     1,
+				VnetSubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				KubeletConfig: &azure.KubeletConfig{
+					CPUManagerPolicy:     to.StringPtr("static"),
+					ImageGcHighThreshold: to.Int32Ptr(85),
+					ImageGcLowThreshold:  to.Int32Ptr(80),
+					AllowedUnsafeSysctls: []string{"net.*"},
+				},
+				LinuxOSConfig: &azure.LinuxOSConfig{
+					TransparentHugePageEnabled: to.StringPtr("never"),
+					Sysctls: &azure.SysctlConfig{
+						VMMaxMapCount: to.Int32Ptr(262144),
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_Spot(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "Spot with SpotMaxPrice of -1",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePoolWithSpot("pool0", "-1"),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:             "pool0",
+				SKU:              "Standard_D2s_v3",
+				Mode:             "User",
+				Cluster:          "cluster1",
+				Replicas:         1,
+				VnetSubnetID:     "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				ScaleSetPriority: to.StringPtr("Spot"),
+				SpotMaxPrice:     to.Float64Ptr(-1),
+			},
+		},
+		{
+			Name: "Spot with positive SpotMaxPrice",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool1"),
+					InfraMachinePool: getAzureMachinePoolWithSpot("pool1", "0.25"),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:             "pool1",
+				SKU:              "Standard_D2s_v3",
+				Mode:             "User",
+				Cluster:          "cluster1",
+				Replicas:         1,
+				VnetSubnetID:     "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				ScaleSetPriority: to.StringPtr("Spot"),
+				SpotMaxPrice:     to.Float64Ptr(0.25),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_NodePublicIP(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "With node public IP and prefix",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePoolWithNodePublicIP("pool0", "my-prefix"),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:                 "pool0",
+				SKU:                  "Standard_D2s_v3",
+				Mode:                 "User",
+				Cluster:              "cluster1",
+				Replicas:             1,
+				VnetSubnetID:         "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				EnableNodePublicIP:   to.BoolPtr(true),
+				NodePublicIPPrefixID: to.StringPtr("my-prefix"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_PodSubnet(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "With pod subnet",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID:    "00000000-0000-0000-0000-000000000000",
+						ResourceGroupName: "my-rg",
+						VirtualNetwork: infrav1exp.ManagedControlPlaneVirtualNetwork{
+							Name: "my-vnet",
+							Subnet: infrav1exp.ManagedControlPlaneSubnet{
+								Name: "node-subnet",
+							},
+						},
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePoolWithPodSubnet("pool0", "pod-subnet"),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:          "pool0",
+				ResourceGroup: "my-rg",
+				SKU:           "Standard_D2s_v3",
+				Mode:          "User",
+				Cluster:       "cluster1",
+				Replicas:      1,
+				VnetSubnetID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/node-subnet",
+				PodSubnetID:   to.StringPtr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/pod-subnet"),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_SnapshotID(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	snapshotID := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/my-snapshot"
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "Without snapshot",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePool("pool0", infrav1exp.NodePoolModeUser),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:         "pool0",
+				SKU:          "Standard_D2s_v3",
+				Mode:         "User",
+				Cluster:      "cluster1",
+				Replicas:     1,
+				VnetSubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+			},
+		},
+		{
+			Name: "With snapshot",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool1"),
+					InfraMachinePool: getAzureMachinePoolWithSnapshot("pool1", snapshotID),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:         "pool1",
+				SKU:          "Standard_D2s_v3",
+				Mode:         "User",
+				Cluster:      "cluster1",
+				Replicas:     1,
+				VnetSubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				CreationData: &azure.CreationData{
+					SourceResourceID: snapshotID,
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_ScaleDownMode(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "With Delete scale-down mode",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePoolWithScaleDownMode("pool0", infrav1exp.ScaleDownModeDelete),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:          "pool0",
+				SKU:           "Standard_D2s_v3",
+				Mode:          "User",
+				Cluster:       "cluster1",
+				Replicas:      1,
+				VnetSubnetID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				ScaleDownMode: "Delete",
+			},
+		},
+		{
+			Name: "With Deallocate scale-down mode",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool1"),
+					InfraMachinePool: getAzureMachinePoolWithScaleDownMode("pool1", infrav1exp.ScaleDownModeDeallocate),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:          "pool1",
+				SKU:           "Standard_D2s_v3",
+				Mode:          "User",
+				Cluster:       "cluster1",
+				Replicas:      1,
+				VnetSubnetID:  "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				ScaleDownMode: "Deallocate",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_EncryptionAtHost(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "Without encryption at host",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePool("pool0", infrav1exp.NodePoolModeUser),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:         "pool0",
+				SKU:          "Standard_D2s_v3",
+				Mode:         "User",
+				Cluster:      "cluster1",
+				Replicas:     1,
+				VnetSubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+			},
+		},
+		{
+			Name: "With encryption at host",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool1"),
+					InfraMachinePool: getAzureMachinePoolWithEncryptionAtHost("pool1"),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:                   "pool1",
+				SKU:                    "Standard_D2s_v3",
+				Mode:                   "User",
+				Cluster:                "cluster1",
+				Replicas:               1,
+				VnetSubnetID:           "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				EnableEncryptionAtHost: to.BoolPtr(true),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_WorkloadRuntime(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = expv1.AddToScheme(scheme)
+	_ = infrav1exp.AddToScheme(scheme)
+
+	cases := []struct {
+		Name     string
+		Input    ManagedMachinePoolScopeParams
+		Expected azure.AgentPoolSpec
+	}{
+		{
+			Name: "Without workload runtime",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool0"),
+					InfraMachinePool: getAzureMachinePool("pool0", infrav1exp.NodePoolModeUser),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:         "pool0",
+				SKU:          "Standard_D2s_v3",
+				Mode:         "User",
+				Cluster:      "cluster1",
+				Replicas:     1,
+				VnetSubnetID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+			},
+		},
+		{
+			Name: "With WasmWasi workload runtime",
+			Input: ManagedMachinePoolScopeParams{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1",
+						Namespace: "default",
+					},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						SubscriptionID: "00000000-0000-0000-0000-000000000000",
+					},
+				},
+				ManagedMachinePool: ManagedMachinePool{
+					MachinePool:      getMachinePool("pool1"),
+					InfraMachinePool: getAzureMachinePoolWithWorkloadRuntime("pool1", infrav1exp.WorkloadRuntimeWasmWasi),
+				},
+			},
+			Expected: azure.AgentPoolSpec{
+				Name:            "pool1",
+				SKU:             "Standard_D2s_v3",
+				Mode:            "User",
+				Cluster:         "cluster1",
+				Replicas:        1,
+				VnetSubnetID:    "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups//providers/Microsoft.Network/virtualNetworks//subnets/",
+				WorkloadRuntime: "WasmWasi",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.Input.MachinePool, c.Input.InfraMachinePool, c.Input.ControlPlane).Build()
+			c.Input.Client = fakeClient
+			s, err := NewManagedMachinePoolScope(context.TODO(), c.Input)
+			g.Expect(err).To(Succeed())
+			agentPool := s.AgentPoolSpec()
+			g.Expect(agentPool).To(Equal(c.Expected))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_AdditionalTags(t *testing.T) {
+	cases := []struct {
+		Name             string
+		ControlPlaneTags infrav1.Tags
+		MachinePoolTags  infrav1.Tags
+		Expected         infrav1.Tags
+	}{
+		{
+			Name: "Without tags",
+		},
+		{
+			Name:             "With control plane tags only",
+			ControlPlaneTags: infrav1.Tags{"env": "prod"},
+			Expected:         infrav1.Tags{"env": "prod"},
+		},
+		{
+			Name:            "With machine pool tags only",
+			MachinePoolTags: infrav1.Tags{"team": "payments"},
+			Expected:        infrav1.Tags{"team": "payments"},
+		},
+		{
+			Name:             "Machine pool tag takes precedence",
+			ControlPlaneTags: infrav1.Tags{"env": "prod", "owner": "platform"},
+			MachinePoolTags:  infrav1.Tags{"env": "staging", "team": "payments"},
+			Expected:         infrav1.Tags{"env": "staging", "owner": "platform", "team": "payments"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			controlPlane := &infrav1exp.AzureManagedControlPlane{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster1",
+					Namespace: "default",
+				},
+				Spec: infrav1exp.AzureManagedControlPlaneSpec{
+					AdditionalTags: c.ControlPlaneTags,
+				},
+			}
+			machinePool := getAzureMachinePool("pool0", infrav1exp.NodePoolModeUser)
+			machinePool.Spec.AdditionalTags = c.MachinePoolTags
+
+			agentPool := buildAgentPoolSpec(controlPlane, getMachinePool("pool0"), machinePool)
+			g.Expect(agentPool.AdditionalTags).To(Equal(c.Expected))
+			// The tags of the control plane are not modified by merging.
+			g.Expect(controlPlane.Spec.AdditionalTags).To(Equal(c.ControlPlaneTags))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_ValidateAgentPoolName(t *testing.T) {
+	cases := []struct {
+		Name        string
+		PoolName    *string
+		OSType      *string
+		ExpectedErr string
+	}{
+		{
+			Name:     "Valid Linux name",
+			PoolName: to.StringPtr("pool0"),
+			OSType:   to.StringPtr(azure.LinuxOS),
+		},
+		{
+			Name:     "Valid Linux name with maximum length",
+			PoolName: to.StringPtr("abcdefghijkl"),
+		},
+		{
+			Name:     "Valid Windows name",
+			PoolName: to.StringPtr("win0"),
+			OSType:   to.StringPtr(azure.WindowsOS),
+		},
+		{
+			Name:        "Missing name",
+			ExpectedErr: `reconcile error that cannot be recovered occurred: name "" of agent pool must be between 1 and 12 characters long. Object will not be requeued`,
+		},
+		{
+			Name:        "Too long Linux name",
+			PoolName:    to.StringPtr("abcdefghijklm"),
+			OSType:      to.StringPtr(azure.LinuxOS),
+			ExpectedErr: `reconcile error that cannot be recovered occurred: name "abcdefghijklm" of agent pool must be between 1 and 12 characters long. Object will not be requeued`,
+		},
+		{
+			Name:        "Too long Windows name",
+			PoolName:    to.StringPtr("windows"),
+			OSType:      to.StringPtr(azure.WindowsOS),
+			ExpectedErr: `reconcile error that cannot be recovered occurred: name "windows" of agent pool must be between 1 and 6 characters long. Object will not be requeued`,
+		},
+		{
+			Name:        "Name with uppercase letters",
+			PoolName:    to.StringPtr("Pool0"),
+			ExpectedErr: `reconcile error that cannot be recovered occurred: name "Pool0" of agent pool must start with a lowercase letter and contain only lowercase letters and numbers. Object will not be requeued`,
+		},
+		{
+			Name:        "Name with invalid characters",
+			PoolName:    to.StringPtr("pool-0"),
+			ExpectedErr: `reconcile error that cannot be recovered occurred: name "pool-0" of agent pool must start with a lowercase letter and contain only lowercase letters and numbers. Object will not be requeued`,
+		},
+		{
+			Name:        "Name starting with a number",
+			PoolName:    to.StringPtr("0pool"),
+			ExpectedErr: `reconcile error that cannot be recovered occurred: name "0pool" of agent pool must start with a lowercase letter and contain only lowercase letters and numbers. Object will not be requeued`,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			s := &ManagedMachinePoolScope{
+				InfraMachinePool: &infrav1exp.AzureManagedMachinePool{
+					Spec: infrav1exp.AzureManagedMachinePoolSpec{
+						Name:   c.PoolName,
+						OSType: c.OSType,
+					},
+				},
+			}
+
+			err := s.ValidateAgentPoolName()
+			if c.ExpectedErr != "" {
+				g.Expect(err).To(MatchError(c.ExpectedErr))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_SetAgentPoolProvisioningState(t *testing.T) {
+	cases := []struct {
+		Name              string
+		ProvisioningState string
+		ExpectedStatus    corev1.ConditionStatus
+		ExpectedReason    string
+		ExpectedSeverity  clusterv1.ConditionSeverity
+	}{
+		{
+			Name:              "Succeeded",
+			ProvisioningState: "Succeeded",
+			ExpectedStatus:    corev1.ConditionTrue,
+		},
+		{
+			Name:              "Creating",
+			ProvisioningState: "Creating",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    infrav1.AgentPoolCreatingReason,
+			ExpectedSeverity:  clusterv1.ConditionSeverityInfo,
+		},
+		{
+			Name:              "Updating",
+			ProvisioningState: "Updating",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    infrav1.AgentPoolUpdatingReason,
+			ExpectedSeverity:  clusterv1.ConditionSeverityInfo,
+		},
+		{
+			Name:              "Upgrading",
+			ProvisioningState: "Upgrading",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    infrav1.AgentPoolUpgradingReason,
+			ExpectedSeverity:  clusterv1.ConditionSeverityInfo,
+		},
+		{
+			Name:              "Scaling",
+			ProvisioningState: "Scaling",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    infrav1.AgentPoolScalingReason,
+			ExpectedSeverity:  clusterv1.ConditionSeverityInfo,
+		},
+		{
+			Name:              "Deleting",
+			ProvisioningState: "Deleting",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    infrav1.AgentPoolDeletingReason,
+			ExpectedSeverity:  clusterv1.ConditionSeverityInfo,
+		},
+		{
+			Name:              "Failed",
+			ProvisioningState: "Failed",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    infrav1.AgentPoolProvisionFailedReason,
+			ExpectedSeverity:  clusterv1.ConditionSeverityError,
+		},
+		{
+			Name:              "unknown state",
+			ProvisioningState: "Migrating",
+			ExpectedStatus:    corev1.ConditionFalse,
+			ExpectedReason:    "Migrating",
+			ExpectedSeverity:  clusterv1.ConditionSeverityInfo,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			s := &ManagedMachinePoolScope{
+				InfraMachinePool: getAzureMachinePool("pool0", infrav1exp.NodePoolModeUser),
+			}
+			s.SetAgentPoolProvisioningState(c.ProvisioningState)
+			g.Expect(s.InfraMachinePool.Status.ProvisioningState).To(Equal(c.ProvisioningState))
+			condition := conditions.Get(s.InfraMachinePool, infrav1.AgentPoolRunningCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(c.ExpectedStatus))
+			g.Expect(condition.Reason).To(Equal(c.ExpectedReason))
+			g.Expect(condition.Severity).To(Equal(c.ExpectedSeverity))
+		})
+	}
+}
+
+func TestManagedMachinePoolScope_SetAgentPoolPowerState(t *testing.T) {
+	cases := []struct {
+		Name           string
+		PowerState     string
+		ExpectedStatus corev1.ConditionStatus
+		ExpectedReason string
+	}{
+		{
+			Name:           "Running",
+			PowerState:     "Running",
+			ExpectedStatus: corev1.ConditionTrue,
+		},
+		{
+			Name:           "Stopped",
+			PowerState:     "Stopped",
+			ExpectedStatus: corev1.ConditionFalse,
+			ExpectedReason: infrav1.AgentPoolStoppedReason,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			s := &ManagedMachinePoolScope{
+				InfraMachinePool: getAzureMachinePool("pool0", infrav1exp.NodePoolModeUser),
+			}
+			s.SetAgentPoolProvisioningState("Succeeded")
+			s.SetAgentPoolPowerState(c.PowerState)
+			g.Expect(s.InfraMachinePool.Status.PowerState).To(Equal(c.PowerState))
+			g.Expect(s.AgentPoolPowerState()).To(Equal(c.PowerState))
+			condition := conditions.Get(s.InfraMachinePool, infrav1.AgentPoolRunningCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(c.ExpectedStatus))
+			g.Expect(condition.Reason).To(Equal(c.ExpectedReason))
+		})
+	}
+}
+
 func getAzureMachinePool(name string, mode infrav1exp.NodePoolMode) *infrav1exp.AzureManagedMachinePool {
 	return &infrav1exp.AzureManagedMachinePool{
 		ObjectMeta: metav1.ObjectMeta{
@@ -559,6 +1520,58 @@ func getAzureMachinePoolWithLabels(name string, nodeLabels map[string]string) *i
 	return managedPool
 }
 
+func getAzureMachinePoolWithKubeletAndLinuxOSConfig(name string, kubeletConfig *infrav1exp.KubeletConfig, linuxOSConfig *infrav1exp.LinuxOSConfig) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.KubeletConfig = kubeletConfig
+	managedPool.Spec.LinuxOSConfig = linuxOSConfig
+	return managedPool
+}
+
+func getAzureMachinePoolWithSpot(name string, spotMaxPrice string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	maxPrice := resource.MustParse(spotMaxPrice)
+	managedPool.Spec.ScaleSetPriority = to.StringPtr(infrav1exp.ScaleSetPrioritySpot)
+	managedPool.Spec.SpotMaxPrice = &maxPrice
+	return managedPool
+}
+
+func getAzureMachinePoolWithNodePublicIP(name string, nodePublicIPPrefixID string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.EnableNodePublicIP = to.BoolPtr(true)
+	managedPool.Spec.NodePublicIPPrefixID = to.StringPtr(nodePublicIPPrefixID)
+	return managedPool
+}
+
+func getAzureMachinePoolWithPodSubnet(name string, podSubnetName string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.PodSubnetName = to.StringPtr(podSubnetName)
+	return managedPool
+}
+
+func getAzureMachinePoolWithSnapshot(name string, snapshotID string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.SnapshotID = snapshotID
+	return managedPool
+}
+
+func getAzureMachinePoolWithScaleDownMode(name string, scaleDownMode string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.ScaleDownMode = scaleDownMode
+	return managedPool
+}
+
+func getAzureMachinePoolWithEncryptionAtHost(name string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.EnableEncryptionAtHost = to.BoolPtr(true)
+	return managedPool
+}
+
+func getAzureMachinePoolWithWorkloadRuntime(name string, workloadRuntime string) *infrav1exp.AzureManagedMachinePool {
+	managedPool := getAzureMachinePool(name, infrav1exp.NodePoolModeUser)
+	managedPool.Spec.WorkloadRuntime = workloadRuntime
+	return managedPool
+}
+
 func getMachinePool(name string) *expv1.MachinePool {
 	return &expv1.MachinePool{
 		ObjectMeta: metav1.ObjectMeta{
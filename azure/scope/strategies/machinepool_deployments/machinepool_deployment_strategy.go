@@ -25,8 +25,10 @@ import (
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -103,7 +105,8 @@ func (rollingUpdateStrategy *rollingUpdateStrategy) maxUnavailable(desiredReplic
 }
 
 // SelectMachinesToDelete selects the machines to delete based on the machine state, desired replica count, and
-// the DeletePolicy.
+// the DeletePolicy. Machines marked for deletion are selected first, while machines protected from deletion are only
+// selected if no other machine can be selected.
 func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx context.Context, desiredReplicaCount int32, machinesByProviderID map[string]infrav1exp.AzureMachinePoolMachine) ([]infrav1exp.AzureMachinePoolMachine, error) {
 	ctx, _, done := tele.StartSpanWithLogger(
 		ctx,
@@ -117,7 +120,7 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 	}
 
 	var (
-		order = func() func(machines []infrav1exp.AzureMachinePoolMachine) []infrav1exp.AzureMachinePoolMachine {
+		orderByDeletePolicy = func() func(machines []infrav1exp.AzureMachinePoolMachine) []infrav1exp.AzureMachinePoolMachine {
 			switch rollingUpdateStrategy.DeletePolicy {
 			case infrav1exp.OldestDeletePolicyType:
 				return orderByOldest
@@ -127,6 +130,10 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 				return orderRandom
 			}
 		}()
+		// machines marked for deletion with the Cluster API delete-machine annotation are deleted first
+		order = func(machines []infrav1exp.AzureMachinePoolMachine) []infrav1exp.AzureMachinePoolMachine {
+			return orderMarkedForDeletionFirst(orderByDeletePolicy(machines))
+		}
 		log                        = ctrl.LoggerFrom(ctx).V(4)
 		failedMachines             = order(getFailedMachines(machinesByProviderID))
 		deletingMachines           = order(getDeletingMachines(machinesByProviderID))
@@ -142,6 +149,10 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 		}()
 	)
 
+	// machines protected from deletion are only selected if no other machine can be selected
+	unprotectedReadyMachines, protectedReadyMachines := partitionProtectedMachines(readyMachines)
+	unprotectedMachinesWithoutLatestModel, protectedMachinesWithoutLatestModel := partitionProtectedMachines(machinesWithoutLatestModel)
+
 	log.Info("selecting machines to delete",
 		"readyMachines", len(readyMachines),
 		"desiredReplicaCount", desiredReplicaCount,
@@ -150,6 +161,7 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 		"machinesWithoutTheLatestModel", len(machinesWithoutLatestModel),
 		"failedMachines", len(failedMachines),
 		"deletingMachines", len(deletingMachines),
+		"protectedMachines", len(protectedReadyMachines)+len(protectedMachinesWithoutLatestModel),
 	)
 
 	// if we have failed or deleting machines, remove them
@@ -175,7 +187,7 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 		var toDelete []infrav1exp.AzureMachinePoolMachine
 		log.Info("over-provisioned", "desiredReplicaCount", desiredReplicaCount, "overProvisionCount", overProvisionCount, "machinesWithoutLatestModel", getProviderIDs(machinesWithoutLatestModel))
 		// we are over-provisioned try to remove old models
-		for _, v := range machinesWithoutLatestModel {
+		for _, v := range unprotectedMachinesWithoutLatestModel {
 			if len(toDelete) >= overProvisionCount {
 				return toDelete, nil
 			}
@@ -185,7 +197,18 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 
 		log.Info("over-provisioned ready", "desiredReplicaCount", desiredReplicaCount, "overProvisionCount", overProvisionCount, "readyMachines", getProviderIDs(readyMachines))
 		// remove ready machines
-		for _, v := range readyMachines {
+		for _, v := range unprotectedReadyMachines {
+			if len(toDelete) >= overProvisionCount {
+				return toDelete, nil
+			}
+
+			toDelete = append(toDelete, v)
+		}
+
+		protectedMachines := append(protectedMachinesWithoutLatestModel, protectedReadyMachines...)
+		log.Info("over-provisioned protected", "desiredReplicaCount", desiredReplicaCount, "overProvisionCount", overProvisionCount, "protectedMachines", getProviderIDs(protectedMachines))
+		// remove machines protected from deletion only if there are no other machines left to remove
+		for _, v := range protectedMachines {
 			if len(toDelete) >= overProvisionCount {
 				return toDelete, nil
 			}
@@ -208,7 +231,7 @@ func (rollingUpdateStrategy rollingUpdateStrategy) SelectMachinesToDelete(ctx co
 
 	var toDelete []infrav1exp.AzureMachinePoolMachine
 	log.Info("removing ready machines within disruption budget", "desiredReplicaCount", desiredReplicaCount, "maxUnavailable", maxUnavailable, "readyMachines", getProviderIDs(readyMachines), "readyMachinesCount", len(readyMachines))
-	for _, v := range readyMachines {
+	for _, v := range append(unprotectedReadyMachines, protectedReadyMachines...) {
 		if len(toDelete) >= disruptionBudget {
 			return toDelete, nil
 		}
@@ -279,6 +302,42 @@ func getMachinesWithoutLatestModel(machinesByProviderID map[string]infrav1exp.Az
 	return machinesWithLatestModel
 }
 
+// IsProtectedFromDeletion returns true if the machine is annotated to be protected from deletion.
+func IsProtectedFromDeletion(machine infrav1exp.AzureMachinePoolMachine) bool {
+	_, ok := machine.GetAnnotations()[azure.DoNotDeleteAnnotation]
+	return ok
+}
+
+// partitionProtectedMachines splits the machines into the machines which are not protected from deletion and the
+// machines which are, preserving their order.
+func partitionProtectedMachines(machines []infrav1exp.AzureMachinePoolMachine) (unprotected, protected []infrav1exp.AzureMachinePoolMachine) {
+	for _, v := range machines {
+		if IsProtectedFromDeletion(v) {
+			protected = append(protected, v)
+		} else {
+			unprotected = append(unprotected, v)
+		}
+	}
+
+	return unprotected, protected
+}
+
+// orderMarkedForDeletionFirst moves the machines annotated with the Cluster API delete-machine annotation to the front
+// of the given machines, keeping the order otherwise.
+func orderMarkedForDeletionFirst(machines []infrav1exp.AzureMachinePoolMachine) []infrav1exp.AzureMachinePoolMachine {
+	sort.SliceStable(machines, func(i, j int) bool {
+		return isMarkedForDeletion(machines[i]) && !isMarkedForDeletion(machines[j])
+	})
+
+	return machines
+}
+
+// isMarkedForDeletion returns true if the machine is annotated with the Cluster API delete-machine annotation.
+func isMarkedForDeletion(machine infrav1exp.AzureMachinePoolMachine) bool {
+	_, ok := machine.GetAnnotations()[clusterv1.DeleteMachineAnnotation]
+	return ok
+}
+
 func orderByNewest(machines []infrav1exp.AzureMachinePoolMachine) []infrav1exp.AzureMachinePoolMachine {
 	sort.Slice(machines, func(i, j int) bool {
 		return machines[i].ObjectMeta.CreationTimestamp.After(machines[j].ObjectMeta.CreationTimestamp.Time)
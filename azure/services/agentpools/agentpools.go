@@ -19,15 +19,21 @@ package agentpools
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
+	azuresdk "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/converters"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/util/maps"
+	"sigs.k8s.io/cluster-api-provider-azure/util/slice"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
@@ -39,23 +45,30 @@ type ManagedMachinePoolScope interface {
 
 	NodeResourceGroup() string
 	AgentPoolAnnotations() map[string]string
+	RemoveAgentPoolAnnotation(string)
 	AgentPoolSpec() azure.AgentPoolSpec
 	SetAgentPoolProviderIDList([]string)
 	SetAgentPoolReplicas(int32)
 	SetAgentPoolReady(bool)
+	SetAgentPoolNodeImageVersion(string)
+	SetAgentPoolProvisioningState(string)
+	SetAgentPoolPowerState(string)
+	AgentPoolPowerState() string
 }
 
 // Service provides operations on Azure resources.
 type Service struct {
 	scope ManagedMachinePoolScope
 	Client
+	resourceSKUCache *resourceskus.Cache
 }
 
 // New creates a new service.
-func New(scope ManagedMachinePoolScope) *Service {
+func New(scope ManagedMachinePoolScope, skuCache *resourceskus.Cache) *Service {
 	return &Service{
-		scope:  scope,
-		Client: NewClient(scope),
+		scope:            scope,
+		Client:           NewClient(scope),
+		resourceSKUCache: skuCache,
 	}
 }
 
@@ -73,6 +86,17 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	defer done()
 
 	agentPoolSpec := s.scope.AgentPoolSpec()
+	if agentPoolSpec.PodSubnetID != nil && strings.EqualFold(*agentPoolSpec.PodSubnetID, agentPoolSpec.VnetSubnetID) {
+		return azure.WithTerminalError(errors.Errorf("pod subnet %s of agent pool %s must be different from its node subnet",
+			*agentPoolSpec.PodSubnetID, agentPoolSpec.Name))
+	}
+	if agentPoolSpec.MaxPods != nil {
+		minPods, maxPods := maxPodsBounds(agentPoolSpec.NetworkPlugin)
+		if *agentPoolSpec.MaxPods < minPods || *agentPoolSpec.MaxPods > maxPods {
+			return azure.WithTerminalError(errors.Errorf("max pods %d of agent pool %s must be between %d and %d for network plugin %s",
+				*agentPoolSpec.MaxPods, agentPoolSpec.Name, minPods, maxPods, networkPluginOrDefault(agentPoolSpec.NetworkPlugin)))
+		}
+	}
 	profile := converters.AgentPoolToContainerServiceAgentPool(agentPoolSpec)
 
 	existingPool, err := s.Client.Get(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name)
@@ -87,21 +111,95 @@ func (s *Service) Reconcile(ctx context.Context) error {
 
 	customHeaders := maps.FilterByKeyPrefix(s.scope.AgentPoolAnnotations(), azure.CustomHeaderPrefix)
 	if isCreate := azure.ResourceNotFound(err); isCreate {
+		if err := s.validateAvailabilityZones(ctx, agentPoolSpec); err != nil {
+			return err
+		}
+		if err := s.validateNodePublicIPPrefix(ctx, agentPoolSpec); err != nil {
+			return err
+		}
+		// AKS rejects autoscaled agent pools with an initial node count outside of the autoscaler bounds.
+		if to.Bool(profile.EnableAutoScaling) {
+			profile.Count = to.Int32Ptr(boundedCount(to.Int32(profile.Count), profile.MinCount, profile.MaxCount))
+		}
 		err = s.Client.CreateOrUpdate(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name,
 			profile, customHeaders)
 		if err != nil && azure.ResourceNotFound(err) {
 			return azure.WithTransientError(errors.Wrap(err, "agent pool dependent resource does not exist yet"), 20*time.Second)
+		} else if err != nil && to.Bool(agentPoolSpec.EnableEncryptionAtHost) && strings.Contains(err.Error(), "EncryptionAtHost") {
+			return errors.Wrap(err, "failed to create agent pool with encryption at host, make sure the EncryptionAtHost feature is registered for the Microsoft.Compute resource provider of the subscription")
 		} else if err != nil {
 			return errors.Wrap(err, "failed to create or update agent pool")
 		}
+
+		// AKS always creates agent pools running, so an agent pool which should be stopped is stopped by the update
+		// of the next reconcile instead of blocking this reconcile for a second long running operation.
+		if agentPoolSpec.PowerState == infrav1exp.PowerStateStopped {
+			s.scope.SetAgentPoolPowerState(azure.AgentPoolPowerStateRunning)
+			return azure.WithTransientError(errors.Errorf("agent pool %s was created running and is stopped on the next reconcile", agentPoolSpec.Name), 20*time.Second)
+		}
 	} else {
+		s.scope.SetAgentPoolNodeImageVersion(to.String(existingPool.NodeImageVersion))
+
 		ps := *existingPool.ManagedClusterAgentPoolProfileProperties.ProvisioningState
+		s.scope.SetAgentPoolProvisioningState(ps)
+		var powerState string
+		if existingPool.PowerState != nil {
+			powerState = string(existingPool.PowerState.Code)
+		}
+		s.scope.SetAgentPoolPowerState(powerState)
 		if ps != string(infrav1.Canceled) && ps != string(infrav1.Failed) && ps != string(infrav1.Succeeded) {
 			msg := fmt.Sprintf("Unable to update existing agent pool in non terminal state. Agent pool must be in one of the following provisioning states: canceled, failed, or succeeded. Actual state: %s", ps)
 			log.V(2).Info(msg)
 			return azure.WithTransientError(errors.New(msg), 20*time.Second)
 		}
 
+		// AKS only starts or stops an agent pool in an update which changes nothing else, so the power state is
+		// changed on its own first. Other changes are applied once the agent pool is running. Like every other
+		// operation of this service, the update waits for its completion instead of being tracked as a future, since
+		// AzureManagedMachinePools keep no long running operation states. The number of agent pool operations running
+		// concurrently per managed cluster can be limited with the --managed-cluster-agentpool-concurrency flag.
+		if agentPoolSpec.PowerState != "" && powerState != "" && agentPoolSpec.PowerState != powerState {
+			log.V(2).Info(fmt.Sprintf("changing power state of agent pool %s from %s to %s", agentPoolSpec.Name, powerState, agentPoolSpec.PowerState))
+			existingPool.PowerState = &containerservice.PowerState{Code: containerservice.Code(agentPoolSpec.PowerState)}
+			err = s.Client.CreateOrUpdate(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name,
+				existingPool, customHeaders)
+			if err != nil {
+				return errors.Wrapf(err, "failed to change power state of agent pool %s to %s", agentPoolSpec.Name, agentPoolSpec.PowerState)
+			}
+			// the operation has completed, so the agent pool is provisioned in its new power state
+			powerState = agentPoolSpec.PowerState
+			s.scope.SetAgentPoolProvisioningState(string(infrav1.Succeeded))
+			s.scope.SetAgentPoolPowerState(powerState)
+		}
+
+		// AKS rejects updates of stopped agent pools, so leave the agent pool as is until it has been started again.
+		if powerState == azure.AgentPoolPowerStateStopped {
+			log.V(2).Info(fmt.Sprintf("agent pool %s is stopped, skipping update", agentPoolSpec.Name))
+			return nil
+		}
+
+		if to.Bool(existingPool.EnableFIPS) != to.Bool(profile.EnableFIPS) {
+			return azure.WithTerminalError(errors.Errorf("cannot change EnableFIPS of existing agent pool %s from %t to %t, FIPS can only be set at creation time",
+				agentPoolSpec.Name, to.Bool(existingPool.EnableFIPS), to.Bool(profile.EnableFIPS)))
+		}
+
+		// AKS cannot change the max pods of existing agent pools, the change would never be applied.
+		if existingPool.MaxPods != nil && profile.MaxPods != nil && *existingPool.MaxPods != *profile.MaxPods {
+			return azure.WithTerminalError(errors.Errorf("cannot change MaxPods of existing agent pool %s from %d to %d, max pods can only be set at creation time",
+				agentPoolSpec.Name, *existingPool.MaxPods, *profile.MaxPods))
+		}
+
+		if to.Bool(existingPool.EnableEncryptionAtHost) != to.Bool(profile.EnableEncryptionAtHost) {
+			return azure.WithTerminalError(errors.Errorf("cannot change EnableEncryptionAtHost of existing agent pool %s from %t to %t, encryption at host can only be set at creation time",
+				agentPoolSpec.Name, to.Bool(existingPool.EnableEncryptionAtHost), to.Bool(profile.EnableEncryptionAtHost)))
+		}
+
+		// The cluster autoscaler owns the node count of autoscaled agent pools, so the count reported by AKS is
+		// authoritative and only brought back within the autoscaler bounds instead of reset to the desired replicas.
+		if to.Bool(profile.EnableAutoScaling) && existingPool.Count != nil {
+			profile.Count = to.Int32Ptr(boundedCount(*existingPool.Count, profile.MinCount, profile.MaxCount))
+		}
+
 		// Normalize individual agent pools to diff in case we need to update
 		existingProfile := containerservice.AgentPool{
 			ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
@@ -111,6 +209,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 				EnableAutoScaling:   existingPool.EnableAutoScaling,
 				MinCount:            existingPool.MinCount,
 				MaxCount:            existingPool.MaxCount,
+				ScaleDownMode:       scaleDownModeOrDefault(existingPool.ScaleDownMode),
 			},
 		}
 
@@ -122,13 +221,43 @@ func (s *Service) Reconcile(ctx context.Context) error {
 				EnableAutoScaling:   profile.EnableAutoScaling,
 				MinCount:            profile.MinCount,
 				MaxCount:            profile.MaxCount,
+				ScaleDownMode:       scaleDownModeOrDefault(profile.ScaleDownMode),
 			},
 		}
 
+		// An empty set of tags is equivalent to no tags.
+		if len(existingPool.Tags) > 0 {
+			existingProfile.Tags = existingPool.Tags
+		}
+		if len(profile.Tags) > 0 {
+			normalizedProfile.Tags = profile.Tags
+		}
+
+		// Only diff the upgrade settings if they are specified, as AKS keeps the existing settings otherwise.
+		if profile.UpgradeSettings != nil {
+			existingProfile.UpgradeSettings = existingPool.UpgradeSettings
+			normalizedProfile.UpgradeSettings = profile.UpgradeSettings
+		}
+
 		// Diff and check if we require an update
 		diff := cmp.Diff(normalizedProfile, existingProfile)
 		if diff != "" {
+			if existingPool.Mode == containerservice.AgentPoolModeSystem && profile.Mode == containerservice.AgentPoolModeUser {
+				otherSystemPoolExists, err := s.otherSystemPoolExists(ctx, agentPoolSpec)
+				if err != nil {
+					return err
+				}
+				if !otherSystemPoolExists {
+					return azure.WithTerminalError(errors.Errorf("cannot change mode of agent pool %s to %s, it is the only %s agent pool of the managed cluster",
+						agentPoolSpec.Name, containerservice.AgentPoolModeUser, containerservice.AgentPoolModeSystem))
+				}
+			}
+
 			log.V(2).Info(fmt.Sprintf("Update required (+new -old):\n%s", diff))
+			if profile.Tags == nil && len(existingPool.Tags) > 0 {
+				// Explicitly send an empty set of tags to remove all existing tags.
+				profile.Tags = map[string]*string{}
+			}
 			err = s.Client.CreateOrUpdate(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name,
 				profile, customHeaders)
 			if err != nil {
@@ -137,11 +266,124 @@ func (s *Service) Reconcile(ctx context.Context) error {
 		} else {
 			log.V(2).Info("Normalized and desired agent pool matched, no update needed")
 		}
+
+		if s.scope.AgentPoolAnnotations()[azure.UpgradeNodeImageAnnotation] == "true" {
+			log.V(2).Info(fmt.Sprintf("upgrading node image version of agent pool %s", agentPoolSpec.Name))
+			err = s.Client.UpgradeNodeImageVersion(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name)
+			if err != nil {
+				return errors.Wrap(err, "failed to upgrade node image version of agent pool")
+			}
+			s.scope.RemoveAgentPoolAnnotation(azure.UpgradeNodeImageAnnotation)
+		}
+	}
+
+	return nil
+}
+
+// validateAvailabilityZones checks that the VM size of the agent pool is available in all of its availability zones,
+// as AKS fails to create the agent pool without telling which zones are not supported.
+func (s *Service) validateAvailabilityZones(ctx context.Context, agentPoolSpec azure.AgentPoolSpec) error {
+	if len(agentPoolSpec.AvailabilityZones) == 0 {
+		return nil
+	}
+
+	location := s.scope.Location()
+	validZones, err := s.resourceSKUCache.GetZonesWithVMSize(ctx, agentPoolSpec.SKU, location)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get zones for vm size %s in location %s", agentPoolSpec.SKU, location)
+	}
+
+	var invalidZones []string
+	for _, zone := range agentPoolSpec.AvailabilityZones {
+		if !slice.Contains(validZones, zone) {
+			invalidZones = append(invalidZones, zone)
+		}
+	}
+	if len(invalidZones) > 0 {
+		return azure.WithTerminalError(errors.Errorf("availability zones %v of agent pool %s are not available for vm size %s in location %s. valid zones are %v",
+			invalidZones, agentPoolSpec.Name, agentPoolSpec.SKU, location, validZones))
 	}
+	return nil
+}
 
+// validateNodePublicIPPrefix checks that the public IP prefix the node public IPs of the agent pool are allocated from
+// is in the location of the managed cluster, as AKS cannot allocate public IPs from prefixes in other locations.
+func (s *Service) validateNodePublicIPPrefix(ctx context.Context, agentPoolSpec azure.AgentPoolSpec) error {
+	if !to.Bool(agentPoolSpec.EnableNodePublicIP) || agentPoolSpec.NodePublicIPPrefixID == nil {
+		return nil
+	}
+
+	prefixID := *agentPoolSpec.NodePublicIPPrefixID
+	resource, err := azuresdk.ParseResourceID(prefixID)
+	if err != nil || !strings.EqualFold(resource.Provider, "Microsoft.Network") || !strings.EqualFold(resource.ResourceType, "publicIPPrefixes") {
+		return azure.WithTerminalError(errors.Errorf("node public IP prefix %s of agent pool %s is not a valid Microsoft.Network/publicIPPrefixes resource ID",
+			prefixID, agentPoolSpec.Name))
+	}
+
+	prefix, err := s.Client.GetPublicIPPrefix(ctx, resource.ResourceGroup, resource.ResourceName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node public IP prefix %s of agent pool %s", prefixID, agentPoolSpec.Name)
+	}
+	if location := s.scope.Location(); !strings.EqualFold(to.String(prefix.Location), location) {
+		return azure.WithTerminalError(errors.Errorf("node public IP prefix %s of agent pool %s is in location %s, but must be in location %s of the managed cluster",
+			prefixID, agentPoolSpec.Name, to.String(prefix.Location), location))
+	}
 	return nil
 }
 
+// maxPodsBounds returns the minimum and maximum max pods per node which AKS allows for the given network plugin.
+func maxPodsBounds(networkPlugin string) (int32, int32) {
+	if strings.EqualFold(networkPlugin, string(containerservice.NetworkPluginKubenet)) {
+		return 10, 110
+	}
+	return 10, 250
+}
+
+// networkPluginOrDefault returns the given network plugin, or the network plugin AKS defaults to if it is unset.
+func networkPluginOrDefault(networkPlugin string) string {
+	if networkPlugin == "" {
+		return string(containerservice.NetworkPluginAzure)
+	}
+	return networkPlugin
+}
+
+// scaleDownModeOrDefault returns the given scale-down mode, or the scale-down mode AKS defaults to if it is unset.
+func scaleDownModeOrDefault(scaleDownMode containerservice.ScaleDownMode) containerservice.ScaleDownMode {
+	if scaleDownMode == "" {
+		return containerservice.ScaleDownModeDelete
+	}
+	return scaleDownMode
+}
+
+// boundedCount returns the given node count limited to the given minimum and maximum node counts, if set.
+func boundedCount(count int32, minCount, maxCount *int32) int32 {
+	if minCount != nil && count < *minCount {
+		return *minCount
+	}
+	if maxCount != nil && count > *maxCount {
+		return *maxCount
+	}
+	return count
+}
+
+// otherSystemPoolExists returns true if the managed cluster has a System pool other than the given agent pool. AKS
+// requires at least one System pool, so the only System pool must neither be switched to User mode nor deleted.
+func (s *Service) otherSystemPoolExists(ctx context.Context, agentPoolSpec azure.AgentPoolSpec) (bool, error) {
+	agentPools, err := s.Client.List(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list agent pools")
+	}
+
+	for _, pool := range agentPools {
+		if to.String(pool.Name) != agentPoolSpec.Name && pool.ManagedClusterAgentPoolProfileProperties != nil &&
+			pool.Mode == containerservice.AgentPoolModeSystem {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 // Delete deletes the virtual network with the provided name.
 func (s *Service) Delete(ctx context.Context) error {
 	ctx, log, done := tele.StartSpanWithLogger(
@@ -152,6 +394,17 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	agentPoolSpec := s.scope.AgentPoolSpec()
 
+	if agentPoolSpec.Mode == string(infrav1exp.NodePoolModeSystem) {
+		otherSystemPoolExists, err := s.otherSystemPoolExists(ctx, agentPoolSpec)
+		if err != nil {
+			return err
+		}
+		if !otherSystemPoolExists {
+			return azure.WithTerminalError(errors.Errorf("cannot delete agent pool %s, it is the only %s agent pool of the managed cluster",
+				agentPoolSpec.Name, containerservice.AgentPoolModeSystem))
+		}
+	}
+
 	log.V(2).Info(fmt.Sprintf("deleting agent pool  %s ", agentPoolSpec.Name))
 	err := s.Client.Delete(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name)
 	if err != nil {
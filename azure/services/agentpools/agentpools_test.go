@@ -18,18 +18,24 @@ package agentpools
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"reflect"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/scope"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/agentpools/mock_agentpools"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	gomockinternal "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -125,114 +131,990 @@ func TestReconcile(t *testing.T) {
 				} else {
 					g.Expect(err).NotTo(HaveOccurred())
 				}
+				g.Expect(machinePoolScope.InfraMachinePool.Status.ProvisioningState).To(Equal(provisioningstate))
 			})
 		}
 	}
 
 	testcases := []struct {
-		name           string
-		agentPoolsSpec azure.AgentPoolSpec
-		expectedError  string
-		expect         func(m *mock_agentpools.MockClientMockRecorder)
+		name                     string
+		agentPoolsSpec           azure.AgentPoolSpec
+		agentPoolAnnotations     map[string]string
+		virtualNetwork           infrav1exp.ManagedControlPlaneVirtualNetwork
+		podSubnetName            *string
+		snapshotID               string
+		expectedError            string
+		expectedNodeImageVersion string
+		expectedPowerState       string
+		expect                   func(m *mock_agentpools.MockClientMockRecorder)
 	}{
 		{
-			name: "no agentpool exists",
+			name: "no agentpool exists",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				Name:          "my-agentpool",
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agentpool", gomock.Any(), gomock.Any()).Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agentpool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name: "fail to get existing agent pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxPods:       to.Int32Ptr(12),
+				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+			},
+			expectedError: "failed to get existing agent pool: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name: "can create an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxPods:       to.Int32Ptr(12),
+				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "can create an Agent Pool in availability zones of its vm size",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				SKU:               "Standard_D2s_v3",
+				AvailabilityZones: []string{"1", "2"},
+			},
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "cannot create an Agent Pool in availability zones without its vm size",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				SKU:               "Standard_D2s_v3",
+				AvailabilityZones: []string{"1", "3", "4"},
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: availability zones [3 4] of agent pool my-agent-pool are not available for vm size Standard_D2s_v3 in location test-location. valid zones are [1 2]. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
+		{
+			name: "can create an Agent Pool with node public IPs",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:               "my-agent-pool",
+				ResourceGroup:      "my-rg",
+				Cluster:            "my-cluster",
+				SKU:                "Standard_D2s_v3",
+				EnableNodePublicIP: to.BoolPtr(true),
+			},
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "can create an Agent Pool with node public IPs from a public IP prefix in the location of the cluster",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:                 "my-agent-pool",
+				ResourceGroup:        "my-rg",
+				Cluster:              "my-cluster",
+				SKU:                  "Standard_D2s_v3",
+				EnableNodePublicIP:   to.BoolPtr(true),
+				NodePublicIPPrefixID: to.StringPtr("/subscriptions/123/resourceGroups/my-ip-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix"),
+			},
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.GetPublicIPPrefix(gomockinternal.AContext(), "my-ip-rg", "my-prefix").Return(network.PublicIPPrefix{Location: to.StringPtr("test-location")}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "cannot create an Agent Pool with node public IPs from a public IP prefix in another location",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:                 "my-agent-pool",
+				ResourceGroup:        "my-rg",
+				Cluster:              "my-cluster",
+				SKU:                  "Standard_D2s_v3",
+				EnableNodePublicIP:   to.BoolPtr(true),
+				NodePublicIPPrefixID: to.StringPtr("/subscriptions/123/resourceGroups/my-ip-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix"),
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: node public IP prefix /subscriptions/123/resourceGroups/my-ip-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix of agent pool my-agent-pool is in location other-location, but must be in location test-location of the managed cluster. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.GetPublicIPPrefix(gomockinternal.AContext(), "my-ip-rg", "my-prefix").Return(network.PublicIPPrefix{Location: to.StringPtr("other-location")}, nil)
+			},
+		},
+		{
+			name: "cannot create an Agent Pool with node public IPs from an invalid public IP prefix ID",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:                 "my-agent-pool",
+				ResourceGroup:        "my-rg",
+				Cluster:              "my-cluster",
+				SKU:                  "Standard_D2s_v3",
+				EnableNodePublicIP:   to.BoolPtr(true),
+				NodePublicIPPrefixID: to.StringPtr("my-prefix"),
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: node public IP prefix my-prefix of agent pool my-agent-pool is not a valid Microsoft.Network/publicIPPrefixes resource ID. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
+		{
+			name: "cannot use the node subnet as pod subnet of an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+			},
+			podSubnetName: to.StringPtr("my-subnet"),
+			virtualNetwork: infrav1exp.ManagedControlPlaneVirtualNetwork{
+				Name: "my-vnet",
+				Subnet: infrav1exp.ManagedControlPlaneSubnet{
+					Name: "my-subnet",
+				},
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: pod subnet /subscriptions//resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet of agent pool my-agent-pool must be different from its node subnet. Object will not be requeued",
+			expect:        func(m *mock_agentpools.MockClientMockRecorder) {},
+		},
+		{
+			name: "can create an Agent Pool from a snapshot",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+			},
+			snapshotID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/my-snapshot",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomockinternal.CustomMatcher(
+					func(x interface{}, _ map[string]interface{}) bool {
+						agentPool, ok := x.(containerservice.AgentPool)
+						return ok && agentPool.CreationData != nil &&
+							to.String(agentPool.CreationData.SourceResourceID) == "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/my-snapshot"
+					},
+					func(_ map[string]interface{}) string {
+						return "an agent pool created from snapshot my-snapshot"
+					},
+				), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "can create an Agent Pool with Deallocate scale-down mode",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				ScaleDownMode: infrav1exp.ScaleDownModeDeallocate,
+			},
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomockinternal.CustomMatcher(
+					func(x interface{}, _ map[string]interface{}) bool {
+						agentPool, ok := x.(containerservice.AgentPool)
+						return ok && agentPool.ScaleDownMode == containerservice.ScaleDownModeDeallocate
+					},
+					func(_ map[string]interface{}) string {
+						return "an agent pool with Deallocate scale-down mode"
+					},
+				), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "can create an Agent Pool with WasmWasi workload runtime",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:            "my-agent-pool",
+				ResourceGroup:   "my-rg",
+				Cluster:         "my-cluster",
+				SKU:             "SKU123",
+				WorkloadRuntime: infrav1exp.WorkloadRuntimeWasmWasi,
+			},
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomockinternal.CustomMatcher(
+					func(x interface{}, _ map[string]interface{}) bool {
+						agentPool, ok := x.(containerservice.AgentPool)
+						return ok && agentPool.WorkloadRuntime == containerservice.WorkloadRuntimeWasmWasi
+					},
+					func(_ map[string]interface{}) string {
+						return "an agent pool with WasmWasi workload runtime"
+					},
+				), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "create a stopped Agent Pool running and requeue to stop it",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				PowerState:    infrav1exp.PowerStateStopped,
+			},
+			expectedError:      "agent pool my-agent-pool was created running and is stopped on the next reconcile. Object will be requeued after 20s",
+			expectedPowerState: "Running",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomockinternal.CustomMatcher(
+					func(x interface{}, _ map[string]interface{}) bool {
+						agentPool, ok := x.(containerservice.AgentPool)
+						return ok && agentPool.PowerState == nil
+					},
+					func(_ map[string]interface{}) string {
+						return "an agent pool without power state"
+					},
+				), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "fail to create an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxPods:       to.Int32Ptr(12),
+				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+			},
+			expectedError: "failed to create or update agent pool: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name: "fail to create an Agent Pool with encryption at host when the feature is not registered",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:                   "my-agent-pool",
+				ResourceGroup:          "my-rg",
+				Cluster:                "my-cluster",
+				SKU:                    "SKU123",
+				Version:                to.StringPtr("9.99.9999"),
+				Replicas:               2,
+				OSDiskSizeGB:           100,
+				EnableEncryptionAtHost: to.BoolPtr(true),
+			},
+			expectedError: "failed to create agent pool with encryption at host, make sure the EncryptionAtHost feature is registered for the Microsoft.Compute resource provider of the subscription: #: Subscription does not enable EncryptionAtHost.: StatusCode=400",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomockinternal.CustomMatcher(
+					func(x interface{}, _ map[string]interface{}) bool {
+						agentPool, ok := x.(containerservice.AgentPool)
+						return ok && to.Bool(agentPool.EnableEncryptionAtHost)
+					},
+					func(_ map[string]interface{}) string {
+						return "an agent pool with encryption at host enabled"
+					},
+				), gomock.Any()).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 400}, "Subscription does not enable EncryptionAtHost."))
+			},
+		},
+		{
+			name: "fail to update an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxPods:       to.Int32Ptr(12),
+				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+			},
+			expectedError:            "failed to create or update agent pool: #: Internal Server Error: StatusCode=500",
+			expectedNodeImageVersion: "AKSUbuntu-1804gen2containerd-2022.01.19",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(3),
+						OsDiskSizeGB:        to.Int32Ptr(20),
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Failed"),
+						NodeImageVersion:    to.StringPtr("AKSUbuntu-1804gen2containerd-2022.01.19"),
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name: "no update needed on Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxPods:       to.Int32Ptr(12),
+				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeEphemeral)),
+			},
+			expectedError:            "",
+			expectedNodeImageVersion: "AKSUbuntu-1804gen2containerd-2022.02.03",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OsDiskSizeGB:        to.Int32Ptr(100),
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardD2sV3)),
+						OsType:              containerservice.OSTypeLinux,
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						NodeImageVersion:    to.StringPtr("AKSUbuntu-1804gen2containerd-2022.02.03"),
+						VnetSubnetID:        to.StringPtr(""),
+						MaxPods:             to.Int32Ptr(12),
+						OsDiskType:          containerservice.OSDiskTypeEphemeral,
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "add tags to an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:           "my-agent-pool",
+				ResourceGroup:  "my-rg",
+				Cluster:        "my-cluster",
+				SKU:            "Standard_D2s_v3",
+				Version:        to.StringPtr("9.99.9999"),
+				Replicas:       2,
+				OSDiskSizeGB:   100,
+				AdditionalTags: infrav1.Tags{"env": "prod"},
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithTags(map[string]*string{"env": to.StringPtr("prod")}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "update tags of an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:           "my-agent-pool",
+				ResourceGroup:  "my-rg",
+				Cluster:        "my-cluster",
+				SKU:            "Standard_D2s_v3",
+				Version:        to.StringPtr("9.99.9999"),
+				Replicas:       2,
+				OSDiskSizeGB:   100,
+				AdditionalTags: infrav1.Tags{"env": "prod"},
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						Tags:                map[string]*string{"env": to.StringPtr("dev")},
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithTags(map[string]*string{"env": to.StringPtr("prod")}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "remove tags of an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						Tags:                map[string]*string{"env": to.StringPtr("dev")},
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithTags(map[string]*string{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "no update needed when tags of an Agent Pool match",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:           "my-agent-pool",
+				ResourceGroup:  "my-rg",
+				Cluster:        "my-cluster",
+				SKU:            "Standard_D2s_v3",
+				Version:        to.StringPtr("9.99.9999"),
+				Replicas:       2,
+				OSDiskSizeGB:   100,
+				AdditionalTags: infrav1.Tags{"env": "prod"},
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						Tags:                map[string]*string{"env": to.StringPtr("prod")},
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "can create an Agent Pool with FIPS enabled",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				EnableFIPS:    to.BoolPtr(true),
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomockinternal.CustomMatcher(
+					func(x interface{}, _ map[string]interface{}) bool {
+						agentPool, ok := x.(containerservice.AgentPool)
+						return ok && to.Bool(agentPool.EnableFIPS)
+					},
+					func(_ map[string]interface{}) string {
+						return "an agent pool with FIPS enabled"
+					},
+				), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "stopped Agent Pool is not updated",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      5,
+				OSDiskSizeGB:  100,
+			},
+			expectedError:            "",
+			expectedNodeImageVersion: "AKSUbuntu-1804gen2containerd-2022.02.03",
+			expectedPowerState:       "Stopped",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						NodeImageVersion:    to.StringPtr("AKSUbuntu-1804gen2containerd-2022.02.03"),
+						PowerState: &containerservice.PowerState{
+							Code: containerservice.CodeStopped,
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "running Agent Pool reports its power state",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      5,
+				OSDiskSizeGB:  100,
+			},
+			expectedError:            "",
+			expectedNodeImageVersion: "AKSUbuntu-1804gen2containerd-2022.02.03",
+			expectedPowerState:       "Running",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						NodeImageVersion:    to.StringPtr("AKSUbuntu-1804gen2containerd-2022.02.03"),
+						PowerState: &containerservice.PowerState{
+							Code: containerservice.CodeRunning,
+						},
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "stop a running Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      5,
+				OSDiskSizeGB:  100,
+				PowerState:    infrav1exp.PowerStateStopped,
+			},
+			expectedPowerState: "Stopped",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						PowerState: &containerservice.PowerState{
+							Code: containerservice.CodeRunning,
+						},
+					},
+				}, nil)
+				// only the power state is changed, the new node count is applied once the agent pool is started again
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithPowerState(containerservice.CodeStopped), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "start a stopped Agent Pool and update it",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      5,
+				OSDiskSizeGB:  100,
+				PowerState:    infrav1exp.PowerStateRunning,
+			},
+			expectedPowerState: "Running",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						PowerState: &containerservice.PowerState{
+							Code: containerservice.CodeStopped,
+						},
+					},
+				}, nil)
+				gomock.InOrder(
+					m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithPowerState(containerservice.CodeRunning), gomock.Any()).Return(nil),
+					m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithCount(5), gomock.Any()).Return(nil),
+				)
+			},
+		},
+		{
+			name: "fail to start a stopped Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				PowerState:    infrav1exp.PowerStateRunning,
+			},
+			expectedError:      "failed to change power state of agent pool my-agent-pool to Running: #: Internal Server Error: StatusCode=500",
+			expectedPowerState: "Stopped",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						PowerState: &containerservice.PowerState{
+							Code: containerservice.CodeStopped,
+						},
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithPowerState(containerservice.CodeRunning), gomock.Any()).
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name: "stopped Agent Pool with matching power state is not updated",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      5,
+				OSDiskSizeGB:  100,
+				PowerState:    infrav1exp.PowerStateStopped,
+			},
+			expectedError:      "",
+			expectedPowerState: "Stopped",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						PowerState: &containerservice.PowerState{
+							Code: containerservice.CodeStopped,
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "cannot enable FIPS on an existing Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				EnableFIPS:    to.BoolPtr(true),
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: cannot change EnableFIPS of existing agent pool my-agent-pool from false to true, FIPS can only be set at creation time. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						EnableFIPS:          to.BoolPtr(false),
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "cannot enable encryption at host on an existing Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:                   "my-agent-pool",
+				ResourceGroup:          "my-rg",
+				Cluster:                "my-cluster",
+				SKU:                    "Standard_D2s_v3",
+				Version:                to.StringPtr("9.99.9999"),
+				Replicas:               2,
+				OSDiskSizeGB:           100,
+				EnableEncryptionAtHost: to.BoolPtr(true),
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: cannot change EnableEncryptionAtHost of existing agent pool my-agent-pool from false to true, encryption at host can only be set at creation time. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "cannot use more max pods than kubenet allows for an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				MaxPods:       to.Int32Ptr(200),
+				NetworkPlugin: "kubenet",
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: max pods 200 of agent pool my-agent-pool must be between 10 and 110 for network plugin kubenet. Object will not be requeued",
+			expect:        func(m *mock_agentpools.MockClientMockRecorder) {},
+		},
+		{
+			name: "cannot use less max pods than kubenet allows for an Agent Pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				MaxPods:       to.Int32Ptr(5),
+				NetworkPlugin: "kubenet",
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: max pods 5 of agent pool my-agent-pool must be between 10 and 110 for network plugin kubenet. Object will not be requeued",
+			expect:        func(m *mock_agentpools.MockClientMockRecorder) {},
+		},
+		{
+			name: "can create an Agent Pool with more max pods than kubenet allows when using Azure CNI",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "SKU123",
+				MaxPods:       to.Int32Ptr(200),
+				NetworkPlugin: "azure",
+			},
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "cannot change max pods of an existing Agent Pool",
 			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
 				ResourceGroup: "my-rg",
 				Cluster:       "my-cluster",
-				Name:          "my-agentpool",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxPods:       to.Int32Ptr(50),
 			},
-			expectedError: "",
+			expectedError: "reconcile error that cannot be recovered occurred: cannot change MaxPods of existing agent pool my-agent-pool from 30 to 50, max pods can only be set at creation time. Object will not be requeued",
 			expect: func(m *mock_agentpools.MockClientMockRecorder) {
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agentpool", gomock.Any(), gomock.Any()).Return(nil)
-				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agentpool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						MaxPods:             to.Int32Ptr(30),
+					},
+				}, nil)
 			},
 		},
 		{
-			name: "fail to get existing agent pool",
+			name: "upgrade node image version of Agent Pool when requested",
 			agentPoolsSpec: azure.AgentPoolSpec{
 				Name:          "my-agent-pool",
 				ResourceGroup: "my-rg",
 				Cluster:       "my-cluster",
-				SKU:           "SKU123",
+				SKU:           "Standard_D2s_v3",
 				Version:       to.StringPtr("9.99.9999"),
 				Replicas:      2,
 				OSDiskSizeGB:  100,
-				MaxPods:       to.Int32Ptr(12),
-				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
 			},
-			expectedError: "failed to get existing agent pool: #: Internal Server Error: StatusCode=500",
+			agentPoolAnnotations: map[string]string{
+				azure.UpgradeNodeImageAnnotation: "true",
+			},
+			expectedError:            "",
+			expectedNodeImageVersion: "AKSUbuntu-1804gen2containerd-2022.02.03",
 			expect: func(m *mock_agentpools.MockClientMockRecorder) {
-				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						NodeImageVersion:    to.StringPtr("AKSUbuntu-1804gen2containerd-2022.02.03"),
+					},
+				}, nil)
+				m.UpgradeNodeImageVersion(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(nil)
 			},
 		},
 		{
-			name: "can create an Agent Pool",
+			name: "fail to upgrade node image version of Agent Pool",
 			agentPoolsSpec: azure.AgentPoolSpec{
 				Name:          "my-agent-pool",
 				ResourceGroup: "my-rg",
 				Cluster:       "my-cluster",
-				SKU:           "SKU123",
+				SKU:           "Standard_D2s_v3",
 				Version:       to.StringPtr("9.99.9999"),
 				Replicas:      2,
 				OSDiskSizeGB:  100,
-				MaxPods:       to.Int32Ptr(12),
-				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+			},
+			agentPoolAnnotations: map[string]string{
+				azure.UpgradeNodeImageAnnotation: "true",
+			},
+			expectedError:            "failed to upgrade node image version of agent pool: #: Internal Server Error: StatusCode=500",
+			expectedNodeImageVersion: "AKSUbuntu-1804gen2containerd-2022.02.03",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						NodeImageVersion:    to.StringPtr("AKSUbuntu-1804gen2containerd-2022.02.03"),
+					},
+				}, nil)
+				m.UpgradeNodeImageVersion(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name: "update Agent Pool when max surge changes",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				MaxSurge:      "33%",
 			},
 			expectedError: "",
 			expect: func(m *mock_agentpools.MockClientMockRecorder) {
-				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						UpgradeSettings: &containerservice.AgentPoolUpgradeSettings{
+							MaxSurge: to.StringPtr("1"),
+						},
+					},
+				}, nil)
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
 			},
 		},
 		{
-			name: "fail to create an Agent Pool",
+			name: "update Agent Pool when scale-down mode changes",
 			agentPoolsSpec: azure.AgentPoolSpec{
 				Name:          "my-agent-pool",
 				ResourceGroup: "my-rg",
 				Cluster:       "my-cluster",
-				SKU:           "SKU123",
+				SKU:           "Standard_D2s_v3",
 				Version:       to.StringPtr("9.99.9999"),
 				Replicas:      2,
 				OSDiskSizeGB:  100,
-				MaxPods:       to.Int32Ptr(12),
-				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+				ScaleDownMode: infrav1exp.ScaleDownModeDeallocate,
 			},
-			expectedError: "failed to create or update agent pool: #: Internal Server Error: StatusCode=500",
+			expectedError: "",
 			expect: func(m *mock_agentpools.MockClientMockRecorder) {
-				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						ScaleDownMode:       containerservice.ScaleDownModeDelete,
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
 			},
 		},
 		{
-			name: "fail to update an Agent Pool",
+			name: "no update needed on Agent Pool with the default scale-down mode",
 			agentPoolsSpec: azure.AgentPoolSpec{
 				Name:          "my-agent-pool",
 				ResourceGroup: "my-rg",
 				Cluster:       "my-cluster",
-				SKU:           "SKU123",
+				SKU:           "Standard_D2s_v3",
 				Version:       to.StringPtr("9.99.9999"),
 				Replicas:      2,
 				OSDiskSizeGB:  100,
-				MaxPods:       to.Int32Ptr(12),
-				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+				ScaleDownMode: infrav1exp.ScaleDownModeDelete,
 			},
-			expectedError: "failed to create or update agent pool: #: Internal Server Error: StatusCode=500",
+			expectedError: "",
 			expect: func(m *mock_agentpools.MockClientMockRecorder) {
 				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
 					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
-						Count:               to.Int32Ptr(3),
-						OsDiskSizeGB:        to.Int32Ptr(20),
-						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
+						Count:               to.Int32Ptr(2),
 						OrchestratorVersion: to.StringPtr("9.99.9999"),
-						ProvisioningState:   to.StringPtr("Failed"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
 					},
 				}, nil)
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
 		{
-			name: "no update needed on Agent Pool",
+			name: "no update needed on autoscaled Agent Pool with a node count within the autoscaler bounds",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				Version:           to.StringPtr("9.99.9999"),
+				Replicas:          2,
+				OSDiskSizeGB:      100,
+				EnableAutoScaling: to.BoolPtr(true),
+				MinCount:          to.Int32Ptr(1),
+				MaxCount:          to.Int32Ptr(5),
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(4),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						EnableAutoScaling:   to.BoolPtr(true),
+						MinCount:            to.Int32Ptr(1),
+						MaxCount:            to.Int32Ptr(5),
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "scale autoscaled Agent Pool down to the maximum node count",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				Version:           to.StringPtr("9.99.9999"),
+				Replicas:          2,
+				OSDiskSizeGB:      100,
+				EnableAutoScaling: to.BoolPtr(true),
+				MinCount:          to.Int32Ptr(1),
+				MaxCount:          to.Int32Ptr(3),
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(4),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						EnableAutoScaling:   to.BoolPtr(true),
+						MinCount:            to.Int32Ptr(1),
+						MaxCount:            to.Int32Ptr(5),
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithCount(3), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "create autoscaled Agent Pool with the minimum node count",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				Version:           to.StringPtr("9.99.9999"),
+				Replicas:          1,
+				OSDiskSizeGB:      100,
+				EnableAutoScaling: to.BoolPtr(true),
+				MinCount:          to.Int32Ptr(2),
+				MaxCount:          to.Int32Ptr(5),
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", agentPoolWithCount(2), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "can change mode of a System Agent Pool to User when another System Agent Pool exists",
 			agentPoolsSpec: azure.AgentPoolSpec{
 				Name:          "my-agent-pool",
 				ResourceGroup: "my-rg",
@@ -241,22 +1123,69 @@ func TestReconcile(t *testing.T) {
 				Version:       to.StringPtr("9.99.9999"),
 				Replicas:      2,
 				OSDiskSizeGB:  100,
-				MaxPods:       to.Int32Ptr(12),
-				OsDiskType:    to.StringPtr(string(containerservice.OSDiskTypeEphemeral)),
+				Mode:          string(infrav1exp.NodePoolModeUser),
 			},
 			expectedError: "",
 			expect: func(m *mock_agentpools.MockClientMockRecorder) {
 				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
 					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
 						Count:               to.Int32Ptr(2),
-						OsDiskSizeGB:        to.Int32Ptr(100),
-						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardD2sV3)),
-						OsType:              containerservice.OSTypeLinux,
 						OrchestratorVersion: to.StringPtr("9.99.9999"),
 						ProvisioningState:   to.StringPtr("Succeeded"),
-						VnetSubnetID:        to.StringPtr(""),
-						MaxPods:             to.Int32Ptr(12),
-						OsDiskType:          containerservice.OSDiskTypeEphemeral,
+						Mode:                containerservice.AgentPoolModeSystem,
+					},
+				}, nil)
+				m.List(gomockinternal.AContext(), "my-rg", "my-cluster").Return([]containerservice.AgentPool{
+					{
+						Name: to.StringPtr("my-agent-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeSystem,
+						},
+					},
+					{
+						Name: to.StringPtr("my-other-agent-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeSystem,
+						},
+					},
+				}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool", gomock.AssignableToTypeOf(containerservice.AgentPool{}), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "cannot change mode of the last System Agent Pool to User",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				Mode:          string(infrav1exp.NodePoolModeUser),
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: cannot change mode of agent pool my-agent-pool to User, it is the only System agent pool of the managed cluster. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+						Mode:                containerservice.AgentPoolModeSystem,
+					},
+				}, nil)
+				m.List(gomockinternal.AContext(), "my-rg", "my-cluster").Return([]containerservice.AgentPool{
+					{
+						Name: to.StringPtr("my-agent-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeSystem,
+						},
+					},
+					{
+						Name: to.StringPtr("my-user-agent-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeUser,
+						},
 					},
 				}, nil)
 			},
@@ -274,17 +1203,39 @@ func TestReconcile(t *testing.T) {
 
 			replicas := tc.agentPoolsSpec.Replicas
 			osDiskSizeGB := tc.agentPoolsSpec.OSDiskSizeGB
+			maxPods := to.Int32Ptr(12)
+			if tc.agentPoolsSpec.MaxPods != nil {
+				maxPods = tc.agentPoolsSpec.MaxPods
+			}
+			var networkPlugin *string
+			if tc.agentPoolsSpec.NetworkPlugin != "" {
+				networkPlugin = to.StringPtr(tc.agentPoolsSpec.NetworkPlugin)
+			}
+			var scaling *infrav1exp.ManagedMachinePoolScaling
+			if to.Bool(tc.agentPoolsSpec.EnableAutoScaling) {
+				scaling = &infrav1exp.ManagedMachinePoolScaling{
+					MinSize: tc.agentPoolsSpec.MinCount,
+					MaxSize: tc.agentPoolsSpec.MaxCount,
+				}
+			}
 
 			agentpoolsMock := mock_agentpools.NewMockClient(mockCtrl)
+			controlPlane := &infrav1exp.AzureManagedControlPlane{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: tc.agentPoolsSpec.Cluster,
+				},
+				Spec: infrav1exp.AzureManagedControlPlaneSpec{
+					ResourceGroupName: tc.agentPoolsSpec.ResourceGroup,
+					Location:          "test-location",
+					VirtualNetwork:    tc.virtualNetwork,
+					NetworkPlugin:     networkPlugin,
+				},
+			}
 			machinePoolScope := &scope.ManagedMachinePoolScope{
-				ControlPlane: &infrav1exp.AzureManagedControlPlane{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: tc.agentPoolsSpec.Cluster,
-					},
-					Spec: infrav1exp.AzureManagedControlPlaneSpec{
-						ResourceGroupName: tc.agentPoolsSpec.ResourceGroup,
-					},
+				ManagedClusterScoper: &scope.ManagedControlPlaneScope{
+					ControlPlane: controlPlane,
 				},
+				ControlPlane: controlPlane,
 				MachinePool: &expv1.MachinePool{
 					Spec: expv1.MachinePoolSpec{
 						Replicas: &replicas,
@@ -297,14 +1248,29 @@ func TestReconcile(t *testing.T) {
 				},
 				InfraMachinePool: &infrav1exp.AzureManagedMachinePool{
 					ObjectMeta: metav1.ObjectMeta{
-						Name: tc.agentPoolsSpec.Name,
+						Name:        tc.agentPoolsSpec.Name,
+						Annotations: tc.agentPoolAnnotations,
 					},
 					Spec: infrav1exp.AzureManagedMachinePoolSpec{
-						Name:         &tc.agentPoolsSpec.Name,
-						SKU:          tc.agentPoolsSpec.SKU,
-						OSDiskSizeGB: &osDiskSizeGB,
-						MaxPods:      to.Int32Ptr(12),
-						OsDiskType:   to.StringPtr(string(containerservice.OSDiskTypeManaged)),
+						Name:                   &tc.agentPoolsSpec.Name,
+						Mode:                   tc.agentPoolsSpec.Mode,
+						MaxSurge:               tc.agentPoolsSpec.MaxSurge,
+						EnableFIPS:             tc.agentPoolsSpec.EnableFIPS,
+						AdditionalTags:         tc.agentPoolsSpec.AdditionalTags,
+						PodSubnetName:          tc.podSubnetName,
+						SnapshotID:             tc.snapshotID,
+						ScaleDownMode:          tc.agentPoolsSpec.ScaleDownMode,
+						PowerState:             tc.agentPoolsSpec.PowerState,
+						EnableEncryptionAtHost: tc.agentPoolsSpec.EnableEncryptionAtHost,
+						WorkloadRuntime:        tc.agentPoolsSpec.WorkloadRuntime,
+						Scaling:                scaling,
+						SKU:                    tc.agentPoolsSpec.SKU,
+						OSDiskSizeGB:           &osDiskSizeGB,
+						MaxPods:                maxPods,
+						AvailabilityZones:      tc.agentPoolsSpec.AvailabilityZones,
+						EnableNodePublicIP:     tc.agentPoolsSpec.EnableNodePublicIP,
+						NodePublicIPPrefixID:   tc.agentPoolsSpec.NodePublicIPPrefixID,
+						OsDiskType:             to.StringPtr(string(containerservice.OSDiskTypeManaged)),
 					},
 				},
 			}
@@ -312,8 +1278,9 @@ func TestReconcile(t *testing.T) {
 			tc.expect(agentpoolsMock.EXPECT())
 
 			s := &Service{
-				Client: agentpoolsMock,
-				scope:  machinePoolScope,
+				Client:           agentpoolsMock,
+				scope:            machinePoolScope,
+				resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
 			}
 
 			err := s.Reconcile(context.TODO())
@@ -323,16 +1290,87 @@ func TestReconcile(t *testing.T) {
 			} else {
 				g.Expect(err).NotTo(HaveOccurred())
 			}
+			g.Expect(machinePoolScope.InfraMachinePool.Status.NodeImageVersion).To(Equal(tc.expectedNodeImageVersion))
+			g.Expect(machinePoolScope.InfraMachinePool.Status.PowerState).To(Equal(tc.expectedPowerState))
+			if tc.expectedError == "" {
+				g.Expect(machinePoolScope.InfraMachinePool.Annotations).NotTo(HaveKey(azure.UpgradeNodeImageAnnotation))
+			}
 		})
 	}
 }
 
+func getFakeSkus() []compute.ResourceSku {
+	return []compute.ResourceSku{
+		{
+			Name:         to.StringPtr("Standard_D2s_v3"),
+			ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
+			Locations: &[]string{
+				"test-location",
+			},
+			LocationInfo: &[]compute.ResourceSkuLocationInfo{
+				{
+					Location: to.StringPtr("test-location"),
+					Zones:    &[]string{"1", "2", "3"},
+				},
+			},
+			Restrictions: &[]compute.ResourceSkuRestrictions{
+				{
+					Type: compute.ResourceSkuRestrictionsTypeZone,
+					RestrictionInfo: &compute.ResourceSkuRestrictionInfo{
+						Zones: &[]string{"3"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// agentPoolWithTags returns a matcher for an agent pool with exactly the given, non-nil tags.
+func agentPoolWithTags(tags map[string]*string) gomock.Matcher {
+	return gomockinternal.CustomMatcher(
+		func(x interface{}, _ map[string]interface{}) bool {
+			agentPool, ok := x.(containerservice.AgentPool)
+			return ok && agentPool.Tags != nil && reflect.DeepEqual(agentPool.Tags, tags)
+		},
+		func(_ map[string]interface{}) string {
+			return fmt.Sprintf("an agent pool with tags %v", tags)
+		},
+	)
+}
+
+// agentPoolWithCount returns a matcher for an agent pool with the given node count.
+func agentPoolWithCount(count int32) gomock.Matcher {
+	return gomockinternal.CustomMatcher(
+		func(x interface{}, _ map[string]interface{}) bool {
+			agentPool, ok := x.(containerservice.AgentPool)
+			return ok && agentPool.ManagedClusterAgentPoolProfileProperties != nil && to.Int32(agentPool.Count) == count
+		},
+		func(_ map[string]interface{}) string {
+			return fmt.Sprintf("an agent pool with count %d", count)
+		},
+	)
+}
+
+// agentPoolWithPowerState returns a matcher for an agent pool with the given power state.
+func agentPoolWithPowerState(code containerservice.Code) gomock.Matcher {
+	return gomockinternal.CustomMatcher(
+		func(x interface{}, _ map[string]interface{}) bool {
+			agentPool, ok := x.(containerservice.AgentPool)
+			return ok && agentPool.ManagedClusterAgentPoolProfileProperties != nil && agentPool.PowerState != nil && agentPool.PowerState.Code == code
+		},
+		func(_ map[string]interface{}) string {
+			return fmt.Sprintf("an agent pool with power state %s", code)
+		},
+	)
+}
+
 func TestDeleteAgentPools(t *testing.T) {
 	testcases := []struct {
-		name           string
-		agentPoolsSpec azure.AgentPoolSpec
-		expectedError  string
-		expect         func(m *mock_agentpools.MockClientMockRecorder)
+		name                     string
+		agentPoolsSpec           azure.AgentPoolSpec
+		expectedError            string
+		expectedNodeImageVersion string
+		expect                   func(m *mock_agentpools.MockClientMockRecorder)
 	}{
 		{
 			name: "successfully delete an existing agent pool",
@@ -372,6 +1410,73 @@ func TestDeleteAgentPools(t *testing.T) {
 					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
+		{
+			name: "successfully delete a System agent pool if another System agent pool exists",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				Mode:          "System",
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.List(gomockinternal.AContext(), "my-rg", "my-cluster").Return([]containerservice.AgentPool{
+					{
+						Name: to.StringPtr("my-agent-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeSystem,
+						},
+					},
+					{
+						Name: to.StringPtr("other-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeSystem,
+						},
+					},
+				}, nil)
+				m.Delete(gomockinternal.AContext(), "my-rg", "my-cluster", "my-agent-pool")
+			},
+		},
+		{
+			name: "cannot delete the only System agent pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				Mode:          "System",
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: cannot delete agent pool my-agent-pool, it is the only System agent pool of the managed cluster. Object will not be requeued",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.List(gomockinternal.AContext(), "my-rg", "my-cluster").Return([]containerservice.AgentPool{
+					{
+						Name: to.StringPtr("my-agent-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeSystem,
+						},
+					},
+					{
+						Name: to.StringPtr("user-pool"),
+						ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+							Mode: containerservice.AgentPoolModeUser,
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "listing agent pools fails when deleting a System agent pool",
+			agentPoolsSpec: azure.AgentPoolSpec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				Mode:          "System",
+			},
+			expectedError: "failed to list agent pools: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.List(gomockinternal.AContext(), "my-rg", "my-cluster").
+					Return(nil, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -399,6 +1504,7 @@ func TestDeleteAgentPools(t *testing.T) {
 					},
 					Spec: infrav1exp.AzureManagedMachinePoolSpec{
 						Name: &tc.agentPoolsSpec.Name,
+						Mode: tc.agentPoolsSpec.Mode,
 					},
 				},
 			}
@@ -18,8 +18,10 @@ package agentpools
 
 import (
 	"context"
+	"fmt"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
@@ -29,13 +31,17 @@ import (
 // Client wraps go-sdk.
 type Client interface {
 	Get(context.Context, string, string, string) (containerservice.AgentPool, error)
+	List(context.Context, string, string) ([]containerservice.AgentPool, error)
 	CreateOrUpdate(context.Context, string, string, string, containerservice.AgentPool, map[string]string) error
 	Delete(context.Context, string, string, string) error
+	UpgradeNodeImageVersion(context.Context, string, string, string) error
+	GetPublicIPPrefix(context.Context, string, string) (network.PublicIPPrefix, error)
 }
 
 // AzureClient contains the Azure go-sdk Client.
 type AzureClient struct {
-	agentpools containerservice.AgentPoolsClient
+	agentpools       containerservice.AgentPoolsClient
+	publicIPPrefixes network.PublicIPPrefixesClient
 }
 
 var _ Client = &AzureClient{}
@@ -43,7 +49,8 @@ var _ Client = &AzureClient{}
 // NewClient creates a new agent pools client from subscription ID.
 func NewClient(auth azure.Authorizer) *AzureClient {
 	c := newAgentPoolsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
-	return &AzureClient{c}
+	p := newPublicIPPrefixesClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
+	return &AzureClient{c, p}
 }
 
 // newAgentPoolsClient creates a new agent pool client from subscription ID.
@@ -53,6 +60,13 @@ func newAgentPoolsClient(subscriptionID string, baseURI string, authorizer autor
 	return agentPoolsClient
 }
 
+// newPublicIPPrefixesClient creates a new public IP prefixes client from subscription ID.
+func newPublicIPPrefixesClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) network.PublicIPPrefixesClient {
+	publicIPPrefixesClient := network.NewPublicIPPrefixesClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&publicIPPrefixesClient.Client, authorizer)
+	return publicIPPrefixesClient
+}
+
 // Get gets an agent pool.
 func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, cluster, name string) (containerservice.AgentPool, error) {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "agentpools.AzureClient.Get")
@@ -61,6 +75,26 @@ func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, cluster, name
 	return ac.agentpools.Get(ctx, resourceGroupName, cluster, name)
 }
 
+// List returns all agent pools of a managed cluster.
+func (ac *AzureClient) List(ctx context.Context, resourceGroupName, cluster string) ([]containerservice.AgentPool, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "agentpools.AzureClient.List")
+	defer done()
+
+	itr, err := ac.agentpools.ListComplete(ctx, resourceGroupName, cluster)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list agent pools of the managed cluster")
+	}
+
+	var agentPools []containerservice.AgentPool
+	for ; itr.NotDone(); err = itr.NextWithContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate agent pools [%w]", err)
+		}
+		agentPools = append(agentPools, itr.Value())
+	}
+	return agentPools, nil
+}
+
 // CreateOrUpdate creates or updates an agent pool.
 func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, cluster, name string,
 	properties containerservice.AgentPool, customHeaders map[string]string) error {
@@ -101,3 +135,27 @@ func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, cluster, n
 	_, err = future.Result(ac.agentpools)
 	return err
 }
+
+// UpgradeNodeImageVersion upgrades the node image version of an agent pool to the latest.
+func (ac *AzureClient) UpgradeNodeImageVersion(ctx context.Context, resourceGroupName, cluster, name string) error {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "agentpools.AzureClient.UpgradeNodeImageVersion")
+	defer done()
+
+	future, err := ac.agentpools.UpgradeNodeImageVersion(ctx, resourceGroupName, cluster, name)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin operation")
+	}
+	if err := future.WaitForCompletionRef(ctx, ac.agentpools.Client); err != nil {
+		return errors.Wrap(err, "failed to end operation")
+	}
+	_, err = future.Result(ac.agentpools)
+	return err
+}
+
+// GetPublicIPPrefix gets a public IP prefix, e.g. the one the node public IPs of an agent pool are allocated from.
+func (ac *AzureClient) GetPublicIPPrefix(ctx context.Context, resourceGroupName, name string) (network.PublicIPPrefix, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "agentpools.AzureClient.GetPublicIPPrefix")
+	defer done()
+
+	return ac.publicIPPrefixes.Get(ctx, resourceGroupName, name, "")
+}
@@ -24,7 +24,8 @@ import (
 	context "context"
 	reflect "reflect"
 
-	containerservice "github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	containerservice "github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
+	network "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -93,3 +94,47 @@ func (mr *MockClientMockRecorder) Get(arg0, arg1, arg2, arg3 interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockClient)(nil).Get), arg0, arg1, arg2, arg3)
 }
+
+// GetPublicIPPrefix mocks base method.
+func (m *MockClient) GetPublicIPPrefix(arg0 context.Context, arg1, arg2 string) (network.PublicIPPrefix, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPublicIPPrefix", arg0, arg1, arg2)
+	ret0, _ := ret[0].(network.PublicIPPrefix)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPublicIPPrefix indicates an expected call of GetPublicIPPrefix.
+func (mr *MockClientMockRecorder) GetPublicIPPrefix(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPublicIPPrefix", reflect.TypeOf((*MockClient)(nil).GetPublicIPPrefix), arg0, arg1, arg2)
+}
+
+// List mocks base method.
+func (m *MockClient) List(arg0 context.Context, arg1, arg2 string) ([]containerservice.AgentPool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]containerservice.AgentPool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockClientMockRecorder) List(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockClient)(nil).List), arg0, arg1, arg2)
+}
+
+// UpgradeNodeImageVersion mocks base method.
+func (m *MockClient) UpgradeNodeImageVersion(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpgradeNodeImageVersion", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpgradeNodeImageVersion indicates an expected call of UpgradeNodeImageVersion.
+func (mr *MockClientMockRecorder) UpgradeNodeImageVersion(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpgradeNodeImageVersion", reflect.TypeOf((*MockClient)(nil).UpgradeNodeImageVersion), arg0, arg1, arg2, arg3)
+}
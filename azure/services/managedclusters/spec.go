@@ -22,7 +22,7 @@ import (
 	"net"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
@@ -325,7 +325,7 @@ func (s *ManagedClusterSpec) Parameters(existing interface{}) (params interface{
 
 		// Avoid changing agent pool profiles through AMCP and just use the existing agent pool profiles
 		// AgentPool changes are managed through AMMP.
-		managedCluster.AgentPoolProfiles = existingMC.AgentPoolProfiles
+		managedCluster.AgentPoolProfiles = agentPoolProfilesWithoutPowerState(existingMC.AgentPoolProfiles)
 
 		diff := computeDiffOfNormalizedClusters(managedCluster, existingMC)
 		if diff == "" {
@@ -347,6 +347,21 @@ func (s *ManagedClusterSpec) Parameters(existing interface{}) (params interface{
 	return managedCluster, nil
 }
 
+// agentPoolProfilesWithoutPowerState returns a copy of the agent pool profiles without their power state. The power
+// state of agent pools is writable, so sending back the one read with the managed cluster could revert a start or
+// stop of an agent pool by AMMP.
+func agentPoolProfilesWithoutPowerState(profiles *[]containerservice.ManagedClusterAgentPoolProfile) *[]containerservice.ManagedClusterAgentPoolProfile {
+	if profiles == nil {
+		return nil
+	}
+	result := make([]containerservice.ManagedClusterAgentPoolProfile, len(*profiles))
+	for i, profile := range *profiles {
+		profile.PowerState = nil
+		result[i] = profile
+	}
+	return &result
+}
+
 func convertToResourceReferences(resources []string) *[]containerservice.ResourceReference {
 	resourceReferences := make([]containerservice.ResourceReference, len(resources))
 	for i := range resources {
@@ -19,7 +19,7 @@ package managedclusters
 import (
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/gomega"
@@ -128,6 +128,30 @@ func TestParameters(t *testing.T) {
 				g.Expect(result.(containerservice.ManagedCluster).KubernetesVersion).To(Equal(to.StringPtr("v1.22.99")))
 			},
 		},
+		{
+			name: "managedcluster exists and an update is needed without the power state of the agent pools",
+			existing: func() containerservice.ManagedCluster {
+				mc := getExistingCluster()
+				for i := range *mc.AgentPoolProfiles {
+					(*mc.AgentPoolProfiles)[i].PowerState = &containerservice.PowerState{Code: containerservice.CodeRunning}
+				}
+				return mc
+			}(),
+			spec: &ManagedClusterSpec{
+				Name:          "test-managedcluster",
+				ResourceGroup: "test-rg",
+				Location:      "test-location",
+				Tags: map[string]string{
+					"test-tag": "test-value",
+				},
+				Version:         "v1.22.99",
+				LoadBalancerSKU: "Standard",
+			},
+			expect: func(g *WithT, result interface{}) {
+				g.Expect(result).To(BeAssignableToTypeOf(containerservice.ManagedCluster{}))
+				g.Expect(result.(containerservice.ManagedCluster).AgentPoolProfiles).To(Equal(getSampleManagedCluster().AgentPoolProfiles))
+			},
+		},
 	}
 	for _, tc := range testcases {
 		tc := tc
@@ -68,6 +68,10 @@ const (
 	MaximumPlatformFaultDomainCount = "MaximumPlatformFaultDomainCount"
 	// UltraSSDAvailable identifies the capability for the support of UltraSSD data disks.
 	UltraSSDAvailable = "UltraSSDAvailable"
+	// MaxNetworkInterfaces identifies the capability for the maximum number of network interfaces.
+	MaxNetworkInterfaces = "MaxNetworkInterfaces"
+	// PremiumIO identifies the capability for the support of premium storage disks.
+	PremiumIO = "PremiumIO"
 )
 
 // HasCapability return true for a capability which can be either
@@ -131,6 +135,20 @@ func (s SKU) GetCapability(name string) (string, bool) {
 	return "", false
 }
 
+// GetZones returns the availability zones of the provided location in which the resource is available.
+func (s SKU) GetZones(location string) []string {
+	if s.LocationInfo == nil {
+		return nil
+	}
+
+	for _, info := range *s.LocationInfo {
+		if info.Location != nil && strings.EqualFold(*info.Location, location) && info.Zones != nil {
+			return *info.Zones
+		}
+	}
+	return nil
+}
+
 // HasLocationCapability returns true if the provided resource supports the location capability.
 func (s SKU) HasLocationCapability(capabilityName, location, zone string) bool {
 	if s.LocationInfo == nil {
@@ -42,6 +42,11 @@ type RoleAssignmentScope interface {
 	ResourceGroup() string
 }
 
+// scaleSetResourceGroupGetter is implemented by scopes whose VMSS may be in a separate resource group.
+type scaleSetResourceGroupGetter interface {
+	ScaleSetResourceGroup() string
+}
+
 // Service provides operations on Azure resources.
 type Service struct {
 	Scope                 RoleAssignmentScope
@@ -138,7 +143,12 @@ func (s *Service) getVMSSPrincipalID(ctx context.Context) (*string, error) {
 	ctx, log, done := tele.StartSpanWithLogger(ctx, "roleassignments.Service.getVMPrincipalID")
 	defer done()
 	log.V(2).Info("fetching principal ID for VMSS")
-	resultVMSS, err := s.virtualMachineScaleSetClient.Get(ctx, s.Scope.ResourceGroup(), s.Scope.Name())
+	resourceGroup := s.Scope.ResourceGroup()
+	// the VMSS of a machine pool may live in a resource group other than the cluster's
+	if vmssScope, ok := s.Scope.(scaleSetResourceGroupGetter); ok {
+		resourceGroup = vmssScope.ScaleSetResourceGroup()
+	}
+	resultVMSS, err := s.virtualMachineScaleSetClient.Get(ctx, resourceGroup, s.Scope.Name())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get principal ID for VMSS")
 	}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+const availabilitySetServiceName = "availabilitysetmachinepools"
+
+// AvailabilitySetService provides operations for machine pools which are orchestrated as discrete Virtual Machines in
+// an availability set instead of a Virtual Machine Scale Set.
+// NOTE: Only the translation of the spec is in place, reconciling the Virtual Machines is not implemented yet.
+type AvailabilitySetService struct {
+	Scope ScaleSetScope
+}
+
+// NewAvailabilitySetService creates a new availability set machine pool service.
+func NewAvailabilitySetService(scope ScaleSetScope) *AvailabilitySetService {
+	return &AvailabilitySetService{
+		Scope: scope,
+	}
+}
+
+// Name returns the service name.
+func (s *AvailabilitySetService) Name() string {
+	return availabilitySetServiceName
+}
+
+// Reconcile returns a terminal error, as machine pools orchestrated with an availability set are not supported yet.
+func (s *AvailabilitySetService) Reconcile(ctx context.Context) error {
+	_, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AvailabilitySetService.Reconcile")
+	defer done()
+
+	spec := s.Scope.ScaleSetSpec()
+	return azure.WithTerminalError(errors.Errorf("cannot reconcile machine pool %s in availability set %s, orchestration with an availability set is not implemented yet",
+		spec.Name, spec.AvailabilitySetName))
+}
+
+// Delete is a no-op, as no Azure resources are created for machine pools orchestrated with an availability set yet.
+func (s *AvailabilitySetService) Delete(ctx context.Context) error {
+	_, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AvailabilitySetService.Delete")
+	defer done()
+
+	return nil
+}
+
+// IsManaged always returns true as CAPZ does not support BYO availability set machine pools.
+func (s *AvailabilitySetService) IsManaged(ctx context.Context) (bool, error) {
+	return true, nil
+}
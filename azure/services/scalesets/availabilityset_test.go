@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/scalesets/mock_scalesets"
+)
+
+func TestAvailabilitySetServiceReconcile(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+	scopeMock.EXPECT().ScaleSetSpec().Return(azure.ScaleSetSpec{
+		Name:                "my-pool",
+		OrchestrationMode:   azure.AvailabilitySetOrchestrationMode,
+		AvailabilitySetName: "my-cluster_my-pool-as",
+	})
+
+	s := NewAvailabilitySetService(scopeMock)
+	err := s.Reconcile(context.TODO())
+	g.Expect(err).To(MatchError("reconcile error that cannot be recovered occurred: cannot reconcile machine pool my-pool in availability set my-cluster_my-pool-as, orchestration with an availability set is not implemented yet. Object will not be requeued"))
+}
+
+func TestAvailabilitySetServiceDelete(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	s := NewAvailabilitySetService(mock_scalesets.NewMockScaleSetScope(mockCtrl))
+	g.Expect(s.Delete(context.TODO())).To(Succeed())
+}
@@ -24,6 +24,8 @@ import (
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	"github.com/Azure/go-autorest/autorest"
 	azureautorest "github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
@@ -44,14 +46,21 @@ type Client interface {
 	UpdateAsync(context.Context, string, string, compute.VirtualMachineScaleSetUpdate) (*infrav1.Future, error)
 	GetResultIfDone(ctx context.Context, future *infrav1.Future) (compute.VirtualMachineScaleSet, error)
 	UpdateInstances(context.Context, string, string, []string) error
+	ReimageInstance(context.Context, string, string, string) error
 	DeleteAsync(context.Context, string, string) (*infrav1.Future, error)
+	GetLoadBalancer(context.Context, string, string) (network.LoadBalancer, error)
+	GetImagePlanTerms(context.Context, string, string, string) (marketplaceordering.AgreementTerms, error)
+	ListUsages(context.Context, string) ([]compute.Usage, error)
 }
 
 type (
 	// AzureClient contains the Azure go-sdk Client.
 	AzureClient struct {
-		scalesetvms compute.VirtualMachineScaleSetVMsClient
-		scalesets   compute.VirtualMachineScaleSetsClient
+		scalesetvms   compute.VirtualMachineScaleSetVMsClient
+		scalesets     compute.VirtualMachineScaleSetsClient
+		loadbalancers network.LoadBalancersClient
+		agreements    marketplaceordering.MarketplaceAgreementsClient
+		usages        compute.UsageClient
 	}
 
 	genericScaleSetFuture interface {
@@ -74,8 +83,11 @@ var _ Client = &AzureClient{}
 // NewClient creates a new VMSS client from subscription ID.
 func NewClient(auth azure.Authorizer) *AzureClient {
 	return &AzureClient{
-		scalesetvms: newVirtualMachineScaleSetVMsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
-		scalesets:   newVirtualMachineScaleSetsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+		scalesetvms:   newVirtualMachineScaleSetVMsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+		scalesets:     newVirtualMachineScaleSetsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+		loadbalancers: newLoadBalancersClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+		agreements:    newMarketplaceAgreementsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+		usages:        newUsageClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
 	}
 }
 
@@ -93,6 +105,27 @@ func newVirtualMachineScaleSetsClient(subscriptionID string, baseURI string, aut
 	return c
 }
 
+// newLoadBalancersClient creates a new load balancer client from subscription ID.
+func newLoadBalancersClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) network.LoadBalancersClient {
+	c := network.NewLoadBalancersClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&c.Client, authorizer)
+	return c
+}
+
+// newMarketplaceAgreementsClient creates a new marketplace agreements client from subscription ID.
+func newMarketplaceAgreementsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) marketplaceordering.MarketplaceAgreementsClient {
+	c := marketplaceordering.NewMarketplaceAgreementsClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&c.Client, authorizer)
+	return c
+}
+
+// newUsageClient creates a new compute usage client from subscription ID.
+func newUsageClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) compute.UsageClient {
+	c := compute.NewUsageClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&c.Client, authorizer)
+	return c
+}
+
 // ListInstances retrieves information about the model views of a virtual machine scale set.
 func (ac *AzureClient) ListInstances(ctx context.Context, resourceGroupName, vmssName string) ([]compute.VirtualMachineScaleSetVM, error) {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AzureClient.ListInstances")
@@ -143,6 +176,43 @@ func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, vmssName stri
 	return ac.scalesets.Get(ctx, resourceGroupName, vmssName, "")
 }
 
+// GetLoadBalancer retrieves a load balancer, e.g. to look up the inbound NAT pools the scale set is attached to.
+func (ac *AzureClient) GetLoadBalancer(ctx context.Context, resourceGroupName, lbName string) (network.LoadBalancer, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AzureClient.GetLoadBalancer")
+	defer done()
+
+	return ac.loadbalancers.Get(ctx, resourceGroupName, lbName, "")
+}
+
+// GetImagePlanTerms retrieves the terms of the plan of a marketplace virtual machine image in the subscription.
+func (ac *AzureClient) GetImagePlanTerms(ctx context.Context, publisher, offer, plan string) (marketplaceordering.AgreementTerms, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AzureClient.GetImagePlanTerms")
+	defer done()
+
+	return ac.agreements.Get(ctx, marketplaceordering.OfferTypeVirtualmachine, publisher, offer, plan)
+}
+
+// ListUsages returns the current compute resource usages and limits of the subscription in a location, e.g. of the
+// regional and per VM family vCPU quotas.
+func (ac *AzureClient) ListUsages(ctx context.Context, location string) ([]compute.Usage, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AzureClient.ListUsages")
+	defer done()
+
+	itr, err := ac.usages.ListComplete(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []compute.Usage
+	for ; itr.NotDone(); err = itr.NextWithContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate compute usages [%w]", err)
+		}
+		usages = append(usages, itr.Value())
+	}
+	return usages, nil
+}
+
 // CreateOrUpdateAsync the operation to create or update a virtual machine scale set without waiting for the operation
 // to complete.
 func (ac *AzureClient) CreateOrUpdateAsync(ctx context.Context, resourceGroupName, vmssName string, vmss compute.VirtualMachineScaleSet) (*infrav1.Future, error) {
@@ -282,6 +352,23 @@ func (ac *AzureClient) UpdateInstances(ctx context.Context, resourceGroupName, v
 	return err
 }
 
+// ReimageInstance reimages an instance of a VM scale set.
+func (ac *AzureClient) ReimageInstance(ctx context.Context, resourceGroupName, vmssName, instanceID string) error {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.AzureClient.ReimageInstance")
+	defer done()
+
+	future, err := ac.scalesetvms.Reimage(ctx, resourceGroupName, vmssName, instanceID, nil)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.scalesetvms.Client)
+	if err != nil {
+		return err
+	}
+	_, err = future.Result(ac.scalesetvms)
+	return err
+}
+
 // DeleteAsync is the operation to delete a virtual machine scale set asynchronously. DeleteAsync sends a DELETE
 // request to Azure and if accepted without error, the func will return a Future which can be used to track the ongoing
 // progress of the operation.
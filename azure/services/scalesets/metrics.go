@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// reconcileDuration records the duration of the VMSS reconciliations per service.
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "capz_vmss_reconcile_duration_seconds",
+		Help: "Duration of VMSS reconciliations in seconds.",
+	}, []string{"service"})
+
+	// modelUpdates counts the VMSS patches which change the model of the VMSS and therefore roll the machine pool.
+	modelUpdates = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "capz_vmss_model_updates_total",
+		Help: "Number of VMSS patches changing the VMSS model.",
+	})
+
+	// surges counts the VMSS patches which surge the capacity above the desired replicas.
+	surges = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "capz_vmss_surges_total",
+		Help: "Number of VMSS patches surging the VMSS capacity above the desired replicas.",
+	})
+)
+
+func init() {
+	// register with the controller-runtime registry, which is served on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(reconcileDuration, modelUpdates, surges)
+}
+
+// observeReconcileDuration records the duration of a reconciliation of the given service which started at start.
+func observeReconcileDuration(service string, start time.Time) {
+	reconcileDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+}
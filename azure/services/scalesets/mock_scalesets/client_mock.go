@@ -25,6 +25,8 @@ import (
 	reflect "reflect"
 
 	compute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	marketplaceordering "github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
+	network "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	autorest "github.com/Azure/go-autorest/autorest"
 	gomock "github.com/golang/mock/gomock"
 	v1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
@@ -98,6 +100,36 @@ func (mr *MockClientMockRecorder) Get(arg0, arg1, arg2 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockClient)(nil).Get), arg0, arg1, arg2)
 }
 
+// GetImagePlanTerms mocks base method.
+func (m *MockClient) GetImagePlanTerms(arg0 context.Context, arg1, arg2, arg3 string) (marketplaceordering.AgreementTerms, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImagePlanTerms", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(marketplaceordering.AgreementTerms)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImagePlanTerms indicates an expected call of GetImagePlanTerms.
+func (mr *MockClientMockRecorder) GetImagePlanTerms(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImagePlanTerms", reflect.TypeOf((*MockClient)(nil).GetImagePlanTerms), arg0, arg1, arg2, arg3)
+}
+
+// GetLoadBalancer mocks base method.
+func (m *MockClient) GetLoadBalancer(arg0 context.Context, arg1, arg2 string) (network.LoadBalancer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoadBalancer", arg0, arg1, arg2)
+	ret0, _ := ret[0].(network.LoadBalancer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoadBalancer indicates an expected call of GetLoadBalancer.
+func (mr *MockClientMockRecorder) GetLoadBalancer(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoadBalancer", reflect.TypeOf((*MockClient)(nil).GetLoadBalancer), arg0, arg1, arg2)
+}
+
 // GetResultIfDone mocks base method.
 func (m *MockClient) GetResultIfDone(ctx context.Context, future *v1beta1.Future) (compute.VirtualMachineScaleSet, error) {
 	m.ctrl.T.Helper()
@@ -143,6 +175,35 @@ func (mr *MockClientMockRecorder) ListInstances(arg0, arg1, arg2 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstances", reflect.TypeOf((*MockClient)(nil).ListInstances), arg0, arg1, arg2)
 }
 
+// ListUsages mocks base method.
+func (m *MockClient) ListUsages(arg0 context.Context, arg1 string) ([]compute.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsages", arg0, arg1)
+	ret0, _ := ret[0].([]compute.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsages indicates an expected call of ListUsages.
+func (mr *MockClientMockRecorder) ListUsages(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsages", reflect.TypeOf((*MockClient)(nil).ListUsages), arg0, arg1)
+}
+
+// ReimageInstance mocks base method.
+func (m *MockClient) ReimageInstance(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReimageInstance", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReimageInstance indicates an expected call of ReimageInstance.
+func (mr *MockClientMockRecorder) ReimageInstance(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReimageInstance", reflect.TypeOf((*MockClient)(nil).ReimageInstance), arg0, arg1, arg2, arg3)
+}
+
 // UpdateAsync mocks base method.
 func (m *MockClient) UpdateAsync(arg0 context.Context, arg1, arg2 string, arg3 compute.VirtualMachineScaleSetUpdate) (*v1beta1.Future, error) {
 	m.ctrl.T.Helper()
@@ -96,6 +96,20 @@ func (mr *MockScaleSetScopeMockRecorder) AvailabilitySetEnabled() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AvailabilitySetEnabled", reflect.TypeOf((*MockScaleSetScope)(nil).AvailabilitySetEnabled))
 }
 
+// AzureMachinePoolAnnotations mocks base method.
+func (m *MockScaleSetScope) AzureMachinePoolAnnotations() map[string]string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AzureMachinePoolAnnotations")
+	ret0, _ := ret[0].(map[string]string)
+	return ret0
+}
+
+// AzureMachinePoolAnnotations indicates an expected call of AzureMachinePoolAnnotations.
+func (mr *MockScaleSetScopeMockRecorder) AzureMachinePoolAnnotations() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AzureMachinePoolAnnotations", reflect.TypeOf((*MockScaleSetScope)(nil).AzureMachinePoolAnnotations))
+}
+
 // BaseURI mocks base method.
 func (m *MockScaleSetScope) BaseURI() string {
 	m.ctrl.T.Helper()
@@ -221,6 +235,21 @@ func (mr *MockScaleSetScopeMockRecorder) GetBootstrapData(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBootstrapData", reflect.TypeOf((*MockScaleSetScope)(nil).GetBootstrapData), arg0)
 }
 
+// GetLinuxAdminPassword mocks base method.
+func (m *MockScaleSetScope) GetLinuxAdminPassword(arg0 context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLinuxAdminPassword", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLinuxAdminPassword indicates an expected call of GetLinuxAdminPassword.
+func (mr *MockScaleSetScopeMockRecorder) GetLinuxAdminPassword(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLinuxAdminPassword", reflect.TypeOf((*MockScaleSetScope)(nil).GetLinuxAdminPassword), arg0)
+}
+
 // GetLongRunningOperationState mocks base method.
 func (m *MockScaleSetScope) GetLongRunningOperationState(arg0, arg1 string) *v1beta1.Future {
 	m.ctrl.T.Helper()
@@ -264,6 +293,21 @@ func (mr *MockScaleSetScopeMockRecorder) HashKey() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashKey", reflect.TypeOf((*MockScaleSetScope)(nil).HashKey))
 }
 
+// InstancesToReimage mocks base method.
+func (m *MockScaleSetScope) InstancesToReimage(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InstancesToReimage", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InstancesToReimage indicates an expected call of InstancesToReimage.
+func (mr *MockScaleSetScopeMockRecorder) InstancesToReimage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InstancesToReimage", reflect.TypeOf((*MockScaleSetScope)(nil).InstancesToReimage), arg0)
+}
+
 // Location mocks base method.
 func (m *MockScaleSetScope) Location() string {
 	m.ctrl.T.Helper()
@@ -293,6 +337,32 @@ func (mr *MockScaleSetScopeMockRecorder) MaxSurge() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaxSurge", reflect.TypeOf((*MockScaleSetScope)(nil).MaxSurge))
 }
 
+// RemoveAzureMachinePoolAnnotation mocks base method.
+func (m *MockScaleSetScope) RemoveAzureMachinePoolAnnotation(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemoveAzureMachinePoolAnnotation", arg0)
+}
+
+// RemoveAzureMachinePoolAnnotation indicates an expected call of RemoveAzureMachinePoolAnnotation.
+func (mr *MockScaleSetScopeMockRecorder) RemoveAzureMachinePoolAnnotation(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAzureMachinePoolAnnotation", reflect.TypeOf((*MockScaleSetScope)(nil).RemoveAzureMachinePoolAnnotation), arg0)
+}
+
+// RemoveReimageAnnotation mocks base method.
+func (m *MockScaleSetScope) RemoveReimageAnnotation(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveReimageAnnotation", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveReimageAnnotation indicates an expected call of RemoveReimageAnnotation.
+func (mr *MockScaleSetScopeMockRecorder) RemoveReimageAnnotation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReimageAnnotation", reflect.TypeOf((*MockScaleSetScope)(nil).RemoveReimageAnnotation), arg0, arg1)
+}
+
 // ResourceGroup mocks base method.
 func (m *MockScaleSetScope) ResourceGroup() string {
 	m.ctrl.T.Helper()
@@ -18,13 +18,21 @@ package scalesets
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/converters"
@@ -35,7 +43,33 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
-const serviceName = "scalesets"
+const (
+	serviceName = "scalesets"
+
+	// maxCapacity is the maximum number of instances of a VMSS.
+	maxCapacity = 1000
+
+	// maxSinglePlacementGroupCapacity is the maximum number of instances of a VMSS using a single placement group.
+	maxSinglePlacementGroupCapacity = 100
+
+	// maxDataDiskLuns is the number of logical unit numbers, 0 to 63, available to the data disks of a VMSS.
+	maxDataDiskLuns = 64
+
+	// patchConflictBaseDelay is the delay before retrying a VMSS patch after the first conflict.
+	patchConflictBaseDelay = 30 * time.Second
+	// patchConflictMaxDelay is the maximum delay before retrying a VMSS patch after consecutive conflicts, before jitter.
+	patchConflictMaxDelay = 10 * time.Minute
+	// patchConflictJitterFactor is the maximum fraction of the delay which is added at random, so that retries competing
+	// with other writers of the VMSS, e.g. the cloud-provider, are not synchronized.
+	patchConflictJitterFactor = 0.2
+
+	// regionalVCPUsUsageName is the name of the compute usage of the vCPUs of all VM families in a location.
+	regionalVCPUsUsageName = "cores"
+
+	// defaultOperationTimeout is the maximum age of a long running VMSS operation before it is treated as failed, unless
+	// overridden by the scale set spec.
+	defaultOperationTimeout = 2 * time.Hour
+)
 
 type (
 	// ScaleSetScope defines the scope interface for a scale sets service.
@@ -43,14 +77,19 @@ type (
 		azure.ClusterDescriber
 		azure.AsyncStatusUpdater
 		GetBootstrapData(context.Context) (string, error)
+		GetLinuxAdminPassword(context.Context) (string, error)
 		GetVMImage(context.Context) (*infrav1.Image, error)
 		SaveVMImageToStatus(*infrav1.Image)
 		MaxSurge() (int, error)
 		ScaleSetSpec() azure.ScaleSetSpec
 		VMSSExtensionSpecs() []azure.ResourceSpecGetter
 		SetAnnotation(string, string)
+		AzureMachinePoolAnnotations() map[string]string
+		RemoveAzureMachinePoolAnnotation(string)
 		SetProviderID(string)
 		SetVMSSState(*azure.VMSS)
+		InstancesToReimage(context.Context) ([]string, error)
+		RemoveReimageAnnotation(context.Context, string) error
 	}
 
 	// Service provides operations on Azure resources.
@@ -79,6 +118,7 @@ func (s *Service) Name() string {
 func (s *Service) Reconcile(ctx context.Context) (retErr error) {
 	ctx, log, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.Reconcile")
 	defer done()
+	defer observeReconcileDuration(serviceName, time.Now())
 
 	if err := s.validateSpec(ctx); err != nil {
 		// do as much early validation as possible to limit calls to Azure
@@ -88,6 +128,7 @@ func (s *Service) Reconcile(ctx context.Context) (retErr error) {
 	var err error
 
 	scaleSetSpec := s.Scope.ScaleSetSpec()
+	resourceGroup := s.scaleSetResourceGroup(scaleSetSpec)
 
 	// check if there is an ongoing long running operation
 	var (
@@ -98,7 +139,7 @@ func (s *Service) Reconcile(ctx context.Context) (retErr error) {
 	defer func() {
 		// save the updated state of the VMSS for the MachinePoolScope to use for updating K8s state
 		if fetchedVMSS == nil {
-			fetchedVMSS, err = s.getVirtualMachineScaleSet(ctx, scaleSetSpec.Name)
+			fetchedVMSS, err = s.getVirtualMachineScaleSet(ctx, resourceGroup, scaleSetSpec.Name)
 			if err != nil && !azure.ResourceNotFound(err) {
 				log.Error(err, "failed to get vmss in deferred update")
 			}
@@ -116,9 +157,14 @@ func (s *Service) Reconcile(ctx context.Context) (retErr error) {
 	}()
 
 	if future == nil {
-		fetchedVMSS, err = s.getVirtualMachineScaleSet(ctx, scaleSetSpec.Name)
+		fetchedVMSS, err = s.getVirtualMachineScaleSet(ctx, resourceGroup, scaleSetSpec.Name)
 	} else {
 		fetchedVMSS, err = s.getVirtualMachineScaleSetIfDone(ctx, future)
+		if azure.IsOperationNotDoneError(err) {
+			if timeoutErr := s.checkOperationTimeout(ctx, future, scaleSetSpec.OperationTimeout); timeoutErr != nil {
+				return timeoutErr
+			}
+		}
 	}
 
 	switch {
@@ -144,6 +190,7 @@ func (s *Service) Reconcile(ctx context.Context) (retErr error) {
 	// Try to get the VMSS to update status if we have created a long running operation. If the VMSS is still in a long
 	// running operation, getVirtualMachineScaleSetIfDone will return an azure.WithTransientError and requeue.
 	if future != nil {
+		s.Scope.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, time.Now().UTC().Format(time.RFC3339))
 		fetchedVMSS, err = s.getVirtualMachineScaleSetIfDone(ctx, future)
 		if err != nil {
 			return errors.Wrapf(err, "failed to get VMSS %s after create or update", scaleSetSpec.Name)
@@ -152,10 +199,66 @@ func (s *Service) Reconcile(ctx context.Context) (retErr error) {
 
 	// If we get to here, we have completed any long running VMSS operations (creates / updates)
 	s.Scope.DeleteLongRunningOperationState(s.Scope.ScaleSetSpec().Name, serviceName)
+	s.Scope.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
 	// This also means that the VMSS extensions were successfully installed
 	// Note: we want to handle UpdatePutStatus when VMSSExtensions have an error when scalesets become an async service
 	s.Scope.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
 
+	if err := s.reimageInstances(ctx, resourceGroup, scaleSetSpec.Name); err != nil {
+		return errors.Wrapf(err, "failed to reimage instances of VMSS %s", scaleSetSpec.Name)
+	}
+
+	return nil
+}
+
+// checkOperationTimeout returns a terminal error if the long running operation of the future has been running for
+// longer than the timeout, and clears the future so that the next reconciliation starts over.
+func (s *Service) checkOperationTimeout(ctx context.Context, future *infrav1.Future, timeout time.Duration) error {
+	_, log, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.checkOperationTimeout")
+	defer done()
+
+	if timeout <= 0 {
+		timeout = defaultOperationTimeout
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, s.Scope.AzureMachinePoolAnnotations()[azure.VMSSOperationStartedAtAnnotation])
+	if err != nil {
+		// the start of the operation was not recorded, e.g. by an earlier version, so the timeout starts now
+		s.Scope.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, time.Now().UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	if age := time.Since(startedAt); age > timeout {
+		log.Info("giving up on long running operation exceeding the timeout", "scale set", future.Name, "type", future.Type, "age", age, "timeout", timeout)
+		s.Scope.DeleteLongRunningOperationState(future.Name, serviceName)
+		s.Scope.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
+		return azure.WithTerminalError(errors.Errorf("operation type %s on VMSS %s did not complete within %s", future.Type, future.Name, timeout))
+	}
+
+	return nil
+}
+
+// reimageInstances reimages the instances of the scale set whose AzureMachinePoolMachines are annotated to be
+// reimaged, and removes the annotation of each instance once it has been reimaged.
+func (s *Service) reimageInstances(ctx context.Context, resourceGroup, vmssName string) error {
+	ctx, log, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.reimageInstances")
+	defer done()
+
+	instanceIDs, err := s.Scope.InstancesToReimage(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get instances to reimage")
+	}
+
+	for _, instanceID := range instanceIDs {
+		log.V(2).Info("reimaging instance", "vmss", vmssName, "instanceID", instanceID)
+		if err := s.Client.ReimageInstance(ctx, resourceGroup, vmssName, instanceID); err != nil {
+			return errors.Wrapf(err, "failed to reimage instance %s", instanceID)
+		}
+		if err := s.Scope.RemoveReimageAnnotation(ctx, instanceID); err != nil {
+			return errors.Wrapf(err, "failed to remove reimage annotation of instance %s", instanceID)
+		}
+	}
+
 	return nil
 }
 
@@ -168,10 +271,11 @@ func (s *Service) Delete(ctx context.Context) error {
 	var err error
 
 	vmssSpec := s.Scope.ScaleSetSpec()
+	resourceGroup := s.scaleSetResourceGroup(vmssSpec)
 
 	defer func() {
 		// save the updated state of the VMSS for the MachinePoolScope to use for updating K8s state
-		fetchedVMSS, err := s.getVirtualMachineScaleSet(ctx, vmssSpec.Name)
+		fetchedVMSS, err := s.getVirtualMachineScaleSet(ctx, resourceGroup, vmssSpec.Name)
 		if err != nil && !azure.ResourceNotFound(err) {
 			log.Error(err, "failed to get vmss in deferred update")
 		}
@@ -200,13 +304,13 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	// no long running delete operation is active, so delete the ScaleSet
 	log.V(2).Info("deleting VMSS", "scale set", vmssSpec.Name)
-	future, err = s.Client.DeleteAsync(ctx, s.Scope.ResourceGroup(), vmssSpec.Name)
+	future, err = s.Client.DeleteAsync(ctx, resourceGroup, vmssSpec.Name)
 	if err != nil {
 		if azure.ResourceNotFound(err) {
 			// already deleted
 			return nil
 		}
-		return errors.Wrapf(err, "failed to delete VMSS %s in resource group %s", vmssSpec.Name, s.Scope.ResourceGroup())
+		return errors.Wrapf(err, "failed to delete VMSS %s in resource group %s", vmssSpec.Name, resourceGroup)
 	}
 
 	s.Scope.SetLongRunningOperationState(future)
@@ -235,10 +339,25 @@ func (s *Service) createVMSS(ctx context.Context) (*infrav1.Future, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed building VMSS from spec")
 	}
+	if err := s.setLinuxAdminPassword(ctx, vmss.VirtualMachineProfile.OsProfile); err != nil {
+		return nil, err
+	}
 
-	future, err := s.Client.CreateOrUpdateAsync(ctx, s.Scope.ResourceGroup(), spec.Name, vmss)
+	if spec.VerifyImagePlanTerms {
+		if err := s.verifyImagePlanTerms(ctx, vmss.Plan); err != nil {
+			return nil, err
+		}
+	}
+
+	future, err := s.Client.CreateOrUpdateAsync(ctx, s.scaleSetResourceGroup(spec), spec.Name, vmss)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot create VMSS")
+		err = wrapWithCorrelationID(err, "cannot create VMSS")
+		s.Scope.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, err)
+		return nil, err
+	}
+
+	if spec.RollOnBootstrapDataChanges {
+		s.Scope.SetAnnotation(azure.BootstrapDataHashAnnotation, bootstrapDataHash(vmss))
 	}
 
 	log.V(2).Info("starting to create VMSS", "scale set", spec.Name)
@@ -246,11 +365,75 @@ func (s *Service) createVMSS(ctx context.Context) (*infrav1.Future, error) {
 	return future, err
 }
 
+// setLinuxAdminPassword sets the admin password of a Linux scale set with password authentication enabled, since Azure
+// requires one, e.g. for the serial console of appliance images. It is only set when the scale set is created, as the
+// patches of the scale set don't contain an admin password.
+func (s *Service) setLinuxAdminPassword(ctx context.Context, osProfile *compute.VirtualMachineScaleSetOSProfile) error {
+	if osProfile == nil || osProfile.LinuxConfiguration == nil || to.Bool(osProfile.LinuxConfiguration.DisablePasswordAuthentication) {
+		return nil
+	}
+
+	password, err := s.Scope.GetLinuxAdminPassword(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the admin password")
+	}
+	osProfile.AdminPassword = to.StringPtr(password)
+	return nil
+}
+
+// verifyImagePlanTerms checks that the terms of the marketplace image plan have been accepted in the subscription, since
+// creating the scale set fails with an opaque error otherwise.
+func (s *Service) verifyImagePlanTerms(ctx context.Context, plan *compute.Plan) error {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.verifyImagePlanTerms")
+	defer done()
+
+	if plan == nil {
+		return nil
+	}
+
+	publisher, offer, name := to.String(plan.Publisher), to.String(plan.Product), to.String(plan.Name)
+	terms, err := s.Client.GetImagePlanTerms(ctx, publisher, offer, name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the terms of image plan %s", name)
+	}
+	if terms.AgreementProperties == nil || !to.Bool(terms.Accepted) {
+		return azure.WithTerminalError(errors.Errorf("the terms of image plan %s of offer %s by publisher %s have not been accepted in the subscription. "+
+			"accept them, e.g. with `az vm image terms accept --publisher %s --offer %s --plan %s`", name, offer, publisher, publisher, offer, name))
+	}
+	return nil
+}
+
+// wrapWithCorrelationID wraps the error of a failed Azure request with the message and the correlation ID of the request,
+// which is needed when opening support tickets.
+func wrapWithCorrelationID(err error, message string) error {
+	if id := azure.CorrelationID(err); id != "" {
+		return errors.Wrapf(err, "%s (correlation ID %s)", message, id)
+	}
+	return errors.Wrap(err, message)
+}
+
+// patchConflictDelay returns the delay before retrying a VMSS patch after the given number of consecutive conflicts. The
+// delay doubles with every conflict up to patchConflictMaxDelay, and is jittered.
+func patchConflictDelay(conflicts int) time.Duration {
+	delay := patchConflictBaseDelay
+	for i := 0; i < conflicts && delay < patchConflictMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > patchConflictMaxDelay {
+		delay = patchConflictMaxDelay
+	}
+	return wait.Jitter(delay, patchConflictJitterFactor)
+}
+
 func (s *Service) patchVMSSIfNeeded(ctx context.Context, infraVMSS *azure.VMSS) (*infrav1.Future, error) {
 	ctx, log, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.patchVMSSIfNeeded")
 	defer done()
 
 	spec := s.Scope.ScaleSetSpec()
+	if spec.ReplicasManagedExternally {
+		// The external autoscaler owns the capacity, so keep the current capacity instead of the machine pool replicas.
+		spec.Capacity = infraVMSS.Capacity
+	}
 
 	vmss, err := s.buildVMSSFromSpec(ctx, spec)
 	if err != nil {
@@ -261,34 +444,92 @@ func (s *Service) patchVMSSIfNeeded(ctx context.Context, infraVMSS *azure.VMSS)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to generate vmss patch for %s", spec.Name)
 	}
+	markRemovedUserAssignedIdentities(patch.Identity, infraVMSS.UserAssignedIdentities)
 
 	maxSurge, err := s.Scope.MaxSurge()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to calculate maxSurge")
 	}
 
-	hasModelChanges := hasModelModifyingDifferences(infraVMSS, vmss)
-	if maxSurge > 0 && (hasModelChanges || !infraVMSS.HasEnoughLatestModelOrNotMixedModel()) {
+	// the instances of the new size, including the surged ones, are placed into the zones of the existing scale set
+	if !strings.EqualFold(infraVMSS.Sku, spec.Size) {
+		if err := s.validateZonesWithVMSize(ctx, spec, infraVMSS.Zones); err != nil {
+			return nil, err
+		}
+	}
+
+	// the network profile is not patched unless the DNS servers change, so updates won't conflict with Cloud Provider updates
+	dnsServersChanged := hasDNSServerChanges(infraVMSS.DNSServers, spec.DNSServers)
+	if dnsServersChanged {
+		networkProfile, err := s.getNetworkProfileUpdate(ctx, spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to generate network profile patch for %s", spec.Name)
+		}
+		patch.VirtualMachineProfile.NetworkProfile = networkProfile
+	}
+
+	// bootstrap data changes only change the model if opted in, since some bootstrap providers rotate the data regularly
+	bootstrapDataChanged := false
+	newBootstrapDataHash := bootstrapDataHash(vmss)
+	if spec.RollOnBootstrapDataChanges {
+		// without a recorded hash the current bootstrap data is taken as applied, so opting in doesn't roll the instances
+		bootstrapDataChanged = spec.BootstrapDataHash != "" && spec.BootstrapDataHash != newBootstrapDataHash
+	} else if spec.BootstrapDataHash != "" {
+		// forget the hash, so opting in again later doesn't compare against outdated bootstrap data
+		s.Scope.RemoveAzureMachinePoolAnnotation(azure.BootstrapDataHashAnnotation)
+	}
+
+	hasModelChanges := hasModelModifyingDifferences(infraVMSS, vmss) || dnsServersChanged || bootstrapDataChanged
+	// a scale set scaling to zero does not roll its instances, so it is never surged. Neither is a scale set whose
+	// capacity is owned by an external autoscaler, since the surged capacity would be the base of the next surge.
+	scalingToZero := spec.Capacity == 0 && infraVMSS.Capacity > 0
+	surging := !scalingToZero && !spec.ReplicasManagedExternally && maxSurge > 0 &&
+		(hasModelChanges || !infraVMSS.HasEnoughLatestModelOrNotMixedModel())
+	if surging {
 		// surge capacity with the intention of lowering during instance reconciliation
 		surge := spec.Capacity + int64(maxSurge)
+		if spec.CheckQuotaBeforeSurge {
+			if surge, err = s.capSurgeToQuota(ctx, spec, infraVMSS.Capacity, surge); err != nil {
+				return nil, err
+			}
+			surging = surge > spec.Capacity
+		}
 		log.V(4).Info("surging...", "surge", surge)
 		patch.Sku.Capacity = to.Int64Ptr(surge)
 	}
 
 	// If there are no model changes and no increase in the replica count, do not update the VMSS.
-	// Decreases in replica count is handled by deleting AzureMachinePoolMachine instances in the MachinePoolScope
-	if *patch.Sku.Capacity <= infraVMSS.Capacity && !hasModelChanges {
+	// Decreases in replica count is handled by deleting AzureMachinePoolMachine instances in the MachinePoolScope,
+	// except for scaling to zero, which needs no selection of the instances to delete and is patched directly.
+	if *patch.Sku.Capacity <= infraVMSS.Capacity && !hasModelChanges && !scalingToZero {
 		log.V(4).Info("nothing to update on vmss", "scale set", spec.Name, "newReplicas", *patch.Sku.Capacity, "oldReplicas", infraVMSS.Capacity, "hasChanges", hasModelChanges)
+		if spec.RollOnBootstrapDataChanges && spec.BootstrapDataHash == "" {
+			s.Scope.SetAnnotation(azure.BootstrapDataHashAnnotation, newBootstrapDataHash)
+		}
 		return nil, nil
 	}
 
 	log.V(4).Info("patching vmss", "scale set", spec.Name, "patch", patch)
-	future, err := s.UpdateAsync(ctx, s.Scope.ResourceGroup(), spec.Name, patch)
+	future, err := s.UpdateAsync(ctx, s.scaleSetResourceGroup(spec), spec.Name, patch)
 	if err != nil {
 		if azure.ResourceConflict(err) {
-			return nil, azure.WithTransientError(err, 30*time.Second)
+			conflicts, _ := strconv.Atoi(s.Scope.AzureMachinePoolAnnotations()[azure.VMSSPatchConflictsAnnotation])
+			s.Scope.SetAnnotation(azure.VMSSPatchConflictsAnnotation, strconv.Itoa(conflicts+1))
+			return nil, azure.WithTransientError(err, patchConflictDelay(conflicts))
 		}
-		return nil, errors.Wrap(err, "failed updating VMSS")
+		return nil, wrapWithCorrelationID(err, "failed updating VMSS")
+	}
+
+	s.Scope.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+	if spec.RollOnBootstrapDataChanges && spec.BootstrapDataHash != newBootstrapDataHash {
+		s.Scope.SetAnnotation(azure.BootstrapDataHashAnnotation, newBootstrapDataHash)
+	}
+
+	if hasModelChanges {
+		modelUpdates.Inc()
+	}
+	if surging {
+		surges.Inc()
 	}
 
 	s.Scope.SetLongRunningOperationState(future)
@@ -296,92 +537,192 @@ func (s *Service) patchVMSSIfNeeded(ctx context.Context, infraVMSS *azure.VMSS)
 	return future, err
 }
 
-func hasModelModifyingDifferences(infraVMSS *azure.VMSS, vmss compute.VirtualMachineScaleSet) bool {
-	other := converters.SDKToVMSS(vmss, []compute.VirtualMachineScaleSetVM{})
-	return infraVMSS.HasModelChanges(*other)
+// markRemovedUserAssignedIdentities adds the existing user-assigned identities missing from the identity of a patch
+// with a null value, since Azure keeps the user-assigned identities which are not part of a patch.
+func markRemovedUserAssignedIdentities(identity *compute.VirtualMachineScaleSetIdentity, existing []string) {
+	if identity == nil || identity.UserAssignedIdentities == nil {
+		// without user-assigned identities in the patch, the identity type removes them all
+		return
+	}
+
+	for _, existingID := range existing {
+		removed := true
+		for id := range identity.UserAssignedIdentities {
+			if strings.EqualFold(id, existingID) {
+				removed = false
+				break
+			}
+		}
+		if removed {
+			identity.UserAssignedIdentities[existingID] = nil
+		}
+	}
 }
 
-func (s *Service) validateSpec(ctx context.Context) error {
-	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.validateSpec")
+// bootstrapDataHash returns the hex encoded SHA-256 hash of the custom data of the scale set.
+func bootstrapDataHash(vmss compute.VirtualMachineScaleSet) string {
+	var customData string
+	if vmss.VirtualMachineProfile != nil && vmss.VirtualMachineProfile.OsProfile != nil && vmss.VirtualMachineProfile.OsProfile.CustomData != nil {
+		customData = *vmss.VirtualMachineProfile.OsProfile.CustomData
+	}
+	sum := sha256.Sum256([]byte(customData))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateZonesWithVMSize returns a terminal error if the VM size of the spec is not available in one of the zones,
+// since the instances of the scale set could never be created in that zone.
+func (s *Service) validateZonesWithVMSize(ctx context.Context, spec azure.ScaleSetSpec, zones []string) error {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.validateZonesWithVMSize")
 	defer done()
 
-	spec := s.Scope.ScaleSetSpec()
+	if len(zones) == 0 {
+		return nil
+	}
 
-	sku, err := s.resourceSKUCache.Get(ctx, spec.Size, resourceskus.VirtualMachines)
+	location := s.Scope.Location()
+	zonesWithVMSize, err := s.resourceSKUCache.GetZonesWithVMSize(ctx, spec.Size, location)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get SKU %s in compute api", spec.Size)
+		return errors.Wrapf(err, "failed to get zones for VM type %s in location %s", spec.Size, location)
 	}
 
-	// Checking if the requested VM size has at least 2 vCPUS
-	vCPUCapability, err := sku.HasCapabilityWithCapacity(resourceskus.VCPUs, resourceskus.MinimumVCPUS)
-	if err != nil {
-		return azure.WithTerminalError(errors.Wrap(err, "failed to validate the vCPU capability"))
+	for _, zone := range zones {
+		if !slice.Contains(zonesWithVMSize, zone) {
+			return azure.WithTerminalError(errors.Errorf("vm size %s is not available in availability zone %s of VMSS %s in location %s. select a different vm size",
+				spec.Size, zone, spec.Name, location))
+		}
 	}
 
-	if !vCPUCapability {
-		return azure.WithTerminalError(errors.New("vm size should be bigger or equal to at least 2 vCPUs"))
+	return nil
+}
+
+// capSurgeToQuota caps the surged capacity of the scale set to the capacity the regional vCPU quotas of the
+// subscription allow for, i.e. the quota of all VM families and the quota of the VM family of the scale set. Only the
+// surge is capped, the capacity is never capped below the desired capacity.
+func (s *Service) capSurgeToQuota(ctx context.Context, spec azure.ScaleSetSpec, currentCapacity, surge int64) (int64, error) {
+	ctx, log, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.capSurgeToQuota")
+	defer done()
+
+	// instances which already exist are accounted for in the current usage
+	if surge <= currentCapacity {
+		return surge, nil
 	}
 
-	// Checking if the requested VM size has at least 2 Gi of memory
-	MemoryCapability, err := sku.HasCapabilityWithCapacity(resourceskus.MemoryGB, resourceskus.MinimumMemory)
+	sku, err := s.resourceSKUCache.Get(ctx, spec.Size, resourceskus.VirtualMachines)
 	if err != nil {
-		return azure.WithTerminalError(errors.Wrap(err, "failed to validate the memory capability"))
+		return 0, errors.Wrapf(err, "failed to find SKU %s in compute api", spec.Size)
+	}
+	vCPUsCapability, _ := sku.GetCapability(resourceskus.VCPUs)
+	vCPUs, err := strconv.ParseInt(vCPUsCapability, 10, 64)
+	if err != nil || vCPUs <= 0 {
+		return 0, errors.Errorf("failed to determine the vCPUs of VM size %s", spec.Size)
 	}
 
-	if !MemoryCapability {
-		return azure.WithTerminalError(errors.New("vm memory should be bigger or equal to at least 2Gi"))
+	usages, err := s.Client.ListUsages(ctx, s.Scope.Location())
+	if err != nil {
+		return 0, wrapWithCorrelationID(err, "failed to list compute usages")
 	}
 
-	// enable ephemeral OS
-	if spec.OSDisk.DiffDiskSettings != nil && !sku.HasCapability(resourceskus.EphemeralOSDisk) {
-		return azure.WithTerminalError(fmt.Errorf("vm size %s does not support ephemeral os. select a different vm size or disable ephemeral os", spec.Size))
+	availableVCPUs := int64(-1)
+	for _, usage := range usages {
+		if usage.Name == nil || usage.Name.Value == nil || usage.Limit == nil || usage.CurrentValue == nil {
+			continue
+		}
+		if *usage.Name.Value != regionalVCPUsUsageName && (sku.Family == nil || !strings.EqualFold(*usage.Name.Value, *sku.Family)) {
+			continue
+		}
+		available := *usage.Limit - int64(*usage.CurrentValue)
+		if available < 0 {
+			available = 0
+		}
+		if availableVCPUs < 0 || available < availableVCPUs {
+			availableVCPUs = available
+		}
+	}
+	if availableVCPUs < 0 {
+		// no quota applies to the VM size
+		return surge, nil
 	}
 
-	if spec.SecurityProfile != nil && !sku.HasCapability(resourceskus.EncryptionAtHost) {
-		return azure.WithTerminalError(errors.Errorf("encryption at host is not supported for VM type %s", spec.Size))
+	allowed := currentCapacity + availableVCPUs/vCPUs
+	if allowed >= surge {
+		return surge, nil
 	}
+	if allowed < spec.Capacity {
+		allowed = spec.Capacity
+	}
+	log.V(2).Info("capping surge to vCPU quota", "scale set", spec.Name, "surge", surge, "cappedSurge", allowed,
+		"requiredVCPUs", (surge-currentCapacity)*vCPUs, "availableVCPUs", availableVCPUs)
+	return allowed, nil
+}
 
-	// Fetch location and zone to check for their support of ultra disks.
-	location := s.Scope.Location()
-	zones, err := s.resourceSKUCache.GetZones(ctx, location)
+func hasModelModifyingDifferences(infraVMSS *azure.VMSS, vmss compute.VirtualMachineScaleSet) bool {
+	other := converters.SDKToVMSS(vmss, []compute.VirtualMachineScaleSetVM{})
+	return infraVMSS.HasModelChanges(*other)
+}
+
+// hasDNSServerChanges returns true if the DNS servers of the scale set differ from the desired DNS servers.
+func hasDNSServerChanges(current, desired []string) bool {
+	if len(current) != len(desired) {
+		return true
+	}
+	for i := range current {
+		if current[i] != desired[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// getNetworkProfileUpdate returns the network profile of the existing scale set with the DNS servers of the spec. The
+// existing network profile is used as the base of the patch, so that changes of the Cloud Provider are preserved.
+func (s *Service) getNetworkProfileUpdate(ctx context.Context, spec azure.ScaleSetSpec) (*compute.VirtualMachineScaleSetUpdateNetworkProfile, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.getNetworkProfileUpdate")
+	defer done()
+
+	existing, err := s.Client.Get(ctx, s.scaleSetResourceGroup(spec), spec.Name)
 	if err != nil {
-		return azure.WithTerminalError(errors.Wrapf(err, "failed to get the zones for location %s", location))
+		return nil, errors.Wrapf(err, "failed to get VMSS %s", spec.Name)
+	}
+	if existing.VirtualMachineScaleSetProperties == nil || existing.VirtualMachineProfile == nil ||
+		existing.VirtualMachineProfile.NetworkProfile == nil || existing.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations == nil {
+		return nil, errors.Errorf("VMSS %s has no network interface configurations", spec.Name)
 	}
 
-	for _, zone := range zones {
-		hasLocationCapability := sku.HasLocationCapability(resourceskus.UltraSSDAvailable, location, zone)
-		err := fmt.Errorf("vm size %s does not support ultra disks in location %s. select a different vm size or disable ultra disks", spec.Size, location)
-
-		// Check support for ultra disks as data disks.
-		for _, disks := range spec.DataDisks {
-			if disks.ManagedDisk != nil &&
-				disks.ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) &&
-				!hasLocationCapability {
-				return azure.WithTerminalError(err)
-			}
+	networkProfile := existing.VirtualMachineProfile.NetworkProfile
+	for i := range *networkProfile.NetworkInterfaceConfigurations {
+		config := &(*networkProfile.NetworkInterfaceConfigurations)[i]
+		if config.VirtualMachineScaleSetNetworkConfigurationProperties == nil {
+			continue
 		}
-		// Check support for ultra disks as persistent volumes.
-		if spec.AdditionalCapabilities != nil && spec.AdditionalCapabilities.UltraSSDEnabled != nil {
-			if *spec.AdditionalCapabilities.UltraSSDEnabled &&
-				!hasLocationCapability {
-				return azure.WithTerminalError(err)
-			}
+		// an empty list, rather than nil, removes the DNS servers
+		dnsServers := make([]string, len(spec.DNSServers))
+		copy(dnsServers, spec.DNSServers)
+		config.DNSSettings = &compute.VirtualMachineScaleSetNetworkConfigurationDNSSettings{
+			DNSServers: &dnsServers,
 		}
 	}
 
-	// Checking if selected availability zones are available selected VM type in location
-	azsInLocation, err := s.resourceSKUCache.GetZonesWithVMSize(ctx, spec.Size, s.Scope.Location())
+	jsonData, err := json.Marshal(networkProfile)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get zones for VM type %s in location %s", spec.Size, s.Scope.Location())
+		return nil, err
 	}
-
-	for _, az := range spec.FailureDomains {
-		if !slice.Contains(azsInLocation, az) {
-			return azure.WithTerminalError(errors.Errorf("availability zone %s is not available for VM type %s in location %s", az, spec.Size, s.Scope.Location()))
-		}
+	var update compute.VirtualMachineScaleSetUpdateNetworkProfile
+	if err := json.Unmarshal(jsonData, &update); err != nil {
+		return nil, err
 	}
+	return &update, nil
+}
 
-	return nil
+func (s *Service) validateSpec(ctx context.Context) error {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.validateSpec")
+	defer done()
+
+	allErrs, err := ValidateSpec(ctx, s.Scope.ScaleSetSpec(), s.Scope.Location(), s.resourceSKUCache)
+	if len(allErrs) > 0 {
+		// Report the first invalid setting only, as the spec won't become valid without being changed anyways.
+		return azure.WithTerminalError(errors.New(allErrs[0].Detail))
+	}
+	return err
 }
 
 func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSetSpec) (compute.VirtualMachineScaleSet, error) {
@@ -404,7 +745,14 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 		return compute.VirtualMachineScaleSet{}, err
 	}
 
-	storageProfile, err := s.generateStorageProfile(ctx, vmssSpec, sku)
+	// the image is fetched once for the storage profile and the plan, since getting it may call the images API
+	image, err := s.Scope.GetVMImage(ctx)
+	if err != nil {
+		return compute.VirtualMachineScaleSet{}, errors.Wrap(err, "failed to get VM image")
+	}
+	s.Scope.SaveVMImageToStatus(image)
+
+	storageProfile, err := s.generateStorageProfile(ctx, vmssSpec, sku, image)
 	if err != nil {
 		return compute.VirtualMachineScaleSet{}, err
 	}
@@ -430,22 +778,53 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 		}
 	}
 
+	// Get the internal LB backend pool ID for pools which are reachable behind an internal load balancer
+	if vmssSpec.InternalLBName != "" && vmssSpec.InternalLBAddressPoolName != "" {
+		backendAddressPools = append(backendAddressPools,
+			compute.SubResource{
+				ID: to.StringPtr(azure.AddressPoolID(s.Scope.SubscriptionID(), s.Scope.ResourceGroup(), vmssSpec.InternalLBName, vmssSpec.InternalLBAddressPoolName)),
+			})
+	}
+
+	inboundNatPools, err := s.getInboundNatPools(ctx, vmssSpec.LoadBalancerInboundNatPools)
+	if err != nil {
+		return compute.VirtualMachineScaleSet{}, err
+	}
+
 	osProfile, err := s.generateOSProfile(ctx, vmssSpec)
 	if err != nil {
 		return compute.VirtualMachineScaleSet{}, err
 	}
 
+	var dnsSettings *compute.VirtualMachineScaleSetNetworkConfigurationDNSSettings
+	if len(vmssSpec.DNSServers) > 0 {
+		dnsSettings = &compute.VirtualMachineScaleSetNetworkConfigurationDNSSettings{
+			DNSServers: to.StringSlicePtr(vmssSpec.DNSServers),
+		}
+	}
+
+	tier := vmssSpec.Tier
+	if tier == "" {
+		tier = azure.DefaultVMSSTier
+	}
+
+	singlePlacementGroup := vmssSpec.SinglePlacementGroup
+	if singlePlacementGroup == nil {
+		singlePlacementGroup = to.BoolPtr(false)
+	}
+
 	vmss := compute.VirtualMachineScaleSet{
 		Location: to.StringPtr(s.Scope.Location()),
 		Sku: &compute.Sku{
 			Name:     to.StringPtr(vmssSpec.Size),
-			Tier:     to.StringPtr("Standard"),
+			Tier:     to.StringPtr(tier),
 			Capacity: to.Int64Ptr(vmssSpec.Capacity),
 		},
 		Zones: to.StringSlicePtr(vmssSpec.FailureDomains),
-		Plan:  s.generateImagePlan(ctx),
+		Plan:  converters.ImageToPlan(image),
 		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
-			SinglePlacementGroup: to.BoolPtr(false),
+			SinglePlacementGroup:     singlePlacementGroup,
+			PlatformFaultDomainCount: vmssSpec.PlatformFaultDomainCount,
 			UpgradePolicy: &compute.UpgradePolicy{
 				Mode: compute.UpgradeModeManual,
 			},
@@ -466,6 +845,7 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 							VirtualMachineScaleSetNetworkConfigurationProperties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
 								Primary:            to.BoolPtr(true),
 								EnableIPForwarding: to.BoolPtr(true),
+								DNSSettings:        dnsSettings,
 								IPConfigurations: &[]compute.VirtualMachineScaleSetIPConfiguration{
 									{
 										Name: to.StringPtr(vmssSpec.Name),
@@ -476,6 +856,7 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 											Primary:                         to.BoolPtr(true),
 											PrivateIPAddressVersion:         compute.IPVersionIPv4,
 											LoadBalancerBackendAddressPools: &backendAddressPools,
+											LoadBalancerInboundNatPools:     inboundNatPools,
 										},
 									},
 								},
@@ -499,13 +880,17 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 		vmss.Identity = &compute.VirtualMachineScaleSetIdentity{
 			Type: compute.ResourceIdentityTypeSystemAssigned,
 		}
-	} else if vmssSpec.Identity == infrav1.VMIdentityUserAssigned {
+	} else if vmssSpec.Identity == infrav1.VMIdentityUserAssigned || vmssSpec.Identity == infrav1.VMIdentitySystemAndUserAssigned {
 		userIdentitiesMap, err := converters.UserAssignedIdentitiesToVMSSSDK(vmssSpec.UserAssignedIdentities)
 		if err != nil {
 			return vmss, errors.Wrapf(err, "failed to assign identity %q", vmssSpec.Name)
 		}
+		identityType := compute.ResourceIdentityTypeUserAssigned
+		if vmssSpec.Identity == infrav1.VMIdentitySystemAndUserAssigned {
+			identityType = compute.ResourceIdentityTypeSystemAssignedUserAssigned
+		}
 		vmss.Identity = &compute.VirtualMachineScaleSetIdentity{
-			Type:                   compute.ResourceIdentityTypeUserAssigned,
+			Type:                   identityType,
 			UserAssignedIdentities: userIdentitiesMap,
 		}
 	}
@@ -537,29 +922,115 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 		}
 	}
 
+	// the role tag is informational only, the ownership of the scale set is tracked by the cluster tag
+	role := vmssSpec.Role
+	if role == "" {
+		role = infrav1.Node
+	}
 	tags := infrav1.Build(infrav1.BuildParams{
 		ClusterName: s.Scope.ClusterName(),
 		Lifecycle:   infrav1.ResourceLifecycleOwned,
 		Name:        to.StringPtr(vmssSpec.Name),
-		Role:        to.StringPtr(infrav1.Node),
+		Role:        to.StringPtr(role),
 		Additional:  s.Scope.AdditionalTags(),
 	})
 
 	vmss.Tags = converters.TagsToMap(tags)
+
+	if err := validateAcceleratedNetworkingInterfaces(vmss, sku); err != nil {
+		return compute.VirtualMachineScaleSet{}, err
+	}
 	return vmss, nil
 }
 
+// validateAcceleratedNetworkingInterfaces checks that the VM size supports the number of network interfaces which have
+// accelerated networking enabled.
+func validateAcceleratedNetworkingInterfaces(vmss compute.VirtualMachineScaleSet, sku resourceskus.SKU) error {
+	if vmss.VirtualMachineScaleSetProperties == nil || vmss.VirtualMachineProfile == nil ||
+		vmss.VirtualMachineProfile.NetworkProfile == nil || vmss.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations == nil {
+		return nil
+	}
+
+	acceleratedInterfaces := 0
+	for _, config := range *vmss.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations {
+		if config.VirtualMachineScaleSetNetworkConfigurationProperties != nil && to.Bool(config.EnableAcceleratedNetworking) {
+			acceleratedInterfaces++
+		}
+	}
+	// a single network interface with accelerated networking is supported by any VM size with accelerated networking
+	if acceleratedInterfaces <= 1 {
+		return nil
+	}
+
+	supported, err := sku.HasCapabilityWithCapacity(resourceskus.MaxNetworkInterfaces, int64(acceleratedInterfaces))
+	if err != nil {
+		return errors.Wrap(err, "failed to validate the network interface capability")
+	}
+	if !supported {
+		maxInterfaces, _ := sku.GetCapability(resourceskus.MaxNetworkInterfaces)
+		return azure.WithTerminalError(errors.Errorf("vm size %s supports at most %s network interfaces, but %d network interfaces have accelerated networking enabled",
+			to.String(sku.Name), maxInterfaces, acceleratedInterfaces))
+	}
+	return nil
+}
+
+// getInboundNatPools returns the references to the inbound NAT pools the instances are attached to, after checking that
+// each NAT pool exists on its load balancer. It returns nil if the scale set is not attached to any inbound NAT pool.
+func (s *Service) getInboundNatPools(ctx context.Context, natPools []azure.InboundNatPoolSpec) (*[]compute.SubResource, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.getInboundNatPools")
+	defer done()
+
+	if len(natPools) == 0 {
+		return nil, nil
+	}
+
+	loadBalancers := make(map[string]network.LoadBalancer)
+	subResources := make([]compute.SubResource, 0, len(natPools))
+	for _, natPool := range natPools {
+		lb, ok := loadBalancers[natPool.LoadBalancerName]
+		if !ok {
+			var err error
+			lb, err = s.Client.GetLoadBalancer(ctx, s.Scope.ResourceGroup(), natPool.LoadBalancerName)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get load balancer %s", natPool.LoadBalancerName)
+			}
+			loadBalancers[natPool.LoadBalancerName] = lb
+		}
+
+		if !hasInboundNatPool(lb, natPool.Name) {
+			return nil, errors.Errorf("inbound NAT pool %s does not exist on load balancer %s", natPool.Name, natPool.LoadBalancerName)
+		}
+		subResources = append(subResources, compute.SubResource{
+			ID: to.StringPtr(azure.InboundNATPoolID(s.Scope.SubscriptionID(), s.Scope.ResourceGroup(), natPool.LoadBalancerName, natPool.Name)),
+		})
+	}
+	return &subResources, nil
+}
+
+// hasInboundNatPool returns true if the load balancer has an inbound NAT pool with the given name.
+func hasInboundNatPool(lb network.LoadBalancer, name string) bool {
+	if lb.LoadBalancerPropertiesFormat == nil || lb.InboundNatPools == nil {
+		return false
+	}
+	for _, natPool := range *lb.InboundNatPools {
+		if strings.EqualFold(to.String(natPool.Name), name) {
+			return true
+		}
+	}
+	return false
+}
+
 // getVirtualMachineScaleSet provides information about a Virtual Machine Scale Set and its instances.
-func (s *Service) getVirtualMachineScaleSet(ctx context.Context, vmssName string) (*azure.VMSS, error) {
+func (s *Service) getVirtualMachineScaleSet(ctx context.Context, resourceGroup, vmssName string) (*azure.VMSS, error) {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.getVirtualMachineScaleSet")
 	defer done()
 
-	vmss, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), vmssName)
+	vmss, err := s.Client.Get(ctx, resourceGroup, vmssName)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get existing vmss")
 	}
 
-	vmssInstances, err := s.Client.ListInstances(ctx, s.Scope.ResourceGroup(), vmssName)
+	vmssInstances, err := s.Client.ListInstances(ctx, resourceGroup, vmssName)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list instances")
 	}
@@ -567,6 +1038,15 @@ func (s *Service) getVirtualMachineScaleSet(ctx context.Context, vmssName string
 	return converters.SDKToVMSS(vmss, vmssInstances), nil
 }
 
+// scaleSetResourceGroup returns the resource group of the scale set, which defaults to the resource group of the
+// cluster. The load balancers and the virtual network the scale set references remain in their own resource groups.
+func (s *Service) scaleSetResourceGroup(spec azure.ScaleSetSpec) string {
+	if spec.ResourceGroup != "" {
+		return spec.ResourceGroup
+	}
+	return s.Scope.ResourceGroup()
+}
+
 // getVirtualMachineScaleSetIfDone gets a Virtual Machine Scale Set and its instances from Azure if the future is completed.
 func (s *Service) getVirtualMachineScaleSetIfDone(ctx context.Context, future *infrav1.Future) (*azure.VMSS, error) {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.getVirtualMachineScaleSetIfDone")
@@ -600,12 +1080,40 @@ func (s *Service) generateExtensions() ([]compute.VirtualMachineScaleSetExtensio
 		extensions[i] = vmssextension
 	}
 
+	if err := validateExtensionOrder(extensions); err != nil {
+		return nil, err
+	}
+
 	return extensions, nil
 }
 
+// validateExtensionOrder checks that the extensions an extension is provisioned after are part of the VMSS.
+func validateExtensionOrder(extensions []compute.VirtualMachineScaleSetExtension) error {
+	names := make(map[string]struct{}, len(extensions))
+	for _, extension := range extensions {
+		names[to.String(extension.Name)] = struct{}{}
+	}
+
+	for _, extension := range extensions {
+		if extension.VirtualMachineScaleSetExtensionProperties == nil || extension.ProvisionAfterExtensions == nil {
+			continue
+		}
+		for _, dependency := range *extension.ProvisionAfterExtensions {
+			if dependency == to.String(extension.Name) {
+				return azure.WithTerminalError(errors.Errorf("extension %s cannot be provisioned after itself", dependency))
+			}
+			if _, ok := names[dependency]; !ok {
+				return azure.WithTerminalError(errors.Errorf("extension %s is provisioned after extension %s which does not exist", to.String(extension.Name), dependency))
+			}
+		}
+	}
+
+	return nil
+}
+
 // generateStorageProfile generates a pointer to a compute.VirtualMachineScaleSetStorageProfile which can utilized for VM creation.
-func (s *Service) generateStorageProfile(ctx context.Context, vmssSpec azure.ScaleSetSpec, sku resourceskus.SKU) (*compute.VirtualMachineScaleSetStorageProfile, error) {
-	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.generateStorageProfile")
+func (s *Service) generateStorageProfile(ctx context.Context, vmssSpec azure.ScaleSetSpec, sku resourceskus.SKU, image *infrav1.Image) (*compute.VirtualMachineScaleSetStorageProfile, error) {
+	_, _, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.generateStorageProfile")
 	defer done()
 
 	storageProfile := &compute.VirtualMachineScaleSetStorageProfile{
@@ -637,12 +1145,13 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmssSpec azure.Sca
 		}
 	}
 
+	luns := getDataDiskLuns(vmssSpec.DataDisks, vmssSpec.AutoAssignDataDiskLuns)
 	dataDisks := make([]compute.VirtualMachineScaleSetDataDisk, len(vmssSpec.DataDisks))
 	for i, disk := range vmssSpec.DataDisks {
 		dataDisks[i] = compute.VirtualMachineScaleSetDataDisk{
 			CreateOption: compute.DiskCreateOptionTypesEmpty,
 			DiskSizeGB:   to.Int32Ptr(disk.DiskSizeGB),
-			Lun:          disk.Lun,
+			Lun:          luns[i],
 			Name:         to.StringPtr(azure.GenerateDataDiskName(vmssSpec.Name, disk.NameSuffix)),
 		}
 
@@ -654,17 +1163,19 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmssSpec azure.Sca
 			if disk.ManagedDisk.DiskEncryptionSet != nil {
 				dataDisks[i].ManagedDisk.DiskEncryptionSet = &compute.DiskEncryptionSetParameters{ID: to.StringPtr(disk.ManagedDisk.DiskEncryptionSet.ID)}
 			}
+
+			// the performance of UltraSSD_LRS and PremiumV2_LRS disks is set on the data disk rather than on its managed disk parameters
+			dataDisks[i].DiskIOPSReadWrite = disk.ManagedDisk.DiskIOPSReadWrite
+			dataDisks[i].DiskMBpsReadWrite = disk.ManagedDisk.DiskMBpsReadWrite
 		}
 	}
+	// Azure orders the data disks by LUN, so emit them sorted to keep the device paths and the model comparison
+	// deterministic regardless of the order in the spec
+	sort.SliceStable(dataDisks, func(i, j int) bool {
+		return to.Int32(dataDisks[i].Lun) < to.Int32(dataDisks[j].Lun)
+	})
 	storageProfile.DataDisks = &dataDisks
 
-	image, err := s.Scope.GetVMImage(ctx)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get VM image")
-	}
-
-	s.Scope.SaveVMImageToStatus(image)
-
 	imageRef, err := converters.ImageToSDK(image)
 	if err != nil {
 		return nil, err
@@ -675,6 +1186,37 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmssSpec azure.Sca
 	return storageProfile, nil
 }
 
+// getDataDiskLuns returns the LUN of each data disk. If autoAssign is set, the lowest unused LUN is assigned to data
+// disks without one, which validateDataDiskLuns guarantees to be left.
+func getDataDiskLuns(dataDisks []infrav1.DataDisk, autoAssign bool) []*int32 {
+	set := make(map[int32]struct{}, len(dataDisks))
+	for _, disk := range dataDisks {
+		if disk.Lun != nil {
+			set[*disk.Lun] = struct{}{}
+		}
+	}
+
+	luns := make([]*int32, len(dataDisks))
+	for i, disk := range dataDisks {
+		if disk.Lun != nil {
+			luns[i] = to.Int32Ptr(*disk.Lun)
+			continue
+		}
+		if !autoAssign {
+			continue
+		}
+		for lun := int32(0); lun < maxDataDiskLuns; lun++ {
+			if _, ok := set[lun]; !ok {
+				luns[i] = to.Int32Ptr(lun)
+				set[lun] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return luns
+}
+
 func (s *Service) generateOSProfile(ctx context.Context, vmssSpec azure.ScaleSetSpec) (*compute.VirtualMachineScaleSetOSProfile, error) {
 	sshKey, err := base64.StdEncoding.DecodeString(vmssSpec.SSHKeyData)
 	if err != nil {
@@ -684,6 +1226,10 @@ func (s *Service) generateOSProfile(ctx context.Context, vmssSpec azure.ScaleSet
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to retrieve bootstrap data")
 	}
+	if len(bootstrapData) > azure.MaxCustomDataLength {
+		return nil, azure.WithTerminalError(errors.Errorf("custom data of scale set %s is %d bytes long after base64 encoding, which exceeds the maximum length of %d bytes",
+			vmssSpec.Name, len(bootstrapData), azure.MaxCustomDataLength))
+	}
 
 	osProfile := &compute.VirtualMachineScaleSetOSProfile{
 		ComputerNamePrefix: to.StringPtr(vmssSpec.Name),
@@ -691,6 +1237,20 @@ func (s *Service) generateOSProfile(ctx context.Context, vmssSpec azure.ScaleSet
 		CustomData:         to.StringPtr(bootstrapData),
 	}
 
+	var secrets []compute.VaultSecretGroup
+	for _, group := range vmssSpec.Secrets {
+		for _, certificate := range group.VaultCertificates {
+			vaultCertificate := compute.VaultCertificate{
+				CertificateURL: to.StringPtr(certificate.CertificateURL),
+			}
+			// Linux Virtual Machines have no certificate store, the certificates are placed in /var/lib/waagent.
+			if certificate.CertificateStore != "" {
+				vaultCertificate.CertificateStore = to.StringPtr(certificate.CertificateStore)
+			}
+			secrets = appendVaultCertificate(secrets, group.SourceVaultID, vaultCertificate)
+		}
+	}
+
 	switch vmssSpec.OSDisk.OSType {
 	case string(compute.OperatingSystemTypesWindows):
 		// Cloudbase-init is used to generate a password.
@@ -704,13 +1264,40 @@ func (s *Service) generateOSProfile(ctx context.Context, vmssSpec azure.ScaleSet
 		osProfile.WindowsConfiguration = &compute.WindowsConfiguration{
 			EnableAutomaticUpdates: to.BoolPtr(false),
 		}
+		if windowsConfig := vmssSpec.WindowsConfiguration; windowsConfig != nil {
+			if windowsConfig.TimeZone != "" {
+				osProfile.WindowsConfiguration.TimeZone = to.StringPtr(windowsConfig.TimeZone)
+			}
+			if len(windowsConfig.AdditionalUnattendContent) > 0 {
+				unattendContent := make([]compute.AdditionalUnattendContent, 0, len(windowsConfig.AdditionalUnattendContent))
+				for _, content := range windowsConfig.AdditionalUnattendContent {
+					// Azure only supports additional content for the Microsoft-Windows-Shell-Setup component in the
+					// oobeSystem pass.
+					unattendContent = append(unattendContent, compute.AdditionalUnattendContent{
+						PassName:      compute.PassNamesOobeSystem,
+						ComponentName: compute.ComponentNamesMicrosoftWindowsShellSetup,
+						SettingName:   compute.SettingNames(content.SettingName),
+						Content:       to.StringPtr(content.Content),
+					})
+				}
+				osProfile.WindowsConfiguration.AdditionalUnattendContent = &unattendContent
+			}
+			if len(windowsConfig.WinRMListeners) > 0 {
+				osProfile.WindowsConfiguration.WinRM, secrets = generateWinRMConfiguration(windowsConfig.WinRMListeners, secrets)
+			}
+		}
 	default:
+		authorizedKeysPath := vmssSpec.SSHAuthorizedKeysPath
+		if authorizedKeysPath == "" {
+			authorizedKeysPath = fmt.Sprintf("/home/%s/.ssh/authorized_keys", azure.DefaultUserName)
+		}
+		disablePasswordAuthentication := vmssSpec.DisablePasswordAuthentication == nil || *vmssSpec.DisablePasswordAuthentication
 		osProfile.LinuxConfiguration = &compute.LinuxConfiguration{
-			DisablePasswordAuthentication: to.BoolPtr(true),
+			DisablePasswordAuthentication: to.BoolPtr(disablePasswordAuthentication),
 			SSH: &compute.SSHConfiguration{
 				PublicKeys: &[]compute.SSHPublicKey{
 					{
-						Path:    to.StringPtr(fmt.Sprintf("/home/%s/.ssh/authorized_keys", azure.DefaultUserName)),
+						Path:    to.StringPtr(authorizedKeysPath),
 						KeyData: to.StringPtr(string(sshKey)),
 					},
 				},
@@ -718,40 +1305,48 @@ func (s *Service) generateOSProfile(ctx context.Context, vmssSpec azure.ScaleSet
 		}
 	}
 
+	if len(secrets) > 0 {
+		osProfile.Secrets = &secrets
+	}
+
 	return osProfile, nil
 }
 
-func (s *Service) generateImagePlan(ctx context.Context) *compute.Plan {
-	ctx, log, done := tele.StartSpanWithLogger(ctx, "scalesets.Service.generateImagePlan")
-	defer done()
-
-	image, err := s.Scope.GetVMImage(ctx)
-	if err != nil {
-		log.Error(err, "failed to get vm image, disabling Plan")
-		return nil
-	}
-
-	if image.SharedGallery != nil && image.SharedGallery.Publisher != nil && image.SharedGallery.SKU != nil && image.SharedGallery.Offer != nil {
-		return &compute.Plan{
-			Publisher: image.SharedGallery.Publisher,
-			Name:      image.SharedGallery.SKU,
-			Product:   image.SharedGallery.Offer,
+// generateWinRMConfiguration returns the WinRM configuration for the listeners, and the secrets with the certificates
+// of the Https listeners added, which Azure installs into the certificate store of the Virtual Machines.
+func generateWinRMConfiguration(listeners []azure.WinRMListener, secrets []compute.VaultSecretGroup) (*compute.WinRMConfiguration, []compute.VaultSecretGroup) {
+	winRMListeners := make([]compute.WinRMListener, 0, len(listeners))
+	for _, listener := range listeners {
+		winRMListener := compute.WinRMListener{
+			Protocol: compute.ProtocolTypes(listener.Protocol),
 		}
+		if listener.CertificateURL != "" {
+			winRMListener.CertificateURL = to.StringPtr(listener.CertificateURL)
+			secrets = appendVaultCertificate(secrets, listener.KeyVaultID, compute.VaultCertificate{
+				CertificateURL:   to.StringPtr(listener.CertificateURL),
+				CertificateStore: to.StringPtr("My"),
+			})
+		}
+		winRMListeners = append(winRMListeners, winRMListener)
 	}
 
-	if image.Marketplace == nil || !image.Marketplace.ThirdPartyImage {
-		return nil
-	}
+	return &compute.WinRMConfiguration{Listeners: &winRMListeners}, secrets
+}
 
-	if image.Marketplace.Publisher == "" || image.Marketplace.SKU == "" || image.Marketplace.Offer == "" {
-		return nil
+// appendVaultCertificate adds the certificate to the secret group of the Key Vault, as each Key Vault may only be
+// referenced once in the OS profile.
+func appendVaultCertificate(secrets []compute.VaultSecretGroup, vaultID string, certificate compute.VaultCertificate) []compute.VaultSecretGroup {
+	for i := range secrets {
+		if strings.EqualFold(to.String(secrets[i].SourceVault.ID), vaultID) {
+			*secrets[i].VaultCertificates = append(*secrets[i].VaultCertificates, certificate)
+			return secrets
+		}
 	}
 
-	return &compute.Plan{
-		Publisher: to.StringPtr(image.Marketplace.Publisher),
-		Name:      to.StringPtr(image.Marketplace.SKU),
-		Product:   to.StringPtr(image.Marketplace.Offer),
-	}
+	return append(secrets, compute.VaultSecretGroup{
+		SourceVault:       &compute.SubResource{ID: to.StringPtr(vaultID)},
+		VaultCertificates: &[]compute.VaultCertificate{certificate},
+	})
 }
 
 func getVMSSUpdateFromVMSS(vmss compute.VirtualMachineScaleSet) (compute.VirtualMachineScaleSetUpdate, error) {
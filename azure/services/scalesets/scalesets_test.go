@@ -18,14 +18,23 @@ package scalesets
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/pointer"
@@ -155,7 +164,7 @@ func TestGetExistingVMSS(t *testing.T) {
 				Client: clientMock,
 			}
 
-			result, err := s.getVirtualMachineScaleSet(context.TODO(), tc.vmssName)
+			result, err := s.getVirtualMachineScaleSet(context.TODO(), "my-rg", tc.vmssName)
 			if tc.expectedError != "" {
 				g.Expect(err).To(HaveOccurred())
 				t.Log(err.Error())
@@ -210,6 +219,72 @@ func TestReconcileVMSS(t *testing.T) {
 				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
 			},
 		},
+		{
+			name:          "should fail to change the vm size to one unavailable in a zone of the vmss",
+			expectedError: "failed to start updating VMSS: vm size VM_SIZE_ZONE_1 is not available in availability zone 3 of VMSS my-vmss in location test-location. select a different vm size",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				// the spec only selects zone 1, but the existing vmss spans zones 1 and 3
+				spec.Size = "VM_SIZE_ZONE_1"
+				spec.FailureDomains = []string{"1"}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSUpdateExpectations(s)
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultExistingVMSS("VM_SIZE"), nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil)
+			},
+		},
+		{
+			name:          "should start creating a vmss in a separate resource group",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-vmss-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				const vmssResourceGroup = "my-vmss-rg"
+				future := &infrav1.Future{
+					Type:          infrav1.PutFuture,
+					ResourceGroup: vmssResourceGroup,
+					Name:          defaultVMSSName,
+				}
+				defaultSpec := newDefaultVMSSSpec()
+				defaultSpec.ResourceGroup = vmssResourceGroup
+				s.ScaleSetSpec().Return(defaultSpec).AnyTimes()
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				m.Get(gomockinternal.AContext(), vmssResourceGroup, defaultVMSSName).
+					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				// the load balancers stay in the cluster resource group
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), vmssResourceGroup, defaultVMSSName, gomockinternal.DiffEq(newDefaultVMSS("VM_SIZE"))).
+					Return(future, nil)
+				s.SetLongRunningOperationState(future)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), future).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(future))
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				m.Get(gomockinternal.AContext(), vmssResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), vmssResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil).AnyTimes()
+				s.SetVMSSState(gomock.Any())
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+			},
+		},
+		{
+			name:          "should start creating a vmss with an auto-assigned data disk LUN",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				defaultSpec := newDefaultVMSSSpec()
+				defaultSpec.DataDisks = append(defaultSpec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				defaultSpec.AutoAssignDataDiskLuns = true
+				s.ScaleSetSpec().Return(defaultSpec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
 		{
 			name:          "should finish creating a vmss when long running operation is done",
 			expectedError: "",
@@ -221,7 +296,47 @@ func TestReconcileVMSS(t *testing.T) {
 
 				setupDefaultVMSSInProgressOperationDoneExpectations(s, m, createdVMSS, instances)
 				s.DeleteLongRunningOperationState(defaultSpec.Name, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
+				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				s.InstancesToReimage(gomockinternal.AContext()).Return(nil, nil)
+			},
+		},
+		{
+			name:          "should reimage instances annotated to be reimaged",
+			expectedError: "",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				defaultSpec := newDefaultVMSSSpec()
+				s.ScaleSetSpec().Return(defaultSpec).AnyTimes()
+				createdVMSS := newDefaultVMSS("VM_SIZE")
+				instances := newDefaultInstances()
+
+				setupDefaultVMSSInProgressOperationDoneExpectations(s, m, createdVMSS, instances)
+				s.DeleteLongRunningOperationState(defaultSpec.Name, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
+				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				s.InstancesToReimage(gomockinternal.AContext()).Return([]string{"0"}, nil)
+				gomock.InOrder(
+					m.ReimageInstance(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, "0").Return(nil),
+					s.RemoveReimageAnnotation(gomockinternal.AContext(), "0").Return(nil),
+				)
+			},
+		},
+		{
+			name:          "should keep the reimage annotation if reimaging an instance fails",
+			expectedError: "failed to reimage instances of VMSS my-vmss: failed to reimage instance 0: #: Internal Server Error: StatusCode=500",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				defaultSpec := newDefaultVMSSSpec()
+				s.ScaleSetSpec().Return(defaultSpec).AnyTimes()
+				createdVMSS := newDefaultVMSS("VM_SIZE")
+				instances := newDefaultInstances()
+
+				setupDefaultVMSSInProgressOperationDoneExpectations(s, m, createdVMSS, instances)
+				s.DeleteLongRunningOperationState(defaultSpec.Name, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
 				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				s.InstancesToReimage(gomockinternal.AContext()).Return([]string{"0"}, nil)
+				m.ReimageInstance(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, "0").
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
 		{
@@ -235,7 +350,9 @@ func TestReconcileVMSS(t *testing.T) {
 
 				setupDefaultVMSSInProgressOperationDoneExpectations(s, m, createdVMSS, instances)
 				s.DeleteLongRunningOperationState(defaultSpec.Name, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
 				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				s.InstancesToReimage(gomockinternal.AContext()).Return(nil, nil)
 			},
 		},
 		{
@@ -256,11 +373,232 @@ func TestReconcileVMSS(t *testing.T) {
 			},
 		},
 		{
-			name:          "should start creating a vmss with spot vm",
+			name:          "should start creating a vmss with a custom SSH authorized keys path",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "VM_SIZE_AN"
+				spec.SSHAuthorizedKeysPath = "/var/lib/capi/.ssh/authorized_keys"
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE_AN")
+				netConfigs := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
+				(*netConfigs)[0].EnableAcceleratedNetworking = to.BoolPtr(true)
+				vmss.Sku.Name = to.StringPtr(spec.Size)
+				publicKeys := *vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.OsProfile.LinuxConfiguration.SSH.PublicKeys
+				publicKeys[0].Path = to.StringPtr("/var/lib/capi/.ssh/authorized_keys")
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE_AN"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a Windows vmss with a time zone and additional unattend content",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newWindowsVMSSSpec()
+				spec.WindowsConfiguration = &azure.WindowsConfiguration{
+					TimeZone: "W. Europe Standard Time",
+					AdditionalUnattendContent: []azure.AdditionalUnattendContent{
+						{SettingName: "AutoLogon", Content: "<AutoLogon><Enabled>true</Enabled></AutoLogon>"},
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				expectedWindowsConfig := &compute.WindowsConfiguration{
+					EnableAutomaticUpdates: to.BoolPtr(false),
+					TimeZone:               to.StringPtr("W. Europe Standard Time"),
+					AdditionalUnattendContent: &[]compute.AdditionalUnattendContent{
+						{
+							PassName:      compute.PassNamesOobeSystem,
+							ComponentName: compute.ComponentNamesMicrosoftWindowsShellSetup,
+							SettingName:   compute.SettingNamesAutoLogon,
+							Content:       to.StringPtr("<AutoLogon><Enabled>true</Enabled></AutoLogon>"),
+						},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, windowsOSProfileMatcher(expectedWindowsConfig, nil)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with secrets from Key Vault",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "VM_SIZE_AN"
+				spec.Secrets = []azure.VaultSecretGroup{
+					{
+						SourceVaultID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+						VaultCertificates: []azure.VaultCertificate{
+							{CertificateURL: "https://myvault.vault.azure.net/secrets/ca/1234"},
+							{CertificateURL: "https://myvault.vault.azure.net/secrets/client/5678"},
+						},
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE_AN")
+				netConfigs := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
+				(*netConfigs)[0].EnableAcceleratedNetworking = to.BoolPtr(true)
+				vmss.Sku.Name = to.StringPtr(spec.Size)
+				// Linux Virtual Machines have no certificate store.
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.OsProfile.Secrets = &[]compute.VaultSecretGroup{
+					{
+						SourceVault: &compute.SubResource{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault")},
+						VaultCertificates: &[]compute.VaultCertificate{
+							{CertificateURL: to.StringPtr("https://myvault.vault.azure.net/secrets/ca/1234")},
+							{CertificateURL: to.StringPtr("https://myvault.vault.azure.net/secrets/client/5678")},
+						},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE_AN"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a Windows vmss with secrets from Key Vault merged with the WinRM certificate",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newWindowsVMSSSpec()
+				spec.Secrets = []azure.VaultSecretGroup{
+					{
+						SourceVaultID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+						VaultCertificates: []azure.VaultCertificate{
+							{CertificateURL: "https://myvault.vault.azure.net/secrets/ca/1234", CertificateStore: "Root"},
+						},
+					},
+					{
+						SourceVaultID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/othervault",
+						VaultCertificates: []azure.VaultCertificate{
+							{CertificateURL: "https://othervault.vault.azure.net/secrets/client/5678", CertificateStore: "My"},
+						},
+					},
+				}
+				spec.WindowsConfiguration = &azure.WindowsConfiguration{
+					WinRMListeners: []azure.WinRMListener{
+						{
+							Protocol:       "Https",
+							CertificateURL: "https://myvault.vault.azure.net/secrets/winrm/9012",
+							KeyVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/MyVault",
+						},
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				expectedWindowsConfig := &compute.WindowsConfiguration{
+					EnableAutomaticUpdates: to.BoolPtr(false),
+					WinRM: &compute.WinRMConfiguration{
+						Listeners: &[]compute.WinRMListener{
+							{
+								Protocol:       compute.ProtocolTypesHTTPS,
+								CertificateURL: to.StringPtr("https://myvault.vault.azure.net/secrets/winrm/9012"),
+							},
+						},
+					},
+				}
+				expectedSecrets := &[]compute.VaultSecretGroup{
+					{
+						SourceVault: &compute.SubResource{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault")},
+						VaultCertificates: &[]compute.VaultCertificate{
+							{
+								CertificateURL:   to.StringPtr("https://myvault.vault.azure.net/secrets/ca/1234"),
+								CertificateStore: to.StringPtr("Root"),
+							},
+							{
+								CertificateURL:   to.StringPtr("https://myvault.vault.azure.net/secrets/winrm/9012"),
+								CertificateStore: to.StringPtr("My"),
+							},
+						},
+					},
+					{
+						SourceVault: &compute.SubResource{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/othervault")},
+						VaultCertificates: &[]compute.VaultCertificate{
+							{
+								CertificateURL:   to.StringPtr("https://othervault.vault.azure.net/secrets/client/5678"),
+								CertificateStore: to.StringPtr("My"),
+							},
+						},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, windowsOSProfileMatcher(expectedWindowsConfig, expectedSecrets)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a Windows vmss with an Http WinRM listener",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newWindowsVMSSSpec()
+				spec.WindowsConfiguration = &azure.WindowsConfiguration{
+					WinRMListeners: []azure.WinRMListener{{Protocol: "Http"}},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				expectedWindowsConfig := &compute.WindowsConfiguration{
+					EnableAutomaticUpdates: to.BoolPtr(false),
+					WinRM: &compute.WinRMConfiguration{
+						Listeners: &[]compute.WinRMListener{
+							{Protocol: compute.ProtocolTypesHTTP},
+						},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, windowsOSProfileMatcher(expectedWindowsConfig, nil)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a Windows vmss with an Https WinRM listener with a certificate from Key Vault",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newWindowsVMSSSpec()
+				spec.WindowsConfiguration = &azure.WindowsConfiguration{
+					WinRMListeners: []azure.WinRMListener{
+						{
+							Protocol:       "Https",
+							CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234",
+							KeyVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+						},
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				expectedWindowsConfig := &compute.WindowsConfiguration{
+					EnableAutomaticUpdates: to.BoolPtr(false),
+					WinRM: &compute.WinRMConfiguration{
+						Listeners: &[]compute.WinRMListener{
+							{
+								Protocol:       compute.ProtocolTypesHTTPS,
+								CertificateURL: to.StringPtr("https://myvault.vault.azure.net/secrets/mycert/1234"),
+							},
+						},
+					},
+				}
+				expectedSecrets := &[]compute.VaultSecretGroup{
+					{
+						SourceVault: &compute.SubResource{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault")},
+						VaultCertificates: &[]compute.VaultCertificate{
+							{
+								CertificateURL:   to.StringPtr("https://myvault.vault.azure.net/secrets/mycert/1234"),
+								CertificateStore: to.StringPtr("My"),
+							},
+						},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, windowsOSProfileMatcher(expectedWindowsConfig, expectedSecrets)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss without load balancer backend pools when outbound LB is disabled",
 			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
 			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
 				spec := newDefaultVMSSSpec()
-				spec.SpotVMOptions = &infrav1.SpotVMOptions{}
 				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
 					NameSuffix: "my_disk_with_ultra_disks",
 					DiskSizeGB: 128,
@@ -269,26 +607,26 @@ func TestReconcileVMSS(t *testing.T) {
 						StorageAccountType: "UltraSSD_LRS",
 					},
 				})
+				spec.PublicLBName = ""
+				spec.PublicLBAddressPoolName = ""
 				s.ScaleSetSpec().Return(spec).AnyTimes()
 				setupDefaultVMSSStartCreatingExpectations(s, m)
 				vmss := newDefaultVMSS("VM_SIZE")
 				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
-				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.Priority = compute.VirtualMachinePriorityTypesSpot
-				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.EvictionPolicy = compute.VirtualMachineEvictionPolicyTypesDeallocate
+				netConfigs := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
+				ipConfigs := (*netConfigs)[0].IPConfigurations
+				var backendAddressPools []compute.SubResource
+				(*ipConfigs)[0].LoadBalancerBackendAddressPools = &backendAddressPools
 				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
 					Return(putFuture, nil)
 				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
 			},
 		},
 		{
-			name:          "should start creating a vmss with spot vm and a maximum price",
+			name:          "should start creating a vmss attached to the backend pools of the public and the internal load balancer",
 			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
 			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
 				spec := newDefaultVMSSSpec()
-				maxPrice := resource.MustParse("0.001")
-				spec.SpotVMOptions = &infrav1.SpotVMOptions{
-					MaxPrice: &maxPrice,
-				}
 				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
 					NameSuffix: "my_disk_with_ultra_disks",
 					DiskSizeGB: 128,
@@ -297,28 +635,28 @@ func TestReconcileVMSS(t *testing.T) {
 						StorageAccountType: "UltraSSD_LRS",
 					},
 				})
+				spec.InternalLBName = "capz-internal-lb"
+				spec.InternalLBAddressPoolName = "internalBackendPool"
 				s.ScaleSetSpec().Return(spec).AnyTimes()
 				setupDefaultVMSSStartCreatingExpectations(s, m)
 				vmss := newDefaultVMSS("VM_SIZE")
-				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.Priority = compute.VirtualMachinePriorityTypesSpot
-				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.BillingProfile = &compute.BillingProfile{
-					MaxPrice: to.Float64Ptr(0.001),
-				}
 				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
-				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.EvictionPolicy = compute.VirtualMachineEvictionPolicyTypesDeallocate
+				netConfigs := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
+				ipConfigs := (*netConfigs)[0].IPConfigurations
+				(*ipConfigs)[0].LoadBalancerBackendAddressPools = &[]compute.SubResource{
+					{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/capz-lb/backendAddressPools/backendPool")},
+					{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/capz-internal-lb/backendAddressPools/internalBackendPool")},
+				}
 				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
 					Return(putFuture, nil)
 				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
 			},
 		},
 		{
-			name:          "should start creating a vmss with encryption",
+			name:          "should start creating a vmss attached to an inbound NAT pool",
 			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
 			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
 				spec := newDefaultVMSSSpec()
-				spec.OSDisk.ManagedDisk.DiskEncryptionSet = &infrav1.DiskEncryptionSetParameters{
-					ID: "my-diskencryptionset-id",
-				}
 				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
 					NameSuffix: "my_disk_with_ultra_disks",
 					DiskSizeGB: 128,
@@ -327,16 +665,83 @@ func TestReconcileVMSS(t *testing.T) {
 						StorageAccountType: "UltraSSD_LRS",
 					},
 				})
+				spec.LoadBalancerInboundNatPools = []azure.InboundNatPoolSpec{
+					{LoadBalancerName: "capz-lb", Name: "ssh"},
+				}
 				s.ScaleSetSpec().Return(spec).AnyTimes()
 				setupDefaultVMSSStartCreatingExpectations(s, m)
+				m.GetLoadBalancer(gomockinternal.AContext(), defaultResourceGroup, "capz-lb").Return(network.LoadBalancer{
+					LoadBalancerPropertiesFormat: &network.LoadBalancerPropertiesFormat{
+						InboundNatPools: &[]network.InboundNatPool{
+							{Name: to.StringPtr("ssh")},
+						},
+					},
+				}, nil)
 				vmss := newDefaultVMSS("VM_SIZE")
 				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
-				osdisk := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.StorageProfile.OsDisk
-				osdisk.ManagedDisk = &compute.VirtualMachineScaleSetManagedDiskParameters{
-					StorageAccountType: "Premium_LRS",
-					DiskEncryptionSet: &compute.DiskEncryptionSetParameters{
-						ID: to.StringPtr("my-diskencryptionset-id"),
+				netConfigs := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
+				ipConfigs := (*netConfigs)[0].IPConfigurations
+				(*ipConfigs)[0].LoadBalancerInboundNatPools = &[]compute.SubResource{
+					{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/capz-lb/inboundNatPools/ssh")},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should fail creating a vmss attached to an inbound NAT pool which does not exist",
+			expectedError: "failed to start creating VMSS: failed building VMSS from spec: inbound NAT pool ssh does not exist on load balancer capz-lb",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.LoadBalancerInboundNatPools = []azure.InboundNatPoolSpec{
+					{LoadBalancerName: "capz-lb", Name: "ssh"},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				s.SubscriptionID().AnyTimes().Return(defaultSubscriptionID)
+				s.ResourceGroup().AnyTimes().Return(defaultResourceGroup)
+				s.Location().AnyTimes().Return("test-location")
+				s.VMSSExtensionSpecs().Return(nil).AnyTimes()
+				s.GetVMImage(gomockinternal.AContext()).Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{
+							Publisher: "fake-publisher",
+							Offer:     "my-offer",
+							SKU:       "sku-id",
+						},
+						Version: "1.0",
+					},
+				}, nil).AnyTimes()
+				s.SaveVMImageToStatus(gomock.Any()).AnyTimes()
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).
+					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")).Times(2)
+				m.GetLoadBalancer(gomockinternal.AContext(), defaultResourceGroup, "capz-lb").Return(network.LoadBalancer{
+					LoadBalancerPropertiesFormat: &network.LoadBalancerPropertiesFormat{},
+				}, nil)
+			},
+		},
+		{
+			name:          "should start creating a vmss with DNS servers",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
 					},
+				})
+				spec.DNSServers = []string{"10.0.0.10", "10.0.0.11"}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				netConfigs := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations
+				(*netConfigs)[0].DNSSettings = &compute.VirtualMachineScaleSetNetworkConfigurationDNSSettings{
+					DNSServers: &[]string{"10.0.0.10", "10.0.0.11"},
 				}
 				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
 					Return(putFuture, nil)
@@ -344,7 +749,7 @@ func TestReconcileVMSS(t *testing.T) {
 			},
 		},
 		{
-			name:          "can start creating a vmss with user assigned identity",
+			name:          "should start creating a vmss with a custom role tag",
 			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
 			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
 				spec := newDefaultVMSSSpec()
@@ -356,95 +761,762 @@ func TestReconcileVMSS(t *testing.T) {
 						StorageAccountType: "UltraSSD_LRS",
 					},
 				})
+				spec.Role = "build-agent"
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				vmss.Tags["sigs.k8s.io_cluster-api-provider-azure_role"] = to.StringPtr("build-agent")
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with a non-default tier",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				spec.Tier = "Basic"
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				vmss.Sku.Tier = to.StringPtr("Basic")
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with more than 100 instances without a single placement group",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				spec.Capacity = 150
+				spec.SinglePlacementGroup = to.BoolPtr(false)
+				spec.PlatformFaultDomainCount = to.Int32Ptr(1)
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				vmss.Sku.Capacity = to.Int64Ptr(150)
+				vmss.VirtualMachineScaleSetProperties.PlatformFaultDomainCount = to.Int32Ptr(1)
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with an extension provisioned after another extension",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				s.VMSSExtensionSpecs().Return(newDependentVMSSExtensionSpecs("someExtension")).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				extensions := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.ExtensionProfile.Extensions
+				*extensions = append(*extensions, compute.VirtualMachineScaleSetExtension{
+					Name: to.StringPtr("dependentExtension"),
+					VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{
+						Publisher:                to.StringPtr("somePublisher"),
+						Type:                     to.StringPtr("dependentExtension"),
+						TypeHandlerVersion:       to.StringPtr("someVersion"),
+						ProvisionAfterExtensions: &[]string{"someExtension"},
+					},
+				})
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should fail creating a vmss with an extension provisioned after a missing extension",
+			expectedError: "failed to start creating VMSS: failed building VMSS from spec: reconcile error that cannot be recovered occurred: extension dependentExtension is provisioned after extension missingExtension which does not exist. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				s.ScaleSetSpec().Return(newDefaultVMSSSpec()).AnyTimes()
+				s.VMSSExtensionSpecs().Return(newDependentVMSSExtensionSpecs("missingExtension")).AnyTimes()
+				s.ResourceGroup().AnyTimes().Return(defaultResourceGroup)
+				s.Location().AnyTimes().Return("test-location")
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).
+					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")).Times(2)
+			},
+		},
+		{
+			name:          "should fail creating a vmss with custom data exceeding the maximum length",
+			expectedError: "failed to start creating VMSS: failed building VMSS from spec: reconcile error that cannot be recovered occurred: custom data of scale set my-vmss is 87384 bytes long after base64 encoding, which exceeds the maximum length of 87380 bytes. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				s.ScaleSetSpec().Return(newDefaultVMSSSpec()).AnyTimes()
+				s.SubscriptionID().AnyTimes().Return(defaultSubscriptionID)
+				s.ResourceGroup().AnyTimes().Return(defaultResourceGroup)
+				s.Location().AnyTimes().Return("test-location")
+				s.VMSSExtensionSpecs().Return(nil).AnyTimes()
+				s.GetVMImage(gomockinternal.AContext()).Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						ImagePlan: infrav1.ImagePlan{
+							Publisher: "fake-publisher",
+							Offer:     "my-offer",
+							SKU:       "sku-id",
+						},
+						Version: "1.0",
+					},
+				}, nil).AnyTimes()
+				s.SaveVMImageToStatus(gomock.Any()).AnyTimes()
+				s.GetBootstrapData(gomockinternal.AContext()).Return(strings.Repeat("a", 87384), nil)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).
+					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")).Times(2)
+			},
+		},
+		{
+			name:          "should start creating a vmss with spot vm",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.SpotVMOptions = &infrav1.SpotVMOptions{}
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.Priority = compute.VirtualMachinePriorityTypesSpot
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.EvictionPolicy = compute.VirtualMachineEvictionPolicyTypesDeallocate
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with spot vm and a maximum price",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				maxPrice := resource.MustParse("0.001")
+				spec.SpotVMOptions = &infrav1.SpotVMOptions{
+					MaxPrice: &maxPrice,
+				}
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.Priority = compute.VirtualMachinePriorityTypesSpot
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.BillingProfile = &compute.BillingProfile{
+					MaxPrice: to.Float64Ptr(0.001),
+				}
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.EvictionPolicy = compute.VirtualMachineEvictionPolicyTypesDeallocate
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with encryption",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.OSDisk.ManagedDisk.DiskEncryptionSet = &infrav1.DiskEncryptionSetParameters{
+					ID: "my-diskencryptionset-id",
+				}
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				osdisk := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.StorageProfile.OsDisk
+				osdisk.ManagedDisk = &compute.VirtualMachineScaleSetManagedDiskParameters{
+					StorageAccountType: "Premium_LRS",
+					DiskEncryptionSet: &compute.DiskEncryptionSetParameters{
+						ID: to.StringPtr("my-diskencryptionset-id"),
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with ultra disk performance",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+						DiskIOPSReadWrite:  to.Int64Ptr(20000),
+						DiskMBpsReadWrite:  to.Int64Ptr(500),
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				dataDisks := *vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.StorageProfile.DataDisks
+				dataDisks[3].DiskIOPSReadWrite = to.Int64Ptr(20000)
+				dataDisks[3].DiskMBpsReadWrite = to.Int64Ptr(500)
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with a premium v2 data disk",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.FailureDomains = []string{"1"}
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_premium_v2_disk",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "PremiumV2_LRS",
+						DiskIOPSReadWrite:  to.Int64Ptr(5000),
+						DiskMBpsReadWrite:  to.Int64Ptr(200),
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.Zones = &[]string{"1"}
+				dataDisks := *vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.StorageProfile.DataDisks
+				dataDisks[3] = compute.VirtualMachineScaleSetDataDisk{
+					Lun:          to.Int32Ptr(3),
+					Name:         to.StringPtr("my-vmss_my_premium_v2_disk"),
+					CreateOption: "Empty",
+					DiskSizeGB:   to.Int32Ptr(128),
+					ManagedDisk: &compute.VirtualMachineScaleSetManagedDiskParameters{
+						StorageAccountType: "PremiumV2_LRS",
+					},
+					DiskIOPSReadWrite: to.Int64Ptr(5000),
+					DiskMBpsReadWrite: to.Int64Ptr(200),
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "can start creating a vmss with user assigned identity",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				spec.Identity = infrav1.VMIdentityUserAssigned
+				spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{
+					{
+						ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				vmss.Identity = &compute.VirtualMachineScaleSetIdentity{
+					Type: compute.ResourceIdentityTypeUserAssigned,
+					UserAssignedIdentities: map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{
+						"/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1": {},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "can start creating a vmss with system and user assigned identities",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Identity = infrav1.VMIdentitySystemAndUserAssigned
+				spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{
+					{
+						ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE")
+				vmss.Identity = &compute.VirtualMachineScaleSetIdentity{
+					Type: compute.ResourceIdentityTypeSystemAssignedUserAssigned,
+					UserAssignedIdentities: map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{
+						"/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1": {},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+			},
+		},
+		{
+			name:          "should start creating a vmss with encryption at host enabled",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "VM_SIZE_EAH"
+				spec.SecurityProfile = &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				vmss := newDefaultVMSS("VM_SIZE_EAH")
+				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.SecurityProfile = &compute.SecurityProfile{
+					EncryptionAtHost: to.BoolPtr(true),
+				}
+				vmss.Sku.Name = to.StringPtr(spec.Size)
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
+					Return(putFuture, nil)
+				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE_EAH"), putFuture)
+			},
+		},
+		{
+			name:          "creating a vmss with encryption at host enabled for unsupported VM type fails",
+			expectedError: "reconcile error that cannot be recovered occurred: encryption at host is not supported for VM type VM_SIZE. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				s.ScaleSetSpec().Return(azure.ScaleSetSpec{
+					Name:            defaultVMSSName,
+					Size:            "VM_SIZE",
+					Capacity:        2,
+					SSHKeyData:      "ZmFrZXNzaGtleQo=",
+					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
+				})
+			},
+		},
+		{
+			name:          "should start updating when scale set already exists and not currently in a long running operation",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 2
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSUpdateExpectations(s)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				existingVMSS.Sku.Capacity = to.Int64Ptr(2)
+				existingVMSS.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+
+				clone := newDefaultExistingVMSS("VM_SIZE")
+				clone.Sku.Capacity = to.Int64Ptr(3)
+				clone.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+
+				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
+				g.Expect(err).NotTo(HaveOccurred())
+				patchVMSS.VirtualMachineProfile.StorageProfile.ImageReference.Version = to.StringPtr("2.0")
+				patchVMSS.VirtualMachineProfile.NetworkProfile = nil
+				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
+					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+			},
+		},
+		{
+			name:          "should patch the DNS servers of an existing vmss",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 2
+				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+					NameSuffix: "my_disk_with_ultra_disks",
+					DiskSizeGB: 128,
+					Lun:        to.Int32Ptr(3),
+					ManagedDisk: &infrav1.ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+				})
+				spec.DNSServers = []string{"10.0.0.10"}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSUpdateExpectations(s)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				existingVMSS.Sku.Capacity = to.Int64Ptr(2)
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil).Times(2)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+
+				clone := newDefaultExistingVMSS("VM_SIZE")
+				clone.Sku.Capacity = to.Int64Ptr(3)
+				clone.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+
+				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
+				g.Expect(err).NotTo(HaveOccurred())
+				patchVMSS.VirtualMachineProfile.StorageProfile.ImageReference.Version = to.StringPtr("2.0")
+
+				// the network profile of the existing vmss is patched with the DNS servers
+				networkProfile := newDefaultExistingVMSS("VM_SIZE").VirtualMachineProfile.NetworkProfile
+				(*networkProfile.NetworkInterfaceConfigurations)[0].DNSSettings = &compute.VirtualMachineScaleSetNetworkConfigurationDNSSettings{
+					DNSServers: &[]string{"10.0.0.10"},
+				}
+				networkProfileJSON, err := json.Marshal(networkProfile)
+				g.Expect(err).NotTo(HaveOccurred())
+				patchVMSS.VirtualMachineProfile.NetworkProfile = &compute.VirtualMachineScaleSetUpdateNetworkProfile{}
+				g.Expect(json.Unmarshal(networkProfileJSON, patchVMSS.VirtualMachineProfile.NetworkProfile)).To(Succeed())
+				g.Expect(patchVMSS.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations).NotTo(BeNil())
+
+				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
+					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+			},
+		},
+		{
+			name:          "should not patch the capacity of a vmss whose replicas are managed externally",
+			expectedError: "",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 5
+				spec.ReplicasManagedExternally = true
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.Sku.Capacity = to.Int64Ptr(3)
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
+				s.DeleteLongRunningOperationState(defaultVMSSName, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
+				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				s.InstancesToReimage(gomockinternal.AContext()).Return(nil, nil)
+			},
+		},
+		{
+			name:          "should not patch a vmss with changed bootstrap data unless rolling on bootstrap data changes",
+			expectedError: "",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 3
+				spec.BootstrapDataHash = "outdated-hash"
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.Sku.Capacity = to.Int64Ptr(3)
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
+				s.RemoveAzureMachinePoolAnnotation(azure.BootstrapDataHashAnnotation)
+				s.DeleteLongRunningOperationState(defaultVMSSName, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
+				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				s.InstancesToReimage(gomockinternal.AContext()).Return(nil, nil)
+			},
+		},
+		{
+			name:          "should patch and surge a vmss with changed bootstrap data when rolling on bootstrap data changes",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 3
+				spec.RollOnBootstrapDataChanges = true
+				spec.BootstrapDataHash = "outdated-hash"
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.Sku.Capacity = to.Int64Ptr(3)
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
+
+				// the changed bootstrap data is the only change of the model, which surges the capacity
+				clone := newDefaultExistingVMSS("VM_SIZE")
+				clone.Sku.Capacity = to.Int64Ptr(4)
+
+				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
+				g.Expect(err).NotTo(HaveOccurred())
+				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
+					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+				s.SetAnnotation(azure.BootstrapDataHashAnnotation, bootstrapDataHash(clone))
+				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+			},
+		},
+		{
+			name:          "should patch and surge a vmss whose extension protected settings changed",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 3
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
+
+				// Azure doesn't return the protected settings, only the force update tag derived from the previous ones
+				outdated := &VMSSExtensionSpec{
+					ExtensionSpec: azure.ExtensionSpec{
+						ProtectedSettings: map[string]string{
+							"commandToExecute": "echo goodbye",
+						},
+					},
+				}
+				outdatedTag, err := outdated.forceUpdateTag()
+				g.Expect(err).NotTo(HaveOccurred())
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.Sku.Capacity = to.Int64Ptr(3)
+				existingExtension := (*existingVMSS.VirtualMachineProfile.ExtensionProfile.Extensions)[0]
+				existingExtension.ProtectedSettings = nil
+				existingExtension.ForceUpdateTag = to.StringPtr(outdatedTag)
+				existingVMSS.VirtualMachineProfile.ExtensionProfile.Extensions = &[]compute.VirtualMachineScaleSetExtension{existingExtension}
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
+
+				// the changed force update tag of the extension is the only change of the model, which surges the capacity
+				clone := newDefaultExistingVMSS("VM_SIZE")
+				clone.Sku.Capacity = to.Int64Ptr(4)
+
+				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
+				g.Expect(err).NotTo(HaveOccurred())
+				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
+					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+			},
+		},
+		{
+			name:          "should patch and surge a vmss switching from a system-assigned to a user-assigned identity",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 3
+				spec.Identity = infrav1.VMIdentityUserAssigned
+				spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{
+					{
+						ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+					},
+				}
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.Sku.Capacity = to.Int64Ptr(3)
+				existingVMSS.Identity = &compute.VirtualMachineScaleSetIdentity{
+					Type: compute.ResourceIdentityTypeSystemAssigned,
+				}
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
+
+				// the changed identity is the only change of the model, which surges the capacity
+				clone := newDefaultExistingVMSS("VM_SIZE")
+				clone.Sku.Capacity = to.Int64Ptr(4)
+				clone.Identity = &compute.VirtualMachineScaleSetIdentity{
+					Type: compute.ResourceIdentityTypeUserAssigned,
+					UserAssignedIdentities: map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{
+						"/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1": {},
+					},
+				}
+
+				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(patchVMSS.Identity).NotTo(BeNil())
+				g.Expect(patchVMSS.Identity.Type).To(Equal(compute.ResourceIdentityTypeUserAssigned))
+				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
+					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+			},
+		},
+		{
+			name:          "should patch and surge a vmss removing one of two user-assigned identities",
+			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				const (
+					keptIdentity    = "/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"
+					removedIdentity = "/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2"
+				)
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 3
 				spec.Identity = infrav1.VMIdentityUserAssigned
 				spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{
 					{
-						ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+						ProviderID: "azure://" + keptIdentity,
 					},
 				}
 				s.ScaleSetSpec().Return(spec).AnyTimes()
-				setupDefaultVMSSStartCreatingExpectations(s, m)
-				vmss := newDefaultVMSS("VM_SIZE")
-				vmss.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
-				vmss.Identity = &compute.VirtualMachineScaleSetIdentity{
+
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
+				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+				existingVMSS.Sku.Capacity = to.Int64Ptr(3)
+				existingVMSS.Identity = &compute.VirtualMachineScaleSetIdentity{
 					Type: compute.ResourceIdentityTypeUserAssigned,
 					UserAssignedIdentities: map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{
-						"/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1": {},
+						keptIdentity:    {},
+						removedIdentity: {},
 					},
 				}
-				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
-					Return(putFuture, nil)
-				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
-			},
-		},
-		{
-			name:          "should start creating a vmss with encryption at host enabled",
-			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done",
-			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
-				spec := newDefaultVMSSSpec()
-				spec.Size = "VM_SIZE_EAH"
-				spec.SecurityProfile = &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)}
-				s.ScaleSetSpec().Return(spec).AnyTimes()
-				setupDefaultVMSSStartCreatingExpectations(s, m)
-				vmss := newDefaultVMSS("VM_SIZE_EAH")
-				vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.SecurityProfile = &compute.SecurityProfile{
-					EncryptionAtHost: to.BoolPtr(true),
+				instances := newDefaultInstances()
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
+
+				// the removed identity is the only change of the model, which surges the capacity
+				clone := newDefaultExistingVMSS("VM_SIZE")
+				clone.Sku.Capacity = to.Int64Ptr(4)
+				clone.Identity = &compute.VirtualMachineScaleSetIdentity{
+					Type: compute.ResourceIdentityTypeUserAssigned,
+					UserAssignedIdentities: map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{
+						keptIdentity: {},
+					},
 				}
-				vmss.Sku.Name = to.StringPtr(spec.Size)
-				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(vmss)).
-					Return(putFuture, nil)
-				setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE_EAH"), putFuture)
-			},
-		},
-		{
-			name:          "creating a vmss with encryption at host enabled for unsupported VM type fails",
-			expectedError: "reconcile error that cannot be recovered occurred: encryption at host is not supported for VM type VM_SIZE. Object will not be requeued",
-			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
-				s.ScaleSetSpec().Return(azure.ScaleSetSpec{
-					Name:            defaultVMSSName,
-					Size:            "VM_SIZE",
-					Capacity:        2,
-					SSHKeyData:      "ZmFrZXNzaGtleQo=",
-					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
-				})
+
+				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
+				g.Expect(err).NotTo(HaveOccurred())
+				// Azure only removes the identity if it is explicitly set to null in the patch
+				patchVMSS.Identity.UserAssignedIdentities[removedIdentity] = nil
+				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
+					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
+				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
 			},
 		},
 		{
-			name:          "should start updating when scale set already exists and not currently in a long running operation",
+			name:          "should patch the capacity of a vmss scaling from 2 replicas to zero",
 			expectedError: "failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PATCH on Azure resource my-rg/my-vmss is not done",
 			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
 				spec := newDefaultVMSSSpec()
-				spec.Capacity = 2
-				spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
-					NameSuffix: "my_disk_with_ultra_disks",
-					DiskSizeGB: 128,
-					Lun:        to.Int32Ptr(3),
-					ManagedDisk: &infrav1.ManagedDiskParameters{
-						StorageAccountType: "UltraSSD_LRS",
-					},
-				})
+				spec.Capacity = 0
 				s.ScaleSetSpec().Return(spec).AnyTimes()
 
-				setupDefaultVMSSUpdateExpectations(s)
+				setupDefaultVMSSExpectations(s)
+				s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+				s.MaxSurge().Return(1, nil)
 				existingVMSS := newDefaultExistingVMSS("VM_SIZE")
-				existingVMSS.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
 				existingVMSS.Sku.Capacity = to.Int64Ptr(2)
-				existingVMSS.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
 				instances := newDefaultInstances()
 				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
 				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
+				s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+				s.SetVMSSState(gomock.Any())
 
+				// the capacity is patched to zero without surging although the deployment strategy allows surge
 				clone := newDefaultExistingVMSS("VM_SIZE")
-				clone.Sku.Capacity = to.Int64Ptr(3)
-				clone.VirtualMachineScaleSetProperties.AdditionalCapabilities = &compute.AdditionalCapabilities{UltraSSDEnabled: pointer.Bool(true)}
+				clone.Sku.Capacity = to.Int64Ptr(0)
 
 				patchVMSS, err := getVMSSUpdateFromVMSS(clone)
 				g.Expect(err).NotTo(HaveOccurred())
-				patchVMSS.VirtualMachineProfile.StorageProfile.ImageReference.Version = to.StringPtr("2.0")
 				patchVMSS.VirtualMachineProfile.NetworkProfile = nil
 				m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomockinternal.DiffEq(patchVMSS)).
 					Return(patchFuture, nil)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
 				s.SetLongRunningOperationState(patchFuture)
+				s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
 				m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
 				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(clone, nil)
 				m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(instances, nil)
@@ -474,6 +1546,52 @@ func TestReconcileVMSS(t *testing.T) {
 				})
 			},
 		},
+		{
+			name:          "duplicate data disk LUNs",
+			expectedError: "reconcile error that cannot be recovered occurred: logical unit number 1 of data disk my_disk_with_managed_disk is already in use by another data disk. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks[0].Lun = to.Int32Ptr(1)
+				s.ScaleSetSpec().Return(spec)
+			},
+		},
+		{
+			name:          "data disk LUN out of range",
+			expectedError: "reconcile error that cannot be recovered occurred: logical unit number 64 of data disk my_disk must be between 0 and 63. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks[0].Lun = to.Int32Ptr(64)
+				s.ScaleSetSpec().Return(spec)
+			},
+		},
+		{
+			name:          "data disk without a LUN",
+			expectedError: "reconcile error that cannot be recovered occurred: logical unit number of data disk my_disk is required unless it is auto-assigned. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks[0].Lun = nil
+				s.ScaleSetSpec().Return(spec)
+			},
+		},
+		{
+			name:          "single placement group with more than 100 instances",
+			expectedError: "reconcile error that cannot be recovered occurred: capacity 101 exceeds the maximum of 100 instances of a VMSS with a single placement group. disable single placement group or reduce the capacity. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 101
+				spec.SinglePlacementGroup = to.BoolPtr(true)
+				s.ScaleSetSpec().Return(spec)
+			},
+		},
+		{
+			name:          "unsupported platform fault domain count in availability zones",
+			expectedError: "reconcile error that cannot be recovered occurred: platform fault domain count 3 is not supported for a VMSS in availability zones. use either 1 or 5. Object will not be requeued",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				spec.PlatformFaultDomainCount = to.Int32Ptr(3)
+				s.ScaleSetSpec().Return(spec)
+			},
+		},
 		{
 			name:          "failed to get SKU",
 			expectedError: "failed to get SKU INVALID_VM_SIZE in compute api: reconcile error that cannot be recovered occurred: resource sku with name 'INVALID_VM_SIZE' and category 'virtualMachines' not found in location 'test-location'. Object will not be requeued",
@@ -495,6 +1613,31 @@ func TestReconcileVMSS(t *testing.T) {
 				setupDefaultVMSSStartCreatingExpectations(s, m)
 				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomock.AssignableToTypeOf(compute.VirtualMachineScaleSet{})).
 					Return(nil, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal error"))
+				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, gomock.Any())
+				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).
+					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
+		{
+			name:          "fails with the correlation ID of the failed request",
+			expectedError: "failed to start creating VMSS: cannot create VMSS (correlation ID 00000000-0000-0000-0000-000000000001): #: Internal error: StatusCode=500",
+			expect: func(g *WithT, s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				spec := newDefaultVMSSSpec()
+				s.ScaleSetSpec().Return(spec).AnyTimes()
+				setupDefaultVMSSStartCreatingExpectations(s, m)
+				response := &http.Response{
+					StatusCode: 500,
+					Header: http.Header{
+						"X-Ms-Correlation-Request-Id": []string{"00000000-0000-0000-0000-000000000001"},
+						"X-Ms-Request-Id":             []string{"00000000-0000-0000-0000-000000000002"},
+					},
+				}
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomock.AssignableToTypeOf(compute.VirtualMachineScaleSet{})).
+					Return(nil, autorest.NewErrorWithResponse("", "", response, "Internal error"))
+				s.UpdatePutStatus(infrav1.BootstrapSucceededCondition, serviceName, gomock.Any()).
+					Do(func(_ clusterv1.ConditionType, _ string, err error) {
+						g.Expect(err.Error()).To(ContainSubstring("00000000-0000-0000-0000-000000000001"))
+					})
 				m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).
 					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 			},
@@ -510,6 +1653,7 @@ func TestReconcileVMSS(t *testing.T) {
 					SSHKeyData: "ZmFrZXNzaGtleQo=",
 					DataDisks: []infrav1.DataDisk{
 						{
+							Lun: to.Int32Ptr(0),
 							ManagedDisk: &infrav1.ManagedDiskParameters{
 								StorageAccountType: "UltraSSD_LRS",
 							},
@@ -546,6 +1690,7 @@ func TestReconcileVMSS(t *testing.T) {
 					SSHKeyData: "ZmFrZXNzaGtleQo=",
 					DataDisks: []infrav1.DataDisk{
 						{
+							Lun: to.Int32Ptr(0),
 							ManagedDisk: &infrav1.ManagedDiskParameters{
 								StorageAccountType: "UltraSSD_LRS",
 							},
@@ -590,6 +1735,356 @@ func TestReconcileVMSS(t *testing.T) {
 	}
 }
 
+func TestReconcileVMSSPatchConflictBackoff(t *testing.T) {
+	g := NewWithT(t)
+
+	var lastRequeueAfter time.Duration
+	for conflicts := 0; conflicts < 4; conflicts++ {
+		mockCtrl := gomock.NewController(t)
+		scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+		clientMock := mock_scalesets.NewMockClient(mockCtrl)
+		s, m := scopeMock.EXPECT(), clientMock.EXPECT()
+
+		spec := newDefaultVMSSSpec()
+		spec.Capacity = 2
+		s.ScaleSetSpec().Return(spec).AnyTimes()
+		setupDefaultVMSSUpdateExpectations(s)
+		existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+		existingVMSS.Sku.Capacity = to.Int64Ptr(2)
+		m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+		m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil)
+		m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomock.Any()).
+			Return(nil, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: http.StatusConflict}, "Conflict"))
+		s.AzureMachinePoolAnnotations().Return(map[string]string{azure.VMSSPatchConflictsAnnotation: strconv.Itoa(conflicts)})
+		s.SetAnnotation(azure.VMSSPatchConflictsAnnotation, strconv.Itoa(conflicts+1))
+
+		svc := &Service{
+			Scope:            scopeMock,
+			Client:           clientMock,
+			resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
+		}
+
+		err := svc.Reconcile(context.TODO())
+		var reconcileErr azure.ReconcileError
+		g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+		g.Expect(reconcileErr.IsTransient()).To(BeTrue())
+		g.Expect(reconcileErr.RequeueAfter()).To(BeNumerically(">", lastRequeueAfter))
+		lastRequeueAfter = reconcileErr.RequeueAfter()
+		mockCtrl.Finish()
+	}
+}
+
+func TestReconcileVMSSReplicasManagedExternallyDoesNotSurge(t *testing.T) {
+	g := NewWithT(t)
+
+	// the instances are rolled to a new image version over several reconciles
+	capacity := int64(3)
+	for reconcile := 0; reconcile < 2; reconcile++ {
+		mockCtrl := gomock.NewController(t)
+		scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+		clientMock := mock_scalesets.NewMockClient(mockCtrl)
+		s, m := scopeMock.EXPECT(), clientMock.EXPECT()
+
+		spec := newDefaultVMSSSpec()
+		spec.Capacity = 5
+		spec.ReplicasManagedExternally = true
+		s.ScaleSetSpec().Return(spec).AnyTimes()
+		setupDefaultVMSSUpdateExpectations(s)
+		existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+		existingVMSS.Sku.Capacity = to.Int64Ptr(capacity)
+		m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+		m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil)
+		m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ string, patch compute.VirtualMachineScaleSetUpdate) (*infrav1.Future, error) {
+				capacity = *patch.Sku.Capacity
+				return nil, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: http.StatusConflict}, "Conflict")
+			})
+		s.AzureMachinePoolAnnotations().Return(nil)
+		s.SetAnnotation(azure.VMSSPatchConflictsAnnotation, "1")
+
+		svc := &Service{
+			Scope:            scopeMock,
+			Client:           clientMock,
+			resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
+		}
+
+		g.Expect(svc.Reconcile(context.TODO())).NotTo(Succeed())
+		g.Expect(capacity).To(Equal(int64(3)))
+		mockCtrl.Finish()
+	}
+}
+
+func TestReconcileVMSSOperationTimeout(t *testing.T) {
+	testcases := []struct {
+		name      string
+		startedAt time.Time
+		expect    func(s *mock_scalesets.MockScaleSetScopeMockRecorder)
+		terminal  bool
+	}{
+		{
+			name:      "should keep waiting for an operation within the timeout",
+			startedAt: time.Now().Add(-30 * time.Minute),
+			expect:    func(s *mock_scalesets.MockScaleSetScopeMockRecorder) {},
+			terminal:  false,
+		},
+		{
+			name:      "should give up on an operation exceeding the timeout",
+			startedAt: time.Now().Add(-3 * time.Hour),
+			expect: func(s *mock_scalesets.MockScaleSetScopeMockRecorder) {
+				s.DeleteLongRunningOperationState(defaultVMSSName, serviceName)
+				s.RemoveAzureMachinePoolAnnotation(azure.VMSSOperationStartedAtAnnotation)
+			},
+			terminal: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+			clientMock := mock_scalesets.NewMockClient(mockCtrl)
+			s, m := scopeMock.EXPECT(), clientMock.EXPECT()
+
+			future := &infrav1.Future{
+				Type:          infrav1.PatchFuture,
+				ResourceGroup: defaultResourceGroup,
+				Name:          defaultVMSSName,
+			}
+			spec := newDefaultVMSSSpec()
+			spec.OperationTimeout = time.Hour
+			s.ScaleSetSpec().Return(spec).AnyTimes()
+			setupDefaultVMSSExpectations(s)
+			s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(future)
+			m.GetResultIfDone(gomockinternal.AContext(), future).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(future))
+			s.AzureMachinePoolAnnotations().Return(map[string]string{
+				azure.VMSSOperationStartedAtAnnotation: tc.startedAt.UTC().Format(time.RFC3339),
+			})
+			tc.expect(s)
+
+			existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+			m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil)
+			m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil)
+			s.SetProviderID(azure.ProviderIDPrefix + *existingVMSS.ID)
+			s.SetVMSSState(gomock.Any())
+
+			svc := &Service{
+				Scope:            scopeMock,
+				Client:           clientMock,
+				resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
+			}
+
+			err := svc.Reconcile(context.TODO())
+			g.Expect(err).To(HaveOccurred())
+			var reconcileErr azure.ReconcileError
+			g.Expect(errors.As(err, &reconcileErr) && reconcileErr.IsTerminal()).To(Equal(tc.terminal))
+		})
+	}
+}
+
+func TestReconcileVMSSGetsVMImageOnce(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+	clientMock := mock_scalesets.NewMockClient(mockCtrl)
+	s, m := scopeMock.EXPECT(), clientMock.EXPECT()
+
+	putFuture := &infrav1.Future{
+		Type:          infrav1.PutFuture,
+		ResourceGroup: defaultResourceGroup,
+		Name:          defaultVMSSName,
+	}
+	image := &infrav1.Image{
+		Marketplace: &infrav1.AzureMarketplaceImage{
+			ImagePlan: infrav1.ImagePlan{
+				Publisher: "fake-publisher",
+				Offer:     "my-offer",
+				SKU:       "sku-id",
+			},
+			Version: "1.0",
+		},
+	}
+
+	s.ScaleSetSpec().Return(newDefaultVMSSSpec()).AnyTimes()
+	setupVMSSExpectationsWithoutVMImage(s)
+	s.GetVMImage(gomockinternal.AContext()).Return(image, nil).Times(1)
+	s.SaveVMImageToStatus(image)
+	s.GetLongRunningOperationState(defaultVMSSName, serviceName).Return(nil)
+	m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).
+		Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+	m.CreateOrUpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomock.AssignableToTypeOf(compute.VirtualMachineScaleSet{})).
+		Return(putFuture, nil)
+	setupCreatingSucceededExpectations(s, m, newDefaultExistingVMSS("VM_SIZE"), putFuture)
+
+	svc := &Service{
+		Scope:            scopeMock,
+		Client:           clientMock,
+		resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
+	}
+
+	err := svc.Reconcile(context.TODO())
+	g.Expect(err).To(MatchError("failed to get VMSS my-vmss after create or update: failed to get result from future: operation type PUT on Azure resource my-rg/my-vmss is not done"))
+}
+
+func TestReconcileVMSSMetrics(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+	clientMock := mock_scalesets.NewMockClient(mockCtrl)
+	s, m := scopeMock.EXPECT(), clientMock.EXPECT()
+
+	patchFuture := &infrav1.Future{
+		Type:          infrav1.PatchFuture,
+		ResourceGroup: defaultResourceGroup,
+		Name:          defaultVMSSName,
+	}
+
+	// the desired image version 2.0 differs from the version 1.0 of the existing VMSS, which changes the model and surges
+	spec := newDefaultVMSSSpec()
+	spec.Capacity = 2
+	s.ScaleSetSpec().Return(spec).AnyTimes()
+	setupDefaultVMSSUpdateExpectations(s)
+	existingVMSS := newDefaultExistingVMSS("VM_SIZE")
+	existingVMSS.Sku.Capacity = to.Int64Ptr(2)
+	m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(existingVMSS, nil).Times(2)
+	m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil).Times(2)
+	m.UpdateAsync(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName, gomock.Any()).Return(patchFuture, nil)
+	s.RemoveAzureMachinePoolAnnotation(azure.VMSSPatchConflictsAnnotation)
+	s.SetLongRunningOperationState(patchFuture)
+	s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
+	m.GetResultIfDone(gomockinternal.AContext(), patchFuture).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(patchFuture))
+
+	svc := &Service{
+		Scope:            scopeMock,
+		Client:           clientMock,
+		resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
+	}
+
+	modelUpdatesBefore := testutil.ToFloat64(modelUpdates)
+	surgesBefore := testutil.ToFloat64(surges)
+
+	g.Expect(svc.Reconcile(context.TODO())).To(HaveOccurred())
+	g.Expect(testutil.ToFloat64(modelUpdates)).To(Equal(modelUpdatesBefore + 1))
+	g.Expect(testutil.ToFloat64(surges)).To(Equal(surgesBefore + 1))
+	g.Expect(testutil.CollectAndCount(reconcileDuration)).To(Equal(1))
+}
+
+func TestCapSurgeToQuota(t *testing.T) {
+	usage := func(name string, current int32, limit int64) compute.Usage {
+		return compute.Usage{
+			Name:         &compute.UsageName{Value: to.StringPtr(name)},
+			CurrentValue: to.Int32Ptr(current),
+			Limit:        to.Int64Ptr(limit),
+		}
+	}
+
+	testcases := []struct {
+		name            string
+		capacity        int64
+		currentCapacity int64
+		surge           int64
+		usages          []compute.Usage
+		listErr         error
+		expectedSurge   int64
+		expectedError   string
+	}{
+		{
+			name:            "keeps a surge within the quotas",
+			capacity:        3,
+			currentCapacity: 3,
+			surge:           5,
+			usages:          []compute.Usage{usage("cores", 20, 100), usage("standardVMSizeFamily", 12, 100)},
+			expectedSurge:   5,
+		},
+		{
+			name:            "caps the surge to the quota of the VM family",
+			capacity:        3,
+			currentCapacity: 3,
+			surge:           5,
+			usages:          []compute.Usage{usage("cores", 20, 100), usage("standardVMSizeFamily", 12, 18)},
+			expectedSurge:   4,
+		},
+		{
+			name:            "caps the surge to the regional quota",
+			capacity:        3,
+			currentCapacity: 3,
+			surge:           5,
+			usages:          []compute.Usage{usage("cores", 20, 24), usage("standardVMSizeFamily", 12, 100)},
+			expectedSurge:   4,
+		},
+		{
+			name:            "ignores the quotas of other VM families",
+			capacity:        3,
+			currentCapacity: 3,
+			surge:           5,
+			usages:          []compute.Usage{usage("cores", 20, 100), usage("standardOtherFamily", 0, 0)},
+			expectedSurge:   5,
+		},
+		{
+			name:            "doesn't cap the capacity below the desired capacity",
+			capacity:        5,
+			currentCapacity: 3,
+			surge:           6,
+			usages:          []compute.Usage{usage("cores", 20, 20)},
+			expectedSurge:   5,
+		},
+		{
+			name:          "fails to list the usages",
+			capacity:      3,
+			surge:         5,
+			listErr:       autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"),
+			expectedError: "failed to list compute usages",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+			clientMock := mock_scalesets.NewMockClient(mockCtrl)
+			scopeMock.EXPECT().Location().Return("test-location")
+			clientMock.EXPECT().ListUsages(gomockinternal.AContext(), "test-location").Return(tc.usages, tc.listErr)
+
+			s := &Service{
+				Scope:            scopeMock,
+				Client:           clientMock,
+				resourceSKUCache: resourceskus.NewStaticCache(getFakeSkus(), "test-location"),
+			}
+
+			spec := newDefaultVMSSSpec()
+			spec.Capacity = tc.capacity
+			surge, err := s.capSurgeToQuota(context.TODO(), spec, tc.currentCapacity, tc.surge)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(surge).To(Equal(tc.expectedSurge))
+		})
+	}
+}
+
+func TestPatchConflictDelay(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(patchConflictDelay(0)).To(BeNumerically(">=", patchConflictBaseDelay))
+	g.Expect(patchConflictDelay(0)).To(BeNumerically("<=", time.Duration(float64(patchConflictBaseDelay)*(1+patchConflictJitterFactor))))
+	g.Expect(patchConflictDelay(100)).To(BeNumerically(">=", patchConflictMaxDelay))
+	g.Expect(patchConflictDelay(100)).To(BeNumerically("<=", time.Duration(float64(patchConflictMaxDelay)*(1+patchConflictJitterFactor))))
+}
+
 func TestDeleteVMSS(t *testing.T) {
 	const (
 		resourceGroup = "my-rg"
@@ -637,6 +2132,26 @@ func TestDeleteVMSS(t *testing.T) {
 					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 			},
 		},
+		{
+			name:          "successfully delete a vmss in a separate resource group",
+			expectedError: "",
+			expect: func(s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder) {
+				s.ScaleSetSpec().Return(azure.ScaleSetSpec{
+					Name:          name,
+					Size:          "VM_SIZE",
+					Capacity:      3,
+					ResourceGroup: "my-vmss-rg",
+				}).AnyTimes()
+				s.ResourceGroup().AnyTimes().Return(resourceGroup)
+				s.GetLongRunningOperationState(name, serviceName).Return(nil)
+				m.DeleteAsync(gomockinternal.AContext(), "my-vmss-rg", name).Return(nil, nil)
+				s.SetLongRunningOperationState(nil)
+				s.DeleteLongRunningOperationState(name, serviceName)
+				s.UpdateDeleteStatus(infrav1.BootstrapSucceededCondition, serviceName, nil)
+				m.Get(gomockinternal.AContext(), "my-vmss-rg", name).
+					Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
 		{
 			name:          "vmss deletion fails",
 			expectedError: "failed to delete VMSS my-vmss in resource group my-rg: #: Internal Server Error: StatusCode=500",
@@ -692,6 +2207,7 @@ func getFakeSkus() []compute.ResourceSku {
 			Name:         to.StringPtr("VM_SIZE"),
 			ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
 			Kind:         to.StringPtr(string(resourceskus.VirtualMachines)),
+			Family:       to.StringPtr("standardVMSizeFamily"),
 			Locations: &[]string{
 				"test-location",
 			},
@@ -725,6 +2241,10 @@ func getFakeSkus() []compute.ResourceSku {
 					Name:  to.StringPtr(resourceskus.MemoryGB),
 					Value: to.StringPtr("4"),
 				},
+				{
+					Name:  to.StringPtr(resourceskus.PremiumIO),
+					Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+				},
 			},
 		},
 		{
@@ -887,6 +2407,62 @@ func getFakeSkus() []compute.ResourceSku {
 				},
 			},
 		},
+		{
+			Name:         to.StringPtr("VM_SIZE_ZONE_1"),
+			ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
+			Kind:         to.StringPtr(string(resourceskus.VirtualMachines)),
+			Locations: &[]string{
+				"test-location",
+			},
+			LocationInfo: &[]compute.ResourceSkuLocationInfo{
+				{
+					Location: to.StringPtr("test-location"),
+					Zones:    &[]string{"1"},
+				},
+			},
+			Capabilities: &[]compute.ResourceSkuCapabilities{
+				{
+					Name:  to.StringPtr(resourceskus.AcceleratedNetworking),
+					Value: to.StringPtr(string(resourceskus.CapabilityUnsupported)),
+				},
+				{
+					Name:  to.StringPtr(resourceskus.VCPUs),
+					Value: to.StringPtr("4"),
+				},
+				{
+					Name:  to.StringPtr(resourceskus.MemoryGB),
+					Value: to.StringPtr("4"),
+				},
+			},
+		},
+		{
+			Name:         to.StringPtr(string(compute.AvailabilitySetSkuTypesAligned)),
+			ResourceType: to.StringPtr(string(resourceskus.AvailabilitySets)),
+			Kind:         to.StringPtr(string(resourceskus.AvailabilitySets)),
+			Locations: &[]string{
+				"test-location",
+			},
+			Capabilities: &[]compute.ResourceSkuCapabilities{
+				{
+					Name:  to.StringPtr(resourceskus.MaximumPlatformFaultDomainCount),
+					Value: to.StringPtr("2"),
+				},
+			},
+		},
+		{
+			Name:         to.StringPtr(infrav1.StorageAccountTypePremiumV2LRS),
+			ResourceType: to.StringPtr(string(resourceskus.Disks)),
+			Kind:         to.StringPtr(string(resourceskus.Disks)),
+			Locations: &[]string{
+				"test-location",
+			},
+			LocationInfo: &[]compute.ResourceSkuLocationInfo{
+				{
+					Location: to.StringPtr("test-location"),
+					Zones:    &[]string{"1"},
+				},
+			},
+		},
 	}
 }
 
@@ -946,6 +2522,26 @@ func newWindowsVMSSSpec() azure.ScaleSetSpec {
 	return vmss
 }
 
+// windowsOSProfileMatcher matches a vmss with the given Windows configuration and secrets in its OS profile. The rest
+// of the OS profile is ignored, as the admin password of Windows Virtual Machines is generated randomly.
+func windowsOSProfileMatcher(windowsConfig *compute.WindowsConfiguration, secrets *[]compute.VaultSecretGroup) gomock.Matcher {
+	return gomockinternal.CustomMatcher(
+		func(x interface{}, _ map[string]interface{}) bool {
+			vmss, ok := x.(compute.VirtualMachineScaleSet)
+			if !ok {
+				return false
+			}
+			osProfile := vmss.VirtualMachineScaleSetProperties.VirtualMachineProfile.OsProfile
+			return osProfile.LinuxConfiguration == nil &&
+				reflect.DeepEqual(osProfile.WindowsConfiguration, windowsConfig) &&
+				reflect.DeepEqual(osProfile.Secrets, secrets)
+		},
+		func(_ map[string]interface{}) string {
+			return "a Windows vmss with the expected windows configuration and secrets"
+		},
+	)
+}
+
 func newDefaultExistingVMSS(vmSize string) compute.VirtualMachineScaleSet {
 	vmss := newDefaultVMSS(vmSize)
 	vmss.ID = to.StringPtr("subscriptions/1234/resourceGroups/my_resource_group/providers/Microsoft.Compute/virtualMachines/my-vm")
@@ -1064,6 +2660,7 @@ func newDefaultVMSS(vmSize string) compute.VirtualMachineScaleSet {
 								ProtectedSettings: map[string]string{
 									"commandToExecute": "echo hello",
 								},
+								ForceUpdateTag: to.StringPtr("e66050ff"),
 							},
 						},
 					},
@@ -1218,6 +2815,7 @@ func setupDefaultVMSSStartCreatingExpectations(s *mock_scalesets.MockScaleSetSco
 
 func setupCreatingSucceededExpectations(s *mock_scalesets.MockScaleSetScopeMockRecorder, m *mock_scalesets.MockClientMockRecorder, vmss compute.VirtualMachineScaleSet, future *infrav1.Future) {
 	s.SetLongRunningOperationState(future)
+	s.SetAnnotation(azure.VMSSOperationStartedAtAnnotation, gomock.Any())
 	m.GetResultIfDone(gomockinternal.AContext(), future).Return(compute.VirtualMachineScaleSet{}, azure.NewOperationNotDoneError(future))
 	m.Get(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(vmss, nil)
 	m.ListInstances(gomockinternal.AContext(), defaultResourceGroup, defaultVMSSName).Return(newDefaultInstances(), nil).AnyTimes()
@@ -1280,6 +2878,33 @@ func setupVMSSExpectationsWithoutVMImage(s *mock_scalesets.MockScaleSetScopeMock
 	}).AnyTimes()
 }
 
+func newDependentVMSSExtensionSpecs(dependency string) []azure.ResourceSpecGetter {
+	return []azure.ResourceSpecGetter{
+		&VMSSExtensionSpec{
+			ExtensionSpec: azure.ExtensionSpec{
+				Name:      "someExtension",
+				VMName:    "my-vmss",
+				Publisher: "somePublisher",
+				Version:   "someVersion",
+				ProtectedSettings: map[string]string{
+					"commandToExecute": "echo hello",
+				},
+			},
+			ResourceGroup: "my-rg",
+		},
+		&VMSSExtensionSpec{
+			ExtensionSpec: azure.ExtensionSpec{
+				Name:      "dependentExtension",
+				VMName:    "my-vmss",
+				Publisher: "somePublisher",
+				Version:   "someVersion",
+			},
+			ResourceGroup:            "my-rg",
+			ProvisionAfterExtensions: []string{dependency},
+		},
+	}
+}
+
 func setupDefaultVMSSUpdateExpectations(s *mock_scalesets.MockScaleSetScopeMockRecorder) {
 	setupUpdateVMSSExpectations(s)
 	s.SetProviderID(azure.ProviderIDPrefix + "subscriptions/1234/resourceGroups/my_resource_group/providers/Microsoft.Compute/virtualMachines/my-vm")
@@ -1287,3 +2912,352 @@ func setupDefaultVMSSUpdateExpectations(s *mock_scalesets.MockScaleSetScopeMockR
 	s.MaxSurge().Return(1, nil)
 	s.SetVMSSState(gomock.Any())
 }
+
+func TestValidateAcceleratedNetworkingInterfaces(t *testing.T) {
+	newVMSS := func(acceleratedNetworking ...bool) compute.VirtualMachineScaleSet {
+		configs := make([]compute.VirtualMachineScaleSetNetworkConfiguration, len(acceleratedNetworking))
+		for i, enabled := range acceleratedNetworking {
+			configs[i] = compute.VirtualMachineScaleSetNetworkConfiguration{
+				Name: to.StringPtr(strconv.Itoa(i)),
+				VirtualMachineScaleSetNetworkConfigurationProperties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+					EnableAcceleratedNetworking: to.BoolPtr(enabled),
+				},
+			}
+		}
+		return compute.VirtualMachineScaleSet{
+			VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+				VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+					NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+						NetworkInterfaceConfigurations: &configs,
+					},
+				},
+			},
+		}
+	}
+	sku := resourceskus.SKU{
+		Name: to.StringPtr("VM_SIZE_AN"),
+		Capabilities: &[]compute.ResourceSkuCapabilities{
+			{
+				Name:  to.StringPtr(resourceskus.AcceleratedNetworking),
+				Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+			},
+			{
+				Name:  to.StringPtr(resourceskus.MaxNetworkInterfaces),
+				Value: to.StringPtr("1"),
+			},
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		vmss          compute.VirtualMachineScaleSet
+		expectedError string
+	}{
+		{
+			name: "single network interface with accelerated networking",
+			vmss: newVMSS(true),
+		},
+		{
+			name: "multiple network interfaces of which one has accelerated networking",
+			vmss: newVMSS(true, false),
+		},
+		{
+			name:          "multiple network interfaces with accelerated networking on a vm size supporting one",
+			vmss:          newVMSS(true, true),
+			expectedError: "reconcile error that cannot be recovered occurred: vm size VM_SIZE_AN supports at most 1 network interfaces, but 2 network interfaces have accelerated networking enabled. Object will not be requeued",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := validateAcceleratedNetworkingInterfaces(tc.vmss, sku)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestVerifyImagePlanTerms(t *testing.T) {
+	plan := &compute.Plan{
+		Publisher: to.StringPtr("fake-publisher"),
+		Product:   to.StringPtr("my-offer"),
+		Name:      to.StringPtr("sku-id"),
+	}
+
+	testcases := []struct {
+		name          string
+		plan          *compute.Plan
+		expect        func(m *mock_scalesets.MockClientMockRecorder)
+		expectedError string
+	}{
+		{
+			name:   "image without plan",
+			plan:   nil,
+			expect: func(m *mock_scalesets.MockClientMockRecorder) {},
+		},
+		{
+			name: "accepted image plan terms",
+			plan: plan,
+			expect: func(m *mock_scalesets.MockClientMockRecorder) {
+				m.GetImagePlanTerms(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id").Return(marketplaceordering.AgreementTerms{
+					AgreementProperties: &marketplaceordering.AgreementProperties{
+						Accepted: to.BoolPtr(true),
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "image plan terms which are not accepted",
+			plan: plan,
+			expect: func(m *mock_scalesets.MockClientMockRecorder) {
+				m.GetImagePlanTerms(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id").Return(marketplaceordering.AgreementTerms{
+					AgreementProperties: &marketplaceordering.AgreementProperties{
+						Accepted: to.BoolPtr(false),
+					},
+				}, nil)
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: the terms of image plan sku-id of offer my-offer by publisher fake-publisher have not been accepted in the subscription. accept them, e.g. with `az vm image terms accept --publisher fake-publisher --offer my-offer --plan sku-id`. Object will not be requeued",
+		},
+		{
+			name: "failure to get the image plan terms",
+			plan: plan,
+			expect: func(m *mock_scalesets.MockClientMockRecorder) {
+				m.GetImagePlanTerms(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id").Return(marketplaceordering.AgreementTerms{}, errors.New("#: Internal Server Error: StatusCode=500"))
+			},
+			expectedError: "failed to get the terms of image plan sku-id: #: Internal Server Error: StatusCode=500",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			clientMock := mock_scalesets.NewMockClient(mockCtrl)
+			tc.expect(clientMock.EXPECT())
+
+			s := &Service{
+				Client: clientMock,
+			}
+			err := s.verifyImagePlanTerms(context.TODO(), tc.plan)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGenerateStorageProfileSortsDataDisksByLun(t *testing.T) {
+	g := NewWithT(t)
+
+	s := &Service{}
+	spec := newDefaultVMSSSpec()
+	spec.AutoAssignDataDiskLuns = true
+	spec.DataDisks = []infrav1.DataDisk{
+		{
+			NameSuffix: "disk_lun_2",
+			DiskSizeGB: 128,
+			Lun:        to.Int32Ptr(2),
+		},
+		{
+			NameSuffix: "disk_auto_lun",
+			DiskSizeGB: 128,
+		},
+		{
+			NameSuffix: "disk_lun_0",
+			DiskSizeGB: 128,
+			Lun:        to.Int32Ptr(0),
+		},
+	}
+	image := &infrav1.Image{
+		Marketplace: &infrav1.AzureMarketplaceImage{
+			ImagePlan: infrav1.ImagePlan{
+				Publisher: "fake-publisher",
+				Offer:     "my-offer",
+				SKU:       "sku-id",
+			},
+			Version: "1.0",
+		},
+	}
+
+	storageProfile, err := s.generateStorageProfile(context.TODO(), spec, resourceskus.SKU{}, image)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*storageProfile.DataDisks).To(HaveLen(3))
+	var (
+		luns  []int32
+		names []string
+	)
+	for _, disk := range *storageProfile.DataDisks {
+		luns = append(luns, *disk.Lun)
+		names = append(names, *disk.Name)
+	}
+	g.Expect(luns).To(Equal([]int32{0, 1, 2}))
+	g.Expect(names).To(Equal([]string{
+		azure.GenerateDataDiskName(defaultVMSSName, "disk_lun_0"),
+		azure.GenerateDataDiskName(defaultVMSSName, "disk_auto_lun"),
+		azure.GenerateDataDiskName(defaultVMSSName, "disk_lun_2"),
+	}))
+}
+
+func TestGetDataDiskLunsAssignsAllLuns(t *testing.T) {
+	g := NewWithT(t)
+
+	dataDisks := make([]infrav1.DataDisk, maxDataDiskLuns)
+	dataDisks[0].Lun = to.Int32Ptr(63)
+
+	luns := getDataDiskLuns(dataDisks, true)
+	g.Expect(luns).To(HaveLen(maxDataDiskLuns))
+	g.Expect(luns).NotTo(ContainElement(BeNil()))
+	g.Expect(*luns[0]).To(Equal(int32(63)))
+	g.Expect(*luns[maxDataDiskLuns-1]).To(Equal(int32(62)))
+
+	luns = getDataDiskLuns(dataDisks, false)
+	g.Expect(*luns[0]).To(Equal(int32(63)))
+	g.Expect(luns[1]).To(BeNil())
+}
+
+func TestGenerateOSProfilePasswordAuthentication(t *testing.T) {
+	testcases := []struct {
+		name                                  string
+		disablePasswordAuthentication         *bool
+		expectedDisablePasswordAuthentication bool
+	}{
+		{
+			name:                                  "disables password authentication by default",
+			expectedDisablePasswordAuthentication: true,
+		},
+		{
+			name:                                  "disables password authentication",
+			disablePasswordAuthentication:         to.BoolPtr(true),
+			expectedDisablePasswordAuthentication: true,
+		},
+		{
+			name:                                  "enables password authentication",
+			disablePasswordAuthentication:         to.BoolPtr(false),
+			expectedDisablePasswordAuthentication: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+			scopeMock.EXPECT().GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+
+			s := &Service{
+				Scope: scopeMock,
+			}
+
+			spec := newDefaultVMSSSpec()
+			spec.DisablePasswordAuthentication = tc.disablePasswordAuthentication
+
+			osProfile, err := s.generateOSProfile(context.TODO(), spec)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(osProfile.LinuxConfiguration.DisablePasswordAuthentication).To(Equal(to.BoolPtr(tc.expectedDisablePasswordAuthentication)))
+			// the admin password is only set for the creation of the scale set
+			g.Expect(osProfile.AdminPassword).To(BeNil())
+		})
+	}
+}
+
+func TestSetLinuxAdminPassword(t *testing.T) {
+	testcases := []struct {
+		name             string
+		osProfile        *compute.VirtualMachineScaleSetOSProfile
+		expect           func(s *mock_scalesets.MockScaleSetScopeMockRecorder)
+		expectedPassword *string
+		expectedError    string
+	}{
+		{
+			name: "sets the admin password of the AzureMachinePool if password authentication is enabled",
+			osProfile: &compute.VirtualMachineScaleSetOSProfile{
+				LinuxConfiguration: &compute.LinuxConfiguration{DisablePasswordAuthentication: to.BoolPtr(false)},
+			},
+			expect: func(s *mock_scalesets.MockScaleSetScopeMockRecorder) {
+				s.GetLinuxAdminPassword(gomockinternal.AContext()).Return("s3cr3t-Passw0rd", nil)
+			},
+			expectedPassword: to.StringPtr("s3cr3t-Passw0rd"),
+		},
+		{
+			name: "fails if the admin password can't be retrieved",
+			osProfile: &compute.VirtualMachineScaleSetOSProfile{
+				LinuxConfiguration: &compute.LinuxConfiguration{DisablePasswordAuthentication: to.BoolPtr(false)},
+			},
+			expect: func(s *mock_scalesets.MockScaleSetScopeMockRecorder) {
+				s.GetLinuxAdminPassword(gomockinternal.AContext()).Return("", errors.New("secret not found"))
+			},
+			expectedError: "failed to get the admin password: secret not found",
+		},
+		{
+			name: "does not set an admin password if password authentication is disabled",
+			osProfile: &compute.VirtualMachineScaleSetOSProfile{
+				LinuxConfiguration: &compute.LinuxConfiguration{DisablePasswordAuthentication: to.BoolPtr(true)},
+			},
+			expect: func(s *mock_scalesets.MockScaleSetScopeMockRecorder) {},
+		},
+		{
+			name: "does not replace the admin password of a Windows scale set",
+			osProfile: &compute.VirtualMachineScaleSetOSProfile{
+				AdminPassword:        to.StringPtr("windows-password"),
+				WindowsConfiguration: &compute.WindowsConfiguration{},
+			},
+			expect:           func(s *mock_scalesets.MockScaleSetScopeMockRecorder) {},
+			expectedPassword: to.StringPtr("windows-password"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			scopeMock := mock_scalesets.NewMockScaleSetScope(mockCtrl)
+			tc.expect(scopeMock.EXPECT())
+
+			s := &Service{
+				Scope: scopeMock,
+			}
+
+			err := s.setLinuxAdminPassword(context.TODO(), tc.osProfile)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(tc.osProfile.AdminPassword).To(Equal(tc.expectedPassword))
+		})
+	}
+}
+
+func TestGetVMSSUpdateFromVMSSOmitsAdminPassword(t *testing.T) {
+	g := NewWithT(t)
+
+	vmss := newDefaultVMSS("VM_SIZE")
+	vmss.VirtualMachineProfile.OsProfile.LinuxConfiguration.DisablePasswordAuthentication = to.BoolPtr(false)
+	vmss.VirtualMachineProfile.OsProfile.AdminPassword = to.StringPtr("s3cr3t-Passw0rd")
+
+	// patches can't change the admin password, so the one set for the creation is never replaced
+	patch, err := getVMSSUpdateFromVMSS(vmss)
+	g.Expect(err).NotTo(HaveOccurred())
+	patchJSON, err := json.Marshal(patch)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(patchJSON)).NotTo(ContainSubstring("adminPassword"))
+	g.Expect(string(patchJSON)).NotTo(ContainSubstring(*vmss.VirtualMachineProfile.OsProfile.AdminPassword))
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	azuresdk "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/util/slice"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+// ValidateSpec validates a scale set spec against the capabilities of its VM size in the given location. Invalid
+// settings are returned as field errors in the order they are checked, while failures to look up the SKU or zones are
+// returned as error. The field errors collected until a lookup fails are returned along with the error.
+func ValidateSpec(ctx context.Context, spec azure.ScaleSetSpec, location string, skuCache *resourceskus.Cache) (field.ErrorList, error) {
+	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesets.ValidateSpec")
+	defer done()
+
+	allErrs := validateDataDiskLuns(spec.DataDisks, spec.AutoAssignDataDiskLuns, field.NewPath("dataDisks"))
+	allErrs = append(allErrs, validatePlacement(spec)...)
+	allErrs = append(allErrs, validateDNSServers(spec.DNSServers, field.NewPath("dnsServers"))...)
+	allErrs = append(allErrs, validateUserAssignedIdentities(spec.UserAssignedIdentities, field.NewPath("userAssignedIdentities"))...)
+
+	sku, err := skuCache.Get(ctx, spec.Size, resourceskus.VirtualMachines)
+	if err != nil {
+		return allErrs, errors.Wrapf(err, "failed to get SKU %s in compute api", spec.Size)
+	}
+
+	// Checking if the requested VM size has at least 2 vCPUS
+	vCPUCapability, err := sku.HasCapabilityWithCapacity(resourceskus.VCPUs, resourceskus.MinimumVCPUS)
+	if err != nil {
+		allErrs = append(allErrs, field.InternalError(field.NewPath("size"), errors.Wrap(err, "failed to validate the vCPU capability")))
+	} else if !vCPUCapability {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("size"), spec.Size, "vm size should be bigger or equal to at least 2 vCPUs"))
+	}
+
+	// Checking if the requested VM size has at least 2 Gi of memory
+	memoryCapability, err := sku.HasCapabilityWithCapacity(resourceskus.MemoryGB, resourceskus.MinimumMemory)
+	if err != nil {
+		allErrs = append(allErrs, field.InternalError(field.NewPath("size"), errors.Wrap(err, "failed to validate the memory capability")))
+	} else if !memoryCapability {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("size"), spec.Size, "vm memory should be bigger or equal to at least 2Gi"))
+	}
+
+	// enable ephemeral OS
+	if spec.OSDisk.DiffDiskSettings != nil && !sku.HasCapability(resourceskus.EphemeralOSDisk) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("osDisk", "diffDiskSettings"), spec.OSDisk.DiffDiskSettings.Option,
+			fmt.Sprintf("vm size %s does not support ephemeral os. select a different vm size or disable ephemeral os", spec.Size)))
+	}
+
+	if spec.SecurityProfile != nil && !sku.HasCapability(resourceskus.EncryptionAtHost) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("securityProfile", "encryptionAtHost"), to.Bool(spec.SecurityProfile.EncryptionAtHost),
+			fmt.Sprintf("encryption at host is not supported for VM type %s", spec.Size)))
+	}
+
+	// Checking if the platform fault domain count of a regional VMSS is supported in the location
+	if spec.PlatformFaultDomainCount != nil && len(spec.FailureDomains) == 0 {
+		maxFaultDomainCount, err := maxPlatformFaultDomainCount(ctx, skuCache)
+		if err != nil {
+			return allErrs, errors.Wrapf(err, "failed to get the maximum platform fault domain count in location %s", location)
+		}
+		if *spec.PlatformFaultDomainCount > maxFaultDomainCount {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("platformFaultDomainCount"), *spec.PlatformFaultDomainCount,
+				fmt.Sprintf("platform fault domain count %d exceeds the maximum of %d fault domains in location %s", *spec.PlatformFaultDomainCount, maxFaultDomainCount, location)))
+		}
+	}
+
+	// Fetch location and zone to check for their support of ultra disks.
+	zones, err := skuCache.GetZones(ctx, location)
+	if err != nil {
+		return allErrs, azure.WithTerminalError(errors.Wrapf(err, "failed to get the zones for location %s", location))
+	}
+
+	ultraSSDAvailable := true
+	for _, zone := range zones {
+		if !sku.HasLocationCapability(resourceskus.UltraSSDAvailable, location, zone) {
+			ultraSSDAvailable = false
+			break
+		}
+	}
+	if !ultraSSDAvailable {
+		msg := fmt.Sprintf("vm size %s does not support ultra disks in location %s. select a different vm size or disable ultra disks", spec.Size, location)
+
+		// Check support for ultra disks as data disks.
+		for i, disk := range spec.DataDisks {
+			if disk.ManagedDisk != nil && disk.ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("dataDisks").Index(i).Child("managedDisk", "storageAccountType"),
+					disk.ManagedDisk.StorageAccountType, msg))
+			}
+		}
+		// Check support for ultra disks as persistent volumes.
+		if spec.AdditionalCapabilities != nil && to.Bool(spec.AdditionalCapabilities.UltraSSDEnabled) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("additionalCapabilities", "ultraSSDEnabled"), true, msg))
+		}
+	}
+
+	allErrs = append(allErrs, validatePremiumV2Disks(ctx, spec, location, sku, skuCache)...)
+
+	// Checking if selected availability zones are available selected VM type in location
+	azsInLocation, err := skuCache.GetZonesWithVMSize(ctx, spec.Size, location)
+	if err != nil {
+		return allErrs, errors.Wrapf(err, "failed to get zones for VM type %s in location %s", spec.Size, location)
+	}
+
+	for i, az := range spec.FailureDomains {
+		if !slice.Contains(azsInLocation, az) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("failureDomains").Index(i), az,
+				fmt.Sprintf("availability zone %s is not available for VM type %s in location %s", az, spec.Size, location)))
+		}
+	}
+
+	return allErrs, nil
+}
+
+// maxPlatformFaultDomainCount returns the maximum number of fault domains in the location of the SKU cache, which is
+// published as a capability of the aligned availability set SKU.
+func maxPlatformFaultDomainCount(ctx context.Context, skuCache *resourceskus.Cache) (int32, error) {
+	sku, err := skuCache.Get(ctx, string(compute.AvailabilitySetSkuTypesAligned), resourceskus.AvailabilitySets)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get availability set SKU %s in compute api", string(compute.AvailabilitySetSkuTypesAligned))
+	}
+
+	faultDomainCountStr, ok := sku.GetCapability(resourceskus.MaximumPlatformFaultDomainCount)
+	if !ok {
+		return 0, errors.Errorf("unable to get required availability set SKU capability %s", resourceskus.MaximumPlatformFaultDomainCount)
+	}
+
+	faultDomainCount, err := strconv.ParseInt(faultDomainCountStr, 10, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to parse availability set fault domain count")
+	}
+
+	return int32(faultDomainCount), nil
+}
+
+// validatePremiumV2Disks checks that Premium SSD v2 data disks are only used with VM sizes supporting premium storage
+// and with a VMSS whose availability zones all support Premium SSD v2 disks, as they can only be attached to zonal VMs.
+func validatePremiumV2Disks(ctx context.Context, spec azure.ScaleSetSpec, location string, sku resourceskus.SKU, skuCache *resourceskus.Cache) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	var paths []*field.Path
+	for i, disk := range spec.DataDisks {
+		if disk.ManagedDisk != nil && disk.ManagedDisk.StorageAccountType == infrav1.StorageAccountTypePremiumV2LRS {
+			paths = append(paths, field.NewPath("dataDisks").Index(i).Child("managedDisk", "storageAccountType"))
+		}
+	}
+	if len(paths) == 0 {
+		return allErrs
+	}
+
+	var msgs []string
+	if !sku.HasCapability(resourceskus.PremiumIO) {
+		msgs = append(msgs, fmt.Sprintf("vm size %s does not support premium storage. select a different vm size or storage account type", spec.Size))
+	}
+
+	if len(spec.FailureDomains) == 0 {
+		msgs = append(msgs, "PremiumV2_LRS disks can only be attached to VMs in availability zones. set the failure domains or select a different storage account type")
+	} else {
+		// the SKUs of the location have already been cached for the VM size, so a failed lookup means there is no such disk SKU
+		if diskSKU, err := skuCache.Get(ctx, infrav1.StorageAccountTypePremiumV2LRS, resourceskus.Disks); err != nil {
+			msgs = append(msgs, fmt.Sprintf("PremiumV2_LRS disks are not available in location %s. select a different storage account type", location))
+		} else {
+			diskZones := diskSKU.GetZones(location)
+			for _, az := range spec.FailureDomains {
+				if !slice.Contains(diskZones, az) {
+					msgs = append(msgs, fmt.Sprintf("PremiumV2_LRS disks are not available in availability zone %s in location %s. select different failure domains or a different storage account type", az, location))
+				}
+			}
+		}
+	}
+
+	for _, path := range paths {
+		for _, msg := range msgs {
+			allErrs = append(allErrs, field.Invalid(path, infrav1.StorageAccountTypePremiumV2LRS, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validateDataDiskLuns checks that there are at most 64 data disks and that their LUNs are between 0 and 63 and unique.
+// A LUN is required for each data disk unless autoAssign is set. Limiting the number of data disks to the number of
+// LUNs guarantees that a free LUN is left to auto-assign to each data disk without one.
+func validateDataDiskLuns(dataDisks []infrav1.DataDisk, autoAssign bool, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(dataDisks) > maxDataDiskLuns {
+		return append(allErrs, field.TooMany(fldPath, len(dataDisks), maxDataDiskLuns))
+	}
+
+	lunSet := make(map[int32]struct{}, len(dataDisks))
+	for i, disk := range dataDisks {
+		lunPath := fldPath.Index(i).Child("lun")
+		if disk.Lun == nil {
+			if !autoAssign {
+				allErrs = append(allErrs, field.Required(lunPath,
+					fmt.Sprintf("logical unit number of data disk %s is required unless it is auto-assigned", disk.NameSuffix)))
+			}
+			continue
+		}
+		if *disk.Lun < 0 || *disk.Lun > 63 {
+			allErrs = append(allErrs, field.Invalid(lunPath, *disk.Lun,
+				fmt.Sprintf("logical unit number %d of data disk %s must be between 0 and 63", *disk.Lun, disk.NameSuffix)))
+			continue
+		}
+		if _, ok := lunSet[*disk.Lun]; ok {
+			allErrs = append(allErrs, field.Invalid(lunPath, *disk.Lun,
+				fmt.Sprintf("logical unit number %d of data disk %s is already in use by another data disk", *disk.Lun, disk.NameSuffix)))
+			continue
+		}
+		lunSet[*disk.Lun] = struct{}{}
+	}
+
+	return allErrs
+}
+
+// validateDNSServers checks that the DNS servers are valid IP addresses.
+func validateDNSServers(dnsServers []string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, dnsServer := range dnsServers {
+		if net.ParseIP(dnsServer) == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), dnsServer,
+				fmt.Sprintf("DNS server %s is not a valid IP address", dnsServer)))
+		}
+	}
+
+	return allErrs
+}
+
+// validateUserAssignedIdentities checks that the provider IDs of the user-assigned identities are resource IDs of
+// user-assigned managed identities.
+func validateUserAssignedIdentities(identities []infrav1.UserAssignedIdentity, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, identity := range identities {
+		resource, err := azuresdk.ParseResourceID(strings.TrimPrefix(identity.ProviderID, azure.ProviderIDPrefix))
+		if err != nil || !strings.EqualFold(resource.Provider, "Microsoft.ManagedIdentity") ||
+			!strings.EqualFold(resource.ResourceType, "userAssignedIdentities") || resource.ResourceName == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("providerID"), identity.ProviderID,
+				fmt.Sprintf("user-assigned identity %s is not a valid Microsoft.ManagedIdentity/userAssignedIdentities resource ID", identity.ProviderID)))
+		}
+	}
+
+	return allErrs
+}
+
+// validatePlacement checks that the capacity, placement group and fault domain settings can be combined.
+func validatePlacement(spec azure.ScaleSetSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spec.Capacity > maxCapacity {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("capacity"), spec.Capacity,
+			fmt.Sprintf("capacity %d exceeds the maximum of %d instances of a VMSS. reduce the capacity or split the machine pool", spec.Capacity, maxCapacity)))
+	} else if to.Bool(spec.SinglePlacementGroup) && spec.Capacity > maxSinglePlacementGroupCapacity {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("capacity"), spec.Capacity,
+			fmt.Sprintf("capacity %d exceeds the maximum of %d instances of a VMSS with a single placement group. disable single placement group or reduce the capacity", spec.Capacity, maxSinglePlacementGroupCapacity)))
+	}
+
+	if spec.PlatformFaultDomainCount == nil {
+		return allErrs
+	}
+
+	faultDomainCount := *spec.PlatformFaultDomainCount
+	fldPath := field.NewPath("platformFaultDomainCount")
+	if len(spec.FailureDomains) > 0 {
+		// zonal VMSS either spread instances across as many fault domains as possible or statically across 5 fault domains
+		if faultDomainCount != 1 && faultDomainCount != 5 {
+			allErrs = append(allErrs, field.Invalid(fldPath, faultDomainCount,
+				fmt.Sprintf("platform fault domain count %d is not supported for a VMSS in availability zones. use either 1 or 5", faultDomainCount)))
+		}
+		return allErrs
+	}
+
+	// the maximum fault domain count of a regional VMSS depends on its location and is validated against the SKU
+	if faultDomainCount < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath, faultDomainCount,
+			fmt.Sprintf("platform fault domain count %d must be at least 1", faultDomainCount)))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+)
+
+func TestValidateSpec(t *testing.T) {
+	testcases := []struct {
+		name          string
+		spec          func() azure.ScaleSetSpec
+		expectedErrs  field.ErrorList
+		expectedError string
+	}{
+		{
+			name:         "valid spec",
+			spec:         newDefaultVMSSSpec,
+			expectedErrs: field.ErrorList{},
+		},
+		{
+			name: "vm size with too few vCPUs",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "VM_SIZE_1_CPU"
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("size"), "VM_SIZE_1_CPU", "vm size should be bigger or equal to at least 2 vCPUs"),
+			},
+		},
+		{
+			name: "all invalid settings are reported",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks[1].Lun = to.Int32Ptr(0)
+				spec.FailureDomains = []string{"1", "2"}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dataDisks").Index(1).Child("lun"), int32(0), "logical unit number 0 of data disk my_disk_with_managed_disk is already in use by another data disk"),
+				field.Invalid(field.NewPath("failureDomains").Index(1), "2", "availability zone 2 is not available for VM type VM_SIZE in location test-location"),
+			},
+		},
+		{
+			name: "data disk without a LUN",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks[1].Lun = nil
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(field.NewPath("dataDisks").Index(1).Child("lun"), "logical unit number of data disk my_disk_with_managed_disk is required unless it is auto-assigned"),
+			},
+		},
+		{
+			name: "data disk without a LUN which is auto-assigned",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks[1].Lun = nil
+				spec.AutoAssignDataDiskLuns = true
+				return spec
+			},
+			expectedErrs: field.ErrorList{},
+		},
+		{
+			name: "more data disks than LUNs",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.DataDisks = nil
+				for i := 0; i < 65; i++ {
+					spec.DataDisks = append(spec.DataDisks, infrav1.DataDisk{
+						NameSuffix: fmt.Sprintf("my_disk_%d", i),
+						DiskSizeGB: 128,
+					})
+				}
+				spec.AutoAssignDataDiskLuns = true
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.TooMany(field.NewPath("dataDisks"), 65, 64),
+			},
+		},
+		{
+			name: "capacity at the maximum of a vmss",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 1000
+				return spec
+			},
+			expectedErrs: field.ErrorList{},
+		},
+		{
+			name: "capacity exceeding the maximum of a vmss",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 1001
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("capacity"), int64(1001), "capacity 1001 exceeds the maximum of 1000 instances of a VMSS. reduce the capacity or split the machine pool"),
+			},
+		},
+		{
+			name: "capacity exceeding the maximum of a vmss with a single placement group",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Capacity = 101
+				spec.SinglePlacementGroup = to.BoolPtr(true)
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("capacity"), int64(101), "capacity 101 exceeds the maximum of 100 instances of a VMSS with a single placement group. disable single placement group or reduce the capacity"),
+			},
+		},
+		{
+			name: "vm size without ultra disk support",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "VM_SIZE_AN"
+				spec.DataDisks = []infrav1.DataDisk{
+					{
+						NameSuffix: "my_ultra_disk",
+						DiskSizeGB: 128,
+						Lun:        to.Int32Ptr(0),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "UltraSSD_LRS",
+						},
+					},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dataDisks").Index(0).Child("managedDisk", "storageAccountType"), "UltraSSD_LRS", "vm size VM_SIZE_AN does not support ultra disks in location test-location. select a different vm size or disable ultra disks"),
+			},
+		},
+		{
+			name: "premium v2 data disk on a vm size with premium storage in a supported zone",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.FailureDomains = []string{"1"}
+				spec.DataDisks = []infrav1.DataDisk{
+					{
+						NameSuffix: "my_premium_v2_disk",
+						DiskSizeGB: 128,
+						Lun:        to.Int32Ptr(0),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "PremiumV2_LRS",
+						},
+					},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{},
+		},
+		{
+			name: "premium v2 data disk on a vm size without premium storage",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "VM_SIZE_AN"
+				spec.FailureDomains = []string{"1"}
+				spec.DataDisks = []infrav1.DataDisk{
+					{
+						NameSuffix: "my_premium_v2_disk",
+						DiskSizeGB: 128,
+						Lun:        to.Int32Ptr(0),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "PremiumV2_LRS",
+						},
+					},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dataDisks").Index(0).Child("managedDisk", "storageAccountType"), "PremiumV2_LRS", "vm size VM_SIZE_AN does not support premium storage. select a different vm size or storage account type"),
+			},
+		},
+		{
+			name: "premium v2 data disk in a zone without premium v2 disks",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.FailureDomains = []string{"1", "3"}
+				spec.DataDisks = []infrav1.DataDisk{
+					{
+						NameSuffix: "my_premium_v2_disk",
+						DiskSizeGB: 128,
+						Lun:        to.Int32Ptr(0),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "PremiumV2_LRS",
+						},
+					},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dataDisks").Index(0).Child("managedDisk", "storageAccountType"), "PremiumV2_LRS", "PremiumV2_LRS disks are not available in availability zone 3 in location test-location. select different failure domains or a different storage account type"),
+			},
+		},
+		{
+			name: "premium v2 data disk on a regional vmss",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.FailureDomains = nil
+				spec.DataDisks = []infrav1.DataDisk{
+					{
+						NameSuffix: "my_premium_v2_disk",
+						DiskSizeGB: 128,
+						Lun:        to.Int32Ptr(0),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "PremiumV2_LRS",
+						},
+					},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dataDisks").Index(0).Child("managedDisk", "storageAccountType"), "PremiumV2_LRS", "PremiumV2_LRS disks can only be attached to VMs in availability zones. set the failure domains or select a different storage account type"),
+			},
+		},
+		{
+			name: "regional vmss with a supported platform fault domain count",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.FailureDomains = nil
+				spec.PlatformFaultDomainCount = to.Int32Ptr(2)
+				return spec
+			},
+			expectedErrs: field.ErrorList{},
+		},
+		{
+			name: "regional vmss with a platform fault domain count exceeding the maximum of the location",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.FailureDomains = nil
+				spec.PlatformFaultDomainCount = to.Int32Ptr(3)
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("platformFaultDomainCount"), int32(3), "platform fault domain count 3 exceeds the maximum of 2 fault domains in location test-location"),
+			},
+		},
+		{
+			name: "invalid DNS servers",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.DNSServers = []string{"10.0.0.10", "not-an-ip"}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dnsServers").Index(1), "not-an-ip", "DNS server not-an-ip is not a valid IP address"),
+			},
+		},
+		{
+			name: "valid user-assigned identities",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{
+					{ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"},
+					{ProviderID: "/subscriptions/123/resourceGroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2"},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{},
+		},
+		{
+			name: "malformed user-assigned identities",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{
+					{ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"},
+					{ProviderID: "azure:///subscriptions/123/id2"},
+					{ProviderID: "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.Compute/virtualMachines/id3"},
+				}
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("userAssignedIdentities").Index(1).Child("providerID"), "azure:///subscriptions/123/id2",
+					"user-assigned identity azure:///subscriptions/123/id2 is not a valid Microsoft.ManagedIdentity/userAssignedIdentities resource ID"),
+				field.Invalid(field.NewPath("userAssignedIdentities").Index(2).Child("providerID"), "azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.Compute/virtualMachines/id3",
+					"user-assigned identity azure:///subscriptions/123/resourcegroups/456/providers/Microsoft.Compute/virtualMachines/id3 is not a valid Microsoft.ManagedIdentity/userAssignedIdentities resource ID"),
+			},
+		},
+		{
+			name: "invalid settings are returned along with a failed SKU lookup",
+			spec: func() azure.ScaleSetSpec {
+				spec := newDefaultVMSSSpec()
+				spec.Size = "UNKNOWN_SIZE"
+				spec.DataDisks[0].Lun = to.Int32Ptr(64)
+				return spec
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("dataDisks").Index(0).Child("lun"), int32(64), "logical unit number 64 of data disk my_disk must be between 0 and 63"),
+			},
+			expectedError: "failed to get SKU UNKNOWN_SIZE in compute api: reconcile error that cannot be recovered occurred: resource sku with name 'UNKNOWN_SIZE' and category 'virtualMachines' not found in location 'test-location'. Object will not be requeued",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+
+			skuCache := resourceskus.NewStaticCache(getFakeSkus(), "test-location")
+			allErrs, err := ValidateSpec(context.TODO(), tc.spec(), "test-location", skuCache)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(allErrs).To(Equal(tc.expectedErrs))
+		})
+	}
+}
@@ -17,6 +17,10 @@ limitations under the License.
 package scalesets
 
 import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
@@ -27,6 +31,11 @@ import (
 type VMSSExtensionSpec struct {
 	azure.ExtensionSpec
 	ResourceGroup string
+	// ProvisionAfterExtensions are the names of the extensions of the VMSS which have to be provisioned before this extension.
+	ProvisionAfterExtensions []string
+	// ForceUpdateTag forces the extension to run again when it changes. Defaults to a hash of the protected settings,
+	// so that the extension is re-run when only its protected settings change.
+	ForceUpdateTag string
 }
 
 // ResourceName returns the name of the VMSS extension.
@@ -56,7 +65,12 @@ func (s *VMSSExtensionSpec) Parameters(existing interface{}) (interface{}, error
 		return nil, nil
 	}
 
-	return compute.VirtualMachineScaleSetExtension{
+	forceUpdateTag, err := s.forceUpdateTag()
+	if err != nil {
+		return nil, err
+	}
+
+	extension := compute.VirtualMachineScaleSetExtension{
 		Name: to.StringPtr(s.Name),
 		VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{
 			Publisher:          to.StringPtr(s.Publisher),
@@ -65,5 +79,34 @@ func (s *VMSSExtensionSpec) Parameters(existing interface{}) (interface{}, error
 			Settings:           nil,
 			ProtectedSettings:  s.ProtectedSettings,
 		},
-	}, nil
+	}
+
+	if forceUpdateTag != "" {
+		extension.ForceUpdateTag = to.StringPtr(forceUpdateTag)
+	}
+
+	if len(s.ProvisionAfterExtensions) > 0 {
+		extension.ProvisionAfterExtensions = to.StringSlicePtr(s.ProvisionAfterExtensions)
+	}
+
+	return extension, nil
+}
+
+// forceUpdateTag returns the force update tag of the VMSS extension, defaulting to a hash of the protected settings.
+func (s *VMSSExtensionSpec) forceUpdateTag() (string, error) {
+	if s.ForceUpdateTag != "" || len(s.ProtectedSettings) == 0 {
+		return s.ForceUpdateTag, nil
+	}
+
+	// json.Marshal sorts the map keys, which keeps the hash stable across reconciles.
+	protectedSettings, err := json.Marshal(s.ProtectedSettings)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal protected settings")
+	}
+
+	h := fnv.New32a()
+	if _, err := h.Write(protectedSettings); err != nil {
+		return "", errors.Wrap(err, "failed to hash protected settings")
+	}
+	return fmt.Sprintf("%x", h.Sum32()), nil
 }
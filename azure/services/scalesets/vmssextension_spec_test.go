@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+)
+
+func newVMSSExtensionSpec(protectedSettings map[string]string) *VMSSExtensionSpec {
+	return &VMSSExtensionSpec{
+		ExtensionSpec: azure.ExtensionSpec{
+			Name:              "someExtension",
+			VMName:            "my-vmss",
+			Publisher:         "somePublisher",
+			Version:           "someVersion",
+			ProtectedSettings: protectedSettings,
+		},
+		ResourceGroup: "my-rg",
+	}
+}
+
+func TestVMSSExtensionSpecParameters(t *testing.T) {
+	testcases := []struct {
+		name          string
+		spec          *VMSSExtensionSpec
+		existing      interface{}
+		expect        func(g *WithT, result interface{})
+		expectedError string
+	}{
+		{
+			name:     "existing extension is not updated",
+			spec:     newVMSSExtensionSpec(map[string]string{"commandToExecute": "echo hello"}),
+			existing: compute.VirtualMachineScaleSetExtension{},
+			expect: func(g *WithT, result interface{}) {
+				g.Expect(result).To(BeNil())
+			},
+		},
+		{
+			name: "force update tag defaults to a hash of the protected settings",
+			spec: newVMSSExtensionSpec(map[string]string{"commandToExecute": "echo hello"}),
+			expect: func(g *WithT, result interface{}) {
+				g.Expect(result).To(BeAssignableToTypeOf(compute.VirtualMachineScaleSetExtension{}))
+				g.Expect(result.(compute.VirtualMachineScaleSetExtension).ForceUpdateTag).To(Equal(to.StringPtr("e66050ff")))
+			},
+		},
+		{
+			name: "changing the protected settings changes the force update tag",
+			spec: newVMSSExtensionSpec(map[string]string{"commandToExecute": "echo rotated"}),
+			expect: func(g *WithT, result interface{}) {
+				g.Expect(result).To(BeAssignableToTypeOf(compute.VirtualMachineScaleSetExtension{}))
+				g.Expect(result.(compute.VirtualMachineScaleSetExtension).ForceUpdateTag).To(Equal(to.StringPtr("f66a4b9e")))
+			},
+		},
+		{
+			name: "explicit force update tag takes precedence",
+			spec: func() *VMSSExtensionSpec {
+				spec := newVMSSExtensionSpec(map[string]string{"commandToExecute": "echo hello"})
+				spec.ForceUpdateTag = "my-tag"
+				return spec
+			}(),
+			expect: func(g *WithT, result interface{}) {
+				g.Expect(result).To(BeAssignableToTypeOf(compute.VirtualMachineScaleSetExtension{}))
+				g.Expect(result.(compute.VirtualMachineScaleSetExtension).ForceUpdateTag).To(Equal(to.StringPtr("my-tag")))
+			},
+		},
+		{
+			name: "no force update tag without protected settings",
+			spec: newVMSSExtensionSpec(nil),
+			expect: func(g *WithT, result interface{}) {
+				g.Expect(result).To(BeAssignableToTypeOf(compute.VirtualMachineScaleSetExtension{}))
+				g.Expect(result.(compute.VirtualMachineScaleSetExtension).ForceUpdateTag).To(BeNil())
+			},
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+
+			result, err := tc.spec.Parameters(tc.existing)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			tc.expect(g, result)
+		})
+	}
+}
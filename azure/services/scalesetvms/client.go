@@ -36,7 +36,7 @@ import (
 type client interface {
 	Get(context.Context, string, string, string) (compute.VirtualMachineScaleSetVM, error)
 	GetResultIfDone(ctx context.Context, future *infrav1.Future) (compute.VirtualMachineScaleSetVM, error)
-	DeleteAsync(context.Context, string, string, string) (*infrav1.Future, error)
+	DeleteAsync(context.Context, string, string, string, bool) (*infrav1.Future, error)
 }
 
 type (
@@ -131,11 +131,12 @@ func (ac *azureClient) GetResultIfDone(ctx context.Context, future *infrav1.Futu
 //   resourceGroupName - the name of the resource group.
 //   vmssName - the name of the VM scale set to create or update. parameters - the scale set object.
 //   instanceID - the ID of the VM scale set VM.
-func (ac *azureClient) DeleteAsync(ctx context.Context, resourceGroupName, vmssName, instanceID string) (*infrav1.Future, error) {
+//   forceDeletion - whether to force delete the VM scale set VM.
+func (ac *azureClient) DeleteAsync(ctx context.Context, resourceGroupName, vmssName, instanceID string, forceDeletion bool) (*infrav1.Future, error) {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "scalesetvms.azureClient.DeleteAsync")
 	defer done()
 
-	future, err := ac.scalesetvms.Delete(ctx, resourceGroupName, vmssName, instanceID, to.BoolPtr(false))
+	future, err := ac.scalesetvms.Delete(ctx, resourceGroupName, vmssName, instanceID, to.BoolPtr(forceDeletion))
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed deleting vmss named %q", vmssName)
 	}
@@ -205,6 +205,20 @@ func (mr *MockScaleSetVMScopeMockRecorder) FailureDomains() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailureDomains", reflect.TypeOf((*MockScaleSetVMScope)(nil).FailureDomains))
 }
 
+// ForceDeletion mocks base method.
+func (m *MockScaleSetVMScope) ForceDeletion() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForceDeletion")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ForceDeletion indicates an expected call of ForceDeletion.
+func (mr *MockScaleSetVMScopeMockRecorder) ForceDeletion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForceDeletion", reflect.TypeOf((*MockScaleSetVMScope)(nil).ForceDeletion))
+}
+
 // GetLongRunningOperationState mocks base method.
 func (m *MockScaleSetVMScope) GetLongRunningOperationState(arg0, arg1 string) *v1beta1.Future {
 	m.ctrl.T.Helper()
@@ -289,6 +303,20 @@ func (mr *MockScaleSetVMScopeMockRecorder) ScaleSetName() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScaleSetName", reflect.TypeOf((*MockScaleSetVMScope)(nil).ScaleSetName))
 }
 
+// ScaleSetResourceGroup mocks base method.
+func (m *MockScaleSetVMScope) ScaleSetResourceGroup() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScaleSetResourceGroup")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ScaleSetResourceGroup indicates an expected call of ScaleSetResourceGroup.
+func (mr *MockScaleSetVMScopeMockRecorder) ScaleSetResourceGroup() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScaleSetResourceGroup", reflect.TypeOf((*MockScaleSetVMScope)(nil).ScaleSetResourceGroup))
+}
+
 // SetLongRunningOperationState mocks base method.
 func (m *MockScaleSetVMScope) SetLongRunningOperationState(arg0 *v1beta1.Future) {
 	m.ctrl.T.Helper()
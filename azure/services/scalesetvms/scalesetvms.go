@@ -36,6 +36,8 @@ type (
 		azure.AsyncStatusUpdater
 		InstanceID() string
 		ScaleSetName() string
+		ScaleSetResourceGroup() string
+		ForceDeletion() bool
 		SetVMSSVM(vmssvm *azure.VMSSVM)
 	}
 
@@ -65,7 +67,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	defer done()
 
 	var (
-		resourceGroup = s.Scope.ResourceGroup()
+		resourceGroup = s.Scope.ScaleSetResourceGroup()
 		vmssName      = s.Scope.ScaleSetName()
 		instanceID    = s.Scope.InstanceID()
 	)
@@ -86,7 +88,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 // Delete deletes a scaleset instance asynchronously returning a future which encapsulates the long-running operation.
 func (s *Service) Delete(ctx context.Context) error {
 	var (
-		resourceGroup = s.Scope.ResourceGroup()
+		resourceGroup = s.Scope.ScaleSetResourceGroup()
 		vmssName      = s.Scope.ScaleSetName()
 		instanceID    = s.Scope.InstanceID()
 	)
@@ -127,7 +129,7 @@ func (s *Service) Delete(ctx context.Context) error {
 	}
 
 	// since the future was nil, there is no ongoing activity; start deleting the instance
-	future, err := s.Client.DeleteAsync(ctx, resourceGroup, vmssName, instanceID)
+	future, err := s.Client.DeleteAsync(ctx, resourceGroup, vmssName, instanceID, s.Scope.ForceDeletion())
 	if err != nil {
 		if azure.ResourceNotFound(err) {
 			// already deleted
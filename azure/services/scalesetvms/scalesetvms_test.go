@@ -100,7 +100,7 @@ func TestService_Reconcile(t *testing.T) {
 		{
 			Name: "should reconcile successfully",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				vm := compute.VirtualMachineScaleSetVM{
@@ -113,7 +113,7 @@ func TestService_Reconcile(t *testing.T) {
 		{
 			Name: "if 404, then should respond with transient error",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				m.Get(gomock2.AContext(), "rg", "scaleset", "0").Return(compute.VirtualMachineScaleSetVM{}, autorest404)
@@ -124,7 +124,7 @@ func TestService_Reconcile(t *testing.T) {
 		{
 			Name: "if other error, then should respond with error",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				m.Get(gomock2.AContext(), "rg", "scaleset", "0").Return(compute.VirtualMachineScaleSetVM{}, errors.New("boom"))
@@ -174,14 +174,36 @@ func TestService_Delete(t *testing.T) {
 		{
 			Name: "should start deleting successfully if no long running operation is active",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				s.GetLongRunningOperationState("0", serviceName).Return(nil)
 				future := &infrav1.Future{
 					Type: infrav1.DeleteFuture,
 				}
-				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0").Return(future, nil)
+				s.ForceDeletion().Return(false)
+				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0", false).Return(future, nil)
+				s.SetLongRunningOperationState(future)
+				m.GetResultIfDone(gomock2.AContext(), future).Return(compute.VirtualMachineScaleSetVM{}, azure.WithTransientError(azure.NewOperationNotDoneError(future), 15*time.Second))
+				m.Get(gomock2.AContext(), "rg", "scaleset", "0").Return(compute.VirtualMachineScaleSetVM{}, nil)
+			},
+			CheckIsErr: true,
+			Err: errors.Wrap(azure.WithTransientError(azure.NewOperationNotDoneError(&infrav1.Future{
+				Type: infrav1.DeleteFuture,
+			}), 15*time.Second), "failed to get result of long running operation"),
+		},
+		{
+			Name: "should start force deleting if force deletion is enabled",
+			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
+				s.ScaleSetResourceGroup().Return("rg")
+				s.InstanceID().Return("0")
+				s.ScaleSetName().Return("scaleset")
+				s.GetLongRunningOperationState("0", serviceName).Return(nil)
+				future := &infrav1.Future{
+					Type: infrav1.DeleteFuture,
+				}
+				s.ForceDeletion().Return(true)
+				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0", true).Return(future, nil)
 				s.SetLongRunningOperationState(future)
 				m.GetResultIfDone(gomock2.AContext(), future).Return(compute.VirtualMachineScaleSetVM{}, azure.WithTransientError(azure.NewOperationNotDoneError(future), 15*time.Second))
 				m.Get(gomock2.AContext(), "rg", "scaleset", "0").Return(compute.VirtualMachineScaleSetVM{}, nil)
@@ -194,7 +216,7 @@ func TestService_Delete(t *testing.T) {
 		{
 			Name: "should finish deleting successfully when there's a long running operation that has completed",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				future := &infrav1.Future{
@@ -209,22 +231,24 @@ func TestService_Delete(t *testing.T) {
 		{
 			Name: "should not error when deleting, but resource is 404",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				s.GetLongRunningOperationState("0", serviceName).Return(nil)
-				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0").Return(nil, autorest404)
+				s.ForceDeletion().Return(false)
+				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0", false).Return(nil, autorest404)
 				m.Get(gomock2.AContext(), "rg", "scaleset", "0").Return(compute.VirtualMachineScaleSetVM{}, nil)
 			},
 		},
 		{
 			Name: "should error when deleting, but a non-404 error is returned from DELETE call",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				s.GetLongRunningOperationState("0", serviceName).Return(nil)
-				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0").Return(nil, errors.New("boom"))
+				s.ForceDeletion().Return(false)
+				m.DeleteAsync(gomock2.AContext(), "rg", "scaleset", "0", false).Return(nil, errors.New("boom"))
 				m.Get(gomock2.AContext(), "rg", "scaleset", "0").Return(compute.VirtualMachineScaleSetVM{}, nil)
 			},
 			Err: errors.Wrap(errors.New("boom"), "failed to delete instance scaleset/0"),
@@ -232,7 +256,7 @@ func TestService_Delete(t *testing.T) {
 		{
 			Name: "should return error when a long running operation is active and getting the result returns an error",
 			Setup: func(s *mock_scalesetvms.MockScaleSetVMScopeMockRecorder, m *mock_scalesetvms.MockclientMockRecorder) {
-				s.ResourceGroup().Return("rg")
+				s.ScaleSetResourceGroup().Return("rg")
 				s.InstanceID().Return("0")
 				s.ScaleSetName().Return("scaleset")
 				future := &infrav1.Future{
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/blang/semver"
@@ -178,6 +179,61 @@ func (s *Service) getSKUAndVersion(ctx context.Context, location, publisher, off
 	return sku, version, nil
 }
 
+// GetLatestMarketplaceImageVersion returns the most recent version of the marketplace image with the given publisher,
+// offer and SKU in the location, i.e. the version Azure uses for the version "latest".
+func (s *Service) GetLatestMarketplaceImageVersion(ctx context.Context, location, publisher, offer, sku string) (string, error) {
+	ctx, log, done := tele.StartSpanWithLogger(ctx, "virtualmachineimages.Service.GetLatestMarketplaceImageVersion")
+	defer done()
+
+	imageCache, err := GetCache(s.Authorizer)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get image cache")
+	}
+	imageCache.client = s.Client
+
+	listVMImagesResource, err := imageCache.Get(ctx, location, publisher, offer, sku)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list VM images for publisher \"%s\" offer \"%s\" sku \"%s\"", publisher, offer, sku)
+	}
+
+	var version string
+	if listVMImagesResource.Value != nil {
+		for _, vmImage := range *listVMImagesResource.Value {
+			if vmImage.Name != nil && (version == "" || CompareImageVersions(*vmImage.Name, version) > 0) {
+				version = *vmImage.Name
+			}
+		}
+	}
+	if version == "" {
+		return "", errors.Errorf("no VM images found for publisher \"%s\" offer \"%s\" sku \"%s\"", publisher, offer, sku)
+	}
+
+	log.V(4).Info("Resolved latest VM image version", "location", location, "publisher", publisher, "offer", offer, "sku", sku, "version", version)
+
+	return version, nil
+}
+
+// CompareImageVersions compares two image versions in the format {major}.{minor}.{build} by their numeric parts and
+// returns a negative number if a is lower than b, a positive number if a is greater than b, and 0 if they are equal.
+// Parts which are not numeric are compared lexically.
+func CompareImageVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		if aErr != nil || bErr != nil {
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if aNum != bNum {
+			return aNum - bNum
+		}
+	}
+	return len(aParts) - len(bParts)
+}
+
 // getUbuntuOSVersion returns the default Ubuntu OS version for the given Kubernetes version.
 func getUbuntuOSVersion(major, minor, patch uint64) string {
 	// Default to Ubuntu 20.04 LTS, except for k8s versions which have only 18.04 reference images.
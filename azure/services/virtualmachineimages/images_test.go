@@ -487,3 +487,70 @@ func TestGetDefaultImageSKUID(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLatestMarketplaceImageVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		versions        compute.ListVirtualMachineImageResource
+		expectedVersion string
+		expectedError   bool
+	}{
+		{
+			name: "picks the highest version",
+			versions: compute.ListVirtualMachineImageResource{
+				Value: &[]compute.VirtualMachineImageResource{
+					{Name: to.StringPtr("124.0.20220512")},
+					{Name: to.StringPtr("124.0.20221124")},
+					{Name: to.StringPtr("99.0.20230101")},
+				},
+			},
+			expectedVersion: "124.0.20221124",
+		},
+		{
+			name: "compares the version parts numerically",
+			versions: compute.ListVirtualMachineImageResource{
+				Value: &[]compute.VirtualMachineImageResource{
+					{Name: to.StringPtr("2022.9.1")},
+					{Name: to.StringPtr("2022.10.1")},
+				},
+			},
+			expectedVersion: "2022.10.1",
+		},
+		{
+			name:          "fails if there are no versions",
+			versions:      compute.ListVirtualMachineImageResource{Value: &[]compute.VirtualMachineImageResource{}},
+			expectedError: true,
+		},
+	}
+
+	location := "westeurope"
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockAuth := mock_azure.NewMockAuthorizer(mockCtrl)
+			mockAuth.EXPECT().HashKey().Return(t.Name()).AnyTimes()
+			mockAuth.EXPECT().Authorizer().AnyTimes()
+			mockAuth.EXPECT().BaseURI().AnyTimes()
+			mockAuth.EXPECT().SubscriptionID().AnyTimes()
+			mockClient := mock_virtualmachineimages.NewMockClient(mockCtrl)
+			svc := Service{Client: mockClient, Authorizer: mockAuth}
+
+			mockClient.EXPECT().
+				List(gomock.Any(), location, "my-publisher", "my-offer", "my-sku").
+				Return(test.versions, nil)
+			version, err := svc.GetLatestMarketplaceImageVersion(context.TODO(), location, "my-publisher", "my-offer", "my-sku")
+
+			g := NewWithT(t)
+			if test.expectedError {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(version).To(Equal(test.expectedVersion))
+		})
+	}
+}
@@ -17,9 +17,16 @@ limitations under the License.
 package azure
 
 import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 )
 
@@ -93,6 +100,9 @@ type AgentPoolSpec struct {
 	// MaxPods specifies the kubelet --max-pods configuration for the agent pool.
 	MaxPods *int32 `json:"maxPods,omitempty"`
 
+	// NetworkPlugin is the network plugin of the AKS cluster, which determines the bounds of MaxPods.
+	NetworkPlugin string `json:"networkPlugin,omitempty"`
+
 	// OsDiskType specifies the OS disk type for each node in the pool. Allowed values are 'Ephemeral' and 'Managed'.
 	OsDiskType *string `json:"osDiskType,omitempty"`
 
@@ -101,14 +111,160 @@ type AgentPoolSpec struct {
 
 	// OSType specifies the operating system for the node pool. Allowed values are 'Linux' and 'Windows'
 	OSType *string `json:"osType,omitempty"`
+
+	// KubeletConfig specifies the kubelet configuration for nodes in the agent pool.
+	KubeletConfig *KubeletConfig `json:"kubeletConfig,omitempty"`
+
+	// LinuxOSConfig specifies the OS configuration for Linux nodes in the agent pool.
+	LinuxOSConfig *LinuxOSConfig `json:"linuxOSConfig,omitempty"`
+
+	// ScaleSetPriority specifies the ScaleSetPriority for the node pool. Allowed values are 'Spot' and 'Regular'
+	ScaleSetPriority *string `json:"scaleSetPriority,omitempty"`
+
+	// SpotMaxPrice is the maximum price to pay for Spot instances of the node pool. -1 means up to the on-demand price.
+	SpotMaxPrice *float64 `json:"spotMaxPrice,omitempty"`
+
+	// EnableNodePublicIP specifies whether each node in the agent pool is allocated its own public IP.
+	EnableNodePublicIP *bool `json:"enableNodePublicIP,omitempty"`
+
+	// NodePublicIPPrefixID specifies the public IP prefix ID which the node public IPs are allocated from.
+	NodePublicIPPrefixID *string `json:"nodePublicIPPrefixID,omitempty"`
+
+	// MaxSurge specifies the maximum number or percentage of nodes that are surged during an upgrade.
+	MaxSurge string `json:"maxSurge,omitempty"`
+
+	// EnableFIPS enables the FIPS-compliant OS image for the nodes in the agent pool.
+	EnableFIPS *bool `json:"enableFIPS,omitempty"`
+
+	// OSSKU specifies the OS SKU of Linux nodes in the agent pool.
+	OSSKU string `json:"osSKU,omitempty"`
+
+	// AdditionalTags is an optional set of tags to add to the agent pool, merged from the cluster-wide and the agent pool
+	// tags.
+	AdditionalTags infrav1.Tags `json:"additionalTags,omitempty"`
+
+	// GPUInstanceProfile specifies the GPU MIG instance profile for supported GPU VM SKUs.
+	GPUInstanceProfile string `json:"gpuInstanceProfile,omitempty"`
+
+	// PodSubnetID is the ID of the subnet from which pod IPs are dynamically allocated.
+	PodSubnetID *string `json:"podSubnetID,omitempty"`
+
+	// CreationData is the source from which the agent pool is created.
+	CreationData *CreationData `json:"creationData,omitempty"`
+
+	// ScaleDownMode specifies whether nodes of the agent pool are deleted or deallocated on scale-down.
+	ScaleDownMode string `json:"scaleDownMode,omitempty"`
+
+	// PowerState is the desired power state of the agent pool.
+	PowerState string `json:"powerState,omitempty"`
+
+	// EnableEncryptionAtHost enables host-based encryption of the OS and temporary disks of the nodes in the agent pool.
+	EnableEncryptionAtHost *bool `json:"enableEncryptionAtHost,omitempty"`
+
+	// WorkloadRuntime specifies the type of workloads the nodes of the agent pool can run.
+	WorkloadRuntime string `json:"workloadRuntime,omitempty"`
+}
+
+// CreationData defines the source from which an agent pool is created.
+type CreationData struct {
+	// SourceResourceID is the resource ID of the node pool snapshot to create the agent pool from.
+	SourceResourceID string
+}
+
+// KubeletConfig defines the kubelet configuration for nodes in an agent pool.
+type KubeletConfig struct {
+	CPUManagerPolicy      *string
+	CPUCfsQuota           *bool
+	CPUCfsQuotaPeriod     *string
+	ImageGcHighThreshold  *int32
+	ImageGcLowThreshold   *int32
+	TopologyManagerPolicy *string
+	AllowedUnsafeSysctls  []string
+	FailSwapOn            *bool
+	ContainerLogMaxSizeMB *int32
+	ContainerLogMaxFiles  *int32
+	PodMaxPids            *int32
+}
+
+// LinuxOSConfig defines the OS configuration for Linux nodes in an agent pool.
+type LinuxOSConfig struct {
+	SwapFileSizeMB             *int32
+	Sysctls                    *SysctlConfig
+	TransparentHugePageDefrag  *string
+	TransparentHugePageEnabled *string
+}
+
+// SysctlConfig defines the sysctl settings for Linux nodes in an agent pool.
+type SysctlConfig struct {
+	FsFileMax                  *int32
+	FsInotifyMaxUserWatches    *int32
+	KernelThreadsMax           *int32
+	NetCoreSomaxconn           *int32
+	NetIpv4IPLocalPortRange    *string
+	NetIpv4TCPTwReuse          *bool
+	NetNetfilterNfConntrackMax *int32
+	VMMaxMapCount              *int32
+	VMSwappiness               *int32
+	VMVfsCachePressure         *int32
+}
+
+// MachinePoolOrchestrationMode defines how the machines of a machine pool are orchestrated.
+type MachinePoolOrchestrationMode string
+
+const (
+	// ScaleSetOrchestrationMode orchestrates the machines of a machine pool with a Virtual Machine Scale Set.
+	ScaleSetOrchestrationMode MachinePoolOrchestrationMode = "VirtualMachineScaleSet"
+	// AvailabilitySetOrchestrationMode orchestrates the machines of a machine pool as discrete Virtual Machines in an
+	// availability set.
+	AvailabilitySetOrchestrationMode MachinePoolOrchestrationMode = "AvailabilitySet"
+)
+
+// WindowsConfiguration defines the operating system settings of Windows Virtual Machines.
+type WindowsConfiguration struct {
+	TimeZone                  string
+	AdditionalUnattendContent []AdditionalUnattendContent
+	WinRMListeners            []WinRMListener
+}
+
+// WinRMListener defines a Windows Remote Management listener. The certificate of an Https listener is read from the
+// Azure Key Vault with the ID KeyVaultID.
+type WinRMListener struct {
+	Protocol       string
+	CertificateURL string
+	KeyVaultID     string
+}
+
+// AdditionalUnattendContent defines XML formatted content of a setting which is included in the Unattend.xml file
+// used by Windows Setup.
+type AdditionalUnattendContent struct {
+	SettingName string
+	Content     string
+}
+
+// VaultSecretGroup defines a set of certificates from the same Azure Key Vault which are installed on the Virtual
+// Machines.
+type VaultSecretGroup struct {
+	SourceVaultID     string
+	VaultCertificates []VaultCertificate
+}
+
+// VaultCertificate defines a certificate in Azure Key Vault. CertificateStore is the certificate store of Windows
+// Virtual Machines the certificate is added to, it is empty for Linux Virtual Machines.
+type VaultCertificate struct {
+	CertificateURL   string
+	CertificateStore string
 }
 
 // ScaleSetSpec defines the specification for a Scale Set.
 type ScaleSetSpec struct {
 	Name                         string
+	OrchestrationMode            MachinePoolOrchestrationMode
+	AvailabilitySetName          string
 	Size                         string
+	Tier                         string
 	Capacity                     int64
 	SSHKeyData                   string
+	SSHAuthorizedKeysPath        string
 	OSDisk                       infrav1.OSDisk
 	DataDisks                    []infrav1.DataDisk
 	SubnetName                   string
@@ -116,6 +272,8 @@ type ScaleSetSpec struct {
 	VNetResourceGroup            string
 	PublicLBName                 string
 	PublicLBAddressPoolName      string
+	InternalLBName               string
+	InternalLBAddressPoolName    string
 	AcceleratedNetworking        *bool
 	TerminateNotificationTimeout *int
 	Identity                     infrav1.VMIdentity
@@ -124,6 +282,50 @@ type ScaleSetSpec struct {
 	SpotVMOptions                *infrav1.SpotVMOptions
 	AdditionalCapabilities       *infrav1.AdditionalCapabilities
 	FailureDomains               []string
+	SinglePlacementGroup         *bool
+	PlatformFaultDomainCount     *int32
+	WindowsConfiguration         *WindowsConfiguration
+	Secrets                      []VaultSecretGroup
+	// DisablePasswordAuthentication disables password authentication of the admin user of Linux scale sets. Defaults to
+	// true if nil. With password authentication enabled, the admin user gets the password of the secret referenced by
+	// the AzureMachinePool when the scale set is created.
+	DisablePasswordAuthentication *bool
+	// ReplicasManagedExternally is true if an external autoscaler owns the capacity of the scale set.
+	ReplicasManagedExternally bool
+	// LoadBalancerInboundNatPools are the inbound NAT pools of load balancers the instances are attached to, e.g. to
+	// reach each instance via SSH.
+	LoadBalancerInboundNatPools []InboundNatPoolSpec
+	// DNSServers are the IP addresses of the DNS servers of the network interfaces of the instances. The DNS servers of
+	// the virtual network are used if empty.
+	DNSServers []string
+	// Role is the value of the role tag of the scale set, e.g. to distinguish special-purpose pools. Defaults to node.
+	Role string
+	// VerifyImagePlanTerms verifies that the terms of the marketplace image plan have been accepted before creating the
+	// scale set.
+	VerifyImagePlanTerms bool
+	// OperationTimeout is the maximum age of a long running operation on the scale set before it is treated as failed.
+	// A default timeout is used if zero.
+	OperationTimeout time.Duration
+	// CheckQuotaBeforeSurge caps the surge of the scale set to the number of instances the regional vCPU quotas of the
+	// subscription allow for.
+	CheckQuotaBeforeSurge bool
+	// RollOnBootstrapDataChanges treats changes of the bootstrap data as changes of the scale set model, which rolls the
+	// instances.
+	RollOnBootstrapDataChanges bool
+	// BootstrapDataHash is the hash of the bootstrap data last applied to the scale set, or empty if none is recorded.
+	BootstrapDataHash string
+	// AutoAssignDataDiskLuns assigns the lowest unused LUN to data disks without one instead of requiring it.
+	AutoAssignDataDiskLuns bool
+	// ResourceGroup is the resource group of the scale set. Defaults to the resource group of the cluster if empty.
+	ResourceGroup string
+}
+
+// InboundNatPoolSpec references an inbound NAT pool of a load balancer.
+type InboundNatPoolSpec struct {
+	// LoadBalancerName is the name of the load balancer the inbound NAT pool belongs to.
+	LoadBalancerName string
+	// Name is the name of the inbound NAT pool.
+	Name string
 }
 
 // TagsSpec defines the specification for a set of tags.
@@ -150,17 +352,22 @@ type (
 	VMSSVM struct {
 		ID               string                    `json:"id,omitempty"`
 		InstanceID       string                    `json:"instanceID,omitempty"`
+		VMID             string                    `json:"vmID,omitempty"`
 		Image            infrav1.Image             `json:"image,omitempty"`
 		Name             string                    `json:"name,omitempty"`
 		AvailabilityZone string                    `json:"availabilityZone,omitempty"`
 		State            infrav1.ProvisioningState `json:"vmState,omitempty"`
+		// ExtensionStates are the provisioning states of the extensions of the instance by extension name.
+		ExtensionStates map[string]infrav1.ProvisioningState `json:"extensionStates,omitempty"`
 	}
 
 	// VMSS defines a virtual machine scale set.
 	VMSS struct {
 		ID        string                    `json:"id,omitempty"`
+		UniqueID  string                    `json:"uniqueID,omitempty"`
 		Name      string                    `json:"name,omitempty"`
 		Sku       string                    `json:"sku,omitempty"`
+		Tier      string                    `json:"tier,omitempty"`
 		Capacity  int64                     `json:"capacity,omitempty"`
 		Zones     []string                  `json:"zones,omitempty"`
 		Image     infrav1.Image             `json:"image,omitempty"`
@@ -168,19 +375,90 @@ type (
 		Identity  infrav1.VMIdentity        `json:"identity,omitempty"`
 		Tags      infrav1.Tags              `json:"tags,omitempty"`
 		Instances []VMSSVM                  `json:"instances,omitempty"`
+		// DNSServers are the DNS servers of the network interfaces of the instances.
+		DNSServers []string `json:"dnsServers,omitempty"`
+		// UserAssignedIdentities are the resource IDs of the user-assigned identities of the VMSS.
+		UserAssignedIdentities []string `json:"userAssignedIdentities,omitempty"`
+		// ExtensionForceUpdateTags are the force update tags of the extensions of the VMSS by extension name.
+		ExtensionForceUpdateTags map[string]string `json:"extensionForceUpdateTags,omitempty"`
 	}
 )
 
-// HasModelChanges returns true if the spec fields which will mutate the Azure VMSS model are different.
+// HasModelChanges returns true if the spec fields which will mutate the Azure VMSS model are different. The other VMSS
+// is the desired one.
 func (vmss VMSS) HasModelChanges(other VMSS) bool {
 	equal := cmp.Equal(vmss.Image, other.Image) &&
 		cmp.Equal(vmss.Identity, other.Identity) &&
+		cmp.Equal(normalizedResourceIDs(vmss.UserAssignedIdentities), normalizedResourceIDs(other.UserAssignedIdentities)) &&
 		cmp.Equal(vmss.Zones, other.Zones) &&
 		cmp.Equal(vmss.Tags, other.Tags) &&
-		cmp.Equal(vmss.Sku, other.Sku)
+		cmp.Equal(vmss.Sku, other.Sku) &&
+		// Azure may normalize the casing of the tier, so it is compared case-insensitively.
+		strings.EqualFold(vmss.Tier, other.Tier) &&
+		!hasExtensionForceUpdateTagChanges(vmss.ExtensionForceUpdateTags, other.ExtensionForceUpdateTags)
 	return !equal
 }
 
+// hasExtensionForceUpdateTagChanges returns true if any of the desired force update tags differs from the current one,
+// e.g. because the protected settings of the extension changed. Extensions which are not desired anymore are ignored,
+// as patching the VMSS does not remove them.
+func hasExtensionForceUpdateTagChanges(current, desired map[string]string) bool {
+	for name, tag := range desired {
+		if current[name] != tag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizedResourceIDs returns the resource IDs in lower case and sorted, since Azure may change the casing of the
+// resource IDs and doesn't preserve their order. It returns nil if there are no resource IDs.
+func normalizedResourceIDs(ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(ids))
+	for i, id := range ids {
+		normalized[i] = strings.ToLower(id)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// ModelID returns an identifier of the VMSS model derived from the spec fields compared by HasModelChanges, so it
+// changes whenever the model of the VMSS changes.
+func (vmss VMSS) ModelID() (string, error) {
+	// json.Marshal sorts the map keys, which keeps the identifier stable across reconciles.
+	model, err := json.Marshal(struct {
+		Image                    infrav1.Image
+		Identity                 infrav1.VMIdentity
+		UserAssignedIdentities   []string
+		Zones                    []string
+		Tags                     infrav1.Tags
+		Sku                      string
+		Tier                     string
+		ExtensionForceUpdateTags map[string]string
+	}{
+		Image:                    vmss.Image,
+		Identity:                 vmss.Identity,
+		UserAssignedIdentities:   normalizedResourceIDs(vmss.UserAssignedIdentities),
+		Zones:                    vmss.Zones,
+		Tags:                     vmss.Tags,
+		Sku:                      vmss.Sku,
+		Tier:                     strings.ToLower(vmss.Tier),
+		ExtensionForceUpdateTags: vmss.ExtensionForceUpdateTags,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal VMSS model")
+	}
+
+	h := fnv.New32a()
+	if _, err := h.Write(model); err != nil {
+		return "", errors.Wrap(err, "failed to hash VMSS model")
+	}
+	return fmt.Sprintf("%x", h.Sum32()), nil
+}
+
 // InstancesByProviderID returns VMSSVMs by ID.
 func (vmss VMSS) InstancesByProviderID() map[string]VMSSVM {
 	instancesByProviderID := make(map[string]VMSSVM, len(vmss.Instances))
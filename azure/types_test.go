@@ -72,6 +72,26 @@ func TestVMSS_HasModelChanges(t *testing.T) {
 			},
 			HasModelChanges: false,
 		},
+		{
+			Name: "with different tier",
+			Factory: func() (VMSS, VMSS) {
+				l := getDefaultVMSSForModelTesting()
+				l.Tier = "Basic"
+				r := getDefaultVMSSForModelTesting()
+				return r, l
+			},
+			HasModelChanges: true,
+		},
+		{
+			Name: "with tier normalized by Azure",
+			Factory: func() (VMSS, VMSS) {
+				l := getDefaultVMSSForModelTesting()
+				l.Tier = "standard"
+				r := getDefaultVMSSForModelTesting()
+				return r, l
+			},
+			HasModelChanges: false,
+		},
 		{
 			Name: "with different identity",
 			Factory: func() (VMSS, VMSS) {
@@ -82,6 +102,37 @@ func TestVMSS_HasModelChanges(t *testing.T) {
 			},
 			HasModelChanges: true,
 		},
+		{
+			Name: "with different user-assigned identities",
+			Factory: func() (VMSS, VMSS) {
+				l := getDefaultVMSSForModelTesting()
+				l.UserAssignedIdentities = []string{"/subscriptions/123/resourceGroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"}
+				r := getDefaultVMSSForModelTesting()
+				r.UserAssignedIdentities = []string{
+					"/subscriptions/123/resourceGroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+					"/subscriptions/123/resourceGroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2",
+				}
+				return r, l
+			},
+			HasModelChanges: true,
+		},
+		{
+			Name: "with user-assigned identities in a different order and casing",
+			Factory: func() (VMSS, VMSS) {
+				l := getDefaultVMSSForModelTesting()
+				l.UserAssignedIdentities = []string{
+					"/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2",
+					"/subscriptions/123/resourcegroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+				}
+				r := getDefaultVMSSForModelTesting()
+				r.UserAssignedIdentities = []string{
+					"/subscriptions/123/resourceGroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+					"/subscriptions/123/resourceGroups/456/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2",
+				}
+				return r, l
+			},
+			HasModelChanges: false,
+		},
 		{
 			Name: "with different Zones",
 			Factory: func() (VMSS, VMSS) {
@@ -136,6 +187,41 @@ func TestVMSS_HasModelChanges(t *testing.T) {
 			},
 			HasModelChanges: true,
 		},
+		{
+			Name: "with a different extension force update tag",
+			Factory: func() (VMSS, VMSS) {
+				current := getDefaultVMSSForModelTesting()
+				current.ExtensionForceUpdateTags = map[string]string{"CAPZ.Linux.Bootstrapping": "e66050ff"}
+				desired := getDefaultVMSSForModelTesting()
+				desired.ExtensionForceUpdateTags = map[string]string{"CAPZ.Linux.Bootstrapping": "b4d2e2b1"}
+				return current, desired
+			},
+			HasModelChanges: true,
+		},
+		{
+			Name: "with a new extension force update tag",
+			Factory: func() (VMSS, VMSS) {
+				current := getDefaultVMSSForModelTesting()
+				desired := getDefaultVMSSForModelTesting()
+				desired.ExtensionForceUpdateTags = map[string]string{"CAPZ.Linux.Bootstrapping": "e66050ff"}
+				return current, desired
+			},
+			HasModelChanges: true,
+		},
+		{
+			Name: "with the force update tag of an extension which is not desired anymore",
+			Factory: func() (VMSS, VMSS) {
+				current := getDefaultVMSSForModelTesting()
+				current.ExtensionForceUpdateTags = map[string]string{
+					"CAPZ.Linux.Bootstrapping": "e66050ff",
+					"custom-extension":         "1",
+				}
+				desired := getDefaultVMSSForModelTesting()
+				desired.ExtensionForceUpdateTags = map[string]string{"CAPZ.Linux.Bootstrapping": "e66050ff"}
+				return current, desired
+			},
+			HasModelChanges: false,
+		},
 	}
 
 	for _, c := range cases {
@@ -148,6 +234,38 @@ func TestVMSS_HasModelChanges(t *testing.T) {
 	}
 }
 
+func TestVMSS_ModelID(t *testing.T) {
+	g := NewWithT(t)
+
+	vmss := getDefaultVMSSForModelTesting()
+	modelID, err := vmss.ModelID()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(modelID).NotTo(BeEmpty())
+
+	// fields which are not part of the model don't change the model ID
+	scaled := getDefaultVMSSForModelTesting()
+	scaled.UniqueID = "unique-id"
+	scaled.Capacity = 3
+	scaled.State = infrav1.Updating
+	scaled.Instances = []VMSSVM{{InstanceID: "0"}}
+	scaled.Tier = "standard"
+	scaledModelID, err := scaled.ModelID()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(scaledModelID).To(Equal(modelID))
+
+	updated := getDefaultVMSSForModelTesting()
+	updated.Image.Marketplace.Version = "bar"
+	updatedModelID, err := updated.ModelID()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(updatedModelID).NotTo(Equal(modelID))
+
+	rotated := getDefaultVMSSForModelTesting()
+	rotated.ExtensionForceUpdateTags = map[string]string{"CAPZ.Linux.Bootstrapping": "b4d2e2b1"}
+	rotatedModelID, err := rotated.ModelID()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rotatedModelID).NotTo(Equal(modelID))
+}
+
 func getDefaultVMSSForModelTesting() VMSS {
 	return VMSS{
 		Zones: []string{"0", "1"},
@@ -157,6 +275,7 @@ func getDefaultVMSSForModelTesting() VMSS {
 			},
 		},
 		Sku:      "reallyBigVM",
+		Tier:     "Standard",
 		Identity: infrav1.VMIdentitySystemAssigned,
 		Tags: infrav1.Tags{
 			"foo": "baz",
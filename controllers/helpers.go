@@ -182,7 +182,8 @@ func GetCloudProviderSecret(d azure.ClusterScoper, namespace, name string, owner
 	var controlPlaneConfig, workerNodeConfig *CloudProviderConfig
 
 	switch identityType {
-	case infrav1.VMIdentitySystemAssigned:
+	case infrav1.VMIdentitySystemAssigned, infrav1.VMIdentitySystemAndUserAssigned:
+		// The cloud provider uses the system-assigned identity, which is the identity granted a role by CAPZ.
 		controlPlaneConfig, workerNodeConfig = systemAssignedIdentityCloudProviderConfig(d)
 	case infrav1.VMIdentityUserAssigned:
 		if len(userIdentityID) < 1 {
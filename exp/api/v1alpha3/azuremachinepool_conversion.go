@@ -62,9 +62,31 @@ func (src *AzureMachinePool) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.NodeDrainTimeout = restored.Spec.NodeDrainTimeout
 	}
 
+	dst.Spec.OutboundLBDisabled = restored.Spec.OutboundLBDisabled
+	dst.Spec.OrchestrationMode = restored.Spec.OrchestrationMode
+	dst.Spec.ForceDeleteInstances = restored.Spec.ForceDeleteInstances
+	dst.Spec.PlatformFaultDomainCount = restored.Spec.PlatformFaultDomainCount
+	dst.Spec.BootstrapFailureGracePeriod = restored.Spec.BootstrapFailureGracePeriod
+	dst.Spec.BootstrapExtension = restored.Spec.BootstrapExtension
+	dst.Spec.SkipBootstrapExtension = restored.Spec.SkipBootstrapExtension
+	dst.Spec.ResourceGroup = restored.Spec.ResourceGroup
+	dst.Spec.RoleAssignmentScope = restored.Spec.RoleAssignmentScope
+	dst.Spec.RoleDefinitionID = restored.Spec.RoleDefinitionID
+	dst.Spec.Template.SSHAuthorizedKeysPath = restored.Spec.Template.SSHAuthorizedKeysPath
+	dst.Spec.Template.WindowsConfiguration = restored.Spec.Template.WindowsConfiguration
+	dst.Spec.Template.Secrets = restored.Spec.Template.Secrets
+	dst.Spec.Template.AdditionalCloudInit = restored.Spec.Template.AdditionalCloudInit
+	dst.Spec.Template.LinuxConfiguration = restored.Spec.Template.LinuxConfiguration
+	restoreDataDisksPerformance(dst.Spec.Template.DataDisks, restored.Spec.Template.DataDisks)
+
 	if restored.Status.Image != nil {
 		dst.Status.Image = restored.Status.Image
 	}
+	dst.Status.UpdatedReplicas = restored.Status.UpdatedReplicas
+	dst.Status.OutdatedReplicas = restored.Status.OutdatedReplicas
+	dst.Status.UniqueID = restored.Status.UniqueID
+	dst.Status.LatestModelID = restored.Status.LatestModelID
+	dst.Status.BootstrapFailedSince = restored.Status.BootstrapFailedSince
 
 	if restored.Spec.Template.Image != nil && restored.Spec.Template.Image.SharedGallery != nil {
 		dst.Spec.Template.Image.SharedGallery.Offer = restored.Spec.Template.Image.SharedGallery.Offer
@@ -142,3 +164,19 @@ func (dst *AzureMachinePoolList) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*expv1beta1.AzureMachinePoolList)
 	return Convert_v1beta1_AzureMachinePoolList_To_v1alpha3_AzureMachinePoolList(src, dst, nil)
 }
+
+// restoreDataDisksPerformance restores the performance settings of the managed data disks, which don't exist in this
+// version, from the matching data disks of the restored Hub version.
+func restoreDataDisksPerformance(dst, restored []infrav1beta1.DataDisk) {
+	for i := range dst {
+		if dst[i].ManagedDisk == nil {
+			continue
+		}
+		for _, disk := range restored {
+			if disk.NameSuffix == dst[i].NameSuffix && disk.ManagedDisk != nil {
+				dst[i].ManagedDisk.DiskIOPSReadWrite = disk.ManagedDisk.DiskIOPSReadWrite
+				dst[i].ManagedDisk.DiskMBpsReadWrite = disk.ManagedDisk.DiskMBpsReadWrite
+			}
+		}
+	}
+}
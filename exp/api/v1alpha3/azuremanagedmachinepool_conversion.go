@@ -45,9 +45,29 @@ func (src *AzureManagedMachinePool) ConvertTo(dstRaw conversion.Hub) error {
 	dst.Spec.OSType = restored.Spec.OSType
 	dst.Spec.NodeLabels = restored.Spec.NodeLabels
 	dst.Spec.EnableUltraSSD = restored.Spec.EnableUltraSSD
+	dst.Spec.KubeletConfig = restored.Spec.KubeletConfig
+	dst.Spec.LinuxOSConfig = restored.Spec.LinuxOSConfig
+	dst.Spec.ScaleSetPriority = restored.Spec.ScaleSetPriority
+	dst.Spec.SpotMaxPrice = restored.Spec.SpotMaxPrice
+	dst.Spec.EnableNodePublicIP = restored.Spec.EnableNodePublicIP
+	dst.Spec.NodePublicIPPrefixID = restored.Spec.NodePublicIPPrefixID
+	dst.Spec.MaxSurge = restored.Spec.MaxSurge
+	dst.Spec.EnableFIPS = restored.Spec.EnableFIPS
+	dst.Spec.OSSKU = restored.Spec.OSSKU
+	dst.Spec.AdditionalTags = restored.Spec.AdditionalTags
+	dst.Spec.GPUInstanceProfile = restored.Spec.GPUInstanceProfile
+	dst.Spec.PodSubnetName = restored.Spec.PodSubnetName
+	dst.Spec.SnapshotID = restored.Spec.SnapshotID
+	dst.Spec.ScaleDownMode = restored.Spec.ScaleDownMode
+	dst.Spec.PowerState = restored.Spec.PowerState
+	dst.Spec.EnableEncryptionAtHost = restored.Spec.EnableEncryptionAtHost
+	dst.Spec.WorkloadRuntime = restored.Spec.WorkloadRuntime
 
 	dst.Status.LongRunningOperationStates = restored.Status.LongRunningOperationStates
 	dst.Status.Conditions = restored.Status.Conditions
+	dst.Status.NodeImageVersion = restored.Status.NodeImageVersion
+	dst.Status.ProvisioningState = restored.Status.ProvisioningState
+	dst.Status.PowerState = restored.Status.PowerState
 
 	return nil
 }
@@ -34,6 +34,16 @@ func Convert_v1beta1_OSDisk_To_v1alpha3_OSDisk(in *v1beta1.OSDisk, out *v1alpha3
 	return v1alpha3.Convert_v1beta1_OSDisk_To_v1alpha3_OSDisk(in, out, s)
 }
 
+// Convert_v1alpha3_DataDisk_To_v1beta1_DataDisk is a conversion function.
+func Convert_v1alpha3_DataDisk_To_v1beta1_DataDisk(in *v1alpha3.DataDisk, out *v1beta1.DataDisk, s conversion.Scope) error {
+	return v1alpha3.Convert_v1alpha3_DataDisk_To_v1beta1_DataDisk(in, out, s)
+}
+
+// Convert_v1beta1_DataDisk_To_v1alpha3_DataDisk is a conversion function.
+func Convert_v1beta1_DataDisk_To_v1alpha3_DataDisk(in *v1beta1.DataDisk, out *v1alpha3.DataDisk, s conversion.Scope) error {
+	return v1alpha3.Convert_v1beta1_DataDisk_To_v1alpha3_DataDisk(in, out, s)
+}
+
 // Convert_v1alpha3_Image_To_v1beta1_Image is a conversion function.
 func Convert_v1alpha3_Image_To_v1beta1_Image(in *v1alpha3.Image, out *v1beta1.Image, s conversion.Scope) error {
 	return v1alpha3.Convert_v1alpha3_Image_To_v1beta1_Image(in, out, s)
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha4
 
 import (
+	apiconversion "k8s.io/apimachinery/pkg/conversion"
+	infrav1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	expv1beta1 "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
@@ -43,6 +45,28 @@ func (src *AzureMachinePool) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Status.Image.ComputeGallery = restored.Status.Image.ComputeGallery
 	}
 
+	dst.Spec.OutboundLBDisabled = restored.Spec.OutboundLBDisabled
+	dst.Spec.OrchestrationMode = restored.Spec.OrchestrationMode
+	dst.Spec.ForceDeleteInstances = restored.Spec.ForceDeleteInstances
+	dst.Spec.PlatformFaultDomainCount = restored.Spec.PlatformFaultDomainCount
+	dst.Spec.BootstrapFailureGracePeriod = restored.Spec.BootstrapFailureGracePeriod
+	dst.Spec.BootstrapExtension = restored.Spec.BootstrapExtension
+	dst.Spec.SkipBootstrapExtension = restored.Spec.SkipBootstrapExtension
+	dst.Spec.ResourceGroup = restored.Spec.ResourceGroup
+	dst.Spec.RoleAssignmentScope = restored.Spec.RoleAssignmentScope
+	dst.Spec.RoleDefinitionID = restored.Spec.RoleDefinitionID
+	dst.Spec.Template.SSHAuthorizedKeysPath = restored.Spec.Template.SSHAuthorizedKeysPath
+	dst.Spec.Template.WindowsConfiguration = restored.Spec.Template.WindowsConfiguration
+	dst.Spec.Template.Secrets = restored.Spec.Template.Secrets
+	dst.Spec.Template.AdditionalCloudInit = restored.Spec.Template.AdditionalCloudInit
+	dst.Spec.Template.LinuxConfiguration = restored.Spec.Template.LinuxConfiguration
+	restoreDataDisksPerformance(dst.Spec.Template.DataDisks, restored.Spec.Template.DataDisks)
+	dst.Status.UpdatedReplicas = restored.Status.UpdatedReplicas
+	dst.Status.OutdatedReplicas = restored.Status.OutdatedReplicas
+	dst.Status.UniqueID = restored.Status.UniqueID
+	dst.Status.LatestModelID = restored.Status.LatestModelID
+	dst.Status.BootstrapFailedSince = restored.Status.BootstrapFailedSince
+
 	return nil
 }
 
@@ -57,6 +81,21 @@ func (dst *AzureMachinePool) ConvertFrom(srcRaw conversion.Hub) error {
 	return utilconversion.MarshalData(src, dst)
 }
 
+// Convert_v1beta1_AzureMachinePoolMachineTemplate_To_v1alpha4_AzureMachinePoolMachineTemplate is an autogenerated conversion function.
+func Convert_v1beta1_AzureMachinePoolMachineTemplate_To_v1alpha4_AzureMachinePoolMachineTemplate(in *expv1beta1.AzureMachinePoolMachineTemplate, out *AzureMachinePoolMachineTemplate, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_AzureMachinePoolMachineTemplate_To_v1alpha4_AzureMachinePoolMachineTemplate(in, out, s)
+}
+
+// Convert_v1beta1_AzureMachinePoolSpec_To_v1alpha4_AzureMachinePoolSpec is an autogenerated conversion function.
+func Convert_v1beta1_AzureMachinePoolSpec_To_v1alpha4_AzureMachinePoolSpec(in *expv1beta1.AzureMachinePoolSpec, out *AzureMachinePoolSpec, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_AzureMachinePoolSpec_To_v1alpha4_AzureMachinePoolSpec(in, out, s)
+}
+
+// Convert_v1beta1_AzureMachinePoolStatus_To_v1alpha4_AzureMachinePoolStatus is an autogenerated conversion function.
+func Convert_v1beta1_AzureMachinePoolStatus_To_v1alpha4_AzureMachinePoolStatus(in *expv1beta1.AzureMachinePoolStatus, out *AzureMachinePoolStatus, s apiconversion.Scope) error {
+	return autoConvert_v1beta1_AzureMachinePoolStatus_To_v1alpha4_AzureMachinePoolStatus(in, out, s)
+}
+
 // ConvertTo converts this AzureMachinePool to the Hub version (v1beta1).
 func (src *AzureMachinePoolList) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*expv1beta1.AzureMachinePoolList)
@@ -68,3 +107,19 @@ func (dst *AzureMachinePoolList) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*expv1beta1.AzureMachinePoolList)
 	return Convert_v1beta1_AzureMachinePoolList_To_v1alpha4_AzureMachinePoolList(src, dst, nil)
 }
+
+// restoreDataDisksPerformance restores the performance settings of the managed data disks, which don't exist in this
+// version, from the matching data disks of the restored Hub version.
+func restoreDataDisksPerformance(dst, restored []infrav1beta1.DataDisk) {
+	for i := range dst {
+		if dst[i].ManagedDisk == nil {
+			continue
+		}
+		for _, disk := range restored {
+			if disk.NameSuffix == dst[i].NameSuffix && disk.ManagedDisk != nil {
+				dst[i].ManagedDisk.DiskIOPSReadWrite = disk.ManagedDisk.DiskIOPSReadWrite
+				dst[i].ManagedDisk.DiskMBpsReadWrite = disk.ManagedDisk.DiskMBpsReadWrite
+			}
+		}
+	}
+}
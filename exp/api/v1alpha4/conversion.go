@@ -34,6 +34,16 @@ func Convert_v1beta1_OSDisk_To_v1alpha4_OSDisk(in *v1beta1.OSDisk, out *v1alpha4
 	return v1alpha4.Convert_v1beta1_OSDisk_To_v1alpha4_OSDisk(in, out, s)
 }
 
+// Convert_v1alpha4_DataDisk_To_v1beta1_DataDisk is a conversion function.
+func Convert_v1alpha4_DataDisk_To_v1beta1_DataDisk(in *v1alpha4.DataDisk, out *v1beta1.DataDisk, s conversion.Scope) error {
+	return v1alpha4.Convert_v1alpha4_DataDisk_To_v1beta1_DataDisk(in, out, s)
+}
+
+// Convert_v1beta1_DataDisk_To_v1alpha4_DataDisk is a conversion function.
+func Convert_v1beta1_DataDisk_To_v1alpha4_DataDisk(in *v1beta1.DataDisk, out *v1alpha4.DataDisk, s conversion.Scope) error {
+	return v1alpha4.Convert_v1beta1_DataDisk_To_v1alpha4_DataDisk(in, out, s)
+}
+
 // Convert_v1alpha4_Image_To_v1beta1_Image is a conversion function.
 func Convert_v1alpha4_Image_To_v1beta1_Image(in *v1alpha4.Image, out *v1beta1.Image, s conversion.Scope) error {
 	return v1alpha4.Convert_v1alpha4_Image_To_v1beta1_Image(in, out, s)
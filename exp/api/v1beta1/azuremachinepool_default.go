@@ -40,7 +40,7 @@ func (amp *AzureMachinePool) SetDefaultSSHPublicKey() error {
 
 // SetIdentityDefaults sets the defaults for VMSS Identity.
 func (amp *AzureMachinePool) SetIdentityDefaults() {
-	if amp.Spec.Identity == infrav1.VMIdentitySystemAssigned {
+	if amp.Spec.Identity == infrav1.VMIdentitySystemAssigned || amp.Spec.Identity == infrav1.VMIdentitySystemAndUserAssigned {
 		if amp.Spec.RoleAssignmentName == "" {
 			amp.Spec.RoleAssignmentName = string(uuid.NewUUID())
 		}
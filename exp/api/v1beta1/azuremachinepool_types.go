@@ -39,6 +39,23 @@ const (
 	NewestDeletePolicyType AzureMachinePoolDeletePolicyType = "Newest"
 	// RandomDeletePolicyType will delete machines in random order.
 	RandomDeletePolicyType AzureMachinePoolDeletePolicyType = "Random"
+
+	// VirtualMachineScaleSetOrchestrationMode orchestrates the machines of an AzureMachinePool with a Virtual Machine
+	// Scale Set.
+	VirtualMachineScaleSetOrchestrationMode AzureMachinePoolOrchestrationMode = "VirtualMachineScaleSet"
+	// AvailabilitySetOrchestrationMode orchestrates the machines of an AzureMachinePool as discrete Virtual Machines in
+	// an availability set.
+	AvailabilitySetOrchestrationMode AzureMachinePoolOrchestrationMode = "AvailabilitySet"
+
+	// WinRMProtocolHTTP is the Http protocol of a Windows Remote Management listener.
+	WinRMProtocolHTTP WinRMProtocol = "Http"
+	// WinRMProtocolHTTPS is the Https protocol of a Windows Remote Management listener.
+	WinRMProtocolHTTPS WinRMProtocol = "Https"
+
+	// CloudInitContentTypeCloudConfig is the content type of a cloud-config cloud-init fragment.
+	CloudInitContentTypeCloudConfig CloudInitContentType = "text/cloud-config"
+	// CloudInitContentTypeShellScript is the content type of a shell script cloud-init fragment.
+	CloudInitContentTypeShellScript CloudInitContentType = "text/x-shellscript"
 )
 
 type (
@@ -65,6 +82,12 @@ type (
 		// SSHPublicKey is the SSH public key string base64 encoded to add to a Virtual Machine
 		SSHPublicKey string `json:"sshPublicKey"`
 
+		// SSHAuthorizedKeysPath is the absolute path of the authorized_keys file the SSH public key is written to on
+		// Linux Virtual Machines, for images which don't use the home directory of the admin user.
+		// Defaults to /home/capi/.ssh/authorized_keys.
+		// +optional
+		SSHAuthorizedKeysPath string `json:"sshAuthorizedKeysPath,omitempty"`
+
 		// AcceleratedNetworking enables or disables Azure accelerated networking. If omitted, it will be set based on
 		// whether the requested VMSize supports accelerated networking.
 		// If AcceleratedNetworking is set to true with a VMSize that does not support it, Azure will return an error.
@@ -87,6 +110,138 @@ type (
 		// SubnetName selects the Subnet where the VMSS will be placed
 		// +optional
 		SubnetName string `json:"subnetName,omitempty"`
+
+		// WindowsConfiguration specifies operating system settings of Windows Virtual Machines. It is ignored for
+		// Linux Virtual Machines.
+		// +optional
+		WindowsConfiguration *WindowsConfiguration `json:"windowsConfiguration,omitempty"`
+
+		// Secrets is the list of certificates from Azure Key Vault which are installed on the Virtual Machines.
+		// +optional
+		Secrets []VaultSecretGroup `json:"secrets,omitempty"`
+
+		// AdditionalCloudInit is a list of cloud-init fragments which are merged with the bootstrap data of the
+		// Virtual Machines into a MIME multipart archive. The bootstrap data must be a cloud-config or a shell script.
+		// +optional
+		AdditionalCloudInit []CloudInitFragment `json:"additionalCloudInit,omitempty"`
+
+		// LinuxConfiguration specifies operating system settings of Linux Virtual Machines. It is ignored for
+		// Windows Virtual Machines.
+		// +optional
+		LinuxConfiguration *LinuxConfiguration `json:"linuxConfiguration,omitempty"`
+	}
+
+	// CloudInitFragment is a cloud-init fragment which is merged with the bootstrap data of Virtual Machines.
+	CloudInitFragment struct {
+		// ContentType is the MIME content type of the fragment.
+		// +kubebuilder:validation:Enum=text/cloud-config;text/x-shellscript
+		ContentType CloudInitContentType `json:"contentType"`
+
+		// MergeType controls how cloud-init merges a cloud-config fragment with the preceding cloud-config, see
+		// https://cloudinit.readthedocs.io/en/latest/topics/merging.html. Defaults to
+		// "list(append)+dict(no_replace,recurse_list)+str()", which appends lists such as runcmd instead of
+		// replacing them. It must not be set for shell script fragments.
+		// +optional
+		MergeType string `json:"mergeType,omitempty"`
+
+		// Content is the content of the fragment.
+		// +kubebuilder:validation:MinLength=1
+		Content string `json:"content"`
+	}
+
+	// CloudInitContentType is the MIME content type of a cloud-init fragment.
+	CloudInitContentType string
+
+	// VaultSecretGroup specifies a set of certificates from the same Azure Key Vault.
+	VaultSecretGroup struct {
+		// SourceVaultID is the resource ID of the Azure Key Vault containing the certificates.
+		SourceVaultID string `json:"sourceVaultID"`
+
+		// VaultCertificates is the list of certificates in the Azure Key Vault.
+		VaultCertificates []VaultCertificate `json:"vaultCertificates"`
+	}
+
+	// VaultCertificate specifies a certificate in Azure Key Vault.
+	VaultCertificate struct {
+		// CertificateURL is the URL of the certificate in Azure Key Vault, e.g.
+		// https://myvault.vault.azure.net/secrets/mycert/<version>.
+		CertificateURL string `json:"certificateURL"`
+
+		// CertificateStore is the certificate store of Windows Virtual Machines the certificate is added to, e.g. My.
+		// It is required for Windows and must not be set for Linux Virtual Machines, where the certificate is placed
+		// in /var/lib/waagent as <UppercaseThumbprint>.crt with the private key in <UppercaseThumbprint>.prv.
+		// +optional
+		CertificateStore string `json:"certificateStore,omitempty"`
+	}
+
+	// LinuxConfiguration specifies operating system settings of Linux Virtual Machines.
+	LinuxConfiguration struct {
+		// DisablePasswordAuthentication disables the password authentication of the admin user. Defaults to true.
+		// Images which require a password, e.g. for their serial console, need password authentication enabled.
+		// +optional
+		DisablePasswordAuthentication *bool `json:"disablePasswordAuthentication,omitempty"`
+
+		// AdminPasswordSecretName is the name of a secret in the namespace of the AzureMachinePool whose "password"
+		// key holds the password of the admin user. It is required if password authentication is enabled. The
+		// password is set when the Virtual Machine Scale Set is created and isn't updated afterwards.
+		// +optional
+		AdminPasswordSecretName string `json:"adminPasswordSecretName,omitempty"`
+	}
+
+	// WindowsConfiguration specifies operating system settings of Windows Virtual Machines.
+	WindowsConfiguration struct {
+		// TimeZone is the time zone of the Virtual Machines, e.g. "Pacific Standard Time". Possible values are the
+		// IDs of the time zones returned by TimeZoneInfo.GetSystemTimeZones on Windows.
+		// +optional
+		TimeZone string `json:"timeZone,omitempty"`
+
+		// AdditionalUnattendContent is additional XML formatted information which is included in the Unattend.xml
+		// file used by Windows Setup.
+		// +optional
+		AdditionalUnattendContent []AdditionalUnattendContent `json:"additionalUnattendContent,omitempty"`
+
+		// WinRM configures the Windows Remote Management listeners of the Virtual Machines.
+		// +optional
+		WinRM *WinRMConfiguration `json:"winRM,omitempty"`
+	}
+
+	// WinRMConfiguration specifies the Windows Remote Management configuration of Windows Virtual Machines.
+	WinRMConfiguration struct {
+		// Listeners is the list of Windows Remote Management listeners.
+		// +optional
+		Listeners []WinRMListener `json:"listeners,omitempty"`
+	}
+
+	// WinRMListener specifies a Windows Remote Management listener.
+	WinRMListener struct {
+		// Protocol is the protocol of the listener.
+		// +kubebuilder:validation:Enum=Http;Https
+		Protocol WinRMProtocol `json:"protocol"`
+
+		// CertificateURL is the URL of the certificate in Azure Key Vault used by an Https listener, e.g.
+		// https://myvault.vault.azure.net/secrets/mycert/<version>. Required for Https listeners.
+		// +optional
+		CertificateURL string `json:"certificateURL,omitempty"`
+
+		// KeyVaultID is the resource ID of the Azure Key Vault containing the certificate of an Https listener.
+		// Required for Https listeners.
+		// +optional
+		KeyVaultID string `json:"keyVaultID,omitempty"`
+	}
+
+	// WinRMProtocol is the protocol of a Windows Remote Management listener.
+	WinRMProtocol string
+
+	// AdditionalUnattendContent is XML formatted information which is included in the Unattend.xml file used by
+	// Windows Setup for the Microsoft-Windows-Shell-Setup component in the oobeSystem pass.
+	AdditionalUnattendContent struct {
+		// SettingName is the name of the setting the content applies to.
+		// +kubebuilder:validation:Enum=AutoLogon;FirstLogonCommands
+		SettingName string `json:"settingName"`
+
+		// Content is the XML formatted content of the setting, including its root element. The content must be less
+		// than 4KB.
+		Content string `json:"content"`
 	}
 
 	// AzureMachinePoolSpec defines the desired state of AzureMachinePool.
@@ -116,7 +271,8 @@ type (
 		// The type 'SystemAssigned' is an implicitly created identity.
 		// The generated identity will be assigned a Subscription contributor role.
 		// The type 'UserAssigned' is a standalone Azure resource provided by the user
-		// and assigned to the VM
+		// and assigned to the VM.
+		// The type 'SystemAssigned, UserAssigned' assigns both a system-assigned identity and the user-assigned identities.
 		// +kubebuilder:default=None
 		// +optional
 		Identity infrav1.VMIdentity `json:"identity,omitempty"`
@@ -133,6 +289,17 @@ type (
 		// +optional
 		RoleAssignmentName string `json:"roleAssignmentName,omitempty"`
 
+		// RoleAssignmentScope is the scope of the role assignment created for a system assigned identity, e.g. a
+		// subscription, a resource group or a single resource. Defaults to the subscription of the cluster.
+		// The field is immutable.
+		// +optional
+		RoleAssignmentScope string `json:"roleAssignmentScope,omitempty"`
+
+		// RoleDefinitionID is the resource ID of the role definition assigned to a system assigned identity, e.g. of a
+		// custom role. Defaults to the built-in Contributor role. The field is immutable.
+		// +optional
+		RoleDefinitionID string `json:"roleDefinitionID,omitempty"`
+
 		// The deployment strategy to use to replace existing AzureMachinePoolMachines with new ones.
 		// +optional
 		// +kubebuilder:default={type: "RollingUpdate", rollingUpdate: {maxSurge: 1, maxUnavailable: 0, deletePolicy: Oldest}}
@@ -143,8 +310,79 @@ type (
 		// NOTE: NodeDrainTimeout is different from `kubectl drain --timeout`
 		// +optional
 		NodeDrainTimeout *metav1.Duration `json:"nodeDrainTimeout,omitempty"`
+
+		// OutboundLBDisabled excludes the Virtual Machine Scale Set from the backend pool of the cluster's node outbound
+		// load balancer, e.g. for private pools which egress through a firewall or user defined route.
+		// Backend pool memberships of an existing Virtual Machine Scale Set are not removed when this is enabled later on.
+		// +optional
+		OutboundLBDisabled bool `json:"outboundLBDisabled,omitempty"`
+
+		// OrchestrationMode specifies how the machines of the pool are orchestrated, either by a Virtual Machine Scale
+		// Set or as discrete Virtual Machines in an availability set. The field is immutable.
+		// NOTE: Orchestration with an availability set is not implemented yet.
+		// +kubebuilder:validation:Enum=VirtualMachineScaleSet;AvailabilitySet
+		// +kubebuilder:default=VirtualMachineScaleSet
+		// +optional
+		OrchestrationMode AzureMachinePoolOrchestrationMode `json:"orchestrationMode,omitempty"`
+
+		// ForceDeleteInstances force deletes the Virtual Machine Scale Set instances of deleted AzureMachinePoolMachines,
+		// e.g. when scaling in, so that instances which are stuck are removed promptly. Force deletion skips the graceful
+		// shutdown of the instance. Defaults to false.
+		// +optional
+		ForceDeleteInstances bool `json:"forceDeleteInstances,omitempty"`
+
+		// PlatformFaultDomainCount is the number of fault domains the instances of the Virtual Machine Scale Set are
+		// spread across. In availability zones it is either 1 or 5, otherwise it must not exceed the maximum fault domain
+		// count of the location. It is not supported with the AvailabilitySet orchestration mode. The field is immutable.
+		// +kubebuilder:validation:Minimum=1
+		// +optional
+		PlatformFaultDomainCount *int32 `json:"platformFaultDomainCount,omitempty"`
+
+		// BootstrapFailureGracePeriod is the time a failed bootstrap extension is given to recover, e.g. from transient
+		// boot failures, before the bootstrap is considered to have failed terminally. The default value is 0, meaning
+		// that a failed bootstrap extension is terminal right away.
+		// +optional
+		BootstrapFailureGracePeriod *metav1.Duration `json:"bootstrapFailureGracePeriod,omitempty"`
+
+		// BootstrapExtension overrides the VM extension which reports the bootstrap status of the instances, e.g. in
+		// air-gapped or Azure Stack environments which don't provide the built-in bootstrap extension. Unset fields fall
+		// back to the built-in bootstrap extension.
+		// +optional
+		BootstrapExtension *BootstrapExtension `json:"bootstrapExtension,omitempty"`
+
+		// SkipBootstrapExtension skips installing the VM extension which reports the bootstrap status of the instances,
+		// e.g. for fully baked images which bootstrap themselves. The bootstrap is considered to have succeeded right away.
+		// Defaults to false.
+		// +optional
+		SkipBootstrapExtension bool `json:"skipBootstrapExtension,omitempty"`
+
+		// ResourceGroup is the name of an existing resource group the Virtual Machine Scale Set is created in, e.g. to
+		// isolate the quota or policies of node pools. The resource group is neither created nor deleted. The load
+		// balancers and the virtual network of the cluster remain in their resource groups. Defaults to the resource group
+		// of the cluster. The field is immutable.
+		// +optional
+		ResourceGroup string `json:"resourceGroup,omitempty"`
 	}
 
+	// BootstrapExtension describes the VM extension which reports the bootstrap status of the instances.
+	BootstrapExtension struct {
+		// Publisher is the name of the publisher of the extension, e.g. Microsoft.Azure.ContainerUpstream.
+		// +optional
+		Publisher string `json:"publisher,omitempty"`
+
+		// Type is the type of the extension, e.g. CAPZ.Linux.Bootstrapping. It is used as the name of the extension as
+		// well.
+		// +optional
+		Type string `json:"type,omitempty"`
+
+		// Version is the version of the extension handler, e.g. 1.0.
+		// +optional
+		Version string `json:"version,omitempty"`
+	}
+
+	// AzureMachinePoolOrchestrationMode is the way the machines of an AzureMachinePool are orchestrated.
+	AzureMachinePoolOrchestrationMode string
+
 	// AzureMachinePoolDeploymentStrategyType is the type of deployment strategy employed to rollout a new version of
 	// the AzureMachinePool.
 	AzureMachinePoolDeploymentStrategyType string
@@ -222,6 +460,26 @@ type (
 		// +optional
 		Replicas int32 `json:"replicas"`
 
+		// UpdatedReplicas is the most recently observed number of instances which have the latest model of the VMSS
+		// applied.
+		// +optional
+		UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+		// OutdatedReplicas is the most recently observed number of instances which don't have the latest model of the
+		// VMSS applied yet.
+		// +optional
+		OutdatedReplicas int32 `json:"outdatedReplicas,omitempty"`
+
+		// UniqueID is the unique ID Azure assigned to the VMSS. Unlike the resource ID, it changes when the VMSS is
+		// recreated with the same name.
+		// +optional
+		UniqueID string `json:"uniqueID,omitempty"`
+
+		// LatestModelID identifies the latest model of the VMSS. It is derived from the model properties which are
+		// compared to detect model changes, and changes whenever a new model is applied to the VMSS.
+		// +optional
+		LatestModelID string `json:"latestModelID,omitempty"`
+
 		// Instances is the VM instance status for each VM in the VMSS
 		// +optional
 		Instances []*AzureMachinePoolInstanceStatus `json:"instances,omitempty"`
@@ -239,6 +497,11 @@ type (
 		// +optional
 		ProvisioningState *infrav1.ProvisioningState `json:"provisioningState,omitempty"`
 
+		// BootstrapFailedSince is the time the bootstrap extension was first observed to have failed. It is cleared once
+		// the bootstrap extension recovers.
+		// +optional
+		BootstrapFailedSince *metav1.Time `json:"bootstrapFailedSince,omitempty"`
+
 		// FailureReason will be set in the event that there is a terminal problem
 		// reconciling the MachinePool and will contain a succinct value suitable
 		// for machine interpretation.
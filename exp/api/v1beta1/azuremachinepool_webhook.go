@@ -19,19 +19,35 @@ package v1beta1
 import (
 	"errors"
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/feature"
 	capifeature "sigs.k8s.io/cluster-api/feature"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
+const (
+	// maxUnattendContentLength is the maximum length of the XML formatted content of an additional unattend setting.
+	maxUnattendContentLength = 4096
+)
+
+// windowsTimeZoneRegex matches the IDs of Windows time zones, e.g. "UTC", "UTC-11", "W. Europe Standard Time" or
+// "Russia Time Zone 3".
+var windowsTimeZoneRegex = regexp.MustCompile(`^(UTC([+-]\d{2})?|[A-Z][A-Za-z. ]* (Standard Time|Time Zone \d{1,2}))$`)
+
+// keyVaultIDRegex matches the resource ID of an Azure Key Vault.
+var keyVaultIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/microsoft\.keyvault/vaults/[^/]+$`)
+
 // SetupWebhookWithManager sets up and registers the webhook with the manager.
 func (amp *AzureMachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
@@ -83,11 +99,21 @@ func (amp *AzureMachinePool) Validate(old runtime.Object) error {
 
 	validators := []func() error{
 		amp.ValidateImage,
+		amp.ValidateDiskPerformance,
 		amp.ValidateTerminateNotificationTimeout,
 		amp.ValidateSSHKey,
+		amp.ValidateSSHAuthorizedKeysPath,
+		amp.ValidateWindowsConfiguration,
+		amp.ValidateLinuxConfiguration,
+		amp.ValidateSecrets,
+		amp.ValidateAdditionalCloudInit,
 		amp.ValidateUserAssignedIdentity,
 		amp.ValidateStrategy(),
 		amp.ValidateSystemAssignedIdentity(old),
+		amp.ValidateOrchestrationMode(old),
+		amp.ValidatePlatformFaultDomainCount(old),
+		amp.ValidateResourceGroup(old),
+		amp.ValidateRoleAssignment(old),
 	}
 
 	var errs []error
@@ -113,6 +139,21 @@ func (amp *AzureMachinePool) ValidateImage() error {
 	return nil
 }
 
+// ValidateDiskPerformance validates the read-write IOPS and throughput of the managed disks of an AzureMachinePool.
+func (amp *AzureMachinePool) ValidateDiskPerformance() error {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, infrav1.ValidateManagedDiskPerformance(amp.Spec.Template.OSDisk.ManagedDisk, field.NewPath("osDisk", "managedDisk"))...)
+	for i, disk := range amp.Spec.Template.DataDisks {
+		allErrs = append(allErrs, infrav1.ValidateManagedDiskPerformance(disk.ManagedDisk, field.NewPath("dataDisks").Index(i).Child("managedDisk"))...)
+	}
+
+	if len(allErrs) > 0 {
+		return kerrors.NewAggregate(allErrs.ToAggregate().Errors())
+	}
+
+	return nil
+}
+
 // ValidateTerminateNotificationTimeout termination notification timeout to be between 5 and 15.
 func (amp *AzureMachinePool) ValidateTerminateNotificationTimeout() error {
 	if amp.Spec.Template.TerminateNotificationTimeout == nil {
@@ -142,6 +183,134 @@ func (amp *AzureMachinePool) ValidateSSHKey() error {
 	return nil
 }
 
+// ValidateSSHAuthorizedKeysPath validates that the SSH authorized keys path is absolute.
+func (amp *AzureMachinePool) ValidateSSHAuthorizedKeysPath() error {
+	if keysPath := amp.Spec.Template.SSHAuthorizedKeysPath; keysPath != "" && !path.IsAbs(keysPath) {
+		return field.Invalid(field.NewPath("Spec", "Template", "SSHAuthorizedKeysPath"), keysPath, "must be an absolute path")
+	}
+
+	return nil
+}
+
+// ValidateWindowsConfiguration validates the time zone, the additional unattend content and the WinRM listeners of the
+// Windows configuration.
+func (amp *AzureMachinePool) ValidateWindowsConfiguration() error {
+	windowsConfig := amp.Spec.Template.WindowsConfiguration
+	if windowsConfig == nil {
+		return nil
+	}
+
+	fldPath := field.NewPath("Spec", "Template", "WindowsConfiguration")
+	var allErrs field.ErrorList
+	if windowsConfig.TimeZone != "" && !windowsTimeZoneRegex.MatchString(windowsConfig.TimeZone) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("TimeZone"), windowsConfig.TimeZone,
+			"must be the ID of a Windows time zone, e.g. 'Pacific Standard Time'"))
+	}
+
+	settingNames := make(map[string]bool)
+	for i, content := range windowsConfig.AdditionalUnattendContent {
+		contentPath := fldPath.Child("AdditionalUnattendContent").Index(i)
+		if settingNames[content.SettingName] {
+			allErrs = append(allErrs, field.Duplicate(contentPath.Child("SettingName"), content.SettingName))
+		}
+		settingNames[content.SettingName] = true
+
+		if content.Content == "" {
+			allErrs = append(allErrs, field.Required(contentPath.Child("Content"), "content of the setting is required"))
+		} else if len(content.Content) >= maxUnattendContentLength {
+			allErrs = append(allErrs, field.TooLong(contentPath.Child("Content"), content.Content, maxUnattendContentLength-1))
+		}
+	}
+
+	if windowsConfig.WinRM != nil {
+		protocols := make(map[WinRMProtocol]bool)
+		for i, listener := range windowsConfig.WinRM.Listeners {
+			listenerPath := fldPath.Child("WinRM", "Listeners").Index(i)
+			if protocols[listener.Protocol] {
+				allErrs = append(allErrs, field.Duplicate(listenerPath.Child("Protocol"), listener.Protocol))
+			}
+			protocols[listener.Protocol] = true
+
+			switch listener.Protocol {
+			case WinRMProtocolHTTPS:
+				if listener.CertificateURL == "" {
+					allErrs = append(allErrs, field.Required(listenerPath.Child("CertificateURL"), "Https listeners require a certificate"))
+				}
+				if listener.KeyVaultID == "" {
+					allErrs = append(allErrs, field.Required(listenerPath.Child("KeyVaultID"), "Https listeners require the Key Vault of the certificate"))
+				} else if !keyVaultIDRegex.MatchString(listener.KeyVaultID) {
+					allErrs = append(allErrs, field.Invalid(listenerPath.Child("KeyVaultID"), listener.KeyVaultID, "must be the resource ID of an Azure Key Vault"))
+				}
+			case WinRMProtocolHTTP:
+				if listener.CertificateURL != "" || listener.KeyVaultID != "" {
+					allErrs = append(allErrs, field.Forbidden(listenerPath, "Http listeners must not have a certificate"))
+				}
+			}
+		}
+	}
+
+	return allErrs.ToAggregate()
+}
+
+// ValidateSecrets validates the Key Vault IDs of the secrets, and that the certificates have a certificate store on
+// Windows only.
+func (amp *AzureMachinePool) ValidateSecrets() error {
+	isWindows := amp.Spec.Template.OSDisk.OSType == azure.WindowsOS
+	var allErrs field.ErrorList
+	for i, group := range amp.Spec.Template.Secrets {
+		groupPath := field.NewPath("Spec", "Template", "Secrets").Index(i)
+		if !keyVaultIDRegex.MatchString(group.SourceVaultID) {
+			allErrs = append(allErrs, field.Invalid(groupPath.Child("SourceVaultID"), group.SourceVaultID, "must be the resource ID of an Azure Key Vault"))
+		}
+
+		for j, certificate := range group.VaultCertificates {
+			certificatePath := groupPath.Child("VaultCertificates").Index(j)
+			if certificate.CertificateURL == "" {
+				allErrs = append(allErrs, field.Required(certificatePath.Child("CertificateURL"), "certificate URL is required"))
+			}
+			if isWindows && certificate.CertificateStore == "" {
+				allErrs = append(allErrs, field.Required(certificatePath.Child("CertificateStore"), "certificate store is required for Windows"))
+			} else if !isWindows && certificate.CertificateStore != "" {
+				allErrs = append(allErrs, field.Forbidden(certificatePath.Child("CertificateStore"), "certificate store must not be set for Linux"))
+			}
+		}
+	}
+
+	return allErrs.ToAggregate()
+}
+
+// ValidateAdditionalCloudInit validates that additional cloud-init is only used with Linux, and that only cloud-config
+// fragments have a merge type.
+func (amp *AzureMachinePool) ValidateAdditionalCloudInit() error {
+	fldPath := field.NewPath("Spec", "Template", "AdditionalCloudInit")
+	if len(amp.Spec.Template.AdditionalCloudInit) > 0 && amp.Spec.Template.OSDisk.OSType == azure.WindowsOS {
+		return field.Forbidden(fldPath, "additional cloud-init is not supported for Windows")
+	}
+
+	var allErrs field.ErrorList
+	for i, fragment := range amp.Spec.Template.AdditionalCloudInit {
+		if fragment.MergeType != "" && fragment.ContentType != CloudInitContentTypeCloudConfig {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Index(i).Child("MergeType"), "merge type can only be set for cloud-config fragments"))
+		}
+	}
+
+	return allErrs.ToAggregate()
+}
+
+// ValidateLinuxConfiguration validates that the admin password is supplied if password authentication is enabled.
+func (amp *AzureMachinePool) ValidateLinuxConfiguration() error {
+	linuxConfig := amp.Spec.Template.LinuxConfiguration
+	if linuxConfig == nil || linuxConfig.DisablePasswordAuthentication == nil || *linuxConfig.DisablePasswordAuthentication {
+		return nil
+	}
+
+	if linuxConfig.AdminPasswordSecretName == "" {
+		return field.Required(field.NewPath("Spec", "Template", "LinuxConfiguration", "AdminPasswordSecretName"),
+			"the admin password is required if password authentication is enabled")
+	}
+	return nil
+}
+
 // ValidateUserAssignedIdentity validates the user-assigned identities list.
 func (amp *AzureMachinePool) ValidateUserAssignedIdentity() error {
 	fldPath := field.NewPath("UserAssignedIdentities")
@@ -190,3 +359,138 @@ func (amp *AzureMachinePool) ValidateSystemAssignedIdentity(old runtime.Object)
 		return nil
 	}
 }
+
+// ValidateOrchestrationMode validates that the orchestration mode of an existing AzureMachinePool is not changed.
+func (amp *AzureMachinePool) ValidateOrchestrationMode(old runtime.Object) func() error {
+	return func() error {
+		if old == nil {
+			return nil
+		}
+
+		oldMachinePool, ok := old.(*AzureMachinePool)
+		if !ok {
+			return fmt.Errorf("unexpected type for old azure machine pool object. Expected: %q, Got: %q",
+				"AzureMachinePool", reflect.TypeOf(old))
+		}
+
+		if orchestrationModeOrDefault(amp.Spec.OrchestrationMode) != orchestrationModeOrDefault(oldMachinePool.Spec.OrchestrationMode) {
+			return field.Invalid(field.NewPath("Spec", "OrchestrationMode"), amp.Spec.OrchestrationMode, "field is immutable")
+		}
+
+		return nil
+	}
+}
+
+// ValidatePlatformFaultDomainCount validates that the platform fault domain count is only set for an AzureMachinePool
+// orchestrated by a Virtual Machine Scale Set, and that it is not changed.
+func (amp *AzureMachinePool) ValidatePlatformFaultDomainCount(old runtime.Object) func() error {
+	return func() error {
+		if amp.Spec.PlatformFaultDomainCount == nil && old == nil {
+			return nil
+		}
+
+		fldPath := field.NewPath("Spec", "PlatformFaultDomainCount")
+		if amp.Spec.PlatformFaultDomainCount != nil && orchestrationModeOrDefault(amp.Spec.OrchestrationMode) != VirtualMachineScaleSetOrchestrationMode {
+			return field.Forbidden(fldPath, fmt.Sprintf("platform fault domain count is not supported with orchestration mode %s", amp.Spec.OrchestrationMode))
+		}
+
+		if old == nil {
+			return nil
+		}
+
+		oldMachinePool, ok := old.(*AzureMachinePool)
+		if !ok {
+			return fmt.Errorf("unexpected type for old azure machine pool object. Expected: %q, Got: %q",
+				"AzureMachinePool", reflect.TypeOf(old))
+		}
+
+		if !reflect.DeepEqual(amp.Spec.PlatformFaultDomainCount, oldMachinePool.Spec.PlatformFaultDomainCount) {
+			return field.Invalid(fldPath, amp.Spec.PlatformFaultDomainCount, "field is immutable")
+		}
+
+		return nil
+	}
+}
+
+// ValidateResourceGroup validates the name of the resource group of the Virtual Machine Scale Set, and that it is not
+// changed.
+func (amp *AzureMachinePool) ValidateResourceGroup(old runtime.Object) func() error {
+	return func() error {
+		fldPath := field.NewPath("Spec", "ResourceGroup")
+		if amp.Spec.ResourceGroup != "" {
+			if err := infrav1.ValidateResourceGroup(amp.Spec.ResourceGroup, fldPath); err != nil {
+				return err
+			}
+		}
+
+		if old == nil {
+			return nil
+		}
+
+		oldMachinePool, ok := old.(*AzureMachinePool)
+		if !ok {
+			return fmt.Errorf("unexpected type for old azure machine pool object. Expected: %q, Got: %q",
+				"AzureMachinePool", reflect.TypeOf(old))
+		}
+
+		if amp.Spec.ResourceGroup != oldMachinePool.Spec.ResourceGroup {
+			return field.Invalid(fldPath, amp.Spec.ResourceGroup, "field is immutable")
+		}
+
+		return nil
+	}
+}
+
+// ValidateRoleAssignment validates the scope and the role definition of the role assignment of a system assigned
+// identity, and that they are not changed, as existing role assignments are not updated.
+func (amp *AzureMachinePool) ValidateRoleAssignment(old runtime.Object) func() error {
+	return func() error {
+		scopePath := field.NewPath("Spec", "RoleAssignmentScope")
+		roleDefinitionPath := field.NewPath("Spec", "RoleDefinitionID")
+		if amp.Spec.Identity != infrav1.VMIdentitySystemAssigned && amp.Spec.Identity != infrav1.VMIdentitySystemAndUserAssigned {
+			if amp.Spec.RoleAssignmentScope != "" {
+				return field.Forbidden(scopePath, "Role assignment scope should only be set when using system assigned identity.")
+			}
+			if amp.Spec.RoleDefinitionID != "" {
+				return field.Forbidden(roleDefinitionPath, "Role definition ID should only be set when using system assigned identity.")
+			}
+		}
+
+		if amp.Spec.RoleAssignmentScope != "" && !strings.HasPrefix(strings.ToLower(amp.Spec.RoleAssignmentScope), "/subscriptions/") {
+			return field.Invalid(scopePath, amp.Spec.RoleAssignmentScope,
+				"role assignment scope must be the ID of a subscription, a resource group or a resource")
+		}
+		if amp.Spec.RoleDefinitionID != "" &&
+			!strings.Contains(strings.ToLower(amp.Spec.RoleDefinitionID), "/providers/microsoft.authorization/roledefinitions/") {
+			return field.Invalid(roleDefinitionPath, amp.Spec.RoleDefinitionID,
+				"role definition ID must be the ID of a Microsoft.Authorization/roleDefinitions resource")
+		}
+
+		if old == nil {
+			return nil
+		}
+
+		oldMachinePool, ok := old.(*AzureMachinePool)
+		if !ok {
+			return fmt.Errorf("unexpected type for old azure machine pool object. Expected: %q, Got: %q",
+				"AzureMachinePool", reflect.TypeOf(old))
+		}
+
+		if amp.Spec.RoleAssignmentScope != oldMachinePool.Spec.RoleAssignmentScope {
+			return field.Invalid(scopePath, amp.Spec.RoleAssignmentScope, "field is immutable")
+		}
+		if amp.Spec.RoleDefinitionID != oldMachinePool.Spec.RoleDefinitionID {
+			return field.Invalid(roleDefinitionPath, amp.Spec.RoleDefinitionID, "field is immutable")
+		}
+
+		return nil
+	}
+}
+
+// orchestrationModeOrDefault returns the orchestration mode, treating an unset mode as a Virtual Machine Scale Set.
+func orchestrationModeOrDefault(mode AzureMachinePoolOrchestrationMode) AzureMachinePoolOrchestrationMode {
+	if mode == "" {
+		return VirtualMachineScaleSetOrchestrationMode
+	}
+	return mode
+}
@@ -20,6 +20,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
+	"strings"
 	"testing"
 
 	"github.com/Azure/go-autorest/autorest/to"
@@ -142,6 +143,310 @@ func TestAzureMachinePool_ValidateCreate(t *testing.T) {
 			}),
 			wantErr: false,
 		},
+		{
+			name:    "azuremachinepool with absolute SSHAuthorizedKeysPath",
+			amp:     createMachinePoolWithSSHAuthorizedKeysPath("/var/lib/capi/.ssh/authorized_keys"),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with relative SSHAuthorizedKeysPath",
+			amp:     createMachinePoolWithSSHAuthorizedKeysPath(".ssh/authorized_keys"),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with valid Windows time zone",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				TimeZone: "W. Europe Standard Time",
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with UTC offset Windows time zone",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				TimeZone: "UTC-11",
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with IANA time zone",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				TimeZone: "Europe/Zurich",
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with password authentication enabled and an admin password",
+			amp: createMachinePoolWithLinuxConfiguration(&LinuxConfiguration{
+				DisablePasswordAuthentication: to.BoolPtr(false),
+				AdminPasswordSecretName:       "admin-password",
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with password authentication enabled without an admin password",
+			amp: createMachinePoolWithLinuxConfiguration(&LinuxConfiguration{
+				DisablePasswordAuthentication: to.BoolPtr(false),
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with password authentication disabled",
+			amp: createMachinePoolWithLinuxConfiguration(&LinuxConfiguration{
+				DisablePasswordAuthentication: to.BoolPtr(true),
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with additional unattend content",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				AdditionalUnattendContent: []AdditionalUnattendContent{
+					{SettingName: "AutoLogon", Content: "<AutoLogon><Enabled>true</Enabled></AutoLogon>"},
+					{SettingName: "FirstLogonCommands", Content: "<FirstLogonCommands></FirstLogonCommands>"},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with duplicate additional unattend content",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				AdditionalUnattendContent: []AdditionalUnattendContent{
+					{SettingName: "AutoLogon", Content: "<AutoLogon><Enabled>true</Enabled></AutoLogon>"},
+					{SettingName: "AutoLogon", Content: "<AutoLogon><Enabled>false</Enabled></AutoLogon>"},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with empty additional unattend content",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				AdditionalUnattendContent: []AdditionalUnattendContent{
+					{SettingName: "AutoLogon"},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Http WinRM listener",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{{Protocol: WinRMProtocolHTTP}},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with Https WinRM listener with a certificate",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{
+						{
+							Protocol:       WinRMProtocolHTTPS,
+							CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234",
+							KeyVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+						},
+					},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with Https WinRM listener without a certificate",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{{Protocol: WinRMProtocolHTTPS}},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Https WinRM listener without a Key Vault",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{
+						{
+							Protocol:       WinRMProtocolHTTPS,
+							CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234",
+						},
+					},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Http WinRM listener with a certificate",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{
+						{
+							Protocol:       WinRMProtocolHTTP,
+							CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234",
+						},
+					},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Https WinRM listener with an invalid Key Vault",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{
+						{
+							Protocol:       WinRMProtocolHTTPS,
+							CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234",
+							KeyVaultID:     "myvault",
+						},
+					},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Linux secrets",
+			amp: createMachinePoolWithSecrets("Linux", []VaultSecretGroup{
+				{
+					SourceVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+					VaultCertificates: []VaultCertificate{{CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234"}},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with Windows secrets",
+			amp: createMachinePoolWithSecrets("Windows", []VaultSecretGroup{
+				{
+					SourceVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+					VaultCertificates: []VaultCertificate{{CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234", CertificateStore: "My"}},
+				},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with secrets from an invalid Key Vault",
+			amp: createMachinePoolWithSecrets("Linux", []VaultSecretGroup{
+				{
+					SourceVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Storage/storageAccounts/myaccount",
+					VaultCertificates: []VaultCertificate{{CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234"}},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Linux secrets with a certificate store",
+			amp: createMachinePoolWithSecrets("Linux", []VaultSecretGroup{
+				{
+					SourceVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+					VaultCertificates: []VaultCertificate{{CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234", CertificateStore: "My"}},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with Windows secrets without a certificate store",
+			amp: createMachinePoolWithSecrets("Windows", []VaultSecretGroup{
+				{
+					SourceVaultID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/myvault",
+					VaultCertificates: []VaultCertificate{{CertificateURL: "https://myvault.vault.azure.net/secrets/mycert/1234"}},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with additional cloud-init",
+			amp: createMachinePoolWithAdditionalCloudInit("Linux", []CloudInitFragment{
+				{ContentType: CloudInitContentTypeCloudConfig, MergeType: "list(append)+dict(recurse_array)+str()", Content: "#cloud-config\nruncmd:\n- echo hello\n"},
+				{ContentType: CloudInitContentTypeShellScript, Content: "#!/bin/bash\necho hello\n"},
+			}),
+			wantErr: false,
+		},
+		{
+			name: "azuremachinepool with a merge type for a shell script fragment",
+			amp: createMachinePoolWithAdditionalCloudInit("Linux", []CloudInitFragment{
+				{ContentType: CloudInitContentTypeShellScript, MergeType: "list(append)", Content: "#!/bin/bash\necho hello\n"},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with additional cloud-init for Windows",
+			amp: createMachinePoolWithAdditionalCloudInit("Windows", []CloudInitFragment{
+				{ContentType: CloudInitContentTypeShellScript, Content: "#!/bin/bash\necho hello\n"},
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with duplicate WinRM listeners",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				WinRM: &WinRMConfiguration{
+					Listeners: []WinRMListener{{Protocol: WinRMProtocolHTTP}, {Protocol: WinRMProtocolHTTP}},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with platform fault domain count",
+			amp:     createMachinePoolWithPlatformFaultDomainCount(VirtualMachineScaleSetOrchestrationMode, to.Int32Ptr(2)),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with platform fault domain count and AvailabilitySet orchestration mode",
+			amp:     createMachinePoolWithPlatformFaultDomainCount(AvailabilitySetOrchestrationMode, to.Int32Ptr(2)),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with too long additional unattend content",
+			amp: createMachinePoolWithWindowsConfiguration(&WindowsConfiguration{
+				AdditionalUnattendContent: []AdditionalUnattendContent{
+					{SettingName: "FirstLogonCommands", Content: strings.Repeat("a", 4096)},
+				},
+			}),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with UltraSSD_LRS data disk IOPS and throughput",
+			amp:     createMachinePoolWithDataDisk("UltraSSD_LRS", to.Int64Ptr(20000), to.Int64Ptr(500)),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with Premium_LRS data disk IOPS",
+			amp:     createMachinePoolWithDataDisk("Premium_LRS", to.Int64Ptr(20000), nil),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with UltraSSD_LRS data disk throughput above the maximum",
+			amp:     createMachinePoolWithDataDisk("UltraSSD_LRS", nil, to.Int64Ptr(4001)),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with a valid resource group",
+			amp:     createMachinePoolWithResourceGroup("my-vmss-rg"),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with an invalid resource group",
+			amp:     createMachinePoolWithResourceGroup("invalid rg name."),
+			wantErr: true,
+		},
+		{
+			name: "azuremachinepool with a custom role at a resource group scope",
+			amp: createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "/subscriptions/123/resourceGroups/my-rg",
+				"/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/my-role"),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with a role assignment scope which is no resource ID",
+			amp:     createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "my-rg", ""),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with a role definition ID which is no role definition",
+			amp:     createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "", "/subscriptions/123/resourceGroups/my-rg"),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with a role assignment scope without system assigned identity",
+			amp:     createMachinePoolWithRoleAssignment(infrav1.VMIdentityNone, "/subscriptions/123", ""),
+			wantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -221,6 +526,60 @@ func TestAzureMachinePool_ValidateUpdate(t *testing.T) {
 			}),
 			wantErr: false,
 		},
+		{
+			name:    "azuremachinepool with unset orchestration mode defaulted to VirtualMachineScaleSet",
+			oldAMP:  createMachinePoolWithOrchestrationMode(""),
+			amp:     createMachinePoolWithOrchestrationMode(VirtualMachineScaleSetOrchestrationMode),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with orchestration mode changed",
+			oldAMP:  createMachinePoolWithOrchestrationMode(VirtualMachineScaleSetOrchestrationMode),
+			amp:     createMachinePoolWithOrchestrationMode(AvailabilitySetOrchestrationMode),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with platform fault domain count unchanged",
+			oldAMP:  createMachinePoolWithPlatformFaultDomainCount("", to.Int32Ptr(2)),
+			amp:     createMachinePoolWithPlatformFaultDomainCount("", to.Int32Ptr(2)),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with platform fault domain count changed",
+			oldAMP:  createMachinePoolWithPlatformFaultDomainCount("", to.Int32Ptr(2)),
+			amp:     createMachinePoolWithPlatformFaultDomainCount("", to.Int32Ptr(3)),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with resource group unchanged",
+			oldAMP:  createMachinePoolWithResourceGroup("my-vmss-rg"),
+			amp:     createMachinePoolWithResourceGroup("my-vmss-rg"),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with resource group changed",
+			oldAMP:  createMachinePoolWithResourceGroup(""),
+			amp:     createMachinePoolWithResourceGroup("my-vmss-rg"),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with role assignment scope unchanged",
+			oldAMP:  createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "/subscriptions/123", ""),
+			amp:     createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "/subscriptions/123", ""),
+			wantErr: false,
+		},
+		{
+			name:    "azuremachinepool with role assignment scope changed",
+			oldAMP:  createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "", ""),
+			amp:     createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "/subscriptions/123/resourceGroups/my-rg", ""),
+			wantErr: true,
+		},
+		{
+			name:    "azuremachinepool with role definition ID changed",
+			oldAMP:  createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "", ""),
+			amp:     createMachinePoolWithRoleAssignment(infrav1.VMIdentitySystemAssigned, "", "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/my-role"),
+			wantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -378,3 +737,118 @@ func createMachinePoolWithStrategy(strategy AzureMachinePoolDeploymentStrategy)
 		},
 	}
 }
+
+func createMachinePoolWithSSHAuthorizedKeysPath(keysPath string) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Template: AzureMachinePoolMachineTemplate{
+				SSHAuthorizedKeysPath: keysPath,
+			},
+		},
+	}
+}
+
+func createMachinePoolWithSecrets(osType string, secrets []VaultSecretGroup) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Template: AzureMachinePoolMachineTemplate{
+				OSDisk: infrav1.OSDisk{
+					OSType: osType,
+				},
+				Secrets: secrets,
+			},
+		},
+	}
+}
+
+func createMachinePoolWithAdditionalCloudInit(osType string, fragments []CloudInitFragment) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Template: AzureMachinePoolMachineTemplate{
+				OSDisk: infrav1.OSDisk{
+					OSType: osType,
+				},
+				AdditionalCloudInit: fragments,
+			},
+		},
+	}
+}
+
+func createMachinePoolWithLinuxConfiguration(linuxConfig *LinuxConfiguration) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Template: AzureMachinePoolMachineTemplate{
+				LinuxConfiguration: linuxConfig,
+			},
+		},
+	}
+}
+
+func createMachinePoolWithWindowsConfiguration(windowsConfig *WindowsConfiguration) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Template: AzureMachinePoolMachineTemplate{
+				WindowsConfiguration: windowsConfig,
+			},
+		},
+	}
+}
+
+func createMachinePoolWithDataDisk(storageAccountType string, iops, mbps *int64) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Template: AzureMachinePoolMachineTemplate{
+				DataDisks: []infrav1.DataDisk{
+					{
+						NameSuffix: "data",
+						DiskSizeGB: 64,
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: storageAccountType,
+							DiskIOPSReadWrite:  iops,
+							DiskMBpsReadWrite:  mbps,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func createMachinePoolWithPlatformFaultDomainCount(mode AzureMachinePoolOrchestrationMode, count *int32) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			OrchestrationMode:        mode,
+			PlatformFaultDomainCount: count,
+		},
+	}
+}
+
+func createMachinePoolWithOrchestrationMode(mode AzureMachinePoolOrchestrationMode) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			OrchestrationMode: mode,
+		},
+	}
+}
+
+func createMachinePoolWithResourceGroup(resourceGroup string) *AzureMachinePool {
+	return &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			ResourceGroup: resourceGroup,
+		},
+	}
+}
+
+func createMachinePoolWithRoleAssignment(identity infrav1.VMIdentity, scope, roleDefinitionID string) *AzureMachinePool {
+	amp := &AzureMachinePool{
+		Spec: AzureMachinePoolSpec{
+			Identity:            identity,
+			RoleAssignmentScope: scope,
+			RoleDefinitionID:    roleDefinitionID,
+		},
+	}
+	if identity == infrav1.VMIdentitySystemAssigned {
+		amp.Spec.RoleAssignmentName = "42862306-e485-4319-9bf0-35dbc6f6fe9c"
+	}
+	return amp
+}
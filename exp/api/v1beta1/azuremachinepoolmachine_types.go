@@ -38,6 +38,12 @@ type (
 
 		// InstanceID is the identification of the Machine Instance within the VMSS
 		InstanceID string `json:"instanceID"`
+
+		// NodeDrainTimeout is the total amount of time that the controller will spend on draining the node of the machine.
+		// It is set from the NodeDrainTimeout of the AzureMachinePool when the machine is created.
+		// The default value is 0, meaning that the node can be drained without any time limitations.
+		// +optional
+		NodeDrainTimeout *metav1.Duration `json:"nodeDrainTimeout,omitempty"`
 	}
 
 	// AzureMachinePoolMachineStatus defines the observed state of AzureMachinePoolMachine.
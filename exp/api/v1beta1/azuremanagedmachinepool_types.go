@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
@@ -36,6 +37,43 @@ const (
 
 	// DefaultOSType represents the default operating system for azmachinepool.
 	DefaultOSType string = azure.LinuxOS
+
+	// ScaleSetPrioritySpot represents the Spot priority of an agent pool.
+	ScaleSetPrioritySpot = "Spot"
+
+	// OSSKUUbuntu represents the Ubuntu OS SKU of a Linux agent pool.
+	OSSKUUbuntu = "Ubuntu"
+
+	// OSSKUAzureLinux represents the Azure Linux (formerly CBL-Mariner) OS SKU of a Linux agent pool.
+	OSSKUAzureLinux = "AzureLinux"
+
+	// OSSKUCBLMariner represents the CBL-Mariner OS SKU of a Linux agent pool.
+	OSSKUCBLMariner = "CBLMariner"
+
+	// DefaultOSSKU represents the default OS SKU of a Linux agent pool.
+	DefaultOSSKU = OSSKUUbuntu
+
+	// ScaleDownModeDelete represents the scale-down mode which deletes nodes of an agent pool on scale-down.
+	ScaleDownModeDelete = "Delete"
+
+	// ScaleDownModeDeallocate represents the scale-down mode which deallocates nodes of an agent pool on scale-down.
+	ScaleDownModeDeallocate = "Deallocate"
+
+	// DefaultScaleDownMode represents the default scale-down mode of an agent pool.
+	DefaultScaleDownMode = ScaleDownModeDelete
+
+	// PowerStateRunning represents the desired power state of an agent pool whose nodes are running.
+	PowerStateRunning = "Running"
+
+	// PowerStateStopped represents the desired power state of an agent pool whose nodes are stopped.
+	PowerStateStopped = "Stopped"
+
+	// WorkloadRuntimeOCIContainer represents the workload runtime of an agent pool which runs OCI containers.
+	WorkloadRuntimeOCIContainer = "OCIContainer"
+
+	// WorkloadRuntimeWasmWasi represents the workload runtime of an agent pool which runs WebAssembly System Interface
+	// workloads.
+	WorkloadRuntimeWasmWasi = "WasmWasi"
 )
 
 // NodePoolMode enumerates the values for agent pool mode.
@@ -98,6 +136,224 @@ type AzureManagedMachinePoolSpec struct {
 	// +kubebuilder:validation:Enum=Linux;Windows
 	// +optional
 	OSType *string `json:"osType,omitempty"`
+
+	// KubeletConfig specifies the kubelet configuration for nodes in the agent pool.
+	// +optional
+	KubeletConfig *KubeletConfig `json:"kubeletConfig,omitempty"`
+
+	// LinuxOSConfig specifies the OS configuration for Linux nodes in the agent pool.
+	// +optional
+	LinuxOSConfig *LinuxOSConfig `json:"linuxOSConfig,omitempty"`
+
+	// ScaleSetPriority specifies the ScaleSetPriority value. Default to Regular. Possible values include: 'Regular', 'Spot'
+	// +kubebuilder:validation:Enum=Regular;Spot
+	// +optional
+	ScaleSetPriority *string `json:"scaleSetPriority,omitempty"`
+
+	// SpotMaxPrice defines the maximum price to pay for Spot instances of the agent pool. Possible values are any decimal
+	// value greater than zero or -1, which means paying up to the on-demand price. Requires ScaleSetPriority to be 'Spot'.
+	// +optional
+	SpotMaxPrice *resource.Quantity `json:"spotMaxPrice,omitempty"`
+
+	// EnableNodePublicIP specifies whether each node in the agent pool is allocated its own public IP.
+	// +optional
+	EnableNodePublicIP *bool `json:"enableNodePublicIP,omitempty"`
+
+	// NodePublicIPPrefixID specifies the public IP prefix ID which the node public IPs are allocated from.
+	// Requires EnableNodePublicIP to be true.
+	// +optional
+	NodePublicIPPrefixID *string `json:"nodePublicIPPrefixID,omitempty"`
+
+	// MaxSurge specifies the maximum number or percentage of nodes that are surged during an upgrade of the
+	// agent pool, e.g. "1" or "33%". If not specified, AKS uses a default of 1.
+	// +optional
+	MaxSurge string `json:"maxSurge,omitempty"`
+
+	// EnableFIPS enables the FIPS-compliant OS image for the nodes in the agent pool.
+	// +optional
+	EnableFIPS *bool `json:"enableFIPS,omitempty"`
+
+	// OSSKU specifies the OS SKU of Linux nodes in the agent pool. Defaults to Ubuntu for Linux agent pools.
+	// Possible values include: 'Ubuntu', 'AzureLinux', 'CBLMariner'
+	// +kubebuilder:validation:Enum=Ubuntu;AzureLinux;CBLMariner
+	// +optional
+	OSSKU string `json:"osSKU,omitempty"`
+
+	// AdditionalTags is an optional set of tags to add to the agent pool, which AKS propagates to the virtual machine
+	// scale set of the agent pool in the node resource group. The tags are merged with the AdditionalTags of the
+	// AzureManagedControlPlane. If the same key is present in both, the value from the AzureManagedMachinePool takes
+	// precedence.
+	// +optional
+	AdditionalTags infrav1.Tags `json:"additionalTags,omitempty"`
+
+	// GPUInstanceProfile specifies the GPU MIG instance profile for supported GPU VM SKUs.
+	// Possible values include: 'MIG1g', 'MIG2g', 'MIG3g', 'MIG4g', 'MIG7g'
+	// +kubebuilder:validation:Enum=MIG1g;MIG2g;MIG3g;MIG4g;MIG7g
+	// +optional
+	GPUInstanceProfile string `json:"gpuInstanceProfile,omitempty"`
+
+	// PodSubnetName specifies the name of the subnet in the virtual network of the AzureManagedControlPlane from which
+	// pod IPs are dynamically allocated with Azure CNI. Must be different from the node subnet.
+	// +optional
+	PodSubnetName *string `json:"podSubnetName,omitempty"`
+
+	// SnapshotID is the resource ID of an AKS node pool snapshot from which the agent pool is created.
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+
+	// ScaleDownMode specifies whether nodes of the agent pool are deleted or deallocated on scale-down.
+	// Defaults to Delete. Possible values include: 'Delete', 'Deallocate'
+	// +kubebuilder:validation:Enum=Delete;Deallocate
+	// +optional
+	ScaleDownMode string `json:"scaleDownMode,omitempty"`
+
+	// PowerState is the desired power state of the agent pool. When unset, the power state of the agent pool
+	// is not managed. Possible values include: 'Running', 'Stopped'
+	// +kubebuilder:validation:Enum=Running;Stopped
+	// +optional
+	PowerState string `json:"powerState,omitempty"`
+
+	// EnableEncryptionAtHost enables host-based encryption of the OS and temporary disks of the nodes in the agent
+	// pool. The EncryptionAtHost feature must be registered for the Microsoft.Compute resource provider of the
+	// subscription.
+	// +optional
+	EnableEncryptionAtHost *bool `json:"enableEncryptionAtHost,omitempty"`
+
+	// WorkloadRuntime specifies the type of workloads the nodes of the agent pool can run. Defaults to OCIContainer
+	// in AKS. Possible values include: 'OCIContainer', 'WasmWasi'
+	// +kubebuilder:validation:Enum=OCIContainer;WasmWasi
+	// +optional
+	WorkloadRuntime string `json:"workloadRuntime,omitempty"`
+}
+
+// KubeletConfig defines the supported subset of kubelet configurations for nodes in an agent pool.
+// See https://docs.microsoft.com/azure/aks/custom-node-configuration for more details.
+type KubeletConfig struct {
+	// CPUManagerPolicy - CPU Manager policy to use. Allowed values are 'none' and 'static'.
+	// +kubebuilder:validation:Enum=none;static
+	// +optional
+	CPUManagerPolicy *string `json:"cpuManagerPolicy,omitempty"`
+
+	// CPUCfsQuota - Enable CPU CFS quota enforcement for containers that specify CPU limits.
+	// +optional
+	CPUCfsQuota *bool `json:"cpuCfsQuota,omitempty"`
+
+	// CPUCfsQuotaPeriod - Sets CPU CFS quota period value, e.g. '100ms'.
+	// +optional
+	CPUCfsQuotaPeriod *string `json:"cpuCfsQuotaPeriod,omitempty"`
+
+	// ImageGcHighThreshold - The percent of disk usage after which image garbage collection is always run.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ImageGcHighThreshold *int32 `json:"imageGcHighThreshold,omitempty"`
+
+	// ImageGcLowThreshold - The percent of disk usage before which image garbage collection is never run.
+	// Must be lower than ImageGcHighThreshold.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ImageGcLowThreshold *int32 `json:"imageGcLowThreshold,omitempty"`
+
+	// TopologyManagerPolicy - Topology Manager policy to use.
+	// Allowed values are 'none', 'best-effort', 'restricted' and 'single-numa-node'.
+	// +kubebuilder:validation:Enum=none;best-effort;restricted;single-numa-node
+	// +optional
+	TopologyManagerPolicy *string `json:"topologyManagerPolicy,omitempty"`
+
+	// AllowedUnsafeSysctls - Allowlist of unsafe sysctls or unsafe sysctl patterns (ending in `*`).
+	// Allowed patterns are 'kernel.shm*', 'kernel.msg*', 'kernel.sem', 'fs.mqueue.*' and 'net.*'.
+	// +optional
+	AllowedUnsafeSysctls []string `json:"allowedUnsafeSysctls,omitempty"`
+
+	// FailSwapOn - If set to true it will make the Kubelet fail to start if swap is enabled on the node.
+	// +optional
+	FailSwapOn *bool `json:"failSwapOn,omitempty"`
+
+	// ContainerLogMaxSizeMB - The maximum size in MB of a container log file before it is rotated.
+	// +optional
+	ContainerLogMaxSizeMB *int32 `json:"containerLogMaxSizeMB,omitempty"`
+
+	// ContainerLogMaxFiles - The maximum number of container log files that can be present for a container.
+	// The number must be at least 2.
+	// +kubebuilder:validation:Minimum=2
+	// +optional
+	ContainerLogMaxFiles *int32 `json:"containerLogMaxFiles,omitempty"`
+
+	// PodMaxPids - The maximum number of processes per pod.
+	// +kubebuilder:validation:Minimum=-1
+	// +optional
+	PodMaxPids *int32 `json:"podMaxPids,omitempty"`
+}
+
+// LinuxOSConfig defines the OS configuration for Linux nodes in an agent pool.
+// See https://docs.microsoft.com/azure/aks/custom-node-configuration for more details.
+type LinuxOSConfig struct {
+	// SwapFileSizeMB - The size in MB of a swap file that will be created on each node.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	SwapFileSizeMB *int32 `json:"swapFileSizeMB,omitempty"`
+
+	// Sysctls specifies the sysctl settings for Linux agent nodes.
+	// +optional
+	Sysctls *SysctlConfig `json:"sysctls,omitempty"`
+
+	// TransparentHugePageDefrag - Whether the kernel should make aggressive use of memory compaction to make more
+	// hugepages available. Allowed values are 'always', 'defer', 'defer+madvise', 'madvise' and 'never'.
+	// +kubebuilder:validation:Enum=always;defer;defer+madvise;madvise;never
+	// +optional
+	TransparentHugePageDefrag *string `json:"transparentHugePageDefrag,omitempty"`
+
+	// TransparentHugePageEnabled - Whether transparent hugepages are enabled.
+	// Allowed values are 'always', 'madvise' and 'never'.
+	// +kubebuilder:validation:Enum=always;madvise;never
+	// +optional
+	TransparentHugePageEnabled *string `json:"transparentHugePageEnabled,omitempty"`
+}
+
+// SysctlConfig specifies the supported subset of sysctl settings for Linux agent nodes.
+type SysctlConfig struct {
+	// FsFileMax - Sysctl setting fs.file-max.
+	// +optional
+	FsFileMax *int32 `json:"fsFileMax,omitempty"`
+
+	// FsInotifyMaxUserWatches - Sysctl setting fs.inotify.max_user_watches.
+	// +optional
+	FsInotifyMaxUserWatches *int32 `json:"fsInotifyMaxUserWatches,omitempty"`
+
+	// KernelThreadsMax - Sysctl setting kernel.threads-max.
+	// +optional
+	KernelThreadsMax *int32 `json:"kernelThreadsMax,omitempty"`
+
+	// NetCoreSomaxconn - Sysctl setting net.core.somaxconn.
+	// +optional
+	NetCoreSomaxconn *int32 `json:"netCoreSomaxconn,omitempty"`
+
+	// NetIpv4IPLocalPortRange - Sysctl setting net.ipv4.ip_local_port_range, e.g. '32768 60999'.
+	// +optional
+	NetIpv4IPLocalPortRange *string `json:"netIpv4IPLocalPortRange,omitempty"`
+
+	// NetIpv4TCPTwReuse - Sysctl setting net.ipv4.tcp_tw_reuse.
+	// +optional
+	NetIpv4TCPTwReuse *bool `json:"netIpv4TCPTwReuse,omitempty"`
+
+	// NetNetfilterNfConntrackMax - Sysctl setting net.netfilter.nf_conntrack_max.
+	// +optional
+	NetNetfilterNfConntrackMax *int32 `json:"netNetfilterNfConntrackMax,omitempty"`
+
+	// VMMaxMapCount - Sysctl setting vm.max_map_count.
+	// +optional
+	VMMaxMapCount *int32 `json:"vmMaxMapCount,omitempty"`
+
+	// VMSwappiness - Sysctl setting vm.swappiness.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	VMSwappiness *int32 `json:"vmSwappiness,omitempty"`
+
+	// VMVfsCachePressure - Sysctl setting vm.vfs_cache_pressure.
+	// +optional
+	VMVfsCachePressure *int32 `json:"vmVfsCachePressure,omitempty"`
 }
 
 // ManagedMachinePoolScaling specifies scaling options.
@@ -132,6 +388,20 @@ type AzureManagedMachinePoolStatus struct {
 	// +optional
 	Replicas int32 `json:"replicas"`
 
+	// NodeImageVersion is the most recently observed node image version of the agent pool, e.g. to track security
+	// patches applied to the nodes.
+	// +optional
+	NodeImageVersion string `json:"nodeImageVersion,omitempty"`
+
+	// ProvisioningState is the most recently observed provisioning state of the agent pool, e.g. Succeeded,
+	// Upgrading or Scaling.
+	// +optional
+	ProvisioningState string `json:"provisioningState,omitempty"`
+
+	// PowerState is the most recently observed power state of the agent pool, either Running or Stopped.
+	// +optional
+	PowerState string `json:"powerState,omitempty"`
+
 	// Any transient errors that occur during the reconciliation of Machines
 	// can be added as events to the Machine object and/or logged in the
 	// controller's output.
@@ -20,10 +20,13 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -33,6 +36,28 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+var (
+	allowedCPUManagerPolicies      = []string{"none", "static"}
+	allowedTopologyManagerPolicies = []string{"none", "best-effort", "restricted", "single-numa-node"}
+	allowedOSSKUs                  = []string{OSSKUUbuntu, OSSKUAzureLinux, OSSKUCBLMariner}
+	allowedWorkloadRuntimes        = []string{WorkloadRuntimeOCIContainer, WorkloadRuntimeWasmWasi}
+
+	// migSupportedSKUs are the A100 GPU VM SKUs which support multi-instance GPU partitioning.
+	migSupportedSKUs = []string{
+		"standard_nc24ads_a100_v4",
+		"standard_nc48ads_a100_v4",
+		"standard_nc96ads_a100_v4",
+		"standard_nd96asr_v4",
+		"standard_nd96amsr_a100_v4",
+	}
+
+	// maxSurgeRegex matches a positive integer or a percentage between 1% and 100%.
+	maxSurgeRegex = regexp.MustCompile(`^([1-9][0-9]*|([1-9][0-9]?|100)%)$`)
+
+	// snapshotIDRegex matches the resource ID of an AKS node pool snapshot.
+	snapshotIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/microsoft\.containerservice/snapshots/[^/]+$`)
+)
+
 //+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-azuremanagedmachinepool,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=azuremanagedmachinepools,verbs=create;update,versions=v1beta1,name=default.azuremanagedmachinepools.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
 
 // Default implements webhook.Defaulter so a webhook will be registered for the type.
@@ -49,6 +74,14 @@ func (m *AzureManagedMachinePool) Default(client client.Client) {
 	if m.Spec.OSType == nil {
 		m.Spec.OSType = to.StringPtr(DefaultOSType)
 	}
+
+	if m.Spec.OSSKU == "" && *m.Spec.OSType == azure.LinuxOS {
+		m.Spec.OSSKU = DefaultOSSKU
+	}
+
+	if m.Spec.ScaleDownMode == "" {
+		m.Spec.ScaleDownMode = DefaultScaleDownMode
+	}
 }
 
 //+kubebuilder:webhook:verbs=update;delete,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-azuremanagedmachinepool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=azuremanagedmachinepools,versions=v1beta1,name=validation.azuremanagedmachinepools.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1;v1beta1
@@ -59,6 +92,16 @@ func (m *AzureManagedMachinePool) ValidateCreate(client client.Client) error {
 		m.validateMaxPods,
 		m.validateOSType,
 		m.validateName,
+		m.validateKubeletConfig,
+		m.validateLinuxOSConfig,
+		m.validateSpot,
+		m.validateNodePublicIP,
+		m.validateMaxSurge,
+		m.validateOSSKU,
+		m.validateGPUInstanceProfile,
+		m.validateSnapshotID,
+		m.validatePowerState,
+		m.validateWorkloadRuntime,
 	}
 
 	var errs []error
@@ -223,6 +266,121 @@ func (m *AzureManagedMachinePool) ValidateUpdate(oldRaw runtime.Object, client c
 					"field is immutable, unsetting is not allowed"))
 		}
 	}
+
+	if !reflect.DeepEqual(m.Spec.KubeletConfig, old.Spec.KubeletConfig) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "KubeletConfig"),
+				m.Spec.KubeletConfig,
+				"field is immutable"))
+	}
+
+	if !reflect.DeepEqual(m.Spec.LinuxOSConfig, old.Spec.LinuxOSConfig) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "LinuxOSConfig"),
+				m.Spec.LinuxOSConfig,
+				"field is immutable"))
+	}
+
+	if to.String(m.Spec.ScaleSetPriority) != to.String(old.Spec.ScaleSetPriority) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "ScaleSetPriority"),
+				m.Spec.ScaleSetPriority,
+				"field is immutable"))
+	}
+
+	if !quantitiesAreEqual(m.Spec.SpotMaxPrice, old.Spec.SpotMaxPrice) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "SpotMaxPrice"),
+				m.Spec.SpotMaxPrice,
+				"field is immutable"))
+	}
+
+	if to.Bool(m.Spec.EnableNodePublicIP) != to.Bool(old.Spec.EnableNodePublicIP) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "EnableNodePublicIP"),
+				m.Spec.EnableNodePublicIP,
+				"field is immutable"))
+	}
+
+	if to.String(m.Spec.NodePublicIPPrefixID) != to.String(old.Spec.NodePublicIPPrefixID) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "NodePublicIPPrefixID"),
+				m.Spec.NodePublicIPPrefixID,
+				"field is immutable"))
+	}
+
+	// Agent pools created before OSSKU was introduced get the default OS SKU on their next update, which is the OS SKU
+	// AKS used for them in the first place.
+	if m.Spec.OSSKU != old.Spec.OSSKU && !(old.Spec.OSSKU == "" && m.Spec.OSSKU == DefaultOSSKU) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "OSSKU"),
+				m.Spec.OSSKU,
+				"field is immutable"))
+	}
+
+	if to.String(m.Spec.PodSubnetName) != to.String(old.Spec.PodSubnetName) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "PodSubnetName"),
+				m.Spec.PodSubnetName,
+				"field is immutable"))
+	}
+
+	if m.Spec.SnapshotID != old.Spec.SnapshotID {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "SnapshotID"),
+				m.Spec.SnapshotID,
+				"field is immutable"))
+	}
+
+	if m.Spec.GPUInstanceProfile != old.Spec.GPUInstanceProfile {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "GPUInstanceProfile"),
+				m.Spec.GPUInstanceProfile,
+				"field is immutable"))
+	}
+
+	if to.Bool(m.Spec.EnableFIPS) != to.Bool(old.Spec.EnableFIPS) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "EnableFIPS"),
+				m.Spec.EnableFIPS,
+				"field is immutable"))
+	}
+
+	if m.Spec.WorkloadRuntime != old.Spec.WorkloadRuntime {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "WorkloadRuntime"),
+				m.Spec.WorkloadRuntime,
+				"field is immutable"))
+	}
+
+	if to.Bool(m.Spec.EnableEncryptionAtHost) != to.Bool(old.Spec.EnableEncryptionAtHost) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "EnableEncryptionAtHost"),
+				m.Spec.EnableEncryptionAtHost,
+				"field is immutable"))
+	}
+
+	if err := validateMaxSurge(m.Spec.MaxSurge, field.NewPath("Spec", "MaxSurge")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+
+	if err := validatePowerState(m.Spec.PowerState, m.Spec.Mode, field.NewPath("Spec", "PowerState")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+
 	if len(allErrs) != 0 {
 		return apierrors.NewInvalid(GroupVersion.WithKind("AzureManagedMachinePool").GroupKind(), m.Name, allErrs)
 	}
@@ -306,6 +464,88 @@ func (m *AzureManagedMachinePool) validateOSType() error {
 	return nil
 }
 
+func (m *AzureManagedMachinePool) validateOSSKU() error {
+	if m.Spec.OSSKU == "" {
+		return nil
+	}
+
+	if m.Spec.OSType != nil && *m.Spec.OSType == azure.WindowsOS {
+		return field.Forbidden(
+			field.NewPath("Spec", "OSSKU"),
+			"OSSKU can only be set for node pools with OSType 'Linux'")
+	}
+
+	if !containsString(allowedOSSKUs, m.Spec.OSSKU) {
+		return field.NotSupported(
+			field.NewPath("Spec", "OSSKU"),
+			m.Spec.OSSKU,
+			allowedOSSKUs)
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validateWorkloadRuntime() error {
+	if m.Spec.WorkloadRuntime == "" {
+		return nil
+	}
+
+	if !containsString(allowedWorkloadRuntimes, m.Spec.WorkloadRuntime) {
+		return field.NotSupported(
+			field.NewPath("Spec", "WorkloadRuntime"),
+			m.Spec.WorkloadRuntime,
+			allowedWorkloadRuntimes)
+	}
+
+	if m.Spec.WorkloadRuntime == WorkloadRuntimeWasmWasi && m.Spec.OSType != nil && *m.Spec.OSType == azure.WindowsOS {
+		return field.Forbidden(
+			field.NewPath("Spec", "WorkloadRuntime"),
+			"WorkloadRuntime 'WasmWasi' can only be set for node pools with OSType 'Linux'")
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validateGPUInstanceProfile() error {
+	if m.Spec.GPUInstanceProfile != "" && !containsString(migSupportedSKUs, strings.ToLower(m.Spec.SKU)) {
+		return field.Forbidden(
+			field.NewPath("Spec", "GPUInstanceProfile"),
+			fmt.Sprintf("GPUInstanceProfile is not supported for SKU %s, it requires a GPU SKU which supports multi-instance GPU", m.Spec.SKU))
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validateSnapshotID() error {
+	if m.Spec.SnapshotID != "" && !snapshotIDRegex.MatchString(m.Spec.SnapshotID) {
+		return field.Invalid(
+			field.NewPath("Spec", "SnapshotID"),
+			m.Spec.SnapshotID,
+			"SnapshotID must be the resource ID of a node pool snapshot, e.g. /subscriptions/<subscription-id>/resourceGroups/<resource-group>/providers/Microsoft.ContainerService/snapshots/<snapshot-name>")
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validatePowerState() error {
+	if err := validatePowerState(m.Spec.PowerState, m.Spec.Mode, field.NewPath("Spec", "PowerState")); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validatePowerState validates that System node pools are not stopped.
+func validatePowerState(powerState, mode string, fldPath *field.Path) *field.Error {
+	if powerState == PowerStateStopped && mode == string(NodePoolModeSystem) {
+		return field.Forbidden(
+			fldPath,
+			"System node pools can not be stopped")
+	}
+
+	return nil
+}
+
 func (m *AzureManagedMachinePool) validateName() error {
 	if m.Spec.OSType != nil && *m.Spec.OSType == azure.WindowsOS {
 		if len(m.Name) > 6 {
@@ -319,6 +559,153 @@ func (m *AzureManagedMachinePool) validateName() error {
 	return nil
 }
 
+func (m *AzureManagedMachinePool) validateKubeletConfig() error {
+	kubeletConfig := m.Spec.KubeletConfig
+	if kubeletConfig == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	if kubeletConfig.CPUManagerPolicy != nil && !containsString(allowedCPUManagerPolicies, *kubeletConfig.CPUManagerPolicy) {
+		allErrs = append(allErrs,
+			field.NotSupported(
+				field.NewPath("Spec", "KubeletConfig", "CPUManagerPolicy"),
+				*kubeletConfig.CPUManagerPolicy,
+				allowedCPUManagerPolicies))
+	}
+
+	if kubeletConfig.TopologyManagerPolicy != nil && !containsString(allowedTopologyManagerPolicies, *kubeletConfig.TopologyManagerPolicy) {
+		allErrs = append(allErrs,
+			field.NotSupported(
+				field.NewPath("Spec", "KubeletConfig", "TopologyManagerPolicy"),
+				*kubeletConfig.TopologyManagerPolicy,
+				allowedTopologyManagerPolicies))
+	}
+
+	if kubeletConfig.ImageGcHighThreshold != nil && kubeletConfig.ImageGcLowThreshold != nil &&
+		*kubeletConfig.ImageGcLowThreshold >= *kubeletConfig.ImageGcHighThreshold {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("Spec", "KubeletConfig", "ImageGcLowThreshold"),
+				*kubeletConfig.ImageGcLowThreshold,
+				"ImageGcLowThreshold must be lower than ImageGcHighThreshold"))
+	}
+
+	for i, sysctl := range kubeletConfig.AllowedUnsafeSysctls {
+		if !isAllowedUnsafeSysctl(sysctl) {
+			allErrs = append(allErrs,
+				field.Invalid(
+					field.NewPath("Spec", "KubeletConfig", "AllowedUnsafeSysctls").Index(i),
+					sysctl,
+					"sysctl must be one of 'kernel.shm*', 'kernel.msg*', 'kernel.sem', 'fs.mqueue.*' or 'net.*'"))
+		}
+	}
+
+	return allErrs.ToAggregate()
+}
+
+func (m *AzureManagedMachinePool) validateLinuxOSConfig() error {
+	if m.Spec.LinuxOSConfig == nil {
+		return nil
+	}
+
+	if m.Spec.OSType != nil && *m.Spec.OSType != azure.LinuxOS {
+		return field.Forbidden(
+			field.NewPath("Spec", "LinuxOSConfig"),
+			"LinuxOSConfig can only be set for node pools with OSType 'Linux'")
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validateSpot() error {
+	isSpot := to.String(m.Spec.ScaleSetPriority) == ScaleSetPrioritySpot
+	if isSpot && m.Spec.Mode == string(NodePoolModeSystem) {
+		return field.Forbidden(
+			field.NewPath("Spec", "ScaleSetPriority"),
+			"System node pools can not use Spot instances")
+	}
+
+	if m.Spec.SpotMaxPrice == nil {
+		return nil
+	}
+
+	if !isSpot {
+		return field.Forbidden(
+			field.NewPath("Spec", "SpotMaxPrice"),
+			"SpotMaxPrice can only be set for node pools with ScaleSetPriority 'Spot'")
+	}
+
+	if m.Spec.SpotMaxPrice.Cmp(resource.MustParse("-1")) != 0 && m.Spec.SpotMaxPrice.Sign() <= 0 {
+		return field.Invalid(
+			field.NewPath("Spec", "SpotMaxPrice"),
+			m.Spec.SpotMaxPrice.String(),
+			"SpotMaxPrice must be -1 or a decimal value greater than zero")
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validateNodePublicIP() error {
+	if m.Spec.NodePublicIPPrefixID != nil && !to.Bool(m.Spec.EnableNodePublicIP) {
+		return field.Forbidden(
+			field.NewPath("Spec", "NodePublicIPPrefixID"),
+			"NodePublicIPPrefixID can only be set when EnableNodePublicIP is true")
+	}
+
+	return nil
+}
+
+func (m *AzureManagedMachinePool) validateMaxSurge() error {
+	if err := validateMaxSurge(m.Spec.MaxSurge, field.NewPath("Spec", "MaxSurge")); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateMaxSurge validates that maxSurge is either empty, a positive integer or a percentage.
+func validateMaxSurge(maxSurge string, fldPath *field.Path) *field.Error {
+	if maxSurge != "" && !maxSurgeRegex.MatchString(maxSurge) {
+		return field.Invalid(
+			fldPath,
+			maxSurge,
+			"MaxSurge must be a positive integer or a percentage between 1% and 100%")
+	}
+
+	return nil
+}
+
+// quantitiesAreEqual returns true if both quantities are nil or represent the same value.
+func quantitiesAreEqual(a, b *resource.Quantity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(*b) == 0
+}
+
+// isAllowedUnsafeSysctl returns true if the sysctl is part of the unsafe sysctl groups AKS allows.
+func isAllowedUnsafeSysctl(sysctl string) bool {
+	if sysctl == "kernel.sem" {
+		return true
+	}
+	for _, prefix := range []string{"kernel.shm", "kernel.msg", "fs.mqueue.", "net."} {
+		if strings.HasPrefix(sysctl, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func ensureStringSlicesAreEqual(a []string, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -19,9 +19,10 @@ package v1beta1
 import (
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-05-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2022-03-01/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -49,6 +50,13 @@ func TestAzureManagedMachinePoolDefaultingWebhook(t *testing.T) {
 	g.Expect(val).To(Equal("System"))
 	g.Expect(*ammp.Spec.Name).To(Equal("fooName"))
 	g.Expect(*ammp.Spec.OSType).To(Equal(azure.LinuxOS))
+	g.Expect(ammp.Spec.OSSKU).To(Equal(OSSKUUbuntu))
+	g.Expect(ammp.Spec.ScaleDownMode).To(Equal(ScaleDownModeDelete))
+
+	t.Logf("Testing ammp defaulting webhook with Deallocate ScaleDownMode specified in Spec")
+	ammp.Spec.ScaleDownMode = ScaleDownModeDeallocate
+	ammp.Default(client)
+	g.Expect(ammp.Spec.ScaleDownMode).To(Equal(ScaleDownModeDeallocate))
 
 	t.Logf("Testing ammp defaulting webhook with empty string name specified in Spec")
 	emptyName := ""
@@ -67,6 +75,19 @@ func TestAzureManagedMachinePoolDefaultingWebhook(t *testing.T) {
 	ammp.Spec.OsDiskType = &normalOsDiskType
 	ammp.Default(client)
 	g.Expect(*ammp.Spec.OsDiskType).To(Equal("Ephemeral"))
+
+	t.Logf("Testing ammp defaulting webhook with Windows OSType specified in Spec")
+	windowsAmmp := &AzureManagedMachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "win",
+		},
+		Spec: AzureManagedMachinePoolSpec{
+			Mode:   "User",
+			OSType: to.StringPtr(azure.WindowsOS),
+		},
+	}
+	windowsAmmp.Default(client)
+	g.Expect(windowsAmmp.Spec.OSSKU).To(BeEmpty())
 }
 
 func TestAzureManagedMachinePoolUpdatingWebhook(t *testing.T) {
@@ -463,6 +484,342 @@ func TestAzureManagedMachinePoolUpdatingWebhook(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Cannot change KubeletConfig of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						CPUManagerPolicy: to.StringPtr("static"),
+					},
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						CPUManagerPolicy: to.StringPtr("none"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change ScaleSetPriority of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change SpotMaxPrice of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("0.5"),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("-1"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Equivalent SpotMaxPrice should not result in an error",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("0.50"),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("0.5"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Cannot change EnableNodePublicIP of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableNodePublicIP: to.BoolPtr(true),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change NodePublicIPPrefixID of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableNodePublicIP:   to.BoolPtr(true),
+					NodePublicIPPrefixID: to.StringPtr("my-new-prefix"),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableNodePublicIP:   to.BoolPtr(true),
+					NodePublicIPPrefixID: to.StringPtr("my-prefix"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change OSSKU of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					OSSKU: OSSKUAzureLinux,
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					OSSKU: OSSKUUbuntu,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change WorkloadRuntime of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					WorkloadRuntime: WorkloadRuntimeWasmWasi,
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					WorkloadRuntime: WorkloadRuntimeOCIContainer,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Defaulting OSSKU of an agentpool created without OSSKU should not result in an error",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					OSSKU: OSSKUUbuntu,
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Cannot change PodSubnetName of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					PodSubnetName: to.StringPtr("pod-subnet-2"),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					PodSubnetName: to.StringPtr("pod-subnet-1"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot add PodSubnetName to an existing agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					PodSubnetName: to.StringPtr("pod-subnet"),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change SnapshotID of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					SnapshotID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/snapshot-2",
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					SnapshotID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/snapshot-1",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot change GPUInstanceProfile of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					SKU:                "Standard_ND96asr_v4",
+					GPUInstanceProfile: "MIG2g",
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					SKU:                "Standard_ND96asr_v4",
+					GPUInstanceProfile: "MIG1g",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot enable FIPS on an existing agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableFIPS: to.BoolPtr(true),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unchanged EnableFIPS should not result in an error",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableFIPS: to.BoolPtr(true),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableFIPS: to.BoolPtr(true),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Cannot enable encryption at host on an existing agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableEncryptionAtHost: to.BoolPtr(true),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unchanged EnableEncryptionAtHost should not result in an error",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableEncryptionAtHost: to.BoolPtr(true),
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					EnableEncryptionAtHost: to.BoolPtr(true),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Can change MaxSurge of the agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					MaxSurge: "50%",
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					MaxSurge: "1",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Cannot change MaxSurge of the agentpool to an invalid value",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					MaxSurge: "many",
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					MaxSurge: "1",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Can stop a User agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "User",
+					PowerState: PowerStateStopped,
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "User",
+					PowerState: PowerStateRunning,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Cannot stop a System agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "System",
+					PowerState: PowerStateStopped,
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "System",
+					PowerState: PowerStateRunning,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Cannot add LinuxOSConfig after creating agentpool",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					LinuxOSConfig: &LinuxOSConfig{
+						TransparentHugePageEnabled: to.StringPtr("never"),
+					},
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unchanged KubeletConfig and LinuxOSConfig should not result in an error",
+			new: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						CPUManagerPolicy: to.StringPtr("static"),
+					},
+					LinuxOSConfig: &LinuxOSConfig{
+						TransparentHugePageEnabled: to.StringPtr("never"),
+					},
+				},
+			},
+			old: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						CPUManagerPolicy: to.StringPtr("static"),
+					},
+					LinuxOSConfig: &LinuxOSConfig{
+						TransparentHugePageEnabled: to.StringPtr("never"),
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 	var client client.Client
 	for _, tc := range tests {
@@ -581,6 +938,356 @@ func TestAzureManagedMachinePool_ValidateCreate(t *testing.T) {
 			wantErr:  true,
 			errorLen: 1,
 		},
+		{
+			name: "valid KubeletConfig and LinuxOSConfig",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					OSType: to.StringPtr(azure.LinuxOS),
+					KubeletConfig: &KubeletConfig{
+						CPUManagerPolicy:      to.StringPtr("static"),
+						TopologyManagerPolicy: to.StringPtr("best-effort"),
+						ImageGcHighThreshold:  to.Int32Ptr(85),
+						ImageGcLowThreshold:   to.Int32Ptr(80),
+						AllowedUnsafeSysctls:  []string{"kernel.shm*", "kernel.sem", "net.core.somaxconn"},
+					},
+					LinuxOSConfig: &LinuxOSConfig{
+						TransparentHugePageEnabled: to.StringPtr("madvise"),
+						Sysctls: &SysctlConfig{
+							VMMaxMapCount: to.Int32Ptr(262144),
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "KubeletConfig with invalid CPUManagerPolicy",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						CPUManagerPolicy: to.StringPtr("dynamic"),
+					},
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "KubeletConfig with ImageGcLowThreshold not lower than ImageGcHighThreshold",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						ImageGcHighThreshold: to.Int32Ptr(80),
+						ImageGcLowThreshold:  to.Int32Ptr(80),
+					},
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "KubeletConfig with unsupported unsafe sysctl",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					KubeletConfig: &KubeletConfig{
+						AllowedUnsafeSysctls: []string{"vm.swappiness"},
+					},
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "LinuxOSConfig is not allowed for Windows node pools",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:   "User",
+					OSType: to.StringPtr(azure.WindowsOS),
+					LinuxOSConfig: &LinuxOSConfig{
+						SwapFileSizeMB: to.Int32Ptr(1500),
+					},
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "Spot node pool with SpotMaxPrice of -1",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:             "User",
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("-1"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Spot node pool with positive SpotMaxPrice",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:             "User",
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("0.5"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Spot node pool with SpotMaxPrice of zero",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:             "User",
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("0"),
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "Spot node pool with negative SpotMaxPrice other than -1",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:             "User",
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+					SpotMaxPrice:     quantityPtr("-2"),
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "SpotMaxPrice without Spot priority",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:         "User",
+					SpotMaxPrice: quantityPtr("-1"),
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "System node pool with Spot priority",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:             "System",
+					ScaleSetPriority: to.StringPtr(ScaleSetPrioritySpot),
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "AzureLinux OSSKU for Linux node pool",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:   "User",
+					OSType: to.StringPtr(azure.LinuxOS),
+					OSSKU:  OSSKUAzureLinux,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Linux OSSKU for Windows node pool",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:   "User",
+					OSType: to.StringPtr(azure.WindowsOS),
+					OSSKU:  OSSKUAzureLinux,
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "WasmWasi workload runtime for Linux node pool",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:            "User",
+					OSType:          to.StringPtr(azure.LinuxOS),
+					WorkloadRuntime: WorkloadRuntimeWasmWasi,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "WasmWasi workload runtime for Windows node pool",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:            "User",
+					OSType:          to.StringPtr(azure.WindowsOS),
+					WorkloadRuntime: WorkloadRuntimeWasmWasi,
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "unsupported workload runtime",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:            "User",
+					WorkloadRuntime: "KataMshvVmIsolation",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "unsupported OSSKU",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:   "User",
+					OSType: to.StringPtr(azure.LinuxOS),
+					OSSKU:  "Fedora",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "GPUInstanceProfile on a multi-instance GPU SKU",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:               "User",
+					SKU:                "Standard_ND96asr_v4",
+					GPUInstanceProfile: "MIG1g",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GPUInstanceProfile on a non-GPU SKU",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:               "User",
+					SKU:                "Standard_D2s_v3",
+					GPUInstanceProfile: "MIG1g",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "valid SnapshotID",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "User",
+					SnapshotID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.ContainerService/snapshots/my-snapshot",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid SnapshotID",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "User",
+					SnapshotID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Compute/snapshots/my-snapshot",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "stopped User node pool",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "User",
+					PowerState: PowerStateStopped,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "stopped System node pool",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:       "System",
+					PowerState: PowerStateStopped,
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "node public IP with prefix",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:                 "User",
+					EnableNodePublicIP:   to.BoolPtr(true),
+					NodePublicIPPrefixID: to.StringPtr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "MaxSurge as an integer",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:     "User",
+					MaxSurge: "3",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "MaxSurge as a percentage",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:     "User",
+					MaxSurge: "33%",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "MaxSurge of zero",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:     "User",
+					MaxSurge: "0",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "MaxSurge percentage above 100%",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:     "User",
+					MaxSurge: "150%",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "MaxSurge which is neither an integer nor a percentage",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:     "User",
+					MaxSurge: "-1",
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
+		{
+			name: "node public IP prefix without node public IP enabled",
+			ammp: &AzureManagedMachinePool{
+				Spec: AzureManagedMachinePoolSpec{
+					Mode:                 "User",
+					NodePublicIPPrefixID: to.StringPtr("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix"),
+				},
+			},
+			wantErr:  true,
+			errorLen: 1,
+		},
 	}
 	var client client.Client
 	for _, tc := range tests {
@@ -595,3 +1302,8 @@ func TestAzureManagedMachinePool_ValidateCreate(t *testing.T) {
 		})
 	}
 }
+
+func quantityPtr(value string) *resource.Quantity {
+	q := resource.MustParse(value)
+	return &q
+}
@@ -108,6 +108,21 @@ func (in *AddonProfile) DeepCopy() *AddonProfile {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdditionalUnattendContent) DeepCopyInto(out *AdditionalUnattendContent) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdditionalUnattendContent.
+func (in *AdditionalUnattendContent) DeepCopy() *AdditionalUnattendContent {
+	if in == nil {
+		return nil
+	}
+	out := new(AdditionalUnattendContent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureMachinePool) DeepCopyInto(out *AzureMachinePool) {
 	*out = *in
@@ -212,7 +227,7 @@ func (in *AzureMachinePoolMachine) DeepCopyInto(out *AzureMachinePoolMachine) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -269,6 +284,11 @@ func (in *AzureMachinePoolMachineList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureMachinePoolMachineSpec) DeepCopyInto(out *AzureMachinePoolMachineSpec) {
 	*out = *in
+	if in.NodeDrainTimeout != nil {
+		in, out := &in.NodeDrainTimeout, &out.NodeDrainTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolMachineSpec.
@@ -364,6 +384,28 @@ func (in *AzureMachinePoolMachineTemplate) DeepCopyInto(out *AzureMachinePoolMac
 		*out = new(apiv1beta1.SpotVMOptions)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WindowsConfiguration != nil {
+		in, out := &in.WindowsConfiguration, &out.WindowsConfiguration
+		*out = new(WindowsConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]VaultSecretGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AdditionalCloudInit != nil {
+		in, out := &in.AdditionalCloudInit, &out.AdditionalCloudInit
+		*out = make([]CloudInitFragment, len(*in))
+		copy(*out, *in)
+	}
+	if in.LinuxConfiguration != nil {
+		in, out := &in.LinuxConfiguration, &out.LinuxConfiguration
+		*out = new(LinuxConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolMachineTemplate.
@@ -403,6 +445,21 @@ func (in *AzureMachinePoolSpec) DeepCopyInto(out *AzureMachinePoolSpec) {
 		*out = new(v1.Duration)
 		**out = **in
 	}
+	if in.PlatformFaultDomainCount != nil {
+		in, out := &in.PlatformFaultDomainCount, &out.PlatformFaultDomainCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BootstrapFailureGracePeriod != nil {
+		in, out := &in.BootstrapFailureGracePeriod, &out.BootstrapFailureGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BootstrapExtension != nil {
+		in, out := &in.BootstrapExtension, &out.BootstrapExtension
+		*out = new(BootstrapExtension)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolSpec.
@@ -439,6 +496,10 @@ func (in *AzureMachinePoolStatus) DeepCopyInto(out *AzureMachinePoolStatus) {
 		*out = new(apiv1beta1.ProvisioningState)
 		**out = **in
 	}
+	if in.BootstrapFailedSince != nil {
+		in, out := &in.BootstrapFailedSince, &out.BootstrapFailedSince
+		*out = (*in).DeepCopy()
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachineStatusError)
@@ -844,6 +905,58 @@ func (in *AzureManagedMachinePoolSpec) DeepCopyInto(out *AzureManagedMachinePool
 		*out = new(string)
 		**out = **in
 	}
+	if in.KubeletConfig != nil {
+		in, out := &in.KubeletConfig, &out.KubeletConfig
+		*out = new(KubeletConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LinuxOSConfig != nil {
+		in, out := &in.LinuxOSConfig, &out.LinuxOSConfig
+		*out = new(LinuxOSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleSetPriority != nil {
+		in, out := &in.ScaleSetPriority, &out.ScaleSetPriority
+		*out = new(string)
+		**out = **in
+	}
+	if in.SpotMaxPrice != nil {
+		in, out := &in.SpotMaxPrice, &out.SpotMaxPrice
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.EnableNodePublicIP != nil {
+		in, out := &in.EnableNodePublicIP, &out.EnableNodePublicIP
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NodePublicIPPrefixID != nil {
+		in, out := &in.NodePublicIPPrefixID, &out.NodePublicIPPrefixID
+		*out = new(string)
+		**out = **in
+	}
+	if in.EnableFIPS != nil {
+		in, out := &in.EnableFIPS, &out.EnableFIPS
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AdditionalTags != nil {
+		in, out := &in.AdditionalTags, &out.AdditionalTags
+		*out = make(apiv1beta1.Tags, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PodSubnetName != nil {
+		in, out := &in.PodSubnetName, &out.PodSubnetName
+		*out = new(string)
+		**out = **in
+	}
+	if in.EnableEncryptionAtHost != nil {
+		in, out := &in.EnableEncryptionAtHost, &out.EnableEncryptionAtHost
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedMachinePoolSpec.
@@ -893,6 +1006,161 @@ func (in *AzureManagedMachinePoolStatus) DeepCopy() *AzureManagedMachinePoolStat
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BootstrapExtension) DeepCopyInto(out *BootstrapExtension) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BootstrapExtension.
+func (in *BootstrapExtension) DeepCopy() *BootstrapExtension {
+	if in == nil {
+		return nil
+	}
+	out := new(BootstrapExtension)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudInitFragment) DeepCopyInto(out *CloudInitFragment) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudInitFragment.
+func (in *CloudInitFragment) DeepCopy() *CloudInitFragment {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudInitFragment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeletConfig) DeepCopyInto(out *KubeletConfig) {
+	*out = *in
+	if in.CPUManagerPolicy != nil {
+		in, out := &in.CPUManagerPolicy, &out.CPUManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.CPUCfsQuota != nil {
+		in, out := &in.CPUCfsQuota, &out.CPUCfsQuota
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CPUCfsQuotaPeriod != nil {
+		in, out := &in.CPUCfsQuotaPeriod, &out.CPUCfsQuotaPeriod
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImageGcHighThreshold != nil {
+		in, out := &in.ImageGcHighThreshold, &out.ImageGcHighThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ImageGcLowThreshold != nil {
+		in, out := &in.ImageGcLowThreshold, &out.ImageGcLowThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TopologyManagerPolicy != nil {
+		in, out := &in.TopologyManagerPolicy, &out.TopologyManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.AllowedUnsafeSysctls != nil {
+		in, out := &in.AllowedUnsafeSysctls, &out.AllowedUnsafeSysctls
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailSwapOn != nil {
+		in, out := &in.FailSwapOn, &out.FailSwapOn
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ContainerLogMaxSizeMB != nil {
+		in, out := &in.ContainerLogMaxSizeMB, &out.ContainerLogMaxSizeMB
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ContainerLogMaxFiles != nil {
+		in, out := &in.ContainerLogMaxFiles, &out.ContainerLogMaxFiles
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PodMaxPids != nil {
+		in, out := &in.PodMaxPids, &out.PodMaxPids
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeletConfig.
+func (in *KubeletConfig) DeepCopy() *KubeletConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeletConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LinuxConfiguration) DeepCopyInto(out *LinuxConfiguration) {
+	*out = *in
+	if in.DisablePasswordAuthentication != nil {
+		in, out := &in.DisablePasswordAuthentication, &out.DisablePasswordAuthentication
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinuxConfiguration.
+func (in *LinuxConfiguration) DeepCopy() *LinuxConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(LinuxConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LinuxOSConfig) DeepCopyInto(out *LinuxOSConfig) {
+	*out = *in
+	if in.SwapFileSizeMB != nil {
+		in, out := &in.SwapFileSizeMB, &out.SwapFileSizeMB
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Sysctls != nil {
+		in, out := &in.Sysctls, &out.Sysctls
+		*out = new(SysctlConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TransparentHugePageDefrag != nil {
+		in, out := &in.TransparentHugePageDefrag, &out.TransparentHugePageDefrag
+		*out = new(string)
+		**out = **in
+	}
+	if in.TransparentHugePageEnabled != nil {
+		in, out := &in.TransparentHugePageEnabled, &out.TransparentHugePageEnabled
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinuxOSConfig.
+func (in *LinuxOSConfig) DeepCopy() *LinuxOSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LinuxOSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoadBalancerProfile) DeepCopyInto(out *LoadBalancerProfile) {
 	*out = *in
@@ -1029,6 +1297,71 @@ func (in *SKU) DeepCopy() *SKU {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SysctlConfig) DeepCopyInto(out *SysctlConfig) {
+	*out = *in
+	if in.FsFileMax != nil {
+		in, out := &in.FsFileMax, &out.FsFileMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FsInotifyMaxUserWatches != nil {
+		in, out := &in.FsInotifyMaxUserWatches, &out.FsInotifyMaxUserWatches
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KernelThreadsMax != nil {
+		in, out := &in.KernelThreadsMax, &out.KernelThreadsMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetCoreSomaxconn != nil {
+		in, out := &in.NetCoreSomaxconn, &out.NetCoreSomaxconn
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4IPLocalPortRange != nil {
+		in, out := &in.NetIpv4IPLocalPortRange, &out.NetIpv4IPLocalPortRange
+		*out = new(string)
+		**out = **in
+	}
+	if in.NetIpv4TCPTwReuse != nil {
+		in, out := &in.NetIpv4TCPTwReuse, &out.NetIpv4TCPTwReuse
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NetNetfilterNfConntrackMax != nil {
+		in, out := &in.NetNetfilterNfConntrackMax, &out.NetNetfilterNfConntrackMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMMaxMapCount != nil {
+		in, out := &in.VMMaxMapCount, &out.VMMaxMapCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMSwappiness != nil {
+		in, out := &in.VMSwappiness, &out.VMSwappiness
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMVfsCachePressure != nil {
+		in, out := &in.VMVfsCachePressure, &out.VMVfsCachePressure
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SysctlConfig.
+func (in *SysctlConfig) DeepCopy() *SysctlConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SysctlConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Taint) DeepCopyInto(out *Taint) {
 	*out = *in
@@ -1062,3 +1395,98 @@ func (in Taints) DeepCopy() Taints {
 	in.DeepCopyInto(out)
 	return *out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultCertificate) DeepCopyInto(out *VaultCertificate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultCertificate.
+func (in *VaultCertificate) DeepCopy() *VaultCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultSecretGroup) DeepCopyInto(out *VaultSecretGroup) {
+	*out = *in
+	if in.VaultCertificates != nil {
+		in, out := &in.VaultCertificates, &out.VaultCertificates
+		*out = make([]VaultCertificate, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSecretGroup.
+func (in *VaultSecretGroup) DeepCopy() *VaultSecretGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultSecretGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowsConfiguration) DeepCopyInto(out *WindowsConfiguration) {
+	*out = *in
+	if in.AdditionalUnattendContent != nil {
+		in, out := &in.AdditionalUnattendContent, &out.AdditionalUnattendContent
+		*out = make([]AdditionalUnattendContent, len(*in))
+		copy(*out, *in)
+	}
+	if in.WinRM != nil {
+		in, out := &in.WinRM, &out.WinRM
+		*out = new(WinRMConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowsConfiguration.
+func (in *WindowsConfiguration) DeepCopy() *WindowsConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowsConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WinRMConfiguration) DeepCopyInto(out *WinRMConfiguration) {
+	*out = *in
+	if in.Listeners != nil {
+		in, out := &in.Listeners, &out.Listeners
+		*out = make([]WinRMListener, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WinRMConfiguration.
+func (in *WinRMConfiguration) DeepCopy() *WinRMConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(WinRMConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WinRMListener) DeepCopyInto(out *WinRMListener) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WinRMListener.
+func (in *WinRMListener) DeepCopy() *WinRMListener {
+	if in == nil {
+		return nil
+	}
+	out := new(WinRMListener)
+	in.DeepCopyInto(out)
+	return out
+}
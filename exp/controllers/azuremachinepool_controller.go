@@ -54,6 +54,7 @@ type (
 		Recorder                      record.EventRecorder
 		ReconcileTimeout              time.Duration
 		WatchFilterValue              string
+		MachineCreationConcurrency    int
 		createAzureMachinePoolService azureMachinePoolServiceCreator
 	}
 
@@ -67,12 +68,13 @@ type (
 type azureMachinePoolServiceCreator func(machinePoolScope *scope.MachinePoolScope) (*azureMachinePoolService, error)
 
 // NewAzureMachinePoolReconciler returns a new AzureMachinePoolReconciler instance.
-func NewAzureMachinePoolReconciler(client client.Client, recorder record.EventRecorder, reconcileTimeout time.Duration, watchFilterValue string) *AzureMachinePoolReconciler {
+func NewAzureMachinePoolReconciler(client client.Client, recorder record.EventRecorder, reconcileTimeout time.Duration, watchFilterValue string, machineCreationConcurrency int) *AzureMachinePoolReconciler {
 	ampr := &AzureMachinePoolReconciler{
-		Client:           client,
-		Recorder:         recorder,
-		ReconcileTimeout: reconcileTimeout,
-		WatchFilterValue: watchFilterValue,
+		Client:                     client,
+		Recorder:                   recorder,
+		ReconcileTimeout:           reconcileTimeout,
+		WatchFilterValue:           watchFilterValue,
+		MachineCreationConcurrency: machineCreationConcurrency,
 	}
 
 	ampr.createAzureMachinePoolService = newAzureMachinePoolService
@@ -228,10 +230,11 @@ func (ampr *AzureMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.
 
 	// Create the machine pool scope
 	machinePoolScope, err := scope.NewMachinePoolScope(scope.MachinePoolScopeParams{
-		Client:           ampr.Client,
-		MachinePool:      machinePool,
-		AzureMachinePool: azMachinePool,
-		ClusterScope:     clusterScope,
+		Client:                     ampr.Client,
+		MachinePool:                machinePool,
+		AzureMachinePool:           azMachinePool,
+		ClusterScope:               clusterScope,
+		MachineCreationConcurrency: ampr.MachineCreationConcurrency,
 	})
 	if err != nil {
 		return reconcile.Result{}, errors.Wrap(err, "failed to create scope")
@@ -42,10 +42,16 @@ func newAzureMachinePoolService(machinePoolScope *scope.MachinePoolScope) (*azur
 		return nil, errors.Wrap(err, "failed to create a NewCache")
 	}
 
+	// Machine pools are orchestrated with a Virtual Machine Scale Set unless an availability set is requested.
+	var machinesSvc azure.ServiceReconciler = scalesets.New(machinePoolScope, cache)
+	if machinePoolScope.OrchestrationMode() == azure.AvailabilitySetOrchestrationMode {
+		machinesSvc = scalesets.NewAvailabilitySetService(machinePoolScope)
+	}
+
 	return &azureMachinePoolService{
 		scope: machinePoolScope,
 		services: []azure.ServiceReconciler{
-			scalesets.New(machinePoolScope, cache),
+			machinesSvc,
 			roleassignments.New(machinePoolScope),
 		},
 		skuCache: cache,
@@ -67,6 +73,12 @@ func (s *azureMachinePoolService) Reconcile(ctx context.Context) error {
 		}
 	}
 
+	// The scale set service only reports whether the VMSS was created or updated, the bootstrap status of the instances is
+	// reported by the bootstrap extension on each of them.
+	if err := s.scope.SetBootstrapConditions(ctx, s.scope.BootstrapExtensionName()); err != nil {
+		return errors.Wrap(err, "failed to reconcile AzureMachinePool bootstrap status")
+	}
+
 	return nil
 }
 
@@ -19,11 +19,17 @@ package controllers
 import (
 	"context"
 	"errors"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/mock_azure"
@@ -33,6 +39,7 @@ import (
 	gomockinternal "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestAzureMachinePoolServiceReconcile(t *testing.T) {
@@ -174,3 +181,172 @@ func TestAzureMachinePoolServiceDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestAzureMachinePoolServiceReconcileBootstrapConditions(t *testing.T) {
+	const extensionName = "CAPZ.Linux.Bootstrapping"
+
+	cases := map[string]struct {
+		extensionStates  []infrav1.ProvisioningState
+		annotations      map[string]string
+		gracePeriod      *metav1.Duration
+		failedSince      *metav1.Time
+		expectedStatus   corev1.ConditionStatus
+		expectedReason   string
+		expectedSeverity clusterv1.ConditionSeverity
+		expectFailed     bool
+		expectedError    func(g *WithT, err error)
+	}{
+		"bootstrap succeeded on all instances": {
+			extensionStates: []infrav1.ProvisioningState{infrav1.Succeeded, infrav1.Succeeded},
+			expectedStatus:  corev1.ConditionTrue,
+			expectedError: func(g *WithT, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+			},
+		},
+		"bootstrap still in progress on an instance": {
+			extensionStates:  []infrav1.ProvisioningState{infrav1.Succeeded, infrav1.Creating},
+			expectedStatus:   corev1.ConditionFalse,
+			expectedReason:   infrav1.BootstrapInProgressReason,
+			expectedSeverity: clusterv1.ConditionSeverityInfo,
+			expectedError: func(g *WithT, err error) {
+				var reconcileError azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileError)).To(BeTrue())
+				g.Expect(reconcileError.IsTransient()).To(BeTrue())
+			},
+		},
+		"bootstrap failed on an instance": {
+			extensionStates:  []infrav1.ProvisioningState{infrav1.Failed, infrav1.Succeeded},
+			expectedStatus:   corev1.ConditionFalse,
+			expectedReason:   infrav1.BootstrapFailedReason,
+			expectedSeverity: clusterv1.ConditionSeverityError,
+			expectFailed:     true,
+			expectedError: func(g *WithT, err error) {
+				var reconcileError azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileError)).To(BeTrue())
+				g.Expect(reconcileError.IsTerminal()).To(BeTrue())
+			},
+		},
+		"bootstrap failed on an instance within the grace period": {
+			extensionStates:  []infrav1.ProvisioningState{infrav1.Failed, infrav1.Succeeded},
+			gracePeriod:      &metav1.Duration{Duration: 10 * time.Minute},
+			expectedStatus:   corev1.ConditionFalse,
+			expectedReason:   infrav1.BootstrapFailedReason,
+			expectedSeverity: clusterv1.ConditionSeverityWarning,
+			expectFailed:     true,
+			expectedError: func(g *WithT, err error) {
+				var reconcileError azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileError)).To(BeTrue())
+				g.Expect(reconcileError.IsTransient()).To(BeTrue())
+			},
+		},
+		"bootstrap failed on an instance after the grace period": {
+			extensionStates:  []infrav1.ProvisioningState{infrav1.Failed, infrav1.Succeeded},
+			gracePeriod:      &metav1.Duration{Duration: 10 * time.Minute},
+			failedSince:      &metav1.Time{Time: time.Now().Add(-11 * time.Minute)},
+			expectedStatus:   corev1.ConditionFalse,
+			expectedReason:   infrav1.BootstrapFailedReason,
+			expectedSeverity: clusterv1.ConditionSeverityError,
+			expectFailed:     true,
+			expectedError: func(g *WithT, err error) {
+				var reconcileError azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileError)).To(BeTrue())
+				g.Expect(reconcileError.IsTerminal()).To(BeTrue())
+			},
+		},
+		"bootstrap extension disabled by annotation": {
+			extensionStates: []infrav1.ProvisioningState{"", ""},
+			annotations:     map[string]string{azure.DisableBootstrapExtensionAnnotation: "true"},
+			expectedStatus:  corev1.ConditionTrue,
+			expectedError: func(g *WithT, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+			},
+		},
+		"bootstrap recovered within the grace period": {
+			extensionStates: []infrav1.ProvisioningState{infrav1.Succeeded, infrav1.Succeeded},
+			gracePeriod:     &metav1.Duration{Duration: 10 * time.Minute},
+			failedSince:     &metav1.Time{Time: time.Now().Add(-5 * time.Minute)},
+			expectedStatus:  corev1.ConditionTrue,
+			expectedError: func(g *WithT, err error) {
+				g.Expect(err).NotTo(HaveOccurred())
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			svcMock := mock_azure.NewMockServiceReconciler(mockCtrl)
+
+			machinePoolScope := &scope.MachinePoolScope{
+				ClusterScoper: &scope.ClusterScope{
+					AzureClients: scope.AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: autorestazure.Environment{
+								Name: autorestazure.PublicCloud.Name,
+							},
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{},
+					Cluster:      &clusterv1.Cluster{},
+				},
+				MachinePool: &expv1.MachinePool{},
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: tc.annotations,
+					},
+					Spec: infrav1exp.AzureMachinePoolSpec{
+						Template: infrav1exp.AzureMachinePoolMachineTemplate{
+							SubnetName: "test-subnet",
+							OSDisk: infrav1.OSDisk{
+								OSType: azure.LinuxOS,
+							},
+						},
+						BootstrapFailureGracePeriod: tc.gracePeriod,
+					},
+					Status: infrav1exp.AzureMachinePoolStatus{
+						BootstrapFailedSince: tc.failedSince,
+					},
+				},
+			}
+
+			instances := make([]azure.VMSSVM, len(tc.extensionStates))
+			for i, state := range tc.extensionStates {
+				instances[i] = azure.VMSSVM{
+					InstanceID:      strconv.Itoa(i),
+					ExtensionStates: map[string]infrav1.ProvisioningState{extensionName: state},
+				}
+			}
+
+			// the scale set service marks the bootstrap as succeeded as soon as the VMSS was created or updated
+			svcMock.EXPECT().Reconcile(gomockinternal.AContext()).DoAndReturn(func(_ context.Context) error {
+				machinePoolScope.SetVMSSState(&azure.VMSS{Instances: instances})
+				machinePoolScope.UpdatePutStatus(infrav1.BootstrapSucceededCondition, "scalesets", nil)
+				return nil
+			})
+
+			s := &azureMachinePoolService{
+				scope:    machinePoolScope,
+				services: []azure.ServiceReconciler{svcMock},
+				skuCache: resourceskus.NewStaticCache([]compute.ResourceSku{}, ""),
+			}
+
+			tc.expectedError(g, s.Reconcile(context.TODO()))
+
+			condition := conditions.Get(machinePoolScope.AzureMachinePool, infrav1.BootstrapSucceededCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(tc.expectedStatus))
+			g.Expect(condition.Reason).To(Equal(tc.expectedReason))
+			g.Expect(condition.Severity).To(Equal(tc.expectedSeverity))
+			if tc.expectFailed {
+				g.Expect(machinePoolScope.AzureMachinePool.Status.BootstrapFailedSince).NotTo(BeNil())
+			} else {
+				g.Expect(machinePoolScope.AzureMachinePool.Status.BootstrapFailedSince).To(BeNil())
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ import (
 	infracontroller "sigs.k8s.io/cluster-api-provider-azure/controllers"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-azure/pkg/coalescing"
+	"sigs.k8s.io/cluster-api-provider-azure/util/cache/ttllru"
 	"sigs.k8s.io/cluster-api-provider-azure/util/reconciler"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -45,6 +46,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// deletionBlockedRequeueAfter is the time after which the deletion of an agent pool which can't be deleted yet, i.e.
+// the only System pool of the cluster, is retried.
+const deletionBlockedRequeueAfter = time.Minute
+
 // AzureManagedMachinePoolReconciler reconciles an AzureManagedMachinePool object.
 type AzureManagedMachinePoolReconciler struct {
 	client.Client
@@ -56,8 +61,11 @@ type AzureManagedMachinePoolReconciler struct {
 
 type azureManagedMachinePoolServiceCreator func(managedMachinePoolScope *scope.ManagedMachinePoolScope) (*azureManagedMachinePoolService, error)
 
-// NewAzureManagedMachinePoolReconciler returns a new AzureManagedMachinePoolReconciler instance.
-func NewAzureManagedMachinePoolReconciler(client client.Client, recorder record.EventRecorder, reconcileTimeout time.Duration, watchFilterValue string) *AzureManagedMachinePoolReconciler {
+// NewAzureManagedMachinePoolReconciler returns a new AzureManagedMachinePoolReconciler instance. If vmssListCache is
+// not nil, it is shared by all managed machine pools to reuse recent VMSS listings of their node resource group. If
+// agentPoolConcurrency is positive, it limits the concurrent agent pool operations per managed cluster. The VMSS of an
+// agent pool is matched by the vmssTagKeys, or by the tag keys AKS sets if vmssTagKeys is empty.
+func NewAzureManagedMachinePoolReconciler(client client.Client, recorder record.EventRecorder, reconcileTimeout time.Duration, watchFilterValue string, vmssListCache ttllru.PeekingCacher, agentPoolConcurrency int, vmssTagKeys []string) *AzureManagedMachinePoolReconciler {
 	ampr := &AzureManagedMachinePoolReconciler{
 		Client:           client,
 		Recorder:         recorder,
@@ -65,7 +73,10 @@ func NewAzureManagedMachinePoolReconciler(client client.Client, recorder record.
 		WatchFilterValue: watchFilterValue,
 	}
 
-	ampr.createAzureManagedMachinePoolService = newAzureManagedMachinePoolService
+	agentPoolOperations := newAgentPoolOperationsLimiter(agentPoolConcurrency)
+	ampr.createAzureManagedMachinePoolService = func(managedMachinePoolScope *scope.ManagedMachinePoolScope) (*azureManagedMachinePoolService, error) {
+		return newAzureManagedMachinePoolService(managedMachinePoolScope, vmssListCache, agentPoolOperations, vmssTagKeys)
+	}
 
 	return ampr
 }
@@ -254,7 +265,13 @@ func (ammpr *AzureManagedMachinePoolReconciler) reconcileNormal(ctx context.Cont
 		return reconcile.Result{}, errors.Wrap(err, "failed to create an AzureManageMachinePoolService")
 	}
 
-	if err := svc.Reconcile(ctx); err != nil {
+	// An invalid agent pool name can't be fixed by retrying, so it is handled as a terminal error.
+	if err = scope.ValidateAgentPoolName(); err != nil {
+		err = azure.WithTerminalError(errors.Wrap(err, "invalid agent pool name"))
+	} else {
+		err = svc.Reconcile(ctx)
+	}
+	if err != nil {
 		// Handle transient and terminal errors
 		log := log.WithValues("name", scope.InfraMachinePool.Name, "namespace", scope.InfraMachinePool.Namespace)
 		var reconcileError azure.ReconcileError
@@ -275,9 +292,10 @@ func (ammpr *AzureManagedMachinePoolReconciler) reconcileNormal(ctx context.Cont
 		return reconcile.Result{}, errors.Wrapf(err, "error creating AzureManagedMachinePool %s/%s", scope.InfraMachinePool.Namespace, scope.InfraMachinePool.Name)
 	}
 
-	// No errors, so mark us ready so the Cluster API Cluster Controller can pull it
-	scope.InfraMachinePool.Status.Ready = true
-	ammpr.Recorder.Eventf(scope.InfraMachinePool, corev1.EventTypeNormal, "AzureManagedMachinePool available", "agent pool successfully reconciled")
+	// No errors, the service marked the agent pool ready unless it has been stopped.
+	if scope.InfraMachinePool.Status.Ready {
+		ammpr.Recorder.Eventf(scope.InfraMachinePool, corev1.EventTypeNormal, "AzureManagedMachinePool available", "agent pool successfully reconciled")
+	}
 	return reconcile.Result{}, nil
 }
 
@@ -298,6 +316,14 @@ func (ammpr *AzureManagedMachinePoolReconciler) reconcileDelete(ctx context.Cont
 		}
 
 		if err := svc.Delete(ctx); err != nil {
+			// The only System pool of the cluster can't be deleted, so keep the finalizer and check again later
+			// whether the pool is still the only one, without backing off like on errors.
+			var reconcileError azure.ReconcileError
+			if errors.As(err, &reconcileError) && reconcileError.IsTerminal() {
+				log.Error(err, "failed to delete AzureManagedMachinePool", "name", scope.InfraMachinePool.Name, "namespace", scope.InfraMachinePool.Namespace)
+				ammpr.Recorder.Eventf(scope.InfraMachinePool, corev1.EventTypeWarning, "AgentPoolDeletionBlocked", "%s", err)
+				return reconcile.Result{RequeueAfter: deletionBlockedRequeueAfter}, nil
+			}
 			return reconcile.Result{}, errors.Wrapf(err, "error deleting AzureManagedMachinePool %s/%s", scope.InfraMachinePool.Namespace, scope.InfraMachinePool.Name)
 		}
 		// Machine pool successfully deleted, remove the finalizer.
@@ -19,15 +19,21 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/scope"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/agentpools"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/scalesets"
 	azureutil "sigs.k8s.io/cluster-api-provider-azure/util/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/cache/ttllru"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
@@ -37,6 +43,19 @@ type (
 		scope         agentpools.ManagedMachinePoolScope
 		agentPoolsSvc azure.Reconciler
 		scaleSetsSvc  NodeLister
+		// vmssTagKeys are the tag keys used to match the VMSS of the agent pool. Defaults to defaultAgentPoolVMSSTagKeys.
+		vmssTagKeys []string
+		// agentPoolOperations limits the concurrent agent pool operations per managed cluster. Unlimited if nil.
+		agentPoolOperations *agentPoolOperationsLimiter
+	}
+
+	// agentPoolOperationsLimiter limits the number of agent pool operations running concurrently on the same managed
+	// cluster, since AKS rejects concurrent mutations of a managed cluster. It is shared by the reconciles of all
+	// managed machine pools.
+	agentPoolOperationsLimiter struct {
+		limit   int
+		mu      sync.Mutex
+		running map[string]int
 	}
 
 	// AgentPoolVMSSNotFoundError represents a reconcile error when the VMSS for an agent pool can't be found.
@@ -50,8 +69,29 @@ type (
 		ListInstances(context.Context, string, string) ([]compute.VirtualMachineScaleSetVM, error)
 		List(context.Context, string) ([]compute.VirtualMachineScaleSet, error)
 	}
+
+	// cachingNodeLister is a NodeLister which shares the VMSS listings of a node resource group between the
+	// reconciles of all managed machine pools using the same cache.
+	cachingNodeLister struct {
+		NodeLister
+		subscriptionID string
+		cache          ttllru.PeekingCacher
+	}
 )
 
+// providerIDWorkers is the number of workers converting VMSS instance IDs to provider IDs concurrently.
+const providerIDWorkers = 16
+
+// listInstancesRequeueAfter is the time after which a managed machine pool is requeued when listing its VMSS instances failed.
+const listInstancesRequeueAfter = 20 * time.Second
+
+// agentPoolOperationsRequeueAfter is the time after which a managed machine pool is requeued when the agent pool
+// operations of its managed cluster are at the concurrency limit.
+const agentPoolOperationsRequeueAfter = 10 * time.Second
+
+// defaultAgentPoolVMSSTagKeys are the tag keys AKS sets on a VMSS to reference the agent pool it belongs to.
+var defaultAgentPoolVMSSTagKeys = []string{"poolName", "aks-managed-poolName"}
+
 // NewAgentPoolVMSSNotFoundError creates a new AgentPoolVMSSNotFoundError.
 func NewAgentPoolVMSSNotFoundError(nodeResourceGroup, poolName string) *AgentPoolVMSSNotFoundError {
 	return &AgentPoolVMSSNotFoundError{
@@ -72,8 +112,76 @@ func (a *AgentPoolVMSSNotFoundError) Is(target error) bool {
 	return ok
 }
 
-// newAzureManagedMachinePoolService populates all the services based on input scope.
-func newAzureManagedMachinePoolService(scope *scope.ManagedMachinePoolScope) (*azureManagedMachinePoolService, error) {
+// newCachingNodeLister creates a NodeLister which caches the VMSS listings of the delegate in the given cache.
+func newCachingNodeLister(delegate NodeLister, subscriptionID string, cache ttllru.PeekingCacher) *cachingNodeLister {
+	return &cachingNodeLister{
+		NodeLister:     delegate,
+		subscriptionID: subscriptionID,
+		cache:          cache,
+	}
+}
+
+// List returns the VMSS in the resource group, reusing a listing from the cache if it has not expired yet.
+func (c *cachingNodeLister) List(ctx context.Context, resourceGroupName string) ([]compute.VirtualMachineScaleSet, error) {
+	key := strings.ToLower(c.subscriptionID + "/" + resourceGroupName)
+	// Peek instead of Get, so that cache hits don't extend the time to live of a listing.
+	if cached, _, ok := c.cache.Peek(key); ok {
+		if vmss, ok := cached.([]compute.VirtualMachineScaleSet); ok {
+			return vmss, nil
+		}
+	}
+
+	vmss, err := c.NodeLister.List(ctx, resourceGroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = c.cache.Add(key, vmss)
+	return vmss, nil
+}
+
+// newAgentPoolOperationsLimiter creates a limiter allowing up to limit concurrent agent pool operations per managed
+// cluster. It returns nil, i.e. no limit, if limit is not positive.
+func newAgentPoolOperationsLimiter(limit int) *agentPoolOperationsLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &agentPoolOperationsLimiter{
+		limit:   limit,
+		running: map[string]int{},
+	}
+}
+
+// tryAcquire reserves an agent pool operation on the managed cluster with the given key, and returns false without
+// reserving one if the limit of concurrent operations on the managed cluster has been reached.
+func (l *agentPoolOperationsLimiter) tryAcquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running[key] >= l.limit {
+		return false
+	}
+	l.running[key]++
+	return true
+}
+
+// release frees an agent pool operation on the managed cluster with the given key reserved by tryAcquire.
+func (l *agentPoolOperationsLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running[key] <= 1 {
+		delete(l.running, key)
+		return
+	}
+	l.running[key]--
+}
+
+// newAzureManagedMachinePoolService populates all the services based on input scope. If vmssListCache is not nil,
+// the VMSS listings of the node resource group are shared with other managed machine pools using the same cache.
+// If agentPoolOperations is not nil, it limits the concurrent agent pool operations on the managed cluster. The VMSS
+// of the agent pool is matched by the vmssTagKeys, or by defaultAgentPoolVMSSTagKeys if vmssTagKeys is empty.
+func newAzureManagedMachinePoolService(scope *scope.ManagedMachinePoolScope, vmssListCache ttllru.PeekingCacher, agentPoolOperations *agentPoolOperationsLimiter, vmssTagKeys []string) (*azureManagedMachinePoolService, error) {
 	var authorizer azure.Authorizer = scope
 	if scope.Location() != "" {
 		regionalAuthorizer, err := azure.WithRegionalBaseURI(scope, scope.Location())
@@ -83,10 +191,22 @@ func newAzureManagedMachinePoolService(scope *scope.ManagedMachinePoolScope) (*a
 		authorizer = regionalAuthorizer
 	}
 
+	skuCache, err := resourceskus.GetCache(scope, scope.Location())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create a NewCache")
+	}
+
+	var scaleSetsSvc NodeLister = scalesets.NewClient(authorizer)
+	if vmssListCache != nil {
+		scaleSetsSvc = newCachingNodeLister(scaleSetsSvc, scope.SubscriptionID(), vmssListCache)
+	}
+
 	return &azureManagedMachinePoolService{
-		scope:         scope,
-		agentPoolsSvc: agentpools.New(scope),
-		scaleSetsSvc:  scalesets.NewClient(authorizer),
+		scope:               scope,
+		agentPoolsSvc:       agentpools.New(scope, skuCache),
+		scaleSetsSvc:        scaleSetsSvc,
+		vmssTagKeys:         vmssTagKeys,
+		agentPoolOperations: agentPoolOperations,
 	}, nil
 }
 
@@ -98,47 +218,49 @@ func (s *azureManagedMachinePoolService) Reconcile(ctx context.Context) error {
 	log.Info("reconciling managed machine pool")
 	agentPoolName := s.scope.AgentPoolSpec().Name
 
-	if err := s.agentPoolsSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileAgentPool(ctx); err != nil {
 		return errors.Wrapf(err, "failed to reconcile machine pool %s", agentPoolName)
 	}
 
+	// A stopped agent pool has no running nodes, so it isn't ready, but there is nothing to retry either until it
+	// has been started again.
+	if s.scope.AgentPoolPowerState() == azure.AgentPoolPowerStateStopped {
+		log.Info("agent pool is stopped, skipping vmss instances", "pool", agentPoolName)
+		s.scope.SetAgentPoolProviderIDList(nil)
+		s.scope.SetAgentPoolReplicas(0)
+		s.scope.SetAgentPoolReady(false)
+		return nil
+	}
+
 	nodeResourceGroup := s.scope.NodeResourceGroup()
 	vmss, err := s.scaleSetsSvc.List(ctx, nodeResourceGroup)
 	if err != nil {
 		return errors.Wrapf(err, "failed to list vmss in resource group %s", nodeResourceGroup)
 	}
 
-	var match *compute.VirtualMachineScaleSet
-	for _, ss := range vmss {
-		ss := ss
-		if ss.Tags["poolName"] != nil && *ss.Tags["poolName"] == agentPoolName {
-			match = &ss
-			break
-		}
-
-		if ss.Tags["aks-managed-poolName"] != nil && *ss.Tags["aks-managed-poolName"] == agentPoolName {
-			match = &ss
-			break
-		}
+	tagKeys := s.vmssTagKeys
+	if len(tagKeys) == 0 {
+		tagKeys = defaultAgentPoolVMSSTagKeys
 	}
 
+	match := findAgentPoolVMSS(vmss, agentPoolName, tagKeys)
 	if match == nil {
 		return azure.WithTransientError(NewAgentPoolVMSSNotFoundError(nodeResourceGroup, agentPoolName), 20*time.Second)
 	}
 
 	instances, err := s.scaleSetsSvc.ListInstances(ctx, nodeResourceGroup, *match.Name)
 	if err != nil {
-		return errors.Wrapf(err, "failed to reconcile machine pool %s", agentPoolName)
+		// The agent pool itself has been reconciled successfully, so report it as ready based on the agent pool spec
+		// and only requeue to populate the provider IDs once the instances can be listed.
+		s.scope.SetAgentPoolReplicas(s.scope.AgentPoolSpec().Replicas)
+		s.scope.SetAgentPoolReady(true)
+		return azure.WithTransientError(errors.Wrapf(err, "failed to list instances of vmss %s for machine pool %s", *match.Name, agentPoolName), listInstancesRequeueAfter)
 	}
 
-	var providerIDs = make([]string, len(instances))
-	for i := 0; i < len(instances); i++ {
-		// Transform the VMSS instance resource representation to conform to the cloud-provider-azure representation
-		providerID, err := azureutil.ConvertResourceGroupNameToLower(azure.ProviderIDPrefix + *instances[i].ID)
-		if err != nil {
-			return errors.Wrapf(err, "failed to parse instance ID %s", *instances[i].ID)
-		}
-		providerIDs[i] = providerID
+	// Skip instances without a usable ID so that a single bad instance doesn't stall the whole pool.
+	providerIDs, err := instancesToProviderIDs(instances, providerIDWorkers)
+	if err != nil {
+		log.Error(err, "skipping vmss instances without a usable ID", "vmss", *match.Name)
 	}
 
 	s.scope.SetAgentPoolProviderIDList(providerIDs)
@@ -149,6 +271,106 @@ func (s *azureManagedMachinePoolService) Reconcile(ctx context.Context) error {
 	return nil
 }
 
+// reconcileAgentPool reconciles the agent pool, unless the agent pool operations on its managed cluster are at the
+// concurrency limit, in which case the reconcile is requeued.
+func (s *azureManagedMachinePoolService) reconcileAgentPool(ctx context.Context) error {
+	if s.agentPoolOperations != nil {
+		spec := s.scope.AgentPoolSpec()
+		key := strings.ToLower(spec.ResourceGroup + "/" + spec.Cluster)
+		if !s.agentPoolOperations.tryAcquire(key) {
+			return azure.WithTransientError(errors.Errorf("waiting for other agent pool operations on managed cluster %s to complete", spec.Cluster),
+				agentPoolOperationsRequeueAfter)
+		}
+		defer s.agentPoolOperations.release(key)
+	}
+
+	return s.agentPoolsSvc.Reconcile(ctx)
+}
+
+// instancesToProviderIDs converts the IDs of the VMSS instances to provider IDs using up to the given number of
+// concurrent workers. The provider IDs are returned in the order of the instances. Instances without a usable ID are
+// skipped and the errors for them are aggregated in the order of the instances.
+func instancesToProviderIDs(instances []compute.VirtualMachineScaleSetVM, workers int) ([]string, error) {
+	if workers > len(instances) {
+		workers = len(instances)
+	}
+
+	providerIDs := make([]string, len(instances))
+	errs := make([]error, len(instances))
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				providerIDs[i], errs[i] = instanceToProviderID(instances[i])
+			}
+		}()
+	}
+	for i := range instances {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	result := make([]string, 0, len(instances))
+	var aggregated []error
+	for i := range instances {
+		if errs[i] != nil {
+			aggregated = append(aggregated, errs[i])
+			continue
+		}
+		result = append(result, providerIDs[i])
+	}
+
+	return result, kerrors.NewAggregate(aggregated)
+}
+
+// instanceToProviderID transforms the VMSS instance resource representation to conform to the cloud-provider-azure
+// representation.
+func instanceToProviderID(instance compute.VirtualMachineScaleSetVM) (string, error) {
+	instanceID := to.String(instance.ID)
+	if instanceID == "" {
+		return "", errors.Errorf("vmss instance %s has no ID", to.String(instance.Name))
+	}
+
+	providerID, err := azureutil.ConvertResourceGroupNameToLower(azure.ProviderIDPrefix + instanceID)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse instance ID %s", instanceID)
+	}
+
+	return providerID, nil
+}
+
+// findAgentPoolVMSS returns the VMSS belonging to the agent pool. A VMSS matches if any of the tag keys references
+// the agent pool, ignoring case. If no VMSS is tagged with the agent pool, the VMSS named with the "aks-<poolName>-"
+// prefix AKS uses is returned.
+func findAgentPoolVMSS(vmss []compute.VirtualMachineScaleSet, agentPoolName string, tagKeys []string) *compute.VirtualMachineScaleSet {
+	for i := range vmss {
+		for key, value := range vmss[i].Tags {
+			if value == nil || !strings.EqualFold(*value, agentPoolName) {
+				continue
+			}
+			for _, tagKey := range tagKeys {
+				if strings.EqualFold(key, tagKey) {
+					return &vmss[i]
+				}
+			}
+		}
+	}
+
+	namePrefix := fmt.Sprintf("aks-%s-", strings.ToLower(agentPoolName))
+	for i := range vmss {
+		if vmss[i].Name != nil && strings.HasPrefix(strings.ToLower(*vmss[i].Name), namePrefix) {
+			return &vmss[i]
+		}
+	}
+
+	return nil
+}
+
 // Delete reconciles all the services in a predetermined order.
 func (s *azureManagedMachinePoolService) Delete(ctx context.Context) error {
 	ctx, _, done := tele.StartSpanWithLogger(ctx, "controllers.azureManagedMachinePoolService.Delete")
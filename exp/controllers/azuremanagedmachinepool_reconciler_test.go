@@ -17,10 +17,21 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-11-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
 	"github.com/onsi/gomega"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/agentpools"
+	mock_controllers "sigs.k8s.io/cluster-api-provider-azure/exp/controllers/mocks"
+	gomockinternal "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
+	"sigs.k8s.io/cluster-api-provider-azure/util/cache/ttllru"
 )
 
 func TestIsAgentPoolVMSSNotFoundError(t *testing.T) {
@@ -60,3 +71,428 @@ func TestIsAgentPoolVMSSNotFoundError(t *testing.T) {
 		})
 	}
 }
+
+func TestFindAgentPoolVMSS(t *testing.T) {
+	cases := []struct {
+		Name     string
+		VMSS     []compute.VirtualMachineScaleSet
+		TagKeys  []string
+		Expected *string
+	}{
+		{
+			Name: "MatchesPoolNameTag",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("vmss-other"), Tags: map[string]*string{"poolName": to.StringPtr("other")}},
+				{Name: to.StringPtr("vmss-pool0"), Tags: map[string]*string{"poolName": to.StringPtr("pool0")}},
+			},
+			TagKeys:  defaultAgentPoolVMSSTagKeys,
+			Expected: to.StringPtr("vmss-pool0"),
+		},
+		{
+			Name: "MatchesAKSManagedPoolNameTag",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("vmss-pool0"), Tags: map[string]*string{"aks-managed-poolName": to.StringPtr("pool0")}},
+			},
+			TagKeys:  defaultAgentPoolVMSSTagKeys,
+			Expected: to.StringPtr("vmss-pool0"),
+		},
+		{
+			Name: "MatchesTagKeyAndValueIgnoringCase",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("vmss-pool0"), Tags: map[string]*string{"AKS-Managed-PoolName": to.StringPtr("Pool0")}},
+			},
+			TagKeys:  defaultAgentPoolVMSSTagKeys,
+			Expected: to.StringPtr("vmss-pool0"),
+		},
+		{
+			Name: "MatchesConfiguredTagKey",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("vmss-other"), Tags: map[string]*string{"poolName": to.StringPtr("pool0")}},
+				{Name: to.StringPtr("vmss-pool0"), Tags: map[string]*string{"customPoolName": to.StringPtr("pool0")}},
+			},
+			TagKeys:  []string{"customPoolName"},
+			Expected: to.StringPtr("vmss-pool0"),
+		},
+		{
+			Name: "FallsBackToNamePrefix",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("aks-pool1-12345678-vmss")},
+				{Name: to.StringPtr("aks-pool0-12345678-vmss")},
+			},
+			TagKeys:  defaultAgentPoolVMSSTagKeys,
+			Expected: to.StringPtr("aks-pool0-12345678-vmss"),
+		},
+		{
+			Name: "PrefersTagOverNamePrefix",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("aks-pool0-12345678-vmss")},
+				{Name: to.StringPtr("vmss-pool0"), Tags: map[string]*string{"poolName": to.StringPtr("pool0")}},
+			},
+			TagKeys:  defaultAgentPoolVMSSTagKeys,
+			Expected: to.StringPtr("vmss-pool0"),
+		},
+		{
+			Name: "NoMatch",
+			VMSS: []compute.VirtualMachineScaleSet{
+				{Name: to.StringPtr("aks-pool01-12345678-vmss"), Tags: map[string]*string{"poolName": to.StringPtr("pool01")}},
+			},
+			TagKeys:  defaultAgentPoolVMSSTagKeys,
+			Expected: nil,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			t.Parallel()
+			g := gomega.NewWithT(t)
+			match := findAgentPoolVMSS(c.VMSS, "pool0", c.TagKeys)
+			if c.Expected == nil {
+				g.Expect(match).To(gomega.BeNil())
+				return
+			}
+			g.Expect(match).NotTo(gomega.BeNil())
+			g.Expect(match.Name).To(gomega.Equal(c.Expected))
+		})
+	}
+}
+
+func TestAzureManagedMachinePoolServiceReconcile(t *testing.T) {
+	// the status of the previous reconcile, which is kept when the reconcile fails
+	previousProviderIDs := []string{
+		"azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0",
+		"azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/1",
+	}
+
+	cases := []struct {
+		Name                string
+		AgentPoolErr        error
+		PowerState          string
+		Instances           []compute.VirtualMachineScaleSetVM
+		ListInstancesErr    error
+		ExpectedErr         string
+		ExpectedTransient   bool
+		ExpectedReady       bool
+		ExpectedReplicas    int32
+		ExpectedProviderIDs []string
+	}{
+		{
+			Name:                "PopulatesProviderIDs",
+			ExpectedReady:       true,
+			ExpectedReplicas:    1,
+			ExpectedProviderIDs: []string{"azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0"},
+		},
+		{
+			Name: "SkipsInstancesWithoutID",
+			Instances: []compute.VirtualMachineScaleSetVM{
+				{ID: to.StringPtr("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0")},
+				{Name: to.StringPtr("aks-pool0-12345678-vmss_1")},
+				{ID: to.StringPtr("")},
+				{ID: to.StringPtr("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/3")},
+			},
+			ExpectedReady:    true,
+			ExpectedReplicas: 2,
+			ExpectedProviderIDs: []string{
+				"azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0",
+				"azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/3",
+			},
+		},
+		{
+			Name:                "RequeuesOnListInstancesErrorReportingAgentPoolReplicas",
+			ListInstancesErr:    errors.New("transient failure"),
+			ExpectedErr:         "failed to list instances of vmss aks-pool0-12345678-vmss for machine pool pool0: transient failure. Object will be requeued after 20s",
+			ExpectedTransient:   true,
+			ExpectedReady:       true,
+			ExpectedReplicas:    3,
+			ExpectedProviderIDs: previousProviderIDs,
+		},
+		{
+			Name:             "StoppedAgentPoolIsNotReady",
+			PowerState:       "Stopped",
+			ExpectedReady:    false,
+			ExpectedReplicas: 0,
+		},
+		{
+			Name:                "RunningAgentPoolIsReady",
+			PowerState:          "Running",
+			ExpectedReady:       true,
+			ExpectedReplicas:    1,
+			ExpectedProviderIDs: []string{"azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0"},
+		},
+		{
+			Name:                "FailsOnAgentPoolErrorKeepingStatus",
+			AgentPoolErr:        errors.New("agent pool failure"),
+			ExpectedErr:         "failed to reconcile machine pool pool0: agent pool failure",
+			ExpectedReady:       false,
+			ExpectedReplicas:    2,
+			ExpectedProviderIDs: previousProviderIDs,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			t.Parallel()
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			agentPoolsMock := mock_controllers.NewMockReconciler(mockCtrl)
+			agentPoolsMock.EXPECT().Reconcile(gomockinternal.AContext()).Return(c.AgentPoolErr)
+
+			instances := c.Instances
+			if instances == nil {
+				instances = []compute.VirtualMachineScaleSetVM{
+					{ID: to.StringPtr("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0")},
+				}
+			}
+
+			scope := &fakeManagedMachinePoolScope{
+				powerState:  c.PowerState,
+				providerIDs: previousProviderIDs,
+				replicas:    2,
+				ready:       false,
+			}
+			s := &azureManagedMachinePoolService{
+				scope:         scope,
+				agentPoolsSvc: agentPoolsMock,
+				scaleSetsSvc: &fakeNodeLister{
+					vmss: []compute.VirtualMachineScaleSet{
+						{Name: to.StringPtr("aks-pool0-12345678-vmss"), Tags: map[string]*string{"poolName": to.StringPtr("pool0")}},
+					},
+					instances:        instances,
+					listInstancesErr: c.ListInstancesErr,
+				},
+				vmssTagKeys: defaultAgentPoolVMSSTagKeys,
+			}
+
+			err := s.Reconcile(context.TODO())
+			if c.ExpectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(c.ExpectedErr))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(gomega.Equal(c.ExpectedTransient))
+				if c.ExpectedTransient {
+					g.Expect(reconcileErr.IsTransient()).To(gomega.BeTrue())
+					g.Expect(reconcileErr.RequeueAfter()).To(gomega.Equal(20 * time.Second))
+				}
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			}
+			g.Expect(scope.ready).To(gomega.Equal(c.ExpectedReady))
+			g.Expect(scope.replicas).To(gomega.Equal(c.ExpectedReplicas))
+			g.Expect(scope.providerIDs).To(gomega.Equal(c.ExpectedProviderIDs))
+		})
+	}
+}
+
+func TestAzureManagedMachinePoolServiceReconcileWithVMSSListCache(t *testing.T) {
+	cases := []struct {
+		Name              string
+		TimeToLive        time.Duration
+		ExpectedListCalls int
+	}{
+		{
+			Name:              "SharesListWithinTimeToLive",
+			TimeToLive:        time.Minute,
+			ExpectedListCalls: 1,
+		},
+		{
+			Name:              "ListsAgainAfterTimeToLive",
+			TimeToLive:        time.Nanosecond,
+			ExpectedListCalls: 2,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			t.Parallel()
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			cache, err := ttllru.New(16, c.TimeToLive)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			nodeLister := &fakeNodeLister{
+				vmss: []compute.VirtualMachineScaleSet{
+					{Name: to.StringPtr("aks-pool0-12345678-vmss"), Tags: map[string]*string{"poolName": to.StringPtr("pool0")}},
+				},
+				instances: []compute.VirtualMachineScaleSetVM{
+					{ID: to.StringPtr("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0")},
+				},
+			}
+
+			// Reconcile two pools, each with its own service but sharing the VMSS list cache.
+			for i := 0; i < 2; i++ {
+				agentPoolsMock := mock_controllers.NewMockReconciler(mockCtrl)
+				agentPoolsMock.EXPECT().Reconcile(gomockinternal.AContext()).Return(nil)
+
+				s := &azureManagedMachinePoolService{
+					scope:         &fakeManagedMachinePoolScope{},
+					agentPoolsSvc: agentPoolsMock,
+					scaleSetsSvc:  newCachingNodeLister(nodeLister, "123", cache),
+					vmssTagKeys:   defaultAgentPoolVMSSTagKeys,
+				}
+				g.Expect(s.Reconcile(context.TODO())).To(gomega.Succeed())
+			}
+
+			g.Expect(nodeLister.listCalls).To(gomega.Equal(c.ExpectedListCalls))
+		})
+	}
+}
+
+func TestAzureManagedMachinePoolServiceReconcileWithAgentPoolConcurrency(t *testing.T) {
+	g := gomega.NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	limiter := newAgentPoolOperationsLimiter(1)
+	newService := func(cluster string, agentPoolsSvc azure.Reconciler) *azureManagedMachinePoolService {
+		return &azureManagedMachinePoolService{
+			scope:         &fakeManagedMachinePoolScope{cluster: cluster},
+			agentPoolsSvc: agentPoolsSvc,
+			scaleSetsSvc: &fakeNodeLister{
+				vmss: []compute.VirtualMachineScaleSet{
+					{Name: to.StringPtr("aks-pool0-12345678-vmss"), Tags: map[string]*string{"poolName": to.StringPtr("pool0")}},
+				},
+				instances: []compute.VirtualMachineScaleSetVM{
+					{ID: to.StringPtr("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/0")},
+				},
+			},
+			vmssTagKeys:         defaultAgentPoolVMSSTagKeys,
+			agentPoolOperations: limiter,
+		}
+	}
+
+	// The first reconcile blocks in its agent pool operation until it is released.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blockingAgentPoolsMock := mock_controllers.NewMockReconciler(mockCtrl)
+	blockingAgentPoolsMock.EXPECT().Reconcile(gomockinternal.AContext()).DoAndReturn(func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	firstErr := make(chan error)
+	go func() {
+		firstErr <- newService("cluster1", blockingAgentPoolsMock).Reconcile(context.TODO())
+	}()
+	<-started
+
+	// A concurrent reconcile of a pool of the same managed cluster is requeued without an agent pool operation.
+	err := newService("cluster1", mock_controllers.NewMockReconciler(mockCtrl)).Reconcile(context.TODO())
+	g.Expect(err).To(gomega.MatchError("failed to reconcile machine pool pool0: waiting for other agent pool operations on managed cluster cluster1 to complete. Object will be requeued after 10s"))
+	var reconcileErr azure.ReconcileError
+	g.Expect(errors.As(err, &reconcileErr)).To(gomega.BeTrue())
+	g.Expect(reconcileErr.IsTransient()).To(gomega.BeTrue())
+
+	// A concurrent reconcile of a pool of another managed cluster is not limited.
+	otherAgentPoolsMock := mock_controllers.NewMockReconciler(mockCtrl)
+	otherAgentPoolsMock.EXPECT().Reconcile(gomockinternal.AContext()).Return(nil)
+	g.Expect(newService("cluster2", otherAgentPoolsMock).Reconcile(context.TODO())).To(gomega.Succeed())
+
+	close(release)
+	g.Expect(<-firstErr).To(gomega.Succeed())
+
+	// Once the first agent pool operation completed, the next reconcile of the same managed cluster proceeds.
+	nextAgentPoolsMock := mock_controllers.NewMockReconciler(mockCtrl)
+	nextAgentPoolsMock.EXPECT().Reconcile(gomockinternal.AContext()).Return(nil)
+	g.Expect(newService("cluster1", nextAgentPoolsMock).Reconcile(context.TODO())).To(gomega.Succeed())
+}
+
+func TestInstancesToProviderIDs(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	const instanceCount = 5000
+	instances := make([]compute.VirtualMachineScaleSetVM, instanceCount)
+	var expectedProviderIDs []string
+	for i := 0; i < instanceCount; i++ {
+		if i%1000 == 500 {
+			instances[i] = compute.VirtualMachineScaleSetVM{Name: to.StringPtr(fmt.Sprintf("aks-pool0-12345678-vmss_%d", i))}
+			continue
+		}
+		instances[i] = compute.VirtualMachineScaleSetVM{
+			ID: to.StringPtr(fmt.Sprintf("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/%d", i)),
+		}
+		expectedProviderIDs = append(expectedProviderIDs,
+			fmt.Sprintf("azure:///subscriptions/123/resourceGroups/node-rg/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/%d", i))
+	}
+
+	providerIDs, err := instancesToProviderIDs(instances, providerIDWorkers)
+	g.Expect(providerIDs).To(gomega.Equal(expectedProviderIDs))
+	g.Expect(err).To(gomega.MatchError("[vmss instance aks-pool0-12345678-vmss_500 has no ID, " +
+		"vmss instance aks-pool0-12345678-vmss_1500 has no ID, " +
+		"vmss instance aks-pool0-12345678-vmss_2500 has no ID, " +
+		"vmss instance aks-pool0-12345678-vmss_3500 has no ID, " +
+		"vmss instance aks-pool0-12345678-vmss_4500 has no ID]"))
+
+	providerIDs, err = instancesToProviderIDs(nil, providerIDWorkers)
+	g.Expect(providerIDs).To(gomega.BeEmpty())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func BenchmarkInstancesToProviderIDs(b *testing.B) {
+	instances := make([]compute.VirtualMachineScaleSetVM, 5000)
+	for i := range instances {
+		instances[i] = compute.VirtualMachineScaleSetVM{
+			ID: to.StringPtr(fmt.Sprintf("/subscriptions/123/resourceGroups/NODE-RG/providers/Microsoft.Compute/virtualMachineScaleSets/aks-pool0-12345678-vmss/virtualMachines/%d", i)),
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := instancesToProviderIDs(instances, providerIDWorkers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type fakeManagedMachinePoolScope struct {
+	agentpools.ManagedMachinePoolScope
+	cluster     string
+	providerIDs []string
+	replicas    int32
+	ready       bool
+	powerState  string
+}
+
+func (f *fakeManagedMachinePoolScope) NodeResourceGroup() string {
+	return "node-rg"
+}
+
+func (f *fakeManagedMachinePoolScope) AgentPoolSpec() azure.AgentPoolSpec {
+	return azure.AgentPoolSpec{Name: "pool0", ResourceGroup: "my-rg", Cluster: f.cluster, Replicas: 3}
+}
+
+func (f *fakeManagedMachinePoolScope) SetAgentPoolProviderIDList(providerIDs []string) {
+	f.providerIDs = providerIDs
+}
+
+func (f *fakeManagedMachinePoolScope) SetAgentPoolReplicas(replicas int32) {
+	f.replicas = replicas
+}
+
+func (f *fakeManagedMachinePoolScope) SetAgentPoolReady(ready bool) {
+	f.ready = ready
+}
+
+func (f *fakeManagedMachinePoolScope) AgentPoolPowerState() string {
+	return f.powerState
+}
+
+type fakeNodeLister struct {
+	vmss             []compute.VirtualMachineScaleSet
+	instances        []compute.VirtualMachineScaleSetVM
+	listInstancesErr error
+	listCalls        int
+}
+
+func (f *fakeNodeLister) ListInstances(_ context.Context, _, _ string) ([]compute.VirtualMachineScaleSetVM, error) {
+	return f.instances, f.listInstancesErr
+}
+
+func (f *fakeNodeLister) List(_ context.Context, _ string) ([]compute.VirtualMachineScaleSet, error) {
+	f.listCalls++
+	return f.vmss, nil
+}
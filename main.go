@@ -47,6 +47,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/feature"
 	"sigs.k8s.io/cluster-api-provider-azure/pkg/coalescing"
 	"sigs.k8s.io/cluster-api-provider-azure/pkg/ot"
+	"sigs.k8s.io/cluster-api-provider-azure/util/cache/ttllru"
 	"sigs.k8s.io/cluster-api-provider-azure/util/reconciler"
 	"sigs.k8s.io/cluster-api-provider-azure/util/webhook"
 	"sigs.k8s.io/cluster-api-provider-azure/version"
@@ -109,12 +110,16 @@ var (
 	azureMachineConcurrency            int
 	azureMachinePoolConcurrency        int
 	azureMachinePoolMachineConcurrency int
+	machinePoolMachineCreationWorkers  int
 	debouncingTimer                    time.Duration
 	syncPeriod                         time.Duration
 	healthAddr                         string
 	webhookPort                        int
 	reconcileTimeout                   time.Duration
 	enableTracing                      bool
+	vmssListCacheTTL                   time.Duration
+	agentPoolConcurrency               int
+	agentPoolVMSSTagKeys               []string
 )
 
 // InitFlags initializes all command-line flags.
@@ -204,6 +209,11 @@ func InitFlags(fs *pflag.FlagSet) {
 		10,
 		"Number of AzureMachinePoolMachines to process simultaneously")
 
+	fs.IntVar(&machinePoolMachineCreationWorkers,
+		"azuremachinepoolmachine-creation-concurrency",
+		10,
+		"Number of AzureMachinePoolMachines to create simultaneously per AzureMachinePool")
+
 	fs.DurationVar(&debouncingTimer,
 		"debouncing-timer",
 		10*time.Second,
@@ -241,6 +251,24 @@ func InitFlags(fs *pflag.FlagSet) {
 		"Enable tracing to the opentelemetry-collector service in the same namespace.",
 	)
 
+	fs.DurationVar(&vmssListCacheTTL,
+		"managed-machine-pool-vmss-list-cache-ttl",
+		0,
+		"The duration for which the VMSS listing of a node resource group is shared between AzureManagedMachinePool reconciles (e.g. 30s). Disabled if 0.",
+	)
+
+	fs.IntVar(&agentPoolConcurrency,
+		"managed-cluster-agentpool-concurrency",
+		0,
+		"Number of agent pool operations to run simultaneously per AKS managed cluster, since AKS rejects concurrent mutations of a managed cluster. Unlimited if 0.",
+	)
+
+	fs.StringSliceVar(&agentPoolVMSSTagKeys,
+		"managed-machine-pool-vmss-tag-keys",
+		nil,
+		"Comma-separated tag keys referencing the agent pool name, by which the VMSS of an AzureManagedMachinePool is found in the node resource group. Defaults to the tag keys set by AKS (poolName,aks-managed-poolName).",
+	)
+
 	feature.MutableGates.AddFlag(fs)
 }
 
@@ -392,6 +420,7 @@ func registerControllers(ctx context.Context, mgr manager.Manager) {
 			mgr.GetEventRecorderFor("azuremachinepool-reconciler"),
 			reconcileTimeout,
 			watchFilterValue,
+			machinePoolMachineCreationWorkers,
 		).SetupWithManager(ctx, mgr, controllers.Options{Options: controller.Options{MaxConcurrentReconciles: azureMachinePoolConcurrency}, Cache: mpCache}); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "AzureMachinePool")
 			os.Exit(1)
@@ -428,11 +457,23 @@ func registerControllers(ctx context.Context, mgr manager.Manager) {
 				setupLog.Error(err, "failed to build mmpmCache ReconcileCache")
 			}
 
+			var vmssListCache ttllru.PeekingCacher
+			if vmssListCacheTTL > 0 {
+				vmssListCache, err = ttllru.New(1024, vmssListCacheTTL)
+				if err != nil {
+					setupLog.Error(err, "failed to build vmssListCache")
+					os.Exit(1)
+				}
+			}
+
 			if err := infrav1controllersexp.NewAzureManagedMachinePoolReconciler(
 				mgr.GetClient(),
 				mgr.GetEventRecorderFor("azuremanagedmachinepoolmachine-reconciler"),
 				reconcileTimeout,
 				watchFilterValue,
+				vmssListCache,
+				agentPoolConcurrency,
+				agentPoolVMSSTagKeys,
 			).SetupWithManager(ctx, mgr, controllers.Options{Options: controller.Options{MaxConcurrentReconciles: azureMachinePoolConcurrency}, Cache: mmpmCache}); err != nil {
 				setupLog.Error(err, "unable to create controller", "controller", "AzureManagedMachinePool")
 				os.Exit(1)